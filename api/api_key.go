@@ -0,0 +1,143 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/apikey"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/gin-gonic/gin"
+)
+
+type createApiKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=64"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is optional, keys without an expiry are valid until they are revoked.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type apiKeyResponse struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	Scopes     []string  `json:"scopes"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	LastUsedIP string    `json:"last_used_ip"`
+	RevokedAt  time.Time `json:"revoked_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type createApiKeyResponse struct {
+	// Key is only returned once, only the prefix of the key can be listed afterwards.
+	Key    string         `json:"key"`
+	ApiKey apiKeyResponse `json:"api_key"`
+}
+
+type revokeApiKeyRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+func newApiKeyResponse(key db.UserSvcApiKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIp,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+// createApiKey creates an API key of the authenticated user. Keys can't be created with API keys or the tokens
+// of OAuth clients, so that a leaked key can't be used to create more keys.
+func (server *Server) createApiKey(ctx *gin.Context) {
+	var req createApiKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := authorizationPayload(ctx)
+	if authPayload.ClientID != "" {
+		err := errors.New("API keys can only be created with the access token of a login")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	key, rawKey, err := server.apiKeys.Create(ctx, apikey.CreateParams{
+		Username:  authPayload.Username,
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		handleApiKeyError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, createApiKeyResponse{Key: rawKey, ApiKey: newApiKeyResponse(key)})
+}
+
+// listApiKeys lists the API keys of the authenticated user, including revoked and expired keys.
+func (server *Server) listApiKeys(ctx *gin.Context) {
+	authPayload := authorizationPayload(ctx)
+
+	keys, err := server.apiKeys.List(ctx, authPayload.UserID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]apiKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		rsp = append(rsp, newApiKeyResponse(key))
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// revokeApiKey revokes an API key of the authenticated user. A key can revoke itself, e.g. when a bot
+// is shut down, but not the other keys of the user, and OAuth clients can't revoke the keys of the user.
+func (server *Server) revokeApiKey(ctx *gin.Context) {
+	var req revokeApiKeyRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := authorizationPayload(ctx)
+	if authPayload.ClientID != "" && !apikey.IsAPIKey(authPayload) {
+		err := errors.New("OAuth clients can't revoke API keys")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	if err := server.apiKeys.Revoke(ctx, authPayload, req.ID); err != nil {
+		handleApiKeyError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "API key revoked"})
+}
+
+// handleApiKeyError maps the errors of the API key management to HTTP responses.
+func handleApiKeyError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, apikey.ErrKeyNotFound):
+		ctx.JSON(http.StatusNotFound, errorResponse(err))
+	case errors.Is(err, apikey.ErrOtherKey):
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+	case errors.Is(err, apikey.ErrInvalidName),
+		errors.Is(err, apikey.ErrInvalidScope),
+		errors.Is(err, apikey.ErrInvalidExpiry):
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+	case errors.Is(err, apikey.ErrTooManyKeys):
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+	default:
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	}
+}
@@ -0,0 +1,214 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// testApiKey returns a stored key of the user with the given scopes and the raw key that matches it.
+func testApiKey(user db.UserSvcUser, scopes ...string) (db.UserSvcApiKey, string) {
+	prefix := util.RandomString(8)
+	rawKey := fmt.Sprintf("sf_%s_%s", prefix, util.RandomString(43))
+	return db.UserSvcApiKey{
+		ID:         util.RandomInt(1, 1000),
+		UserID:     user.ID,
+		Name:       "bot",
+		Prefix:     prefix,
+		KeyHash:    util.HashSecretCode(rawKey),
+		Scopes:     scopes,
+		LastUsedAt: time.Now(),
+		LastUsedIp: "192.0.2.1",
+		CreatedAt:  time.Now(),
+	}, rawKey
+}
+
+// expectApiKey lets the auth middleware authenticate the key of the user.
+func expectApiKey(store *mock_db.MockStore, user db.UserSvcUser, key db.UserSvcApiKey) {
+	store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Eq(key.Prefix)).Times(1).Return(key, nil)
+	store.EXPECT().GetUserById(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(user, nil)
+}
+
+func newApiKeyRequest(t *testing.T, method, url string, body any, rawKey string) *http.Request {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	request, err := http.NewRequest(method, url, bytes.NewReader(data))
+	require.NoError(t, err)
+	request.RemoteAddr = "192.0.2.1:1234"
+	if rawKey != "" {
+		request.Header.Set(authorizationHeaderKey, "ApiKey "+rawKey)
+	}
+	return request
+}
+
+func TestCreateApiKeyAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	user, _ := randomUser(t)
+	user.ID = util.RandomInt(1, 1000)
+	user.RoleID = util.ConvertToInt8(policy.RoleModerator)
+
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().
+		ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleModerator)).
+		Times(1).
+		Return([]db.UserSvcPermission{{Name: policy.PermissionReadUsers}, {Name: policy.PermissionListUsers}}, nil)
+	store.EXPECT().ListApiKeysByUserId(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return([]db.UserSvcApiKey{}, nil)
+	store.EXPECT().
+		CreateApiKey(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateApiKeyParams) (db.UserSvcApiKey, error) {
+			require.Equal(t, user.ID, arg.UserID)
+			require.Equal(t, []string{policy.PermissionReadUsers}, arg.Scopes)
+			return db.UserSvcApiKey{ID: 1, UserID: arg.UserID, Name: arg.Name, Prefix: arg.Prefix, KeyHash: arg.KeyHash, Scopes: arg.Scopes}, nil
+		})
+
+	recorder := httptest.NewRecorder()
+	request := newApiKeyRequest(t, http.MethodPost, "/users/api_keys", gin.H{
+		"name":   "bot",
+		"scopes": []string{policy.PermissionReadUsers},
+	}, "")
+	addUserAuthorization(t, request, server.tokenMaker, user, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp createApiKeyResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Equal(t, "sf_"+rsp.ApiKey.Prefix, rsp.Key[:11])
+	require.NotContains(t, recorder.Body.String(), "key_hash")
+
+	// Keys can't create more keys
+	key, rawKey := testApiKey(user, policy.PermissionReadUsers)
+	expectApiKey(store, user, key)
+
+	recorder = httptest.NewRecorder()
+	request = newApiKeyRequest(t, http.MethodPost, "/users/api_keys", gin.H{"name": "bot"}, rawKey)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestApiKeyAuthorizationAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	user, _ := randomUser(t)
+	user.ID = util.RandomInt(1, 1000)
+	user.RoleID = util.ConvertToInt8(policy.RoleAdmin)
+	key, rawKey := testApiKey(user, policy.PermissionReadUsers)
+
+	// The key lists the keys of its user
+	expectApiKey(store, user, key)
+	store.EXPECT().ListApiKeysByUserId(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return([]db.UserSvcApiKey{key}, nil)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, newApiKeyRequest(t, http.MethodGet, "/users/api_keys", nil, rawKey))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), key.Prefix)
+
+	// The role of the user allows to list users, but the key was only granted to read them
+	expectApiKey(store, user, key)
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().
+		ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleAdmin)).
+		Times(1).
+		Return([]db.UserSvcPermission{{Name: policy.PermissionReadUsers}, {Name: policy.PermissionListUsers}}, nil)
+	store.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Times(0)
+
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, newApiKeyRequest(t, http.MethodGet, "/users/list?page_id=1&page_size=5", nil, rawKey))
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+
+	// Keys are revoked instead of logged out
+	expectApiKey(store, user, key)
+	store.EXPECT().RevokeToken(gomock.Any(), gomock.Any()).Times(0)
+
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, newApiKeyRequest(t, http.MethodPost, "/users/logout", nil, rawKey))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	// Keys can't change the security settings of the account of their user
+	expectApiKey(store, user, key)
+	store.EXPECT().CreateTotpSecret(gomock.Any(), gomock.Any()).Times(0)
+
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, newApiKeyRequest(t, http.MethodPost, "/users/mfa/totp", nil, rawKey))
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+
+	expectApiKey(store, user, key)
+
+	recorder = httptest.NewRecorder()
+	body := gin.H{"old_password": "Secret123!", "new_password": "Secret456!"}
+	server.router.ServeHTTP(recorder, newApiKeyRequest(t, http.MethodPut, "/users/change_password", body, rawKey))
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+
+	// Nor revoke the other keys of the user
+	expectApiKey(store, user, key)
+	store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Eq(key.Prefix)).Times(1).Return(key, nil)
+	store.EXPECT().RevokeApiKey(gomock.Any(), gomock.Any()).Times(0)
+
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, newApiKeyRequest(t, http.MethodDelete, fmt.Sprintf("/users/api_keys/%d", key.ID+1), nil, rawKey))
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+
+	expectApiKey(store, user, key)
+	store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Eq(key.Prefix)).Times(1).Return(key, nil)
+	store.EXPECT().
+		RevokeApiKey(gomock.Any(), gomock.Eq(db.RevokeApiKeyParams{ID: key.ID, UserID: user.ID})).
+		Times(1).
+		Return(int64(1), nil)
+
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, newApiKeyRequest(t, http.MethodDelete, fmt.Sprintf("/users/api_keys/%d", key.ID), nil, rawKey))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// Revoked keys are rejected
+	key.RevokedAt = time.Now()
+	store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Eq(key.Prefix)).Times(1).Return(key, nil)
+
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, newApiKeyRequest(t, http.MethodGet, "/users/api_keys", nil, rawKey))
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestRevokeApiKeyNotFoundAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	user, _ := randomUser(t)
+	user.ID = util.RandomInt(1, 1000)
+
+	// Keys of other users aren't revoked
+	store.EXPECT().
+		RevokeApiKey(gomock.Any(), gomock.Eq(db.RevokeApiKeyParams{ID: 42, UserID: user.ID})).
+		Times(1).
+		Return(int64(0), nil)
+
+	recorder := httptest.NewRecorder()
+	request := newApiKeyRequest(t, http.MethodDelete, "/users/api_keys/42", nil, "")
+	addUserAuthorization(t, request, server.tokenMaker, user, time.Minute)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/Streamfair/streamfair_user_svc/validator"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+type changePasswordRequest struct {
+	OldPassword         string `json:"old_password" binding:"required"`
+	NewPassword         string `json:"new_password" binding:"required"`
+	RevokeOtherSessions bool   `json:"revoke_other_sessions"`
+	// SessionID is the session the password is changed from, it stays signed in when the other sessions are revoked.
+	SessionID string `json:"session_id" binding:"omitempty,uuid"`
+}
+
+type changePasswordResponse struct {
+	User            userResponse `json:"user"`
+	RevokedSessions int64        `json:"revoked_sessions"`
+	// Session holds the new tokens of the current session when the other sessions are revoked, as the revocation
+	// includes the tokens the request was made with.
+	Session *renewAccessTokenResponse `json:"session,omitempty"`
+}
+
+// changePassword sets a new password for the authenticated user after checking the old one
+// and optionally signs the user out of all other devices.
+func (server *Server) changePassword(ctx *gin.Context) {
+	var req changePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if err := validator.ValidatePassword(req.NewPassword); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "new_password: " + err.Error()})
+		return
+	}
+	if req.NewPassword == req.OldPassword {
+		err := errors.New("new password must differ from the old password")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := authorizationPayload(ctx)
+	if authPayload.ClientID != "" {
+		ctx.JSON(http.StatusForbidden, errorResponse(errDelegatedAccountChange))
+		return
+	}
+
+	user, err := server.store.GetUserByValue(ctx, authPayload.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			err := errors.New("user of the access token does not exist")
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := util.ComparePassword(user.PasswordHash, user.PasswordSalt, req.OldPassword); err != nil {
+		err := errors.New("old password is incorrect")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	var currentSession db.UserSvcSession
+	if req.RevokeOtherSessions && req.SessionID != "" {
+		session, err := server.store.GetSession(ctx, uuid.MustParse(req.SessionID))
+		if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if err != nil || session.Username != user.Username || session.IsBlocked || session.ReplacedBy.Valid {
+			err := errors.New("session not found")
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		currentSession = session
+	}
+
+	hashedPassword, err := util.HashPassword(req.NewPassword, server.config.Argon2idParams())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	result, err := server.store.ChangePasswordTx(ctx, db.ChangePasswordTxParams{
+		Username:            user.Username,
+		PasswordHash:        hashedPassword,
+		RevokeOtherSessions: req.RevokeOtherSessions,
+		KeepFamilyID:        currentSession.FamilyID,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := changePasswordResponse{
+		User:            newUserResponse(result.User),
+		RevokedSessions: result.RevokedSessions,
+	}
+	if req.RevokeOtherSessions {
+		// Access tokens issued before the change are revoked along with the sessions. The password was changed
+		// already, so a failure is logged and the access tokens expire on their own.
+		if err := server.revocations.RevokeUserTokens(ctx, result.User.Username); err != nil {
+			log.Error().Err(err).Str("username", result.User.Username).Msg("failed to revoke access tokens after password change")
+		}
+
+		rsp.Session, err = server.reissueSessionTokens(ctx, result.User, currentSession)
+		if err != nil {
+			if errors.Is(err, db.ErrSessionAlreadyRotated) {
+				ctx.JSON(http.StatusConflict, errorResponse(err))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// reissueSessionTokens issues new tokens to the client that revoked the tokens of the user. The given session is
+// rotated to the new refresh token, without a session the client is signed in with a new session.
+func (server *Server) reissueSessionTokens(ctx *gin.Context, user db.UserSvcUser, current db.UserSvcSession) (*renewAccessTokenResponse, error) {
+	claims, err := server.tokenClaims(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, accessPayload, err := server.createAccessToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateRefreshToken(
+		claims,
+		server.config.RefreshTokenDuration,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	newSession := db.CreateSessionParams{
+		ID:           refreshPayload.ID,
+		FamilyID:     refreshPayload.ID,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    ctx.Request.UserAgent(),
+		ClientIp:     ctx.ClientIP(),
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	}
+	var session db.UserSvcSession
+	if current.ID == uuid.Nil {
+		session, err = server.store.CreateSession(ctx, newSession)
+	} else {
+		newSession.FamilyID = current.FamilyID
+		var result db.RotateSessionTxResult
+		result, err = server.store.RotateSessionTx(ctx, db.RotateSessionTxParams{
+			OldSessionID: current.ID,
+			NewSession:   newSession,
+		})
+		session = result.Session
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &renewAccessTokenResponse{
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+	}, nil
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestChangePasswordAPI(t *testing.T) {
+	user, password := randomUser(t)
+	newPassword := util.RandomString(12)
+
+	session := db.UserSvcSession{ID: uuid.New(), FamilyID: uuid.New(), Username: user.Username}
+	otherSession := db.UserSvcSession{ID: uuid.New(), FamilyID: uuid.New(), Username: util.RandomString(8)}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mock_db.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"old_password": password,
+				"new_password": newPassword,
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ChangePasswordTxParams) (db.ChangePasswordTxResult, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.False(t, arg.RevokeOtherSessions)
+
+						require.NoError(t, util.ComparePassword(arg.PasswordHash, arg.PasswordSalt, newPassword))
+
+						return db.ChangePasswordTxResult{User: user}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "RevokeOtherSessions",
+			body: gin.H{
+				"old_password":          password,
+				"new_password":          newPassword,
+				"revoke_other_sessions": true,
+				"session_id":            session.ID.String(),
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ChangePasswordTxParams) (db.ChangePasswordTxResult, error) {
+						require.True(t, arg.RevokeOtherSessions)
+						require.Equal(t, session.FamilyID, arg.KeepFamilyID)
+						return db.ChangePasswordTxResult{User: user, RevokedSessions: 2}, nil
+					})
+				// The access tokens issued so far are revoked and the current session is rotated to new tokens
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(user.RoleID.Int64)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					SetTokenWatermark(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.SetTokenWatermarkParams) (db.UserSvcTokenWatermark, error) {
+						require.Equal(t, user.Username, arg.Username)
+						return db.UserSvcTokenWatermark{Username: arg.Username, NotBefore: arg.NotBefore}, nil
+					})
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RotateSessionTxParams) (db.RotateSessionTxResult, error) {
+						require.Equal(t, session.ID, arg.OldSessionID)
+						require.Equal(t, session.FamilyID, arg.NewSession.FamilyID)
+						return db.RotateSessionTxResult{Session: db.UserSvcSession{ID: arg.NewSession.ID}}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp changePasswordResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, int64(2), rsp.RevokedSessions)
+				require.NotNil(t, rsp.Session)
+				require.NotEmpty(t, rsp.Session.AccessToken)
+				require.NotEmpty(t, rsp.Session.RefreshToken)
+			},
+		},
+		{
+			name: "RevokeAllSessions",
+			body: gin.H{
+				"old_password":          password,
+				"new_password":          newPassword,
+				"revoke_other_sessions": true,
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ChangePasswordTxResult{User: user, RevokedSessions: 3}, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(user.RoleID.Int64)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					SetTokenWatermark(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserSvcTokenWatermark{Username: user.Username, NotBefore: time.Now()}, nil)
+				// Without a session the client is signed in with a new session
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateSessionParams) (db.UserSvcSession, error) {
+						require.Equal(t, arg.ID, arg.FamilyID)
+						return db.UserSvcSession{ID: arg.ID}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp changePasswordResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.NotNil(t, rsp.Session)
+				require.NotEqual(t, uuid.Nil, rsp.Session.SessionID)
+			},
+		},
+		{
+			name: "SessionOfOtherUser",
+			body: gin.H{
+				"old_password":          password,
+				"new_password":          newPassword,
+				"revoke_other_sessions": true,
+				"session_id":            otherSession.ID.String(),
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(otherSession.ID)).
+					Times(1).
+					Return(otherSession, nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "WrongOldPassword",
+			body: gin.H{
+				"old_password": util.RandomString(10),
+				"new_password": newPassword,
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "WeakNewPassword",
+			body: gin.H{
+				"old_password": password,
+				"new_password": "short",
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := "/users/change_password"
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, 3, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
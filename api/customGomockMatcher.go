@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/base64"
 	"fmt"
 	"reflect"
 
@@ -21,10 +20,7 @@ func (e eqCreateUserParamsMatcher) Matches(x any) bool {
 		return false
 	}
 
-	hash, _ := base64.StdEncoding.DecodeString(arg.PasswordHash)
-	salt, _ := base64.StdEncoding.DecodeString(arg.PasswordSalt)
-
-	err := util.ComparePassword(hash, salt, e.password)
+	err := util.ComparePassword(arg.PasswordHash, arg.PasswordSalt, e.password)
 	if err != nil {
 		return false
 	}
@@ -41,3 +37,37 @@ func (e eqCreateUserParamsMatcher) String() string {
 func EqCreateUserParams(arg db.CreateUserParams, password string) gomock.Matcher {
 	return eqCreateUserParamsMatcher{arg, password}
 }
+
+type eqCreateUserTxParamsMatcher struct {
+	arg      db.CreateUserParams
+	password string
+	user     db.UserSvcUser
+}
+
+func (e eqCreateUserTxParamsMatcher) Matches(x any) bool {
+	arg, ok := x.(db.CreateUserTxParams)
+	if !ok {
+		return false
+	}
+
+	if !EqCreateUserParams(e.arg, e.password).Matches(arg.CreateUserParams) {
+		return false
+	}
+
+	// The secret code is only stored as hash
+	if len(arg.SecretCode) != 64 || arg.AfterCreate == nil {
+		return false
+	}
+
+	// Sending the verification email must succeed for the transaction to commit
+	err := arg.AfterCreate(e.user, db.UserSvcVerifyEmail{ID: 1, Username: e.user.Username, Email: e.user.Email})
+	return err == nil
+}
+
+func (e eqCreateUserTxParamsMatcher) String() string {
+	return fmt.Sprintf("matches arg: %v and password: (%v)", e.arg, e.password)
+}
+
+func EqCreateUserTxParams(arg db.CreateUserParams, password string, user db.UserSvcUser) gomock.Matcher {
+	return eqCreateUserTxParamsMatcher{arg, password, user}
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/social"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/gin-gonic/gin"
+)
+
+type externalProviderRequest struct {
+	Provider string `uri:"provider" binding:"required"`
+}
+
+type externalLoginCallbackRequest struct {
+	State string `form:"state"`
+	Code  string `form:"code"`
+	// Error is set instead of the code if the login at the provider failed or the user declined it.
+	Error string `form:"error"`
+}
+
+type externalIdentityResponse struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type linkExternalIdentityResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+func newExternalIdentityResponse(identity db.UserSvcExternalIdentity) externalIdentityResponse {
+	return externalIdentityResponse{
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: identity.CreatedAt,
+	}
+}
+
+// startExternalLogin redirects the user to the login of the identity provider.
+func (server *Server) startExternalLogin(ctx *gin.Context) {
+	var req externalProviderRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	location, err := server.social.StartLogin(ctx, req.Provider)
+	if err != nil {
+		handleSocialError(ctx, err)
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, location)
+}
+
+// completeExternalLogin is the callback the identity provider redirects the user back to. Logins create the session
+// like a login with the password, links of the authenticated user only return the linked identity.
+func (server *Server) completeExternalLogin(ctx *gin.Context) {
+	var uri externalProviderRequest
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req externalLoginCallbackRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.Error != "" {
+		err := fmt.Errorf("%w: %s", social.ErrProviderRejected, req.Error)
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	result, err := server.social.Complete(ctx, uri.Provider, req.State, req.Code)
+	if err != nil {
+		handleSocialError(ctx, err)
+		return
+	}
+	if result.Linking && result.User.ID == 0 {
+		ctx.JSON(http.StatusOK, newExternalIdentityResponse(result.Identity))
+		return
+	}
+
+	user := result.User
+	if user.Status.String != util.StatusActive {
+		err := errors.New("user account is not active, the email address has to be verified first")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	// The provider only replaces the password, users with two-factor authentication still need their code
+	mfaEnabled, err := server.mfa.IsEnabled(ctx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if mfaEnabled {
+		server.requireMfa(ctx, user.Username)
+		return
+	}
+
+	rsp, err := server.createLoginSession(ctx, user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// listExternalIdentities returns the accounts of identity providers linked to the authenticated user.
+func (server *Server) listExternalIdentities(ctx *gin.Context) {
+	authPayload := authorizationPayload(ctx)
+
+	identities, err := server.social.ListIdentities(ctx, authPayload.UserID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]externalIdentityResponse, 0, len(identities))
+	for _, identity := range identities {
+		rsp = append(rsp, newExternalIdentityResponse(identity))
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// linkExternalIdentity starts a login at the identity provider that links the account to the authenticated user.
+// The client redirects the user to the returned URL, the account is linked once the provider called back.
+func (server *Server) linkExternalIdentity(ctx *gin.Context) {
+	var req externalProviderRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := authorizationPayload(ctx)
+	if authPayload.ClientID != "" {
+		err := errors.New("OAuth clients can't link accounts of identity providers")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	location, err := server.social.StartLink(ctx, req.Provider, authPayload.UserID)
+	if err != nil {
+		handleSocialError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, linkExternalIdentityResponse{AuthorizationURL: location})
+}
+
+// unlinkExternalIdentity removes the account of the identity provider from the authenticated user.
+func (server *Server) unlinkExternalIdentity(ctx *gin.Context) {
+	var req externalProviderRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := authorizationPayload(ctx)
+	if authPayload.ClientID != "" {
+		err := errors.New("OAuth clients can't unlink accounts of identity providers")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	if err := server.social.Unlink(ctx, authPayload.UserID, req.Provider); err != nil {
+		handleSocialError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "external identity unlinked"})
+}
+
+// handleSocialError maps the errors of the external login to HTTP responses.
+func handleSocialError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, social.ErrUnknownProvider), errors.Is(err, social.ErrNotLinked):
+		ctx.JSON(http.StatusNotFound, errorResponse(err))
+	case errors.Is(err, social.ErrInvalidState),
+		errors.Is(err, social.ErrProviderRejected),
+		errors.Is(err, social.ErrInvalidIDToken):
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+	case errors.Is(err, social.ErrEmailNotVerified), errors.Is(err, social.ErrAccountNotVerified):
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+	case errors.Is(err, social.ErrIdentityLinked), errors.Is(err, social.ErrProviderLinked):
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+	default:
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	}
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/social"
+	"github.com/Streamfair/streamfair_user_svc/social/socialtest"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const testExternalCallbackURL = "https://localhost:8080/users/login/external/test/callback"
+
+func newTestExternalLoginServer(t *testing.T, store *mock_db.MockStore) (*Server, *socialtest.Server) {
+	server := newTestServer(t, store)
+	provider := socialtest.NewServer(t, "streamfair", util.RandomString(32))
+
+	manager, err := social.NewManager(util.Config{
+		ExternalProvidersFile:      provider.ProvidersFile(t, "test", testExternalCallbackURL),
+		ExternalLoginStateDuration: time.Minute,
+	}, store)
+	require.NoError(t, err)
+	server.social = manager
+	return server, provider
+}
+
+// expectExternalLoginState stores the state of the next started login and lets the callback consume it.
+func expectExternalLoginState(store *mock_db.MockStore) {
+	var loginState db.UserSvcExternalLoginState
+	store.EXPECT().
+		CreateExternalLoginState(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateExternalLoginStateParams) (db.UserSvcExternalLoginState, error) {
+			loginState = db.UserSvcExternalLoginState{
+				StateHash:    arg.StateHash,
+				Provider:     arg.Provider,
+				Nonce:        arg.Nonce,
+				CodeVerifier: arg.CodeVerifier,
+				UserID:       arg.UserID,
+				ExpiredAt:    arg.ExpiredAt,
+			}
+			return loginState, nil
+		})
+	store.EXPECT().
+		ConsumeExternalLoginState(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, stateHash string) (db.UserSvcExternalLoginState, error) {
+			if stateHash != loginState.StateHash {
+				return db.UserSvcExternalLoginState{}, pgx.ErrNoRows
+			}
+			return loginState, nil
+		})
+}
+
+// addUserAuthorization adds an access token of the user, which carries the ID of the user unlike the ones of addAuthorization.
+func addUserAuthorization(t *testing.T, request *http.Request, tokenMaker token.Maker, user db.UserSvcUser, duration time.Duration) {
+	accessToken, _, err := tokenMaker.CreateLocalToken(token.Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		RoleID:   user.RoleID.Int64,
+	}, duration)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+}
+
+func callbackURL(code, state string) string {
+	query := url.Values{"code": {code}, "state": {state}}
+	return "/users/login/external/test/callback?" + query.Encode()
+}
+
+func TestExternalLoginAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server, provider := newTestExternalLoginServer(t, store)
+	user, _ := randomUser(t)
+	user.ID = 7
+	identity := db.UserSvcExternalIdentity{ID: 1, UserID: user.ID, Provider: "test", Subject: "12345"}
+
+	expectExternalLoginState(store)
+	store.EXPECT().GetExternalIdentity(gomock.Any(), gomock.Any()).Times(1).Return(identity, nil)
+	store.EXPECT().GetUserById(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(user, nil)
+	store.EXPECT().GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserSvcTotpSecret{}, pgx.ErrNoRows)
+	store.EXPECT().ListPermissionsByRoleId(gomock.Any(), gomock.Eq(user.RoleID.Int64)).Times(1).Return([]db.UserSvcPermission{}, nil)
+	store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/users/login/external/test", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusFound, recorder.Code)
+	location := recorder.Header().Get("Location")
+	require.Contains(t, location, provider.URL+"/authorize?")
+
+	code, state := provider.Authorize(t, location, map[string]any{"sub": identity.Subject})
+
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, callbackURL(code, state), nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp loginUserResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Equal(t, user.Username, rsp.User.Username)
+	require.NotEmpty(t, rsp.AccessToken)
+
+	// The state can't be used twice
+	store.EXPECT().ConsumeExternalLoginState(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcExternalLoginState{}, pgx.ErrNoRows)
+
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, callbackURL(code, state), nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestExternalLoginMfaAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server, provider := newTestExternalLoginServer(t, store)
+	user, _ := randomUser(t)
+	user.ID = 7
+
+	expectExternalLoginState(store)
+	store.EXPECT().
+		GetExternalIdentity(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.UserSvcExternalIdentity{UserID: user.ID, Provider: "test", Subject: "12345"}, nil)
+	store.EXPECT().GetUserById(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(user, nil)
+	store.EXPECT().GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserSvcTotpSecret{IsConfirmed: true}, nil)
+	store.EXPECT().CreateMfaChallenge(gomock.Any(), gomock.Any()).Times(1)
+	store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/users/login/external/test", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusFound, recorder.Code)
+
+	code, state := provider.Authorize(t, recorder.Header().Get("Location"), map[string]any{"sub": "12345"})
+
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, callbackURL(code, state), nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+
+	var rsp mfaRequiredResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.True(t, rsp.MfaRequired)
+}
+
+func TestExternalLoginUnknownProviderAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server, _ := newTestExternalLoginServer(t, store)
+	store.EXPECT().CreateExternalLoginState(gomock.Any(), gomock.Any()).Times(0)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/users/login/external/other", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+
+	// Users that decline the login at the provider come back with an error instead of a code
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, "/users/login/external/test/callback?error=access_denied&state=x", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestExternalIdentitiesAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server, provider := newTestExternalLoginServer(t, store)
+	user, _ := randomUser(t)
+	user.ID = 7
+
+	// Link
+	expectExternalLoginState(store)
+	store.EXPECT().GetExternalIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcExternalIdentity{}, pgx.ErrNoRows)
+	store.EXPECT().
+		CreateExternalIdentity(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateExternalIdentityParams) (db.UserSvcExternalIdentity, error) {
+			require.Equal(t, user.ID, arg.UserID)
+			return db.UserSvcExternalIdentity{ID: 1, UserID: arg.UserID, Provider: arg.Provider, Subject: arg.Subject}, nil
+		})
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/users/external_identities/test", nil)
+	require.NoError(t, err)
+	addUserAuthorization(t, request, server.tokenMaker, user, time.Minute)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var linkRsp linkExternalIdentityResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &linkRsp))
+	code, state := provider.Authorize(t, linkRsp.AuthorizationURL, map[string]any{"sub": "12345"})
+
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, callbackURL(code, state), nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var identityRsp externalIdentityResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &identityRsp))
+	require.Equal(t, "12345", identityRsp.Subject)
+
+	// List
+	store.EXPECT().
+		ListExternalIdentitiesByUserId(gomock.Any(), gomock.Eq(user.ID)).
+		Times(1).
+		Return([]db.UserSvcExternalIdentity{{ID: 1, UserID: user.ID, Provider: "test", Subject: "12345"}}, nil)
+
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, "/users/external_identities", nil)
+	require.NoError(t, err)
+	addUserAuthorization(t, request, server.tokenMaker, user, time.Minute)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var listRsp []externalIdentityResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &listRsp))
+	require.Len(t, listRsp, 1)
+	require.Equal(t, "test", listRsp[0].Provider)
+
+	// Unlink
+	store.EXPECT().
+		DeleteExternalIdentity(gomock.Any(), gomock.Eq(db.DeleteExternalIdentityParams{UserID: user.ID, Provider: "test"})).
+		Times(1).
+		Return(int64(1), nil)
+	store.EXPECT().
+		DeleteExternalIdentity(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(int64(0), nil)
+
+	for _, status := range []int{http.StatusOK, http.StatusNotFound} {
+		recorder = httptest.NewRecorder()
+		request, err = http.NewRequest(http.MethodDelete, "/users/external_identities/test", nil)
+		require.NoError(t, err)
+		addUserAuthorization(t, request, server.tokenMaker, user, time.Minute)
+
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, status, recorder.Code)
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type introspectTokenRequest struct {
+	Token string `form:"token" binding:"required"`
+	// TokenTypeHint is accepted as defined by RFC 7662, the type is detected from the token itself.
+	TokenTypeHint string `form:"token_type_hint"`
+}
+
+// introspectToken tells other services whether a token is active and who it was issued to. Callers authenticate
+// with the client certificate of an introspection client, so the endpoint requires the server to terminate TLS.
+func (server *Server) introspectToken(ctx *gin.Context) {
+	if _, err := server.introspector.AuthorizeClient(ctx.Request.TLS); err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	var req introspectTokenRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	rsp, err := server.introspector.Introspect(ctx, req.Token)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	"github.com/Streamfair/streamfair_user_svc/introspection"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestIntrospectTokenAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	server.introspector = introspection.NewIntrospector(
+		util.Config{IntrospectionClients: []string{"stream-svc"}},
+		server.tokenMaker,
+		store,
+		server.revocations,
+	)
+
+	claims := token.Claims{UserID: 3, Username: util.RandomUsername(), RoleID: 2}
+	accessToken, payload, err := server.tokenMaker.CreateLocalToken(claims, time.Minute)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		client        string
+		token         string
+		buildStubs    func(store *mock_db.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			client: "stream-svc",
+			token:  accessToken,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp introspection.Response
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.True(t, rsp.Active)
+				require.Equal(t, claims.Username, rsp.Subject)
+				require.Equal(t, claims.UserID, rsp.UserID)
+				require.Equal(t, payload.ExpiredAt.Unix(), rsp.ExpiresAt)
+			},
+		},
+		{
+			name:   "InactiveToken",
+			client: "stream-svc",
+			token:  "invalid",
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, `{"active": false}`, recorder.Body.String())
+			},
+		},
+		{
+			name:   "UnknownClient",
+			client: "chat-svc",
+			token:  accessToken,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:   "MissingToken",
+			client: "stream-svc",
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			tc.buildStubs(store)
+
+			form := url.Values{}
+			if tc.token != "" {
+				form.Set("token", tc.token)
+			}
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/tokens/introspect", strings.NewReader(form.Encode()))
+			require.NoError(t, err)
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			// The client certificate was verified against the CA during the TLS handshake
+			request.TLS = &tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{{{DNSNames: []string{tc.client}}}},
+			}
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
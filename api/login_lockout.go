@@ -0,0 +1,72 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Streamfair/streamfair_user_svc/lockout"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// rejectLogin counts a failed login and responds with the same error for unknown usernames and wrong passwords.
+func (server *Server) rejectLogin(ctx *gin.Context, username string) {
+	if err := server.loginGuard.RecordFailure(ctx, username, ctx.ClientIP()); err != nil {
+		log.Error().Err(err).Str("username", username).Msg("failed to record failed login attempt")
+	}
+	ctx.JSON(http.StatusUnauthorized, errorResponse(lockout.ErrInvalidCredentials))
+}
+
+// handleLockoutError responds with 429 and the Retry-After header if the login is locked.
+func (server *Server) handleLockoutError(ctx *gin.Context, err error) {
+	var lockedErr *lockout.LockedError
+	if errors.As(err, &lockedErr) {
+		ctx.Header("Retry-After", strconv.Itoa(int(lockedErr.RetryAfter.Seconds())))
+		ctx.JSON(http.StatusTooManyRequests, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+}
+
+type unlockUserRequest struct {
+	Username string `uri:"username" binding:"required,min=3"`
+}
+
+type unlockUserQuery struct {
+	// ClientIP optionally lifts the lockout of a client address together with the one of the user.
+	ClientIP string `form:"client_ip" binding:"omitempty,ip"`
+}
+
+type unlockUserResponse struct {
+	Cleared int64 `json:"cleared"`
+}
+
+// unlockUser lifts the login lockout of a user before it expires, e.g. after the owner proved their identity to support.
+func (server *Server) unlockUser(ctx *gin.Context) {
+	var req unlockUserRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var query unlockUserQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanUnlockUsers()
+	}) {
+		return
+	}
+
+	cleared, err := server.loginGuard.Unlock(ctx, req.Username, query.ClientIP)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, unlockUserResponse{Cleared: cleared})
+}
@@ -0,0 +1,263 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/lockout"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLoginUserLockoutAPI(t *testing.T) {
+	user, password := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		password      string
+		buildStubs    func(store *mock_db.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Locked",
+			password: password,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.UserSvcLoginAttempt{{
+						Key:            lockout.UsernameKey(user.Username),
+						FailedAttempts: 3,
+						LockedUntil:    time.Now().Add(time.Minute),
+					}}, nil)
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				require.Equal(t, "60", recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			name:     "FailureLocksUsername",
+			password: "incorrect",
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.UserSvcLoginAttempt{}, nil)
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(2).
+					DoAndReturn(func(_ any, arg db.RecordLoginFailureParams) (db.UserSvcLoginAttempt, error) {
+						return db.UserSvcLoginAttempt{Key: arg.Key, FailedAttempts: 3}, nil
+					})
+				store.EXPECT().
+					LockLogin(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.LockLoginParams) error {
+						require.Equal(t, lockout.UsernameKey(user.Username), arg.Key)
+						return nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "invalid credentials")
+			},
+		},
+		{
+			name:     "SuccessClearsFailures",
+			password: password,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.UserSvcLoginAttempt{{Key: lockout.UsernameKey(user.Username), FailedAttempts: 2}}, nil)
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					DeleteLoginAttempts(gomock.Any(), gomock.Eq([]string{lockout.UsernameKey(user.Username)})).
+					Times(1).
+					Return(int64(1), nil)
+				store.EXPECT().
+					GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserSvcTotpSecret{}, pgx.ErrNoRows)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(user.RoleID.Int64)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			server.loginGuard = lockout.NewGuard(util.Config{
+				LoginMaxAttempts:        3,
+				LoginIPMaxAttempts:      20,
+				LoginLockoutDuration:    time.Minute,
+				LoginLockoutMaxDuration: time.Hour,
+				LoginAttemptWindow:      time.Hour,
+			}, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{
+				"username": user.Username,
+				"password": tc.password,
+			})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(data))
+			require.NoError(t, err)
+			request.RemoteAddr = "10.0.0.1:52814"
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestUnlockUserAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.ID = util.RandomInt(1, 1000)
+	user.RoleID = util.ConvertToInt8(policy.RoleUser)
+
+	admin, _ := randomUser(t)
+	admin.ID = user.ID + 1
+	admin.RoleID = util.ConvertToInt8(policy.RoleAdmin)
+
+	testCases := []struct {
+		name          string
+		query         string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mock_db.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "AdminUnlocksUser",
+			query: "?client_ip=10.0.0.1",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, 1, time.Minute)
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(admin.Username)).
+					Times(1).
+					Return(admin, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleAdmin)).
+					Times(1).
+					Return([]db.UserSvcPermission{{Name: policy.PermissionUnlockUsers}}, nil)
+				store.EXPECT().
+					DeleteLoginAttempts(gomock.Any(), gomock.Eq([]string{
+						lockout.UsernameKey(user.Username),
+						lockout.ClientIPKey("10.0.0.1"),
+					})).
+					Times(1).
+					Return(int64(2), nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp unlockUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, int64(2), rsp.Cleared)
+			},
+		},
+		{
+			name: "PermissionDenied",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, 3, time.Minute)
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					DeleteLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:  "InvalidClientIP",
+			query: "?client_ip=nope",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, 1, time.Minute)
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					DeleteLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/users/unlock/%s%s", user.Username, tc.query)
+			request, err := http.NewRequest(http.MethodPut, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
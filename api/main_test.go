@@ -5,24 +5,48 @@ import (
 	"testing"
 	"time"
 
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
 	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/mail"
 	"github.com/Streamfair/streamfair_user_svc/util"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 )
 
 func newTestServer(t *testing.T, store db.Store) *Server {
 	config := util.Config{
-		TokenSymmetricKey:   util.RandomString(32),
-		AccessTokenDuration: time.Minute,
+		TokenSymmetricKey:       util.RandomString(32),
+		AccessTokenDuration:     time.Minute,
+		VerifyEmailURL:          "https://localhost:8080/users/verify_email",
+		VerifyEmailDuration:     time.Hour,
+		PasswordResetURL:        "https://localhost:8080/reset_password",
+		PasswordResetDuration:   time.Hour,
+		MfaEncryptionKey:        util.RandomString(32),
+		MfaIssuer:               "Streamfair",
+		MfaChallengeDuration:    time.Minute,
+		TokenRevocationInterval: time.Minute,
 	}
 
-	server, err := NewServer(config, store)
+	if store == nil {
+		store = mock_db.NewMockStore(gomock.NewController(t))
+	}
+	if mockStore, ok := store.(*mock_db.MockStore); ok {
+		stubTokenRevocations(mockStore)
+	}
+
+	server, err := NewServer(config, store, mail.NewInMemorySender())
 	require.NoError(t, err)
 
 	return server
 }
 
+// stubTokenRevocations lets the auth middleware load an empty list of token revocations.
+func stubTokenRevocations(store *mock_db.MockStore) {
+	store.EXPECT().ListRevokedTokens(gomock.Any()).AnyTimes().Return([]db.UserSvcRevokedToken{}, nil)
+	store.EXPECT().ListTokenWatermarks(gomock.Any(), gomock.Any()).AnyTimes().Return([]db.UserSvcTokenWatermark{}, nil)
+}
+
 func TestMain(m *testing.M) {
 	gin.SetMode(gin.TestMode)
 
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/mfa"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+type mfaRequiredResponse struct {
+	MfaRequired       bool      `json:"mfa_required"`
+	MfaToken          string    `json:"mfa_token"`
+	MfaTokenExpiresAt time.Time `json:"mfa_token_expires_at"`
+}
+
+// requireMfa responds to a login with a correct password with the MFA token instead of the access and refresh tokens.
+func (server *Server) requireMfa(ctx *gin.Context, username string) {
+	mfaToken, expiredAt, err := server.mfa.CreateChallenge(ctx, username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusAccepted, mfaRequiredResponse{
+		MfaRequired:       true,
+		MfaToken:          mfaToken,
+		MfaTokenExpiresAt: expiredAt,
+	})
+}
+
+type loginUserMfaRequest struct {
+	MfaToken string `json:"mfa_token" binding:"required"`
+	// Code is a TOTP code of the authenticator app or one of the recovery codes.
+	Code string `json:"code" binding:"required"`
+}
+
+// loginUserMfa completes a login with two-factor authentication and creates the session.
+func (server *Server) loginUserMfa(ctx *gin.Context) {
+	var req loginUserMfaRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	challenge, err := server.mfa.GetChallenge(ctx, req.MfaToken)
+	if err != nil {
+		server.handleMfaError(ctx, err)
+		return
+	}
+
+	if err := server.loginGuard.Check(ctx, challenge.Username, ctx.ClientIP()); err != nil {
+		server.handleLockoutError(ctx, err)
+		return
+	}
+
+	if err := server.mfa.CompleteChallenge(ctx, challenge, req.Code); err != nil {
+		if errors.Is(err, mfa.ErrInvalidCode) {
+			if err := server.loginGuard.RecordFailure(ctx, challenge.Username, ctx.ClientIP()); err != nil {
+				log.Error().Err(err).Str("username", challenge.Username).Msg("failed to record failed login attempt")
+			}
+		}
+		server.handleMfaError(ctx, err)
+		return
+	}
+
+	user, err := server.store.GetUserByValue(ctx, challenge.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := server.loginGuard.RecordSuccess(ctx, user.Username); err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to reset failed login attempts")
+	}
+
+	rsp, err := server.createLoginSession(ctx, user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type enrollTotpResponse struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth URI of the secret, to be shown as QR code.
+	URI string `json:"uri"`
+}
+
+// enrollTotp generates a TOTP secret for the authenticated user. Two-factor authentication
+// is only enabled once the secret was confirmed with a code of the authenticator app.
+func (server *Server) enrollTotp(ctx *gin.Context) {
+	authPayload := authorizationPayload(ctx)
+	if authPayload.ClientID != "" {
+		ctx.JSON(http.StatusForbidden, errorResponse(errDelegatedAccountChange))
+		return
+	}
+
+	enrollment, err := server.mfa.Enroll(ctx, authPayload.Username)
+	if err != nil {
+		server.handleMfaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollTotpResponse{
+		Secret: enrollment.Secret,
+		URI:    enrollment.URI,
+	})
+}
+
+type confirmTotpRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type confirmTotpResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// confirmTotp enables two-factor authentication for the authenticated user and returns the recovery codes.
+func (server *Server) confirmTotp(ctx *gin.Context) {
+	var req confirmTotpRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := authorizationPayload(ctx)
+	if authPayload.ClientID != "" {
+		ctx.JSON(http.StatusForbidden, errorResponse(errDelegatedAccountChange))
+		return
+	}
+
+	recoveryCodes, err := server.mfa.Confirm(ctx, authPayload.Username, req.Code)
+	if err != nil {
+		server.handleMfaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, confirmTotpResponse{RecoveryCodes: recoveryCodes})
+}
+
+type disableTotpRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// disableTotp turns two-factor authentication off for the authenticated user,
+// which requires both the password and a TOTP or recovery code.
+func (server *Server) disableTotp(ctx *gin.Context) {
+	var req disableTotpRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := authorizationPayload(ctx)
+	if authPayload.ClientID != "" {
+		ctx.JSON(http.StatusForbidden, errorResponse(errDelegatedAccountChange))
+		return
+	}
+
+	user, err := server.store.GetUserByValue(ctx, authPayload.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			err := errors.New("user of the access token does not exist")
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := util.ComparePassword(user.PasswordHash, user.PasswordSalt, req.Password); err != nil {
+		err := errors.New("password is incorrect")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
+	if err := server.mfa.Disable(ctx, user.Username, req.Code); err != nil {
+		server.handleMfaError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "two-factor authentication disabled"})
+}
+
+// handleMfaError maps the errors of the MFA manager to their response.
+func (server *Server) handleMfaError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode), errors.Is(err, mfa.ErrInvalidChallenge):
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+	case errors.Is(err, mfa.ErrAlreadyEnabled), errors.Is(err, mfa.ErrNotEnabled):
+		ctx.JSON(http.StatusConflict, errorResponse(err))
+	case errors.Is(err, mfa.ErrNotConfigured):
+		ctx.JSON(http.StatusNotImplemented, errorResponse(err))
+	default:
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	}
+}
@@ -0,0 +1,321 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/mfa"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// newTestMfa replaces the MFA manager of the server with one whose encryption key is known to the test
+// and returns the stored TOTP secret of the user together with its plaintext.
+func newTestMfa(t *testing.T, server *Server, store db.Store, username string) (db.UserSvcTotpSecret, string) {
+	key := util.RandomString(32)
+	manager, err := mfa.NewManager(util.Config{
+		MfaEncryptionKey:     key,
+		MfaIssuer:            "Streamfair",
+		MfaChallengeDuration: time.Minute,
+	}, store)
+	require.NoError(t, err)
+	server.mfa = manager
+
+	box, err := mfa.NewSecretBox(key)
+	require.NoError(t, err)
+	secret, err := mfa.GenerateTotpSecret()
+	require.NoError(t, err)
+	ciphertext, err := box.Encrypt(secret, username)
+	require.NoError(t, err)
+
+	return db.UserSvcTotpSecret{
+		Username:         username,
+		SecretCiphertext: ciphertext,
+		IsConfirmed:      true,
+	}, secret
+}
+
+func TestLoginUserRequiresMfaAPI(t *testing.T) {
+	user, password := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	totpSecret, _ := newTestMfa(t, server, store, user.Username)
+
+	store.EXPECT().
+		GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(totpSecret, nil)
+	store.EXPECT().
+		CreateMfaChallenge(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateMfaChallengeParams) (db.UserSvcMfaChallenge, error) {
+			return db.UserSvcMfaChallenge{ID: 1, Username: arg.Username, TokenHash: arg.TokenHash, ExpiredAt: arg.ExpiredAt}, nil
+		})
+	// No tokens are issued before the second step
+	store.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	data, err := json.Marshal(gin.H{"username": user.Username, "password": password})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/users/login", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+
+	var rsp mfaRequiredResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.True(t, rsp.MfaRequired)
+	require.NotEmpty(t, rsp.MfaToken)
+	require.NotContains(t, recorder.Body.String(), "access_token")
+}
+
+func TestLoginUserMfaAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	mfaToken := util.RandomString(32)
+	challenge := db.UserSvcMfaChallenge{
+		ID:        util.RandomInt(1, 1000),
+		Username:  user.Username,
+		TokenHash: util.HashSecretCode(mfaToken),
+		ExpiredAt: time.Now().Add(time.Minute),
+	}
+
+	testCases := []struct {
+		name          string
+		code          func(secret string) string
+		buildStubs    func(store *mock_db.MockStore, totpSecret db.UserSvcTotpSecret)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			code: func(secret string) string {
+				code, err := mfa.TotpCode(secret, mfa.TotpStep(time.Now()))
+				require.NoError(t, err)
+				return code
+			},
+			buildStubs: func(store *mock_db.MockStore, totpSecret db.UserSvcTotpSecret) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Eq(challenge.TokenHash)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(totpSecret, nil)
+				store.EXPECT().
+					UseTotpStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(1), nil)
+				store.EXPECT().
+					UseMfaChallenge(gomock.Any(), gomock.Eq(challenge.ID)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(user.RoleID.Int64)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp loginUserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.NotEmpty(t, rsp.AccessToken)
+				require.NotEmpty(t, rsp.RefreshToken)
+			},
+		},
+		{
+			name: "ReplayedCode",
+			code: func(secret string) string {
+				code, err := mfa.TotpCode(secret, mfa.TotpStep(time.Now()))
+				require.NoError(t, err)
+				return code
+			},
+			buildStubs: func(store *mock_db.MockStore, totpSecret db.UserSvcTotpSecret) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetTotpSecret(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(totpSecret, nil)
+				store.EXPECT().
+					UseTotpStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(0), nil)
+				store.EXPECT().
+					RecordMfaChallengeFailure(gomock.Any(), gomock.Eq(challenge.ID)).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "RecoveryCode",
+			code: func(string) string {
+				return "abcd-efgh-ijkl-mnop"
+			},
+			buildStubs: func(store *mock_db.MockStore, totpSecret db.UserSvcTotpSecret) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Eq(db.UseRecoveryCodeParams{
+						Username: user.Username,
+						CodeHash: mfa.HashRecoveryCode("abcd-efgh-ijkl-mnop"),
+					})).
+					Times(1).
+					Return(db.UserSvcRecoveryCode{}, nil)
+				store.EXPECT().
+					UseMfaChallenge(gomock.Any(), gomock.Eq(challenge.ID)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(user.RoleID.Int64)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "ExpiredChallenge",
+			code: func(string) string {
+				return "123456"
+			},
+			buildStubs: func(store *mock_db.MockStore, totpSecret db.UserSvcTotpSecret) {
+				expired := challenge
+				expired.ExpiredAt = time.Now().Add(-time.Second)
+
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(expired, nil)
+				store.EXPECT().
+					GetTotpSecret(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), mfa.ErrInvalidChallenge.Error())
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			server := newTestServer(t, store)
+			totpSecret, secret := newTestMfa(t, server, store, user.Username)
+			tc.buildStubs(store, totpSecret)
+
+			data, err := json.Marshal(gin.H{"mfa_token": mfaToken, "code": tc.code(secret)})
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/users/login/mfa", bytes.NewReader(data))
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestEnrollAndConfirmTotpAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	newTestMfa(t, server, store, user.Username)
+
+	var stored db.UserSvcTotpSecret
+	store.EXPECT().
+		CreateTotpSecret(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateTotpSecretParams) (db.UserSvcTotpSecret, error) {
+			stored = db.UserSvcTotpSecret{Username: arg.Username, SecretCiphertext: arg.SecretCiphertext}
+			return stored, nil
+		})
+
+	request, err := http.NewRequest(http.MethodPost, "/users/mfa/totp", nil)
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, 3, time.Minute)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var enrollment enrollTotpResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &enrollment))
+	require.Contains(t, enrollment.URI, "otpauth://totp/")
+
+	store.EXPECT().
+		GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		DoAndReturn(func(_ any, _ string) (db.UserSvcTotpSecret, error) {
+			return stored, nil
+		})
+	store.EXPECT().
+		EnableTotpTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.EnableTotpTxResult{}, nil)
+
+	code, err := mfa.TotpCode(enrollment.Secret, mfa.TotpStep(time.Now()))
+	require.NoError(t, err)
+	data, err := json.Marshal(gin.H{"code": code})
+	require.NoError(t, err)
+	request, err = http.NewRequest(http.MethodPost, "/users/mfa/totp/confirm", bytes.NewReader(data))
+	require.NoError(t, err)
+	addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, 3, time.Minute)
+
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var confirmation confirmTotpResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &confirmation))
+	require.NotEmpty(t, confirmation.RecoveryCodes)
+}
@@ -1,22 +1,33 @@
 package api
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"github.com/Streamfair/streamfair_user_svc/apikey"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/ratelimit"
+	"github.com/Streamfair/streamfair_user_svc/revocation"
 	"github.com/Streamfair/streamfair_user_svc/token"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
 )
 
 const (
 	authorizationHeaderKey  = "authorization"
-	authorizationTypeBearer = "bearer" // implement other types of authorization
+	authorizationTypeBearer = "bearer"
+	authorizationTypeApiKey = "apikey"
 	authorizationPayloadKey = "authorization_payload"
 )
 
-func authMiddleware(localTokenMaker token.Maker) gin.HandlerFunc {
+// authMiddleware verifies the access token of a bearer authorization header or the key of an ApiKey
+// authorization header and stores the payload for the handlers.
+func authMiddleware(tokenMaker token.Maker, revocations *revocation.List, apiKeys *apikey.Manager) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
 		if len(authorizationHeader) == 0 {
@@ -32,17 +43,19 @@ func authMiddleware(localTokenMaker token.Maker) gin.HandlerFunc {
 			return
 		}
 
-		authorizationType := strings.ToLower(fields[0])
-		if authorizationType != authorizationTypeBearer {
-			err := fmt.Errorf("authorization type '%s' is not supported", authorizationType)
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
-			return
+		var payload *token.Payload
+		var status int
+		var err error
+		switch authorizationType := strings.ToLower(fields[0]); authorizationType {
+		case authorizationTypeBearer:
+			payload, status, err = verifyAccessToken(ctx, tokenMaker, revocations, fields[1])
+		case authorizationTypeApiKey:
+			payload, status, err = authenticateApiKey(ctx, apiKeys, fields[1])
+		default:
+			status, err = http.StatusUnauthorized, fmt.Errorf("authorization type '%s' is not supported", authorizationType)
 		}
-
-		accessToken := fields[1]
-		payload, err := localTokenMaker.VerifyLocalToken(accessToken)
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+			ctx.AbortWithStatusJSON(status, errorResponse(err))
 			return
 		}
 
@@ -50,3 +63,100 @@ func authMiddleware(localTokenMaker token.Maker) gin.HandlerFunc {
 		ctx.Next()
 	}
 }
+
+// verifyAccessToken verifies an access token and checks that it was issued to a user and wasn't revoked.
+func verifyAccessToken(ctx *gin.Context, tokenMaker token.Maker, revocations *revocation.List, accessToken string) (*token.Payload, int, error) {
+	payload, err := token.VerifyToken(tokenMaker, accessToken)
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
+
+	// Tokens of the client credentials grant act on behalf of a client and not of a user
+	if payload.Username == "" {
+		return nil, http.StatusUnauthorized, errors.New("access token was not issued to a user")
+	}
+
+	if err := revocations.Check(ctx, payload); err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
+
+	return payload, http.StatusOK, nil
+}
+
+// authenticateApiKey verifies an API key. API keys are revoked one by one and aren't tracked by the token revocations.
+func authenticateApiKey(ctx *gin.Context, apiKeys *apikey.Manager, rawKey string) (*token.Payload, int, error) {
+	payload, err := apiKeys.Authenticate(ctx, rawKey, ctx.ClientIP())
+	if err != nil {
+		if errors.Is(err, apikey.ErrInvalidKey) ||
+			errors.Is(err, apikey.ErrKeyRevoked) ||
+			errors.Is(err, apikey.ErrKeyExpired) ||
+			errors.Is(err, apikey.ErrUserNotActive) {
+			return nil, http.StatusUnauthorized, err
+		}
+		log.Error().Err(err).Msg("failed to authenticate API key")
+		return nil, http.StatusInternalServerError, errors.New("failed to authenticate API key")
+	}
+
+	return payload, http.StatusOK, nil
+}
+
+// authorizationPayload returns the token payload that the auth middleware stored for the handlers. Besides the
+// username it carries the ID, role and scopes of the user, so that handlers don't have to query them.
+func authorizationPayload(ctx *gin.Context) *token.Payload {
+	return ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+}
+
+// rateLimitMiddleware limits the requests per route and client address. It runs before the auth middleware,
+// so that requests with invalid credentials are limited as well.
+func rateLimitMiddleware(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		route := ctx.Request.Method + " " + ctx.FullPath()
+		err := limiter.Allow(ctx, route, ratelimit.ClientKey(ctx.ClientIP()))
+
+		var limitedErr *ratelimit.LimitedError
+		if errors.As(err, &limitedErr) {
+			ctx.Header("Retry-After", strconv.Itoa(limitedErr.RetryAfterSeconds()))
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse(err))
+			return
+		}
+		if err != nil {
+			// A failing backend must not take the API down, the request is let through
+			log.Error().Err(err).Str("route", route).Msg("failed to check rate limit")
+		}
+
+		ctx.Next()
+	}
+}
+
+// errDelegatedAccountChange is reported when an OAuth client or an API key tries to change the password or
+// the two-factor authentication of its user. Only the tokens of a login may change the security of the account.
+var errDelegatedAccountChange = errors.New("OAuth clients and API keys can't change the security settings of the account")
+
+// authorize loads the policy subject of the authenticated user and runs the given check against it.
+// It writes the error response and returns false if the user is not allowed to proceed.
+func (server *Server) authorize(ctx *gin.Context, check func(subject *policy.Subject) error) bool {
+	authPayload := authorizationPayload(ctx)
+
+	subject, err := server.policy.LoadSubject(ctx, authPayload.Username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			err := errors.New("user of the access token does not exist")
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+
+	// OAuth clients and API keys only get the permissions of the user that were granted to them
+	if authPayload.ClientID != "" {
+		subject = subject.WithScopes(authPayload.Scopes)
+	}
+
+	if err := check(subject); err != nil {
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return false
+	}
+
+	return true
+}
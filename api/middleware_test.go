@@ -4,12 +4,19 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/ratelimit"
 	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 )
 
 func addAuthorization(
@@ -21,7 +28,7 @@ func addAuthorization(
 	roleID int32,
 	duration time.Duration,
 ) {
-	token, payload, err := tokenMaker.CreateLocalToken(username, duration)
+	token, payload, err := tokenMaker.CreateLocalToken(token.Claims{Username: username, RoleID: int64(roleID)}, duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, payload)
 
@@ -79,6 +86,37 @@ func TestAuthMiddleware(t *testing.T) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
 			},
 		},
+		{
+			name: "MalformedApiKey",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				request.Header.Set(authorizationHeaderKey, "ApiKey not-a-key")
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "RefreshToken",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				refreshToken, _, err := tokenMaker.CreateRefreshToken(token.Claims{Username: "testuser"}, time.Minute)
+				require.NoError(t, err)
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, refreshToken))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "ClientToken",
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				accessToken, _, err := tokenMaker.CreateLocalToken(token.Claims{ClientID: "stream-svc"}, time.Minute)
+				require.NoError(t, err)
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
 	}
 
 	for i := range testCases {
@@ -89,7 +127,7 @@ func TestAuthMiddleware(t *testing.T) {
 			authPath := "/auth"
 			server.router.GET(
 				authPath,
-				authMiddleware(server.localTokenMaker),
+				authMiddleware(server.tokenMaker, server.revocations, server.apiKeys),
 				func(ctx *gin.Context) {
 					ctx.JSON(http.StatusOK, gin.H{})
 				},
@@ -99,9 +137,187 @@ func TestAuthMiddleware(t *testing.T) {
 			request, err := http.NewRequest(http.MethodGet, authPath, nil)
 			require.NoError(t, err)
 
-			tc.setupAuth(t, request, server.localTokenMaker)
+			tc.setupAuth(t, request, server.tokenMaker)
 			server.router.ServeHTTP(recorder, request)
 			tc.checkResponse(t, recorder)
 		})
 	}
 }
+
+func TestAuthMiddlewareExposesClaims(t *testing.T) {
+	server := newTestServer(t, nil)
+	claims := token.Claims{
+		UserID:   util.RandomInt(1, 1000),
+		Username: util.RandomUsername(),
+		RoleID:   policy.RoleModerator,
+		Scopes:   []string{policy.PermissionListUsers, policy.PermissionReadUsers},
+	}
+
+	authPath := "/auth"
+	server.router.GET(
+		authPath,
+		authMiddleware(server.tokenMaker, server.revocations, server.apiKeys),
+		func(ctx *gin.Context) {
+			payload := authorizationPayload(ctx)
+			require.Equal(t, claims.UserID, payload.UserID)
+			require.Equal(t, claims.Username, payload.Username)
+			require.Equal(t, claims.RoleID, payload.RoleID)
+			require.True(t, payload.HasScope(policy.PermissionReadUsers))
+			require.False(t, payload.HasScope(policy.PermissionDeleteUsers))
+			ctx.JSON(http.StatusOK, gin.H{})
+		},
+	)
+
+	accessToken, _, err := server.tokenMaker.CreateLocalToken(claims, time.Minute)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, authPath, nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	server := newTestServer(t, nil)
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryBackend(), map[string]ratelimit.Limit{
+		"GET /limited": {Rate: 1.0 / 60, Burst: 2},
+	})
+
+	limitedPath := "/limited"
+	server.router.GET(
+		limitedPath,
+		rateLimitMiddleware(limiter),
+		authMiddleware(server.tokenMaker, server.revocations, server.apiKeys),
+		func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, gin.H{})
+		},
+	)
+
+	send := func(clientAddress string, authorized bool) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, limitedPath, nil)
+		require.NoError(t, err)
+		request.RemoteAddr = clientAddress
+
+		if authorized {
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, "alice", 1, time.Minute)
+		} else {
+			request.Header.Set(authorizationHeaderKey, authorizationTypeBearer+" invalid")
+		}
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// Requests are limited per client address, before the access token is checked
+	require.Equal(t, http.StatusOK, send("203.0.113.7:4711", true).Code)
+	require.Equal(t, http.StatusUnauthorized, send("203.0.113.7:4712", false).Code)
+
+	recorder := send("203.0.113.7:4713", true)
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.Equal(t, "60", recorder.Header().Get("Retry-After"))
+
+	require.Equal(t, http.StatusOK, send("203.0.113.8:4711", true).Code)
+}
+
+func TestDelegatedTokensCantChangeAccount(t *testing.T) {
+	user := db.UserSvcUser{
+		ID:       util.RandomInt(1, 1000),
+		Username: util.RandomUsername(),
+		RoleID:   util.ConvertToInt8(policy.RoleUser),
+	}
+
+	testCases := []struct {
+		name       string
+		method     string
+		url        string
+		body       string
+		buildStubs func(store *mock_db.MockStore)
+	}{
+		{
+			name:       "EnrollTotp",
+			method:     http.MethodPost,
+			url:        "/users/mfa/totp",
+			buildStubs: func(store *mock_db.MockStore) {},
+		},
+		{
+			name:       "ConfirmTotp",
+			method:     http.MethodPost,
+			url:        "/users/mfa/totp/confirm",
+			body:       `{"code":"123456"}`,
+			buildStubs: func(store *mock_db.MockStore) {},
+		},
+		{
+			name:       "DisableTotp",
+			method:     http.MethodPost,
+			url:        "/users/mfa/totp/disable",
+			body:       `{"password":"secret","code":"123456"}`,
+			buildStubs: func(store *mock_db.MockStore) {},
+		},
+		{
+			name:       "ChangePassword",
+			method:     http.MethodPut,
+			url:        "/users/change_password",
+			body:       `{"old_password":"Secret123!","new_password":"Secret456!"}`,
+			buildStubs: func(store *mock_db.MockStore) {},
+		},
+		{
+			name:   "UpdateSelf",
+			method: http.MethodPut,
+			url:    fmt.Sprintf("/users/update/%d", user.ID),
+			body:   `{"full_name":"Eve Example"}`,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+			},
+		},
+		{
+			name:   "RevokeOwnSessions",
+			method: http.MethodPut,
+			url:    "/sessions/revoke_all/" + user.Username,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			// The token of an OAuth client acting on behalf of the user
+			accessToken, _, err := server.tokenMaker.CreateLocalToken(token.Claims{
+				UserID:   user.ID,
+				Username: user.Username,
+				RoleID:   policy.RoleUser,
+				Scopes:   []string{policy.PermissionUpdateUsers, policy.PermissionManageSessions},
+				ClientID: "client",
+			}, time.Minute)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			require.NoError(t, err)
+			request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		})
+	}
+}
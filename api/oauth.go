@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Streamfair/streamfair_user_svc/oauth"
+	"github.com/gin-gonic/gin"
+)
+
+// authorizeOAuthClient serves the authorization page, on which users sign in and allow or deny the request
+// of an OAuth client, and redirects them back to the client.
+func (server *Server) authorizeOAuthClient(ctx *gin.Context) {
+	server.oauth.HandleAuthorize(ctx.Writer, ctx.Request, ctx.ClientIP())
+}
+
+// issueOAuthToken is the token endpoint of the OAuth clients. The request is form encoded as defined by RFC 6749.
+func (server *Server) issueOAuthToken(ctx *gin.Context) {
+	req, err := oauth.ParseTokenRequest(ctx.Request)
+	if err != nil {
+		writeOAuthError(ctx, err)
+		return
+	}
+	req.ClientIP = ctx.ClientIP()
+
+	rsp, err := server.oauth.Token(ctx, req)
+	if err != nil {
+		writeOAuthError(ctx, err)
+		return
+	}
+
+	ctx.Header("Cache-Control", "no-store")
+	ctx.Header("Pragma", "no-cache")
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// getOAuthUserInfo returns the OpenID Connect claims of the user of the access token.
+func (server *Server) getOAuthUserInfo(ctx *gin.Context) {
+	info, err := server.oauth.UserInfo(ctx, authorizationPayload(ctx))
+	if err != nil {
+		writeOAuthError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, info)
+}
+
+// getOpenIDConfiguration publishes the OpenID Connect discovery document.
+func (server *Server) getOpenIDConfiguration(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, server.oauth.Discovery())
+}
+
+// writeOAuthError writes OAuth errors in the format of RFC 6749 and other errors as internal errors.
+func writeOAuthError(ctx *gin.Context, err error) {
+	var oauthErr *oauth.Error
+	if errors.As(err, &oauthErr) {
+		ctx.JSON(oauthErr.StatusCode, oauthErr)
+		return
+	}
+	ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/oauth"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAuthorizeOAuthClientAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	client := db.UserSvcOAuthClient{
+		ID:           "web-app",
+		Name:         "Web App",
+		RedirectUris: []string{"https://app.example.com/callback"},
+		GrantTypes:   []string{oauth.GrantTypeAuthorizationCode},
+		Scopes:       []string{oauth.ScopeOpenID},
+	}
+	user, password := randomUser(t)
+
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).Times(4).Return(client, nil)
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserSvcTotpSecret{}, pgx.ErrNoRows)
+	store.EXPECT().
+		CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateOAuthAuthorizationCodeParams) (db.UserSvcOAuthAuthorizationCode, error) {
+			require.Equal(t, user.Username, arg.Username)
+			return db.UserSvcOAuthAuthorizationCode{}, nil
+		})
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ID},
+		"state":                 {"xyz"},
+		"code_challenge":        {oauth.CodeChallenge(util.RandomString(43))},
+		"code_challenge_method": {oauth.CodeChallengeMethodS256},
+	}
+
+	// The browser of the user is shown the sign-in and consent page without a token
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), client.Name+" wants to access your Streamfair account")
+
+	form := url.Values{
+		"username": {user.Username},
+		"password": {password},
+		"decision": {"allow"},
+	}
+	for key, values := range query {
+		form[key] = values
+	}
+
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusSeeOther, recorder.Code)
+
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, "app.example.com", location.Host)
+	require.NotEmpty(t, location.Query().Get("code"))
+	require.Equal(t, "xyz", location.Query().Get("state"))
+
+	// Unregistered redirect URIs are not followed
+	query.Set("redirect_uri", "https://attacker.example.com/callback")
+
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+	require.Empty(t, recorder.Header().Get("Location"))
+}
+
+func TestIssueOAuthTokenAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	secret := util.RandomString(32)
+	client := db.UserSvcOAuthClient{
+		ID:         "stream-svc",
+		SecretHash: util.HashSecretCode(secret),
+		GrantTypes: []string{oauth.GrantTypeClientCredentials},
+		Scopes:     []string{policy.PermissionReadUsers},
+	}
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).Times(2).Return(client, nil)
+
+	form := url.Values{"grant_type": {oauth.GrantTypeClientCredentials}}
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(client.ID, secret)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+	var rsp oauth.TokenResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Equal(t, "Bearer", rsp.TokenType)
+	require.Equal(t, policy.PermissionReadUsers, rsp.Scope)
+
+	payload, err := server.tokenMaker.VerifyLocalToken(rsp.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, client.ID, payload.ClientID)
+
+	// Tokens of the client have no user and can't be used on the user routes
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodGet, "/users/list?page_id=1&page_size=5", nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, rsp.AccessToken))
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	// Wrong secrets are rejected
+	recorder = httptest.NewRecorder()
+	request, err = http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth(client.ID, "wrong")
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	require.JSONEq(t, `{"error": "invalid_client", "error_description": "invalid client credentials"}`, recorder.Body.String())
+}
+
+func TestOAuthClientTokenScopesAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	user, _ := randomUser(t)
+	user.RoleID = util.ConvertToInt8(policy.RoleAdmin)
+
+	// The role of the user allows to list users, but the client was only granted to read them
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().
+		ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleAdmin)).
+		Times(1).
+		Return([]db.UserSvcPermission{{Name: policy.PermissionReadUsers}, {Name: policy.PermissionListUsers}}, nil)
+	store.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Times(0)
+
+	claims := token.Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		RoleID:   policy.RoleAdmin,
+		Scopes:   []string{oauth.ScopeOpenID, policy.PermissionReadUsers},
+		ClientID: "web-app",
+	}
+	accessToken, _, err := server.tokenMaker.CreateLocalToken(claims, time.Minute)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/users/list?page_id=1&page_size=5", nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+
+	// Refresh tokens of the client can't be renewed as the ones of a login
+	refreshToken, _, err := server.tokenMaker.CreateRefreshToken(claims, time.Hour)
+	require.NoError(t, err)
+
+	recorder = httptest.NewRecorder()
+	body := fmt.Sprintf(`{"refresh_token": "%s"}`, refreshToken)
+	request, err = http.NewRequest(http.MethodPost, "/tokens/renew_access", strings.NewReader(body))
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestGetOAuthUserInfoAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	user, _ := randomUser(t)
+
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+
+	accessToken, _, err := server.tokenMaker.CreateLocalToken(token.Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Scopes:   []string{oauth.ScopeOpenID, oauth.ScopeProfile},
+		ClientID: "web-app",
+	}, time.Minute)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/oauth/userinfo", nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var info oauth.UserInfo
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &info))
+	require.Equal(t, fmt.Sprint(user.ID), info.Subject)
+	require.Equal(t, user.Username, info.PreferredUsername)
+	require.Empty(t, info.Email)
+}
+
+func TestGetOpenIDConfigurationAPI(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var metadata oauth.Metadata
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &metadata))
+	require.Equal(t, []string{"code"}, metadata.ResponseTypesSupported)
+	require.Contains(t, metadata.GrantTypesSupported, oauth.GrantTypeClientCredentials)
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/passwordreset"
+	"github.com/gin-gonic/gin"
+)
+
+type requestPasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type requestPasswordResetResponse struct {
+	Message string `json:"message"`
+}
+
+// requestPasswordReset sends a single-use password reset link to the email address of the user.
+func (server *Server) requestPasswordReset(ctx *gin.Context) {
+	var req requestPasswordResetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if err := server.resetter.Request(ctx, req.Email); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, requestPasswordResetResponse{Message: passwordreset.RequestedMessage})
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" binding:"required,min=32,max=128"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=64"`
+}
+
+type resetPasswordResponse struct {
+	User            userResponse `json:"user"`
+	RevokedSessions int64        `json:"revoked_sessions"`
+}
+
+// resetPassword sets a new password using a password reset token and signs the user out of all devices.
+func (server *Server) resetPassword(ctx *gin.Context) {
+	var req resetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	result, err := server.resetter.Reset(ctx, req.Token, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPasswordReset) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := resetPasswordResponse{
+		User:            newUserResponse(result.User),
+		RevokedSessions: result.RevokedSessions,
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/mail"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRequestPasswordResetAPI(t *testing.T) {
+	user, _ := randomUser(t)
+
+	testCases := []struct {
+		name          string
+		email         string
+		buildStubs    func(store *mock_db.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder, sender *mail.InMemorySender)
+	}{
+		{
+			name:  "OK",
+			email: user.Email,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					CreatePasswordReset(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreatePasswordResetParams) (db.UserSvcPasswordReset, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Len(t, arg.TokenHash, 64)
+						require.True(t, arg.ExpiredAt.After(time.Now()))
+						return db.UserSvcPasswordReset{Username: arg.Username, TokenHash: arg.TokenHash}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, sender *mail.InMemorySender) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				emails := sender.Emails()
+				require.Len(t, emails, 1)
+				require.Equal(t, []string{user.Email}, emails[0].To)
+			},
+		},
+		{
+			name:  "UnknownEmail",
+			email: util.RandomEmail(),
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserSvcUser{}, pgx.ErrNoRows)
+				store.EXPECT().
+					CreatePasswordReset(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, sender *mail.InMemorySender) {
+				// The response must not reveal that no account exists
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Empty(t, sender.Emails())
+			},
+		},
+		{
+			name:  "InvalidEmail",
+			email: "invalid_email",
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByEmail(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, sender *mail.InMemorySender) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(gin.H{"email": tc.email})
+			require.NoError(t, err)
+
+			url := "/users/password_reset/request"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			// The emails are sent after the response
+			server.resetter.Wait()
+			tc.checkResponse(recorder, server.mailer.(*mail.InMemorySender))
+		})
+	}
+}
+
+func TestResetPasswordAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	token, err := util.GenerateSecretCode()
+	require.NoError(t, err)
+	newPassword := util.RandomString(12)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		buildStubs    func(store *mock_db.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"token":        token,
+				"new_password": newPassword,
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ResetPasswordTxParams) (db.ResetPasswordTxResult, error) {
+						require.Equal(t, util.HashSecretCode(token), arg.TokenHash)
+
+						require.NoError(t, util.ComparePassword(arg.PasswordHash, arg.PasswordSalt, newPassword))
+
+						return db.ResetPasswordTxResult{User: user, RevokedSessions: 2}, nil
+					})
+				store.EXPECT().
+					SetTokenWatermark(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.SetTokenWatermarkParams) (db.UserSvcTokenWatermark, error) {
+						require.Equal(t, user.Username, arg.Username)
+						return db.UserSvcTokenWatermark{Username: arg.Username, NotBefore: arg.NotBefore}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp resetPasswordResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, int64(2), rsp.RevokedSessions)
+				require.Equal(t, user.Username, rsp.User.Username)
+			},
+		},
+		{
+			name: "InvalidToken",
+			body: gin.H{
+				"token":        token,
+				"new_password": newPassword,
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ResetPasswordTxResult{}, db.ErrInvalidPasswordReset)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "TooShortPassword",
+			body: gin.H{
+				"token":        token,
+				"new_password": "123",
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := "/users/password_reset"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/gin-gonic/gin"
+)
+
+// createAccessToken creates a public access token if signing keys are configured, so that other services can
+// verify it without the symmetric key, and a local access token otherwise.
+func (server *Server) createAccessToken(claims token.Claims) (string, *token.Payload, error) {
+	if len(server.tokenMaker.PublicKeys()) > 0 {
+		return server.tokenMaker.CreatePublicToken(claims, server.config.AccessTokenDuration)
+	}
+	return server.tokenMaker.CreateLocalToken(claims, server.config.AccessTokenDuration)
+}
+
+// listPublicKeys publishes the public keys that verify public access tokens as JSON web key set.
+// The set is empty if the service only issues local tokens.
+func (server *Server) listPublicKeys(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, token.NewJSONWebKeySet(server.tokenMaker.PublicKeys()))
+}
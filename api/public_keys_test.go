@@ -0,0 +1,94 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	"github.com/Streamfair/streamfair_user_svc/mail"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestPublicTokenServer(t *testing.T, store *mock_db.MockStore) *Server {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	config := util.Config{
+		TokenSymmetricKey:   util.RandomString(32),
+		TokenPrivateKeys:    []string{hex.EncodeToString(privateKey.Seed())},
+		AccessTokenDuration: time.Minute,
+	}
+	stubTokenRevocations(store)
+
+	server, err := NewServer(config, store, mail.NewInMemorySender())
+	require.NoError(t, err)
+	return server
+}
+
+func TestListPublicKeysAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	server := newTestPublicTokenServer(t, mock_db.NewMockStore(ctrl))
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var set token.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 1)
+	require.Equal(t, server.tokenMaker.PublicKeys()[0].ID, set.Keys[0].KeyID)
+
+	// Services without signing keys publish an empty set
+	server = newTestServer(t, mock_db.NewMockStore(ctrl))
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.JSONEq(t, `{"keys":[]}`, recorder.Body.String())
+}
+
+func TestAuthMiddlewarePublicToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	server := newTestPublicTokenServer(t, mock_db.NewMockStore(ctrl))
+
+	authPath := "/auth"
+	server.router.GET(
+		authPath,
+		authMiddleware(server.tokenMaker, server.revocations, server.apiKeys),
+		func(ctx *gin.Context) {
+			payload := authorizationPayload(ctx)
+			ctx.JSON(http.StatusOK, gin.H{"username": payload.Username})
+		},
+	)
+
+	username := util.RandomUsername()
+	publicToken, _, err := server.createAccessToken(token.Claims{Username: username})
+	require.NoError(t, err)
+	require.True(t, token.IsPublicToken(publicToken))
+	localToken, _, err := server.tokenMaker.CreateLocalToken(token.Claims{Username: username}, time.Minute)
+	require.NoError(t, err)
+
+	// Local access tokens issued before the signing keys were configured stay valid
+	for _, accessToken := range []string{publicToken, localToken} {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, authPath, nil)
+		require.NoError(t, err)
+		request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Contains(t, recorder.Body.String(), username)
+	}
+}
@@ -3,7 +3,18 @@ package api
 import (
 	"fmt"
 
+	"github.com/Streamfair/streamfair_user_svc/apikey"
 	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/introspection"
+	"github.com/Streamfair/streamfair_user_svc/lockout"
+	"github.com/Streamfair/streamfair_user_svc/mail"
+	"github.com/Streamfair/streamfair_user_svc/mfa"
+	"github.com/Streamfair/streamfair_user_svc/oauth"
+	"github.com/Streamfair/streamfair_user_svc/passwordreset"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/ratelimit"
+	"github.com/Streamfair/streamfair_user_svc/revocation"
+	"github.com/Streamfair/streamfair_user_svc/social"
 	"github.com/Streamfair/streamfair_user_svc/token"
 	"github.com/Streamfair/streamfair_user_svc/util"
 	"github.com/gin-gonic/gin"
@@ -12,39 +23,109 @@ import (
 
 // Server serves HTTP requests for the streamfair user management service.
 type Server struct {
-	config          util.Config
-	store           db.Store
-	localTokenMaker token.Maker
-	router          *gin.Engine
+	config       util.Config
+	store        db.Store
+	tokenMaker   token.Maker
+	policy       *policy.Policy
+	loginGuard   *lockout.Guard
+	rateLimiter  *ratelimit.Limiter
+	mfa          *mfa.Manager
+	revocations  *revocation.List
+	introspector *introspection.Introspector
+	oauth        *oauth.Provider
+	social       *social.Manager
+	apiKeys      *apikey.Manager
+	mailer       mail.EmailSender
+	resetter     *passwordreset.Resetter
+	router       *gin.Engine
 }
 
 // NewServer creates a new HTTP server and setup routing.
-func NewServer(config util.Config, store db.Store) (*Server, error) {
-	localTokenMaker, err := token.NewLocalPasetoMaker(config.TokenSymmetricKey)
+func NewServer(config util.Config, store db.Store, mailer mail.EmailSender) (*Server, error) {
+	keyring, err := token.LoadKeyring(config.TokenKeyringFile, config.TokenSymmetricKey, config.TokenPreviousSymmetricKeys)
 	if err != nil {
-		panic(fmt.Sprintf("Failed to create local token maker: %v", err))
+		panic(fmt.Sprintf("Failed to load token keyring: %v", err))
 	}
 
+	tokenMaker, err := token.NewPasetoMaker(keyring, config.TokenPrivateKeys, config.TokenIssuer, config.TokenAudience)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to create token maker: %v", err))
+	}
+
+	rateLimits, err := ratelimit.ParseLimits(config.RateLimits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rate limits: %w", err)
+	}
+
+	registerNullableTypes()
+
+	mfaManager, err := mfa.NewManager(config, store)
+	if err != nil {
+		return nil, err
+	}
+
+	socialManager, err := social.NewManager(config, store)
+	if err != nil {
+		return nil, err
+	}
+
+	revocations := revocation.NewList(config, store)
+	authPolicy := policy.NewPolicy(store)
+	loginGuard := lockout.NewGuard(config, store)
+
 	server := &Server{
-		config:          config,
-		store:           store,
-		localTokenMaker: localTokenMaker,
+		config:       config,
+		store:        store,
+		tokenMaker:   tokenMaker,
+		policy:       authPolicy,
+		loginGuard:   loginGuard,
+		rateLimiter:  ratelimit.NewLimiter(ratelimit.NewMemoryBackend(), rateLimits),
+		mfa:          mfaManager,
+		revocations:  revocations,
+		introspector: introspection.NewIntrospector(config, tokenMaker, store, revocations),
+		oauth:        oauth.NewProvider(config, store, tokenMaker, authPolicy, loginGuard, mfaManager),
+		social:       socialManager,
+		apiKeys:      apikey.NewManager(store, authPolicy),
+		mailer:       mailer,
+		resetter:     passwordreset.NewResetter(config, store, mailer, revocations),
 	}
 
-	server.setupRouter()
+	if err := server.setupRouter(); err != nil {
+		return nil, err
+	}
 	return server, nil
 }
 
-func (server *Server) setupRouter() {
+func (server *Server) setupRouter() error {
 	router := gin.Default()
 
+	// Without trusted proxies gin would trust the X-Forwarded-For headers of every client
+	if err := router.SetTrustedProxies(server.config.TrustedProxies); err != nil {
+		return fmt.Errorf("failed to set trusted proxies: %w", err)
+	}
+
 	router.GET("/readiness", server.readinessCheck)
 
-	router.POST("/users", server.createUser)
-	router.POST("/users/login", server.loginUser)
-	router.POST("/tokens/renew_access", server.renewAccessToken)
+	// All routes are limited per client address before the credentials are checked
+	publicRoutes := router.Group("/").Use(rateLimitMiddleware(server.rateLimiter))
+
+	publicRoutes.GET("/.well-known/jwks.json", server.listPublicKeys)
+	publicRoutes.GET("/.well-known/openid-configuration", server.getOpenIDConfiguration)
+	publicRoutes.POST("/users", server.createUser)
+	publicRoutes.POST("/users/login", server.loginUser)
+	publicRoutes.POST("/users/login/mfa", server.loginUserMfa)
+	publicRoutes.GET("/users/login/external/:provider", server.startExternalLogin)
+	publicRoutes.GET("/users/login/external/:provider/callback", server.completeExternalLogin)
+	publicRoutes.POST("/tokens/renew_access", server.renewAccessToken)
+	publicRoutes.POST("/tokens/introspect", server.introspectToken)
+	publicRoutes.GET("/oauth/authorize", server.authorizeOAuthClient)
+	publicRoutes.POST("/oauth/authorize", server.authorizeOAuthClient)
+	publicRoutes.POST("/oauth/token", server.issueOAuthToken)
+	publicRoutes.GET("/users/verify_email", server.verifyEmail)
+	publicRoutes.POST("/users/password_reset/request", server.requestPasswordReset)
+	publicRoutes.POST("/users/password_reset", server.resetPassword)
 
-	authRoutes := router.Group("/").Use(authMiddleware(server.localTokenMaker))
+	authRoutes := router.Group("/").Use(rateLimitMiddleware(server.rateLimiter), authMiddleware(server.tokenMaker, server.revocations, server.apiKeys))
 
 	authRoutes.GET("/users/id/:id", server.getUserByID)
 	authRoutes.GET("/users/id", server.handleMissingID)
@@ -53,10 +134,34 @@ func (server *Server) setupRouter() {
 	authRoutes.GET("/users/list", server.listUsers)
 	authRoutes.PUT("/users/update/:id", server.updateUser)
 	authRoutes.PUT("/users/update", server.handleMissingID)
+	authRoutes.PUT("/users/change_password", server.changePassword)
 	authRoutes.DELETE("/users/delete/:id", server.deleteUser)
 	authRoutes.DELETE("/users/delete", server.handleMissingID)
+	authRoutes.PUT("/users/unlock/:username", server.unlockUser)
+	authRoutes.PUT("/users/unlock", server.handleMissingUsername)
+	authRoutes.POST("/users/mfa/totp", server.enrollTotp)
+	authRoutes.POST("/users/mfa/totp/confirm", server.confirmTotp)
+	authRoutes.POST("/users/mfa/totp/disable", server.disableTotp)
+	authRoutes.POST("/users/logout", server.logoutUser)
+	authRoutes.GET("/users/external_identities", server.listExternalIdentities)
+	authRoutes.POST("/users/external_identities/:provider", server.linkExternalIdentity)
+	authRoutes.DELETE("/users/external_identities/:provider", server.unlinkExternalIdentity)
+
+	authRoutes.POST("/users/api_keys", server.createApiKey)
+	authRoutes.GET("/users/api_keys", server.listApiKeys)
+	authRoutes.DELETE("/users/api_keys/:id", server.revokeApiKey)
+
+	authRoutes.GET("/oauth/userinfo", server.getOAuthUserInfo)
+
+	authRoutes.GET("/sessions/list/:username", server.listSessions)
+	authRoutes.GET("/sessions/list", server.handleMissingUsername)
+	authRoutes.PUT("/sessions/revoke/:id", server.revokeSession)
+	authRoutes.PUT("/sessions/revoke", server.handleMissingID)
+	authRoutes.PUT("/sessions/revoke_all/:username", server.revokeAllSessions)
+	authRoutes.PUT("/sessions/revoke_all", server.handleMissingUsername)
 
 	server.router = router
+	return nil
 }
 
 // StartServer starts a new HTTP server on the specified address.
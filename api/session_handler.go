@@ -0,0 +1,207 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/apikey"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type sessionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	UserAgent string    `json:"user_agent"`
+	ClientIP  string    `json:"client_ip"`
+	IsBlocked bool      `json:"is_blocked"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newSessionResponse(session db.UserSvcSession) sessionResponse {
+	return sessionResponse{
+		ID:        session.ID,
+		Username:  session.Username,
+		UserAgent: session.UserAgent,
+		ClientIP:  session.ClientIp,
+		IsBlocked: session.IsBlocked,
+		ExpiresAt: session.ExpiresAt,
+		CreatedAt: session.CreatedAt,
+	}
+}
+
+type listSessionsRequest struct {
+	Username string `uri:"username" binding:"required,min=3"`
+}
+
+// listSessions returns the active sessions, i.e. the signed in devices, of a user.
+func (server *Server) listSessions(ctx *gin.Context) {
+	var req listSessionsRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanManageSessions(req.Username)
+	}) {
+		return
+	}
+
+	sessions, err := server.store.ListActiveSessionsByUsername(ctx, req.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]sessionResponse, len(sessions))
+	for i, session := range sessions {
+		rsp[i] = newSessionResponse(session)
+	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type revokeSessionRequest struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+type revokeSessionsResponse struct {
+	RevokedSessions int64 `json:"revoked_sessions"`
+}
+
+// revokeSession signs a device out by blocking the token family of the given session,
+// so that none of the refresh tokens issued to the device can be used anymore.
+func (server *Server) revokeSession(ctx *gin.Context) {
+	var req revokeSessionRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	session, err := server.store.GetSession(ctx, uuid.MustParse(req.ID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			err := errors.New("session not found")
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if !server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanManageSessions(session.Username)
+	}) {
+		return
+	}
+
+	revoked, err := server.store.BlockSessionFamily(ctx, session.FamilyID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, revokeSessionsResponse{RevokedSessions: revoked})
+}
+
+type revokeAllSessionsRequest struct {
+	Username string `uri:"username" binding:"required,min=3"`
+}
+
+// revokeAllSessions signs a user out of all devices. Admins use it to force the logout of a compromised account.
+func (server *Server) revokeAllSessions(ctx *gin.Context) {
+	var req revokeAllSessionsRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanManageSessions(req.Username)
+	}) {
+		return
+	}
+
+	revoked, err := server.store.BlockSessionsByUsername(ctx, req.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// Blocking the sessions only stops the renewal, the issued access tokens are revoked as well
+	err = server.revocations.RevokeUserTokens(ctx, req.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, revokeSessionsResponse{RevokedSessions: revoked})
+}
+
+type logoutUserRequest struct {
+	// RefreshToken is optional, the token family of its session is revoked along with the access token.
+	RefreshToken string `json:"refresh_token"`
+}
+
+// logoutUser revokes the access token of the request, so that it can't be used until it expires,
+// and signs the device of the given refresh token out.
+func (server *Server) logoutUser(ctx *gin.Context) {
+	var req logoutUserRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
+	authPayload := authorizationPayload(ctx)
+	if apikey.IsAPIKey(authPayload) {
+		err := errors.New("API keys can't log out, revoke the API key instead")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var session db.UserSvcSession
+	if req.RefreshToken != "" {
+		refreshPayload, err := server.tokenMaker.VerifyRefreshToken(req.RefreshToken)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+
+		session, err = server.store.GetSession(ctx, refreshPayload.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) && !errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		// Users can only sign out their own sessions
+		if err != nil || session.Username != authPayload.Username {
+			err := errors.New("session not found")
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
+	if err := server.revocations.RevokeToken(ctx, authPayload); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	var revoked int64
+	if req.RefreshToken != "" {
+		var err error
+		revoked, err = server.store.BlockSessionFamily(ctx, session.FamilyID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, revokeSessionsResponse{RevokedSessions: revoked})
+}
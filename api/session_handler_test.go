@@ -0,0 +1,279 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestListSessionsAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.ID = util.RandomInt(1, 1000)
+	user.RoleID = util.ConvertToInt8(policy.RoleUser)
+
+	other, _ := randomUser(t)
+
+	sessions := []db.UserSvcSession{
+		{ID: uuid.New(), Username: user.Username, RefreshToken: "secret", UserAgent: "curl", ClientIp: "127.0.0.1"},
+		{ID: uuid.New(), Username: user.Username, RefreshToken: "secret", UserAgent: "firefox", ClientIp: "10.0.0.1"},
+	}
+
+	testCases := []struct {
+		name          string
+		username      string
+		buildStubs    func(store *mock_db.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			username: user.Username,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					ListActiveSessionsByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(sessions, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.NotContains(t, recorder.Body.String(), "secret")
+
+				var rsp []sessionResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, len(sessions))
+				require.Equal(t, sessions[1].UserAgent, rsp[1].UserAgent)
+				require.Equal(t, sessions[1].ClientIp, rsp[1].ClientIP)
+			},
+		},
+		{
+			name:     "OtherUser",
+			username: other.Username,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					ListActiveSessionsByUsername(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/sessions/list/%s", tc.username)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, user.Username, 3, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestRevokeAllSessionsAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.ID = util.RandomInt(1, 1000)
+	user.RoleID = util.ConvertToInt8(policy.RoleUser)
+
+	admin, _ := randomUser(t)
+	admin.ID = user.ID + 1
+	admin.RoleID = util.ConvertToInt8(policy.RoleAdmin)
+
+	testCases := []struct {
+		name          string
+		username      string
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mock_db.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "AdminForcesLogout",
+			username: user.Username,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, 1, time.Minute)
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(admin.Username)).
+					Times(1).
+					Return(admin, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleAdmin)).
+					Times(1).
+					Return([]db.UserSvcPermission{{Name: policy.PermissionManageSessions}}, nil)
+				store.EXPECT().
+					BlockSessionsByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(int64(3), nil)
+				store.EXPECT().
+					SetTokenWatermark(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.SetTokenWatermarkParams) (db.UserSvcTokenWatermark, error) {
+						require.Equal(t, user.Username, arg.Username)
+						return db.UserSvcTokenWatermark{Username: arg.Username, NotBefore: arg.NotBefore}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp revokeSessionsResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, int64(3), rsp.RevokedSessions)
+			},
+		},
+		{
+			name:     "UserRevokesOtherUser",
+			username: admin.Username,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, 3, time.Minute)
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					BlockSessionsByUsername(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/sessions/revoke_all/%s", tc.username)
+			request, err := http.NewRequest(http.MethodPut, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestLogoutUserAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	username := util.RandomUsername()
+	accessToken, accessPayload, err := server.tokenMaker.CreateLocalToken(token.Claims{Username: username}, time.Minute)
+	require.NoError(t, err)
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateRefreshToken(token.Claims{Username: username}, time.Hour)
+	require.NoError(t, err)
+	familyID := uuid.New()
+
+	logout := func(accessToken string, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodPost, "/users/logout", strings.NewReader(body))
+		require.NoError(t, err)
+		request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	// The refresh token of another user is rejected and the access token stays valid
+	otherToken, otherPayload, err := server.tokenMaker.CreateRefreshToken(token.Claims{Username: util.RandomUsername()}, time.Hour)
+	require.NoError(t, err)
+	store.EXPECT().
+		GetSession(gomock.Any(), gomock.Eq(otherPayload.ID)).
+		Times(1).
+		Return(db.UserSvcSession{ID: otherPayload.ID, Username: otherPayload.Username}, nil)
+
+	recorder := logout(accessToken, fmt.Sprintf(`{"refresh_token": %q}`, otherToken))
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	// Logging out revokes the access token and the token family of the refresh token
+	store.EXPECT().
+		GetSession(gomock.Any(), gomock.Eq(refreshPayload.ID)).
+		Times(1).
+		Return(db.UserSvcSession{ID: refreshPayload.ID, FamilyID: familyID, Username: username}, nil)
+	store.EXPECT().
+		RevokeToken(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.RevokeTokenParams) error {
+			require.Equal(t, accessPayload.ID, arg.ID)
+			require.Equal(t, username, arg.Username)
+			require.WithinDuration(t, accessPayload.ExpiredAt, arg.ExpiresAt, time.Second)
+			return nil
+		})
+	store.EXPECT().
+		BlockSessionFamily(gomock.Any(), gomock.Eq(familyID)).
+		Times(1).
+		Return(int64(2), nil)
+
+	recorder = logout(accessToken, fmt.Sprintf(`{"refresh_token": %q}`, refreshToken))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp revokeSessionsResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.Equal(t, int64(2), rsp.RevokedSessions)
+
+	// The revoked access token is rejected right away
+	recorder = logout(accessToken, "")
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	require.Contains(t, recorder.Body.String(), "token has been revoked")
+}
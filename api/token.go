@@ -2,11 +2,16 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
 )
 
 type renewAccessTokenRequest struct {
@@ -14,8 +19,11 @@ type renewAccessTokenRequest struct {
 }
 
 type renewAccessTokenResponse struct {
-	AccessToken          string    `json:"access_token"`
-	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+	SessionID             uuid.UUID `json:"session_id"`
+	AccessToken           string    `json:"access_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
 }
 
 func (server *Server) renewAccessToken(ctx *gin.Context) {
@@ -25,15 +33,22 @@ func (server *Server) renewAccessToken(ctx *gin.Context) {
 		return
 	}
 
-	refreshPayload, err := server.localTokenMaker.VerifyLocalToken(req.RefreshToken)
+	refreshPayload, err := server.tokenMaker.VerifyRefreshToken(req.RefreshToken)
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
 	}
 
+	// Refresh tokens of OAuth clients are limited to their scopes and can only be renewed at the token endpoint
+	if refreshPayload.ClientID != "" {
+		err := fmt.Errorf("refresh token was issued to an OAuth client")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
 	session, err := server.store.GetSession(ctx, refreshPayload.ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
 			ctx.JSON(http.StatusNotFound, errorResponse(err))
 			return
 		}
@@ -41,6 +56,15 @@ func (server *Server) renewAccessToken(ctx *gin.Context) {
 		return
 	}
 
+	// A refresh token can only be exchanged once. Presenting a rotated token again means
+	// that it has been stolen, so the whole token family is revoked.
+	if session.ReplacedBy.Valid {
+		server.revokeSessionFamily(ctx, session)
+		err := fmt.Errorf("refresh token has already been used")
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+
 	if session.IsBlocked {
 		err := fmt.Errorf("session is blocked")
 		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
@@ -65,18 +89,87 @@ func (server *Server) renewAccessToken(ctx *gin.Context) {
 		return
 	}
 
-	accessToken, accessPayload, err := server.localTokenMaker.CreateLocalToken(
-		refreshPayload.Username,
-		server.config.AccessTokenDuration,
+	// The claims are loaded again, so that a changed role takes effect with the next access token
+	user, err := server.store.GetUserByValue(ctx, session.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err := fmt.Errorf("user of the session does not exist")
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	claims, err := server.tokenClaims(ctx, user)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	accessToken, accessPayload, err := server.createAccessToken(claims)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	refreshToken, newRefreshPayload, err := server.tokenMaker.CreateRefreshToken(
+		claims,
+		server.config.RefreshTokenDuration,
 	)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
+	result, err := server.store.RotateSessionTx(ctx, db.RotateSessionTxParams{
+		OldSessionID: session.ID,
+		NewSession: db.CreateSessionParams{
+			ID:           newRefreshPayload.ID,
+			FamilyID:     session.FamilyID,
+			Username:     session.Username,
+			RefreshToken: refreshToken,
+			UserAgent:    ctx.Request.UserAgent(),
+			ClientIp:     ctx.ClientIP(),
+			IsBlocked:    false,
+			ExpiresAt:    newRefreshPayload.ExpiredAt,
+		},
+	})
+	if err != nil {
+		// Another request rotated the same refresh token in the meantime
+		if errors.Is(err, db.ErrSessionAlreadyRotated) {
+			server.revokeSessionFamily(ctx, session)
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
 	rsp := renewAccessTokenResponse{
-		AccessToken:          accessToken,
-		AccessTokenExpiresAt: accessPayload.ExpiredAt,
+		SessionID:             result.Session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: newRefreshPayload.ExpiredAt,
 	}
 	ctx.JSON(http.StatusOK, rsp)
 }
+
+// revokeSessionFamily blocks all sessions of the token family of the given session and reports the reuse of its refresh token.
+func (server *Server) revokeSessionFamily(ctx *gin.Context, session db.UserSvcSession) {
+	revoked, err := server.store.BlockSessionFamily(ctx, session.FamilyID)
+
+	event := log.Warn()
+	if err != nil {
+		event = log.Error().Err(err)
+	}
+	event.Str("event", "refresh_token_reuse").
+		Str("username", session.Username).
+		Str("session_id", session.ID.String()).
+		Str("family_id", session.FamilyID.String()).
+		Str("client_ip", ctx.ClientIP()).
+		Str("user_agent", ctx.Request.UserAgent()).
+		Int64("revoked_sessions", revoked).
+		Msg("security: reuse of a rotated refresh token detected, revoking token family")
+}
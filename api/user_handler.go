@@ -2,17 +2,21 @@ package api
 
 import (
 	"database/sql"
-	"encoding/base64"
 	"errors"
 	"net/http"
 	"time"
 
 	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/mail"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
 	"github.com/Streamfair/streamfair_user_svc/util"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog/log"
 )
 
 type createUserRequest struct {
@@ -21,8 +25,6 @@ type createUserRequest struct {
 	Email       string `json:"email" binding:"required,email"`
 	Password    string `json:"password" binding:"required,min=8,max=64"`
 	CountryCode string `json:"country_code" binding:"required,iso3166_1_alpha2"`
-	RoleID      int64  `json:"role_id" binding:"required,min=1,max=3"`
-	Status      string `json:"status" binding:"required,oneof=active inactive"`
 }
 type userResponse struct {
 	ID                int64     `json:"id"`
@@ -65,26 +67,38 @@ func (server *Server) createUser(ctx *gin.Context) {
 		return
 	}
 
-	byteHash, err := util.HashPassword(req.Password)
+	hashedPassword, err := util.HashPassword(req.Password, server.config.Argon2idParams())
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	hashedPassword := base64.StdEncoding.EncodeToString(byteHash.Hash)
-	passwordSalt := base64.StdEncoding.EncodeToString(byteHash.Salt)
 
-	arg := db.CreateUserParams{
-		Username:     req.Username,
-		FullName:     req.FullName,
-		Email:        req.Email,
-		PasswordHash: hashedPassword,
-		PasswordSalt: passwordSalt,
-		CountryCode:  req.CountryCode,
-		RoleID:       util.ConvertToInt8(req.RoleID),
-		Status:       util.ConvertToText(req.Status),
+	secretCode, err := util.GenerateSecretCode()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// New users stay inactive until they verified their email address. They always get the user role,
+	// other roles can only be assigned by users allowed to do so.
+	arg := db.CreateUserTxParams{
+		CreateUserParams: db.CreateUserParams{
+			Username:     req.Username,
+			FullName:     req.FullName,
+			Email:        req.Email,
+			PasswordHash: hashedPassword,
+			CountryCode:  req.CountryCode,
+			RoleID:       util.ConvertToInt8(policy.RoleUser),
+			Status:       util.ConvertToText(util.StatusInactive),
+		},
+		SecretCode: util.HashSecretCode(secretCode),
+		ExpiredAt:  time.Now().Add(server.config.VerifyEmailDuration),
+		AfterCreate: func(user db.UserSvcUser, verifyEmail db.UserSvcVerifyEmail) error {
+			return mail.SendVerifyEmail(server.mailer, server.config.VerifyEmailURL, user.FullName, user.Email, verifyEmail.ID, secretCode)
+		},
 	}
 
-	user, err := server.store.CreateUser(ctx, arg)
+	result, err := server.store.CreateUserTx(ctx, arg)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -102,7 +116,7 @@ func (server *Server) createUser(ctx *gin.Context) {
 		return
 	}
 
-	rsp := newUserResponse(user)
+	rsp := newUserResponse(result.User)
 	ctx.JSON(http.StatusOK, rsp)
 }
 
@@ -132,6 +146,12 @@ func (server *Server) getUserByID(ctx *gin.Context) {
 		return
 	}
 
+	if !server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanReadUser(req.ID)
+	}) {
+		return
+	}
+
 	user, err := server.store.GetUserById(ctx, req.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -194,6 +214,13 @@ func (server *Server) getUserByUsername(ctx *gin.Context) {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+
+	if !server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanReadUser(user.ID)
+	}) {
+		return
+	}
+
 	rsp := getUserByUsernameResponse{
 		ID:                user.ID,
 		Username:          user.Username,
@@ -224,6 +251,12 @@ func (server *Server) listUsers(ctx *gin.Context) {
 		return
 	}
 
+	if !server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanListUsers()
+	}) {
+		return
+	}
+
 	arg := db.ListUsersParams{
 		Limit:  req.PageSize,
 		Offset: (req.PageID - 1) * req.PageSize,
@@ -247,10 +280,9 @@ type updateUserRequest struct {
 	FullName          pgtype.Text        `json:"full_name" binding:"omitempty"`
 	Email             pgtype.Text        `json:"email" binding:"omitempty,email"`
 	EmailChangedAt    pgtype.Timestamptz `json:"email_changed_at" binding:"omitempty"`
-	Password          pgtype.Text        `json:"password" binding:"omitempty,min=8,max=64"`
-	PasswordChangedAt pgtype.Timestamptz `json:"password_changed_at" binding:"omitempty"`
+	Password          pgtype.Text        `json:"password"`
 	CountryCode       pgtype.Text        `json:"country_code" binding:"omitempty,iso3166_1_alpha2"`
-	RoleID            pgtype.Int8        `json:"role_id" binding:"omitempty,min=1,max=3"`
+	RoleID            pgtype.Int8        `json:"role_id" binding:"omitempty"`
 	Status            pgtype.Text        `json:"status" binding:"omitempty,oneof=active inactive"`
 }
 
@@ -267,35 +299,64 @@ func (server *Server) updateUser(ctx *gin.Context) {
 		return
 	}
 
-	byteHash, err := util.HashPassword(req.Password.String)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	// Passwords are changed via the change password endpoint, which requires the old password
+	if req.Password.Valid {
+		err := errors.New("password can't be updated, use the change password endpoint")
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// Changing the role or the status of a user always requires the permission to do so
+	if !server.authorize(ctx, func(subject *policy.Subject) error {
+		if err := subject.CanUpdateUser(uri.ID); err != nil {
+			return err
+		}
+		if req.RoleID.Valid {
+			if err := subject.CanAssignRole(); err != nil {
+				return err
+			}
+		}
+		if req.Status.Valid {
+			return subject.CanChangeStatus()
+		}
+		return nil
+	}) {
 		return
 	}
-	hashedPassword := base64.StdEncoding.EncodeToString(byteHash.Hash)
-	passwordSalt := base64.StdEncoding.EncodeToString(byteHash.Salt)
 
 	arg := db.UpdateUserParams{
+		ID:                uri.ID,
 		Username:          req.Username,
 		UsernameChangedAt: pgtype.Timestamptz{Time: time.Now()},
 		FullName:          req.FullName,
 		Email:             req.Email,
 		EmailChangedAt:    pgtype.Timestamptz{Time: time.Now()},
-		PasswordHash:      pgtype.Text{String: hashedPassword},
-		PasswordSalt:      pgtype.Text{String: passwordSalt},
-		PasswordChangedAt: pgtype.Timestamptz{Time: time.Now()},
 		CountryCode:       req.CountryCode,
 		RoleID:            req.RoleID,
 		Status:            req.Status,
 	}
 
-	user, err := server.store.UpdateUser(ctx, arg)
+	secretCode, err := util.GenerateSecretCode()
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, user)
+	// A changed email address has to be verified again, the code is sent to the new address
+	result, err := server.store.UpdateUserTx(ctx, db.UpdateUserTxParams{
+		UpdateUserParams: arg,
+		SecretCode:       util.HashSecretCode(secretCode),
+		ExpiredAt:        time.Now().Add(server.config.VerifyEmailDuration),
+		AfterEmailChange: func(user db.UserSvcUser, verifyEmail db.UserSvcVerifyEmail) error {
+			return mail.SendVerifyEmail(server.mailer, server.config.VerifyEmailURL, user.FullName, user.Email, verifyEmail.ID, secretCode)
+		},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result.User)
 }
 
 type deleteUserRequest struct {
@@ -309,7 +370,13 @@ func (server *Server) deleteUser(ctx *gin.Context) {
 		return
 	}
 
-	_, err := server.store.GetUserById(ctx, req.ID)
+	if !server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanDeleteUsers()
+	}) {
+		return
+	}
+
+	user, err := server.store.GetUserById(ctx, req.ID)
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, errorResponse(err))
 		return
@@ -321,6 +388,11 @@ func (server *Server) deleteUser(ctx *gin.Context) {
 		return
 	}
 
+	// The access tokens of the deleted user must not be accepted until they expire
+	if err := server.revocations.RevokeUserTokens(ctx, user.Username); err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to revoke access tokens of deleted user")
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{"status": "user deleted successfully!"})
 }
 
@@ -345,53 +417,83 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		return
 	}
 
+	if err := server.loginGuard.Check(ctx, req.Username, ctx.ClientIP()); err != nil {
+		server.handleLockoutError(ctx, err)
+		return
+	}
+
 	user, err := server.store.GetUserByValue(ctx, req.Username)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, pgx.ErrNoRows) {
+			// Spend the time of a password check, so that unknown usernames can't be told apart by the response time
+			_, _ = util.HashPassword(req.Password, server.config.Argon2idParams())
+			server.rejectLogin(ctx, req.Username)
 			return
 		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	byteHash, err := base64.StdEncoding.DecodeString(user.PasswordHash)
+	err = util.ComparePassword(user.PasswordHash, user.PasswordSalt, req.Password)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		server.rejectLogin(ctx, req.Username)
 		return
 	}
-	byteSalt, err := base64.StdEncoding.DecodeString(user.PasswordSalt)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+
+	if user.Status.String != util.StatusActive {
+		err := errors.New("user account is not active, the email address has to be verified first")
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
 		return
 	}
 
-	err = util.ComparePassword(byteHash, byteSalt, req.Password)
+	server.rehashPassword(ctx, user, req.Password)
+
+	mfaEnabled, err := server.mfa.IsEnabled(ctx, user.Username)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if mfaEnabled {
+		// The failed attempts are only reset after the second step, so that codes can't be guessed with the password
+		server.requireMfa(ctx, user.Username)
 		return
 	}
 
-	accessToken, accessPayload, err := server.localTokenMaker.CreateLocalToken(
-		user.Username,
-		server.config.AccessTokenDuration,
-	)
+	if err := server.loginGuard.RecordSuccess(ctx, user.Username); err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to reset failed login attempts")
+	}
+
+	rsp, err := server.createLoginSession(ctx, user)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// createLoginSession creates the access and refresh tokens of a successful login together with its session.
+func (server *Server) createLoginSession(ctx *gin.Context, user db.UserSvcUser) (loginUserResponse, error) {
+	claims, err := server.tokenClaims(ctx, user)
+	if err != nil {
+		return loginUserResponse{}, err
+	}
 
-	refreshToken, refreshPayload, err := server.localTokenMaker.CreateLocalToken(
-		user.Username,
+	accessToken, accessPayload, err := server.createAccessToken(claims)
+	if err != nil {
+		return loginUserResponse{}, err
+	}
+
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateRefreshToken(
+		claims,
 		server.config.RefreshTokenDuration,
 	)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
+		return loginUserResponse{}, err
 	}
 
 	session, err := server.store.CreateSession(ctx, db.CreateSessionParams{
 		ID:           refreshPayload.ID,
+		FamilyID:     refreshPayload.ID,
 		Username:     user.Username,
 		RefreshToken: refreshToken,
 		UserAgent:    ctx.Request.UserAgent(),
@@ -400,17 +502,51 @@ func (server *Server) loginUser(ctx *gin.Context) {
 		ExpiresAt:    refreshPayload.ExpiredAt,
 	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
+		return loginUserResponse{}, err
 	}
 
-	rsp := loginUserResponse{
+	return loginUserResponse{
 		SessionID:             session.ID,
 		AccessToken:           accessToken,
 		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
 		RefreshToken:          refreshToken,
 		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
 		User:                  newUserResponse(user),
+	}, nil
+}
+
+// tokenClaims returns the claims of the tokens issued to the user, with the permissions of its role as scopes.
+func (server *Server) tokenClaims(ctx *gin.Context, user db.UserSvcUser) (token.Claims, error) {
+	subject, err := server.policy.SubjectOf(ctx, user)
+	if err != nil {
+		return token.Claims{}, err
+	}
+
+	return token.Claims{
+		UserID:   subject.UserID,
+		Username: subject.Username,
+		RoleID:   subject.RoleID,
+		Scopes:   subject.Permissions(),
+	}, nil
+}
+
+// rehashPassword hashes the password again if the stored hash doesn't use the configured Argon2id parameters.
+// It is called after a successful login, a failure is logged but doesn't fail the login.
+func (server *Server) rehashPassword(ctx *gin.Context, user db.UserSvcUser, password string) {
+	params := server.config.Argon2idParams()
+	if !util.NeedsRehash(user.PasswordHash, params) {
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(password, params)
+	if err == nil {
+		_, err = server.store.RehashUserPassword(ctx, db.RehashUserPasswordParams{
+			NewPasswordHash: hashedPassword,
+			Username:        user.Username,
+			OldPasswordHash: user.PasswordHash,
+		})
+	}
+	if err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to rehash password")
 	}
-	ctx.JSON(http.StatusOK, rsp)
 }
@@ -3,25 +3,32 @@ package api
 import (
 	"bytes"
 	"database/sql"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
 	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
 	"github.com/Streamfair/streamfair_user_svc/util"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestCreateUserAPI(t *testing.T) {
 	user, password := randomUser(t)
+	user.Status = util.ConvertToText(util.StatusInactive)
+	user.RoleID = util.ConvertToInt8(policy.RoleUser)
 
 	testCases := []struct {
 		name          string
@@ -37,8 +44,9 @@ func TestCreateUserAPI(t *testing.T) {
 				"email":        user.Email,
 				"password":     password,
 				"country_code": user.CountryCode,
-				"role_id":      user.RoleID,
-				"status":       user.Status,
+				// Clients can't choose their role or activate their account without verifying the email address
+				"role_id": policy.RoleAdmin,
+				"status":  util.StatusActive,
 			},
 			buildStubs: func(store *mock_db.MockStore) {
 				arg := db.CreateUserParams{
@@ -47,12 +55,12 @@ func TestCreateUserAPI(t *testing.T) {
 					Email:       user.Email,
 					CountryCode: user.CountryCode,
 					RoleID:      user.RoleID,
-					Status:      user.Status,
+					Status:      util.ConvertToText(util.StatusInactive),
 				}
 				store.EXPECT().
-					CreateUser(gomock.Any(), EqCreateUserParams(arg, password)).
+					CreateUserTx(gomock.Any(), EqCreateUserTxParams(arg, password, user)).
 					Times(1).
-					Return(user, nil)
+					Return(db.CreateUserTxResult{User: user}, nil)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -67,14 +75,12 @@ func TestCreateUserAPI(t *testing.T) {
 				"email":        user.Email,
 				"password":     password,
 				"country_code": user.CountryCode,
-				"role_id":      user.RoleID,
-				"status":       user.Status,
 			},
 			buildStubs: func(store *mock_db.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.UserSvcUser{}, sql.ErrConnDone)
+					Return(db.CreateUserTxResult{}, sql.ErrConnDone)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, recorder.Code)
@@ -88,14 +94,12 @@ func TestCreateUserAPI(t *testing.T) {
 				"email":        user.Email,
 				"password":     password,
 				"country_code": user.CountryCode,
-				"role_id":      user.RoleID,
-				"status":       user.Status,
 			},
 			buildStubs: func(store *mock_db.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(1).
-					Return(db.UserSvcUser{}, &pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"})
+					Return(db.CreateUserTxResult{}, &pgconn.PgError{Code: "23505", Message: "duplicate key value violates unique constraint"})
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusConflict, recorder.Code)
@@ -109,12 +113,10 @@ func TestCreateUserAPI(t *testing.T) {
 				"email":        user.Email,
 				"password":     password,
 				"country_code": user.CountryCode,
-				"role_id":      user.RoleID,
-				"status":       user.Status,
 			},
 			buildStubs: func(store *mock_db.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
@@ -129,12 +131,10 @@ func TestCreateUserAPI(t *testing.T) {
 				"email":        "invalid_email",
 				"password":     password,
 				"country_code": user.CountryCode,
-				"role_id":      user.RoleID,
-				"status":       user.Status,
 			},
 			buildStubs: func(store *mock_db.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
@@ -149,12 +149,10 @@ func TestCreateUserAPI(t *testing.T) {
 				"email":        user.Email,
 				"password":     "123",
 				"country_code": user.CountryCode,
-				"role_id":      user.RoleID,
-				"status":       user.Status,
 			},
 			buildStubs: func(store *mock_db.MockStore) {
 				store.EXPECT().
-					CreateUser(gomock.Any(), gomock.Any()).
+					CreateUserTx(gomock.Any(), gomock.Any()).
 					Times(0)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
@@ -189,9 +187,7 @@ func TestCreateUserAPI(t *testing.T) {
 
 func randomUser(t *testing.T) (user db.UserSvcUser, password string) {
 	password = util.RandomString(8)
-	byteHash, err := util.HashPassword(password)
-	hashedPassword := base64.StdEncoding.EncodeToString(byteHash.Hash)
-	passwordSalt := base64.StdEncoding.EncodeToString(byteHash.Salt)
+	hashedPassword, err := util.HashPassword(password, util.Argon2idParams{})
 	require.NoError(t, err)
 
 	user = db.UserSvcUser{
@@ -199,7 +195,6 @@ func randomUser(t *testing.T) (user db.UserSvcUser, password string) {
 		FullName:     util.RandomString(8),
 		Email:        util.RandomEmail(),
 		PasswordHash: hashedPassword,
-		PasswordSalt: passwordSalt,
 		CountryCode:  util.RandomCountryCode(),
 		RoleID:       util.ConvertToInt8(util.RandomInt(1, 3)),
 		Status:       util.ConvertToText("active"),
@@ -245,6 +240,95 @@ func TestLoginUserAPI(t *testing.T) {
 					Times(1).
 					Return(user, nil)
 
+				store.EXPECT().
+					GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserSvcTotpSecret{}, pgx.ErrNoRows)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(user.RoleID.Int64)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "RehashOutdatedHash",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				outdatedHash, err := util.HashPassword(password, util.Argon2idParams{Time: 1, Memory: 64, Threads: 1})
+				require.NoError(t, err)
+				outdatedUser := user
+				outdatedUser.PasswordHash = outdatedHash
+
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(outdatedUser, nil)
+				store.EXPECT().
+					RehashUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RehashUserPasswordParams) (int64, error) {
+						require.Equal(t, outdatedHash, arg.OldPasswordHash)
+						require.False(t, util.NeedsRehash(arg.NewPasswordHash, util.DefaultArgon2idParams()))
+						require.NoError(t, util.ComparePassword(arg.NewPasswordHash, "", password))
+						return 1, nil
+					})
+				store.EXPECT().
+					GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserSvcTotpSecret{}, pgx.ErrNoRows)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(user.RoleID.Int64)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "UpgradeImportedBcryptHash",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				bcryptHash, err := util.NewBcryptHasher(bcrypt.MinCost).Hash(password)
+				require.NoError(t, err)
+				importedUser := user
+				importedUser.PasswordHash = bcryptHash
+
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(importedUser, nil)
+				store.EXPECT().
+					RehashUserPassword(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RehashUserPasswordParams) (int64, error) {
+						require.Equal(t, bcryptHash, arg.OldPasswordHash)
+						require.True(t, strings.HasPrefix(arg.NewPasswordHash, "$argon2id$"))
+						return 1, nil
+					})
+				store.EXPECT().
+					GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserSvcTotpSecret{}, pgx.ErrNoRows)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(user.RoleID.Int64)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
 				store.EXPECT().
 					CreateSession(gomock.Any(), gomock.Any()).
 					Times(1)
@@ -266,7 +350,30 @@ func TestLoginUserAPI(t *testing.T) {
 					Return(db.UserSvcUser{}, sql.ErrNoRows)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
-				require.Equal(t, http.StatusNotFound, recorder.Code)
+				// Unknown usernames get the same response as wrong passwords
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "invalid credentials")
+			},
+		},
+		{
+			name: "InactiveUser",
+			body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				inactiveUser := user
+				inactiveUser.Status = util.ConvertToText(util.StatusInactive)
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(inactiveUser, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
 			},
 		},
 		{
@@ -283,6 +390,7 @@ func TestLoginUserAPI(t *testing.T) {
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "invalid credentials")
 			},
 		},
 		{
@@ -344,3 +452,209 @@ func TestLoginUserAPI(t *testing.T) {
 		})
 	}
 }
+
+func TestGetUserByIDAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.ID = util.RandomInt(1, 1000)
+	user.RoleID = util.ConvertToInt8(policy.RoleUser)
+
+	admin, _ := randomUser(t)
+	admin.ID = user.ID + 1
+	admin.RoleID = util.ConvertToInt8(policy.RoleAdmin)
+
+	testCases := []struct {
+		name          string
+		userID        int64
+		setupAuth     func(t *testing.T, request *http.Request, tokenMaker token.Maker)
+		buildStubs    func(store *mock_db.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			userID: user.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, 3, time.Minute)
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					GetUserById(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireBodyMatchUser(t, recorder.Body, user)
+			},
+		},
+		{
+			name:   "AdminReadsOtherUser",
+			userID: user.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, admin.Username, 1, time.Minute)
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(admin.Username)).
+					Times(1).
+					Return(admin, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleAdmin)).
+					Times(1).
+					Return([]db.UserSvcPermission{{Name: policy.PermissionReadUsers}}, nil)
+				store.EXPECT().
+					GetUserById(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "UserReadsOtherUser",
+			userID: admin.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+				addAuthorization(t, request, tokenMaker, authorizationTypeBearer, user.Username, 3, time.Minute)
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					GetUserById(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:   "NoAuthorization",
+			userID: user.ID,
+			setupAuth: func(t *testing.T, request *http.Request, tokenMaker token.Maker) {
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserById(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/users/id/%d", tc.userID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			tc.setupAuth(t, request, server.tokenMaker)
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestUpdateUserStatusAPI(t *testing.T) {
+	user, _ := randomUser(t)
+	user.ID = util.RandomInt(1, 1000)
+	user.RoleID = util.ConvertToInt8(policy.RoleUser)
+
+	admin, _ := randomUser(t)
+	admin.ID = user.ID + 1
+	admin.RoleID = util.ConvertToInt8(policy.RoleAdmin)
+
+	testCases := []struct {
+		name       string
+		caller     db.UserSvcUser
+		buildStubs func(store *mock_db.MockStore)
+		statusCode int
+	}{
+		{
+			name:   "AdminChangesStatus",
+			caller: admin,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleAdmin)).
+					Times(1).
+					Return([]db.UserSvcPermission{{Name: policy.PermissionUpdateUsers}}, nil)
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+						require.Equal(t, util.StatusInactive, arg.Status.String)
+						return db.UpdateUserTxResult{User: user}, nil
+					})
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			// Users can't activate their own account without verifying the email address
+			name:   "UserChangesOwnStatus",
+			caller: user,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					UpdateUserTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			statusCode: http.StatusForbidden,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			store.EXPECT().
+				GetUserByValue(gomock.Any(), gomock.Eq(tc.caller.Username)).
+				Times(1).
+				Return(tc.caller, nil)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/users/update/%d", user.ID)
+			request, err := http.NewRequest(http.MethodPut, url, bytes.NewReader([]byte(`{"status":"inactive"}`)))
+			require.NoError(t, err)
+
+			addAuthorization(t, request, server.tokenMaker, authorizationTypeBearer, tc.caller.Username, int32(tc.caller.RoleID.Int64), time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			require.Equal(t, tc.statusCode, recorder.Code)
+		})
+	}
+}
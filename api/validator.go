@@ -0,0 +1,35 @@
+package api
+
+import (
+	"reflect"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// registerNullableTypes lets the binding tags of optional request fields validate the value of the nullable
+// database types instead of the structs themselves. Fields without a value are treated as empty.
+func registerNullableTypes() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterCustomTypeFunc(nullableValue, pgtype.Text{}, pgtype.Int8{}, pgtype.Timestamptz{})
+	}
+}
+
+func nullableValue(field reflect.Value) any {
+	switch value := field.Interface().(type) {
+	case pgtype.Text:
+		if value.Valid {
+			return value.String
+		}
+	case pgtype.Int8:
+		if value.Valid {
+			return value.Int64
+		}
+	case pgtype.Timestamptz:
+		if value.Valid {
+			return value.Time
+		}
+	}
+	return nil
+}
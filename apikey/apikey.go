@@ -0,0 +1,269 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rs/zerolog/log"
+)
+
+// Keys have the form sf_<prefix>_<secret>. The prefix identifies the key and stays visible in listings,
+// only the hash of the whole key is stored.
+const (
+	keyPrefix    = "sf_"
+	prefixLength = 8
+)
+
+// ClientIDPrefix is the prefix of the client ID of the payloads of API keys, followed by the prefix of the key.
+// Like the tokens of OAuth clients, API keys only grant the permissions among their scopes.
+const ClientIDPrefix = "api_key:"
+
+// maxNameLength is the maximum length of the name of a key.
+const maxNameLength = 64
+
+// maxActiveKeys is the maximum number of keys a user can have that are neither revoked nor expired.
+const maxActiveKeys = 25
+
+// lastUsedInterval is the minimum time between two updates of the last use of a key,
+// so that busy bots don't write to the database on every request.
+const lastUsedInterval = time.Minute
+
+var (
+	ErrInvalidKey    = errors.New("API key is invalid")
+	ErrKeyRevoked    = errors.New("API key has been revoked")
+	ErrKeyExpired    = errors.New("API key has expired")
+	ErrUserNotActive = errors.New("user of the API key is not active")
+	ErrInvalidName   = fmt.Errorf("name of the API key must have 1 to %d characters", maxNameLength)
+	ErrInvalidScope  = errors.New("API keys can only be granted permissions of the user")
+	ErrInvalidExpiry = errors.New("expiry of the API key must be in the future")
+	ErrTooManyKeys   = fmt.Errorf("a user can have at most %d active API keys", maxActiveKeys)
+	ErrKeyNotFound   = errors.New("API key not found")
+	ErrOtherKey      = errors.New("API keys can only revoke themselves")
+)
+
+// Manager creates the long-lived API keys of users and authenticates requests made with them.
+type Manager struct {
+	store  db.Store
+	policy *policy.Policy
+}
+
+// NewManager creates a new Manager.
+func NewManager(store db.Store, policy *policy.Policy) *Manager {
+	return &Manager{store: store, policy: policy}
+}
+
+// CreateParams describe a new API key.
+type CreateParams struct {
+	Username string
+	Name     string
+	// Scopes are the permissions granted to the key, they must be permissions of the user.
+	// Keys without scopes are granted all current permissions of the user.
+	Scopes []string
+	// ExpiresAt is the zero time for keys that don't expire.
+	ExpiresAt time.Time
+}
+
+// Create creates a new API key of the user and returns it together with the raw key.
+// The raw key is only known at this point, afterwards only its prefix can be shown to the user.
+func (m *Manager) Create(ctx context.Context, arg CreateParams) (db.UserSvcApiKey, string, error) {
+	if arg.Name == "" || len(arg.Name) > maxNameLength {
+		return db.UserSvcApiKey{}, "", ErrInvalidName
+	}
+	if !arg.ExpiresAt.IsZero() && !arg.ExpiresAt.After(time.Now()) {
+		return db.UserSvcApiKey{}, "", ErrInvalidExpiry
+	}
+
+	subject, err := m.policy.LoadSubject(ctx, arg.Username)
+	if err != nil {
+		return db.UserSvcApiKey{}, "", err
+	}
+
+	scopes := arg.Scopes
+	if len(scopes) == 0 {
+		scopes = subject.Permissions()
+	}
+	for _, scope := range scopes {
+		if !subject.HasPermission(scope) {
+			return db.UserSvcApiKey{}, "", fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+
+	keys, err := m.store.ListApiKeysByUserId(ctx, subject.UserID)
+	if err != nil {
+		return db.UserSvcApiKey{}, "", err
+	}
+	active := 0
+	for _, key := range keys {
+		if isActive(key, time.Now()) {
+			active++
+		}
+	}
+	if active >= maxActiveKeys {
+		return db.UserSvcApiKey{}, "", ErrTooManyKeys
+	}
+
+	// A prefix that is already taken only fails the insert, so a new one is generated a few times
+	for attempt := 0; ; attempt++ {
+		prefix, err := randomPrefix()
+		if err != nil {
+			return db.UserSvcApiKey{}, "", err
+		}
+		secret, err := util.GenerateSecretCode()
+		if err != nil {
+			return db.UserSvcApiKey{}, "", err
+		}
+		rawKey := keyPrefix + prefix + "_" + secret
+
+		key, err := m.store.CreateApiKey(ctx, db.CreateApiKeyParams{
+			UserID:    subject.UserID,
+			Name:      arg.Name,
+			Prefix:    prefix,
+			KeyHash:   util.HashSecretCode(rawKey),
+			Scopes:    scopes,
+			ExpiresAt: arg.ExpiresAt,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == "23505" && attempt < 2 { // unique_violation
+				continue
+			}
+			return db.UserSvcApiKey{}, "", err
+		}
+		return key, rawKey, nil
+	}
+}
+
+// List returns the API keys of the user, including revoked and expired keys.
+func (m *Manager) List(ctx context.Context, userID int64) ([]db.UserSvcApiKey, error) {
+	return m.store.ListApiKeysByUserId(ctx, userID)
+}
+
+// Revoke revokes an API key of the user of the payload. Keys of other users and keys that were already revoked
+// fail with ErrKeyNotFound. A payload of an API key can only revoke the key itself, e.g. when a bot is shut down,
+// so that a leaked key can't take the other keys of the user down, which fails with ErrOtherKey.
+func (m *Manager) Revoke(ctx context.Context, payload *token.Payload, keyID int64) error {
+	if IsAPIKey(payload) {
+		key, err := m.store.GetApiKeyByPrefix(ctx, strings.TrimPrefix(payload.ClientID, ClientIDPrefix))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrKeyNotFound
+			}
+			return err
+		}
+		if key.ID != keyID {
+			return ErrOtherKey
+		}
+	}
+
+	revoked, err := m.store.RevokeApiKey(ctx, db.RevokeApiKeyParams{ID: keyID, UserID: payload.UserID})
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// Authenticate verifies the raw key of a request and returns a payload on behalf of the user of the key.
+// The payload carries the scopes of the key and a client ID with ClientIDPrefix.
+func (m *Manager) Authenticate(ctx context.Context, rawKey, clientIP string) (*token.Payload, error) {
+	prefix, ok := parsePrefix(rawKey)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+
+	key, err := m.store.GetApiKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(util.HashSecretCode(rawKey)), []byte(key.KeyHash)) != 1 {
+		return nil, ErrInvalidKey
+	}
+
+	now := time.Now()
+	if !key.RevokedAt.IsZero() {
+		return nil, ErrKeyRevoked
+	}
+	if !key.ExpiresAt.IsZero() && now.After(key.ExpiresAt) {
+		return nil, ErrKeyExpired
+	}
+
+	user, err := m.store.GetUserById(ctx, key.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status.String != util.StatusActive {
+		return nil, ErrUserNotActive
+	}
+
+	if now.Sub(key.LastUsedAt) >= lastUsedInterval || key.LastUsedIp != clientIP {
+		// A failed update only loses the last use, it doesn't fail the request
+		err := m.store.UpdateApiKeyLastUsed(ctx, db.UpdateApiKeyLastUsedParams{ID: key.ID, LastUsedIp: clientIP})
+		if err != nil {
+			log.Error().Err(err).Str("prefix", key.Prefix).Msg("failed to update last use of API key")
+		}
+	}
+
+	return &token.Payload{
+		UserID:    user.ID,
+		Username:  user.Username,
+		RoleID:    user.RoleID.Int64,
+		Scopes:    key.Scopes,
+		ClientID:  ClientIDPrefix + key.Prefix,
+		IssuedAt:  key.CreatedAt,
+		NotBefore: key.CreatedAt,
+		ExpiredAt: key.ExpiresAt,
+	}, nil
+}
+
+// IsAPIKey reports whether the payload was authenticated with an API key instead of an access token.
+func IsAPIKey(payload *token.Payload) bool {
+	return strings.HasPrefix(payload.ClientID, ClientIDPrefix)
+}
+
+// isActive reports whether the key is neither revoked nor expired.
+func isActive(key db.UserSvcApiKey, now time.Time) bool {
+	return key.RevokedAt.IsZero() && (key.ExpiresAt.IsZero() || now.Before(key.ExpiresAt))
+}
+
+// parsePrefix returns the prefix of a raw key of the form sf_<prefix>_<secret>.
+func parsePrefix(rawKey string) (string, bool) {
+	rest, ok := strings.CutPrefix(rawKey, keyPrefix)
+	if !ok {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(prefix) != prefixLength || secret == "" {
+		return "", false
+	}
+	return prefix, true
+}
+
+// randomPrefix returns a random prefix of lowercase letters and digits.
+func randomPrefix() (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+	random := make([]byte, prefixLength)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	for i, b := range random {
+		random[i] = alphabet[int(b)%len(alphabet)]
+	}
+	return string(random), nil
+}
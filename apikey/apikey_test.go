@@ -0,0 +1,279 @@
+package apikey
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func randomUser() db.UserSvcUser {
+	return db.UserSvcUser{
+		ID:       util.RandomInt(1, 1000),
+		Username: util.RandomUsername(),
+		RoleID:   util.ConvertToInt8(policy.RoleModerator),
+		Status:   util.ConvertToText(util.StatusActive),
+	}
+}
+
+// expectSubject lets the manager load the user with the read and list permissions.
+func expectSubject(store *mock_db.MockStore, user db.UserSvcUser) {
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().
+		ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleModerator)).
+		Times(1).
+		Return([]db.UserSvcPermission{{Name: policy.PermissionReadUsers}, {Name: policy.PermissionListUsers}}, nil)
+}
+
+// createTestKey creates a key of the user through the manager and returns the stored key with the raw key.
+func createTestKey(t *testing.T, manager *Manager, store *mock_db.MockStore, user db.UserSvcUser, scopes []string) (db.UserSvcApiKey, string) {
+	expectSubject(store, user)
+	store.EXPECT().ListApiKeysByUserId(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return([]db.UserSvcApiKey{}, nil)
+	store.EXPECT().
+		CreateApiKey(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateApiKeyParams) (db.UserSvcApiKey, error) {
+			return db.UserSvcApiKey{
+				ID:        1,
+				UserID:    arg.UserID,
+				Name:      arg.Name,
+				Prefix:    arg.Prefix,
+				KeyHash:   arg.KeyHash,
+				Scopes:    arg.Scopes,
+				ExpiresAt: arg.ExpiresAt,
+				CreatedAt: time.Now(),
+			}, nil
+		})
+
+	key, rawKey, err := manager.Create(context.Background(), CreateParams{Username: user.Username, Name: "bot", Scopes: scopes})
+	require.NoError(t, err)
+	return key, rawKey
+}
+
+func TestCreate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager := NewManager(store, policy.NewPolicy(store))
+	user := randomUser()
+
+	key, rawKey := createTestKey(t, manager, store, user, nil)
+	require.True(t, strings.HasPrefix(rawKey, "sf_"+key.Prefix+"_"))
+	require.Len(t, key.Prefix, prefixLength)
+	require.Equal(t, util.HashSecretCode(rawKey), key.KeyHash)
+	require.NotContains(t, key.KeyHash, rawKey)
+	// Keys without scopes get all permissions of the user
+	require.Equal(t, []string{policy.PermissionListUsers, policy.PermissionReadUsers}, key.Scopes)
+
+	key, _ = createTestKey(t, manager, store, user, []string{policy.PermissionReadUsers})
+	require.Equal(t, []string{policy.PermissionReadUsers}, key.Scopes)
+
+	// Permissions the user doesn't have
+	expectSubject(store, user)
+	_, _, err := manager.Create(context.Background(), CreateParams{
+		Username: user.Username,
+		Name:     "bot",
+		Scopes:   []string{policy.PermissionDeleteUsers},
+	})
+	require.ErrorIs(t, err, ErrInvalidScope)
+
+	_, _, err = manager.Create(context.Background(), CreateParams{Username: user.Username})
+	require.ErrorIs(t, err, ErrInvalidName)
+
+	_, _, err = manager.Create(context.Background(), CreateParams{
+		Username:  user.Username,
+		Name:      "bot",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	require.ErrorIs(t, err, ErrInvalidExpiry)
+}
+
+func TestCreateRetriesTakenPrefix(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager := NewManager(store, policy.NewPolicy(store))
+	user := randomUser()
+
+	expectSubject(store, user)
+	store.EXPECT().ListApiKeysByUserId(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return([]db.UserSvcApiKey{}, nil)
+	gomock.InOrder(
+		store.EXPECT().CreateApiKey(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcApiKey{}, &pgconn.PgError{Code: "23505"}),
+		store.EXPECT().CreateApiKey(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcApiKey{ID: 1}, nil),
+	)
+
+	key, _, err := manager.Create(context.Background(), CreateParams{Username: user.Username, Name: "bot"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), key.ID)
+}
+
+func TestCreateLimitsActiveKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager := NewManager(store, policy.NewPolicy(store))
+	user := randomUser()
+
+	keys := make([]db.UserSvcApiKey, maxActiveKeys)
+	expectSubject(store, user)
+	store.EXPECT().ListApiKeysByUserId(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(keys, nil)
+
+	_, _, err := manager.Create(context.Background(), CreateParams{Username: user.Username, Name: "bot"})
+	require.ErrorIs(t, err, ErrTooManyKeys)
+
+	// Revoked keys don't count
+	keys[0].RevokedAt = time.Now()
+	expectSubject(store, user)
+	store.EXPECT().ListApiKeysByUserId(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(keys, nil)
+	store.EXPECT().CreateApiKey(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcApiKey{ID: 1}, nil)
+
+	_, _, err = manager.Create(context.Background(), CreateParams{Username: user.Username, Name: "bot"})
+	require.NoError(t, err)
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager := NewManager(store, policy.NewPolicy(store))
+	user := randomUser()
+
+	key, rawKey := createTestKey(t, manager, store, user, []string{policy.PermissionReadUsers})
+
+	store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Eq(key.Prefix)).Times(1).Return(key, nil)
+	store.EXPECT().GetUserById(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(user, nil)
+	store.EXPECT().
+		UpdateApiKeyLastUsed(gomock.Any(), gomock.Eq(db.UpdateApiKeyLastUsedParams{ID: key.ID, LastUsedIp: "10.0.0.1"})).
+		Times(1).
+		Return(nil)
+
+	payload, err := manager.Authenticate(context.Background(), rawKey, "10.0.0.1")
+	require.NoError(t, err)
+	require.Equal(t, user.ID, payload.UserID)
+	require.Equal(t, user.Username, payload.Username)
+	require.Equal(t, policy.RoleModerator, payload.RoleID)
+	require.Equal(t, []string{policy.PermissionReadUsers}, payload.Scopes)
+	require.True(t, IsAPIKey(payload))
+
+	// Recent uses from the same address aren't written again
+	key.LastUsedAt = time.Now()
+	key.LastUsedIp = "10.0.0.1"
+	store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Eq(key.Prefix)).Times(1).Return(key, nil)
+	store.EXPECT().GetUserById(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(user, nil)
+
+	_, err = manager.Authenticate(context.Background(), rawKey, "10.0.0.1")
+	require.NoError(t, err)
+}
+
+func TestAuthenticateRejectsKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager := NewManager(store, policy.NewPolicy(store))
+	user := randomUser()
+
+	key, rawKey := createTestKey(t, manager, store, user, nil)
+
+	testCases := []struct {
+		name       string
+		rawKey     string
+		buildStubs func()
+		err        error
+	}{
+		{
+			name:       "Malformed",
+			rawKey:     "not-a-key",
+			buildStubs: func() {},
+			err:        ErrInvalidKey,
+		},
+		{
+			name:   "UnknownPrefix",
+			rawKey: rawKey,
+			buildStubs: func() {
+				store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcApiKey{}, pgx.ErrNoRows)
+			},
+			err: ErrInvalidKey,
+		},
+		{
+			name:   "WrongSecret",
+			rawKey: "sf_" + key.Prefix + "_" + util.RandomString(43),
+			buildStubs: func() {
+				store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Any()).Times(1).Return(key, nil)
+			},
+			err: ErrInvalidKey,
+		},
+		{
+			name:   "Revoked",
+			rawKey: rawKey,
+			buildStubs: func() {
+				revoked := key
+				revoked.RevokedAt = time.Now()
+				store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Any()).Times(1).Return(revoked, nil)
+			},
+			err: ErrKeyRevoked,
+		},
+		{
+			name:   "Expired",
+			rawKey: rawKey,
+			buildStubs: func() {
+				expired := key
+				expired.ExpiresAt = time.Now().Add(-time.Minute)
+				store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Any()).Times(1).Return(expired, nil)
+			},
+			err: ErrKeyExpired,
+		},
+		{
+			name:   "UserNotActive",
+			rawKey: rawKey,
+			buildStubs: func() {
+				inactive := user
+				inactive.Status = util.ConvertToText(util.StatusInactive)
+				store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Any()).Times(1).Return(key, nil)
+				store.EXPECT().GetUserById(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(inactive, nil)
+			},
+			err: ErrUserNotActive,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.buildStubs()
+			_, err := manager.Authenticate(context.Background(), tc.rawKey, "10.0.0.1")
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager := NewManager(store, policy.NewPolicy(store))
+
+	store.EXPECT().RevokeApiKey(gomock.Any(), gomock.Eq(db.RevokeApiKeyParams{ID: 1, UserID: 7})).Times(1).Return(int64(1), nil)
+	require.NoError(t, manager.Revoke(context.Background(), &token.Payload{UserID: 7}, 1))
+
+	store.EXPECT().RevokeApiKey(gomock.Any(), gomock.Eq(db.RevokeApiKeyParams{ID: 1, UserID: 8})).Times(1).Return(int64(0), nil)
+	require.ErrorIs(t, manager.Revoke(context.Background(), &token.Payload{UserID: 8}, 1), ErrKeyNotFound)
+}
+
+func TestRevokeWithApiKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager := NewManager(store, policy.NewPolicy(store))
+
+	payload := &token.Payload{UserID: 7, ClientID: ClientIDPrefix + "abcd1234"}
+	store.EXPECT().
+		GetApiKeyByPrefix(gomock.Any(), gomock.Eq("abcd1234")).
+		Times(2).
+		Return(db.UserSvcApiKey{ID: 1, UserID: 7, Prefix: "abcd1234"}, nil)
+
+	// A key can revoke itself but none of the other keys of the user
+	store.EXPECT().RevokeApiKey(gomock.Any(), gomock.Eq(db.RevokeApiKeyParams{ID: 1, UserID: 7})).Times(1).Return(int64(1), nil)
+	require.NoError(t, manager.Revoke(context.Background(), payload, 1))
+	require.ErrorIs(t, manager.Revoke(context.Background(), payload, 2), ErrOtherKey)
+}
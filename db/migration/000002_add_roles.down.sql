@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS "user_svc"."idx_user_role_id";
+
+ALTER TABLE "user_svc"."Users" DROP CONSTRAINT IF EXISTS "Users_role_id_fkey";
+
+DROP TABLE IF EXISTS "user_svc"."RolePermissions" CASCADE;
+DROP TABLE IF EXISTS "user_svc"."Permissions" CASCADE;
+DROP TABLE IF EXISTS "user_svc"."Roles" CASCADE;
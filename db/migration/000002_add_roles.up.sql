@@ -0,0 +1,50 @@
+CREATE TABLE "user_svc"."Roles" (
+  "id" bigserial PRIMARY KEY,
+  "name" varchar UNIQUE NOT NULL,
+  "description" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "user_svc"."Permissions" (
+  "id" bigserial PRIMARY KEY,
+  "name" varchar UNIQUE NOT NULL,
+  "description" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "user_svc"."RolePermissions" (
+  "role_id" bigint NOT NULL,
+  "permission_id" bigint NOT NULL,
+  PRIMARY KEY ("role_id", "permission_id")
+);
+
+ALTER TABLE "user_svc"."RolePermissions" ADD FOREIGN KEY ("role_id") REFERENCES "user_svc"."Roles" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "user_svc"."RolePermissions" ADD FOREIGN KEY ("permission_id") REFERENCES "user_svc"."Permissions" ("id") ON DELETE CASCADE;
+
+INSERT INTO "user_svc"."Roles" ("id", "name", "description") VALUES
+  (1, 'admin', 'Manages all user accounts and their roles'),
+  (2, 'moderator', 'Reads and lists all user accounts'),
+  (3, 'user', 'Reads and updates its own user account');
+
+SELECT setval(pg_get_serial_sequence('"user_svc"."Roles"', 'id'), 3);
+
+INSERT INTO "user_svc"."Permissions" ("name", "description") VALUES
+  ('users:read', 'Read any user account'),
+  ('users:list', 'List all user accounts'),
+  ('users:update', 'Update any user account'),
+  ('users:delete', 'Delete any user account'),
+  ('roles:assign', 'Assign roles to user accounts');
+
+INSERT INTO "user_svc"."RolePermissions" ("role_id", "permission_id")
+SELECT 1, "id" FROM "user_svc"."Permissions";
+
+INSERT INTO "user_svc"."RolePermissions" ("role_id", "permission_id")
+SELECT 2, "id" FROM "user_svc"."Permissions" WHERE "name" IN ('users:read', 'users:list');
+
+UPDATE "user_svc"."Users" SET "role_id" = 3
+WHERE "role_id" IS NULL OR "role_id" NOT IN (SELECT "id" FROM "user_svc"."Roles");
+
+ALTER TABLE "user_svc"."Users" ADD CONSTRAINT "Users_role_id_fkey" FOREIGN KEY ("role_id") REFERENCES "user_svc"."Roles" ("id");
+
+CREATE INDEX "idx_user_role_id" ON "user_svc"."Users" ("role_id");
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS "user_svc"."idx_session_family_id";
+
+ALTER TABLE "user_svc"."Sessions" DROP COLUMN IF EXISTS "replaced_by";
+
+ALTER TABLE "user_svc"."Sessions" DROP COLUMN IF EXISTS "family_id";
@@ -0,0 +1,9 @@
+ALTER TABLE "user_svc"."Sessions" ADD COLUMN "family_id" uuid;
+
+UPDATE "user_svc"."Sessions" SET "family_id" = "id";
+
+ALTER TABLE "user_svc"."Sessions" ALTER COLUMN "family_id" SET NOT NULL;
+
+ALTER TABLE "user_svc"."Sessions" ADD COLUMN "replaced_by" uuid;
+
+CREATE INDEX "idx_session_family_id" ON "user_svc"."Sessions" ("family_id");
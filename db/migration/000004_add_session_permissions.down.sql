@@ -0,0 +1 @@
+DELETE FROM "user_svc"."Permissions" WHERE "name" = 'sessions:manage';
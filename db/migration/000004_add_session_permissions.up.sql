@@ -0,0 +1,5 @@
+INSERT INTO "user_svc"."Permissions" ("name", "description") VALUES
+  ('sessions:manage', 'List and revoke the sessions of any user account');
+
+INSERT INTO "user_svc"."RolePermissions" ("role_id", "permission_id")
+SELECT 1, "id" FROM "user_svc"."Permissions" WHERE "name" = 'sessions:manage';
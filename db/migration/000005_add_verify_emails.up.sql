@@ -0,0 +1,13 @@
+CREATE TABLE "user_svc"."VerifyEmails" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "email" varchar NOT NULL,
+  "secret_code" varchar NOT NULL,
+  "is_used" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "expired_at" timestamptz NOT NULL DEFAULT (now() + interval '15 minutes')
+);
+
+ALTER TABLE "user_svc"."VerifyEmails" ADD FOREIGN KEY ("username") REFERENCES "user_svc"."Users" ("username") ON DELETE CASCADE;
+
+CREATE INDEX "idx_verify_email_username" ON "user_svc"."VerifyEmails" ("username");
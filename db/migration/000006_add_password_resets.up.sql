@@ -0,0 +1,12 @@
+CREATE TABLE "user_svc"."PasswordResets" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "token_hash" varchar UNIQUE NOT NULL,
+  "is_used" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "expired_at" timestamptz NOT NULL
+);
+
+ALTER TABLE "user_svc"."PasswordResets" ADD FOREIGN KEY ("username") REFERENCES "user_svc"."Users" ("username") ON DELETE CASCADE;
+
+CREATE INDEX "idx_password_reset_username" ON "user_svc"."PasswordResets" ("username");
@@ -0,0 +1,3 @@
+DELETE FROM "user_svc"."Permissions" WHERE "name" = 'users:unlock';
+
+DROP TABLE IF EXISTS "user_svc"."LoginAttempts" CASCADE;
@@ -0,0 +1,14 @@
+CREATE TABLE "user_svc"."LoginAttempts" (
+  "key" varchar PRIMARY KEY,
+  "failed_attempts" integer NOT NULL DEFAULT 0,
+  "last_failed_at" timestamptz NOT NULL DEFAULT (now()),
+  "locked_until" timestamptz NOT NULL DEFAULT('0001-01-01 00:00:00Z')
+);
+
+CREATE INDEX "idx_login_attempt_last_failed_at" ON "user_svc"."LoginAttempts" ("last_failed_at");
+
+INSERT INTO "user_svc"."Permissions" ("name", "description") VALUES
+  ('users:unlock', 'Lift the login lockout of a user account or client address');
+
+INSERT INTO "user_svc"."RolePermissions" ("role_id", "permission_id")
+SELECT 1, "id" FROM "user_svc"."Permissions" WHERE "name" = 'users:unlock';
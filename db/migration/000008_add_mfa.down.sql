@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "user_svc"."MfaChallenges" CASCADE;
+DROP TABLE IF EXISTS "user_svc"."RecoveryCodes" CASCADE;
+DROP TABLE IF EXISTS "user_svc"."TotpSecrets" CASCADE;
@@ -0,0 +1,35 @@
+CREATE TABLE "user_svc"."TotpSecrets" (
+  "username" varchar PRIMARY KEY,
+  "secret_ciphertext" varchar NOT NULL,
+  "is_confirmed" boolean NOT NULL DEFAULT false,
+  "last_used_step" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "user_svc"."RecoveryCodes" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "code_hash" varchar NOT NULL,
+  "is_used" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "user_svc"."MfaChallenges" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "token_hash" varchar UNIQUE NOT NULL,
+  "failed_attempts" integer NOT NULL DEFAULT 0,
+  "is_used" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "expired_at" timestamptz NOT NULL
+);
+
+ALTER TABLE "user_svc"."TotpSecrets" ADD FOREIGN KEY ("username") REFERENCES "user_svc"."Users" ("username") ON DELETE CASCADE;
+
+ALTER TABLE "user_svc"."RecoveryCodes" ADD FOREIGN KEY ("username") REFERENCES "user_svc"."Users" ("username") ON DELETE CASCADE;
+
+ALTER TABLE "user_svc"."MfaChallenges" ADD FOREIGN KEY ("username") REFERENCES "user_svc"."Users" ("username") ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX "idx_recovery_code_username_code_hash" ON "user_svc"."RecoveryCodes" ("username", "code_hash");
+
+CREATE INDEX "idx_mfa_challenge_username" ON "user_svc"."MfaChallenges" ("username");
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "user_svc"."TokenWatermarks" CASCADE;
+
+DROP TABLE IF EXISTS "user_svc"."RevokedTokens" CASCADE;
@@ -0,0 +1,14 @@
+CREATE TABLE "user_svc"."RevokedTokens" (
+  "id" uuid PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "revoked_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX "idx_revoked_token_expires_at" ON "user_svc"."RevokedTokens" ("expires_at");
+
+CREATE TABLE "user_svc"."TokenWatermarks" (
+  "username" varchar PRIMARY KEY,
+  "not_before" timestamptz NOT NULL,
+  "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
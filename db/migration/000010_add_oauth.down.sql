@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "user_svc"."OAuthAuthorizationCodes" CASCADE;
+
+DROP TABLE IF EXISTS "user_svc"."OAuthClients" CASCADE;
@@ -0,0 +1,27 @@
+CREATE TABLE "user_svc"."OAuthClients" (
+  "id" varchar PRIMARY KEY,
+  "name" varchar NOT NULL,
+  "secret_hash" varchar NOT NULL DEFAULT '',
+  "redirect_uris" varchar[] NOT NULL DEFAULT '{}',
+  "grant_types" varchar[] NOT NULL DEFAULT '{}',
+  "scopes" varchar[] NOT NULL DEFAULT '{}',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "user_svc"."OAuthAuthorizationCodes" (
+  "code_hash" varchar PRIMARY KEY,
+  "client_id" varchar NOT NULL,
+  "username" varchar NOT NULL,
+  "redirect_uri" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL DEFAULT '{}',
+  "code_challenge" varchar NOT NULL,
+  "is_used" boolean NOT NULL DEFAULT false,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "expired_at" timestamptz NOT NULL
+);
+
+ALTER TABLE "user_svc"."OAuthAuthorizationCodes" ADD FOREIGN KEY ("client_id") REFERENCES "user_svc"."OAuthClients" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "user_svc"."OAuthAuthorizationCodes" ADD FOREIGN KEY ("username") REFERENCES "user_svc"."Users" ("username") ON DELETE CASCADE;
+
+CREATE INDEX "idx_oauth_authorization_code_expired_at" ON "user_svc"."OAuthAuthorizationCodes" ("expired_at");
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "user_svc"."ExternalLoginStates" CASCADE;
+
+DROP TABLE IF EXISTS "user_svc"."ExternalIdentities" CASCADE;
@@ -0,0 +1,28 @@
+CREATE TABLE "user_svc"."ExternalIdentities" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "provider" varchar NOT NULL,
+  "subject" varchar NOT NULL,
+  "email" varchar NOT NULL DEFAULT '',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "user_svc"."ExternalLoginStates" (
+  "state_hash" varchar PRIMARY KEY,
+  "provider" varchar NOT NULL,
+  "nonce" varchar NOT NULL,
+  "code_verifier" varchar NOT NULL,
+  "user_id" bigint,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "expired_at" timestamptz NOT NULL
+);
+
+ALTER TABLE "user_svc"."ExternalIdentities" ADD FOREIGN KEY ("user_id") REFERENCES "user_svc"."Users" ("id") ON DELETE CASCADE;
+
+ALTER TABLE "user_svc"."ExternalLoginStates" ADD FOREIGN KEY ("user_id") REFERENCES "user_svc"."Users" ("id") ON DELETE CASCADE;
+
+CREATE UNIQUE INDEX "idx_external_identity_provider_subject" ON "user_svc"."ExternalIdentities" ("provider", "subject");
+
+CREATE UNIQUE INDEX "idx_external_identity_user_id_provider" ON "user_svc"."ExternalIdentities" ("user_id", "provider");
+
+CREATE INDEX "idx_external_login_state_expired_at" ON "user_svc"."ExternalLoginStates" ("expired_at");
@@ -0,0 +1,17 @@
+CREATE TABLE "user_svc"."ApiKeys" (
+  "id" bigserial PRIMARY KEY,
+  "user_id" bigint NOT NULL,
+  "name" varchar NOT NULL,
+  "prefix" varchar UNIQUE NOT NULL,
+  "key_hash" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL DEFAULT '{}',
+  "expires_at" timestamptz NOT NULL DEFAULT('0001-01-01 00:00:00Z'),
+  "last_used_at" timestamptz NOT NULL DEFAULT('0001-01-01 00:00:00Z'),
+  "last_used_ip" varchar NOT NULL DEFAULT '',
+  "revoked_at" timestamptz NOT NULL DEFAULT('0001-01-01 00:00:00Z'),
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "user_svc"."ApiKeys" ADD FOREIGN KEY ("user_id") REFERENCES "user_svc"."Users" ("id") ON DELETE CASCADE;
+
+CREATE INDEX "idx_api_key_user_id" ON "user_svc"."ApiKeys" ("user_id");
@@ -0,0 +1 @@
+ALTER TABLE "user_svc"."Users" DROP COLUMN IF EXISTS "email_verified_at";
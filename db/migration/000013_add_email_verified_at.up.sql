@@ -0,0 +1,7 @@
+ALTER TABLE "user_svc"."Users" ADD COLUMN "email_verified_at" timestamptz NOT NULL DEFAULT('0001-01-01 00:00:00Z');
+
+UPDATE "user_svc"."Users" AS u SET "email_verified_at" = u."updated_at"
+WHERE EXISTS (
+  SELECT 1 FROM "user_svc"."VerifyEmails" AS v
+  WHERE v."username" = u."username" AND v."email" = u."email" AND v."is_used"
+);
@@ -0,0 +1 @@
+ALTER TABLE "user_svc"."OAuthAuthorizationCodes" DROP COLUMN IF EXISTS "nonce";
@@ -0,0 +1 @@
+ALTER TABLE "user_svc"."OAuthAuthorizationCodes" ADD COLUMN "nonce" varchar NOT NULL DEFAULT '';
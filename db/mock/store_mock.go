@@ -42,107 +42,898 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// ActivateUser mocks base method.
+func (m *MockStore) ActivateUser(ctx context.Context, arg db.ActivateUserParams) (db.UserSvcUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ActivateUser", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ActivateUser indicates an expected call of ActivateUser.
+func (mr *MockStoreMockRecorder) ActivateUser(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ActivateUser", reflect.TypeOf((*MockStore)(nil).ActivateUser), ctx, arg)
+}
+
+// AddPermissionToRole mocks base method.
+func (m *MockStore) AddPermissionToRole(ctx context.Context, arg db.AddPermissionToRoleParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPermissionToRole", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddPermissionToRole indicates an expected call of AddPermissionToRole.
+func (mr *MockStoreMockRecorder) AddPermissionToRole(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPermissionToRole", reflect.TypeOf((*MockStore)(nil).AddPermissionToRole), ctx, arg)
+}
+
+// BlockOtherSessionsByUsername mocks base method.
+func (m *MockStore) BlockOtherSessionsByUsername(ctx context.Context, arg db.BlockOtherSessionsByUsernameParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockOtherSessionsByUsername", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockOtherSessionsByUsername indicates an expected call of BlockOtherSessionsByUsername.
+func (mr *MockStoreMockRecorder) BlockOtherSessionsByUsername(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockOtherSessionsByUsername", reflect.TypeOf((*MockStore)(nil).BlockOtherSessionsByUsername), ctx, arg)
+}
+
+// BlockSession mocks base method.
+func (m *MockStore) BlockSession(ctx context.Context, id uuid.UUID) (db.UserSvcSession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockSession", ctx, id)
+	ret0, _ := ret[0].(db.UserSvcSession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockSession indicates an expected call of BlockSession.
+func (mr *MockStoreMockRecorder) BlockSession(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockSession", reflect.TypeOf((*MockStore)(nil).BlockSession), ctx, id)
+}
+
+// BlockSessionFamily mocks base method.
+func (m *MockStore) BlockSessionFamily(ctx context.Context, familyID uuid.UUID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockSessionFamily", ctx, familyID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockSessionFamily indicates an expected call of BlockSessionFamily.
+func (mr *MockStoreMockRecorder) BlockSessionFamily(ctx, familyID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockSessionFamily", reflect.TypeOf((*MockStore)(nil).BlockSessionFamily), ctx, familyID)
+}
+
+// BlockSessionsByUsername mocks base method.
+func (m *MockStore) BlockSessionsByUsername(ctx context.Context, username string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockSessionsByUsername", ctx, username)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockSessionsByUsername indicates an expected call of BlockSessionsByUsername.
+func (mr *MockStoreMockRecorder) BlockSessionsByUsername(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockSessionsByUsername", reflect.TypeOf((*MockStore)(nil).BlockSessionsByUsername), ctx, username)
+}
+
+// ChangePasswordTx mocks base method.
+func (m *MockStore) ChangePasswordTx(ctx context.Context, arg db.ChangePasswordTxParams) (db.ChangePasswordTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePasswordTx", ctx, arg)
+	ret0, _ := ret[0].(db.ChangePasswordTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangePasswordTx indicates an expected call of ChangePasswordTx.
+func (mr *MockStoreMockRecorder) ChangePasswordTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePasswordTx", reflect.TypeOf((*MockStore)(nil).ChangePasswordTx), ctx, arg)
+}
+
+// ConfirmTotpSecret mocks base method.
+func (m *MockStore) ConfirmTotpSecret(ctx context.Context, arg db.ConfirmTotpSecretParams) (db.UserSvcTotpSecret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTotpSecret", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcTotpSecret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTotpSecret indicates an expected call of ConfirmTotpSecret.
+func (mr *MockStoreMockRecorder) ConfirmTotpSecret(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTotpSecret", reflect.TypeOf((*MockStore)(nil).ConfirmTotpSecret), ctx, arg)
+}
+
+// ConsumeExternalLoginState mocks base method.
+func (m *MockStore) ConsumeExternalLoginState(ctx context.Context, stateHash string) (db.UserSvcExternalLoginState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeExternalLoginState", ctx, stateHash)
+	ret0, _ := ret[0].(db.UserSvcExternalLoginState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumeExternalLoginState indicates an expected call of ConsumeExternalLoginState.
+func (mr *MockStoreMockRecorder) ConsumeExternalLoginState(ctx, stateHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeExternalLoginState", reflect.TypeOf((*MockStore)(nil).ConsumeExternalLoginState), ctx, stateHash)
+}
+
+// CreateApiKey mocks base method.
+func (m *MockStore) CreateApiKey(ctx context.Context, arg db.CreateApiKeyParams) (db.UserSvcApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateApiKey", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateApiKey indicates an expected call of CreateApiKey.
+func (mr *MockStoreMockRecorder) CreateApiKey(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateApiKey", reflect.TypeOf((*MockStore)(nil).CreateApiKey), ctx, arg)
+}
+
+// CreateExternalIdentity mocks base method.
+func (m *MockStore) CreateExternalIdentity(ctx context.Context, arg db.CreateExternalIdentityParams) (db.UserSvcExternalIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateExternalIdentity", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcExternalIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateExternalIdentity indicates an expected call of CreateExternalIdentity.
+func (mr *MockStoreMockRecorder) CreateExternalIdentity(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateExternalIdentity", reflect.TypeOf((*MockStore)(nil).CreateExternalIdentity), ctx, arg)
+}
+
+// CreateExternalLoginState mocks base method.
+func (m *MockStore) CreateExternalLoginState(ctx context.Context, arg db.CreateExternalLoginStateParams) (db.UserSvcExternalLoginState, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateExternalLoginState", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcExternalLoginState)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateExternalLoginState indicates an expected call of CreateExternalLoginState.
+func (mr *MockStoreMockRecorder) CreateExternalLoginState(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateExternalLoginState", reflect.TypeOf((*MockStore)(nil).CreateExternalLoginState), ctx, arg)
+}
+
+// CreateExternalUserTx mocks base method.
+func (m *MockStore) CreateExternalUserTx(ctx context.Context, arg db.CreateExternalUserTxParams) (db.CreateExternalUserTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateExternalUserTx", ctx, arg)
+	ret0, _ := ret[0].(db.CreateExternalUserTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateExternalUserTx indicates an expected call of CreateExternalUserTx.
+func (mr *MockStoreMockRecorder) CreateExternalUserTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateExternalUserTx", reflect.TypeOf((*MockStore)(nil).CreateExternalUserTx), ctx, arg)
+}
+
+// CreateMfaChallenge mocks base method.
+func (m *MockStore) CreateMfaChallenge(ctx context.Context, arg db.CreateMfaChallengeParams) (db.UserSvcMfaChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMfaChallenge", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcMfaChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateMfaChallenge indicates an expected call of CreateMfaChallenge.
+func (mr *MockStoreMockRecorder) CreateMfaChallenge(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMfaChallenge", reflect.TypeOf((*MockStore)(nil).CreateMfaChallenge), ctx, arg)
+}
+
+// CreateOAuthAuthorizationCode mocks base method.
+func (m *MockStore) CreateOAuthAuthorizationCode(ctx context.Context, arg db.CreateOAuthAuthorizationCodeParams) (db.UserSvcOAuthAuthorizationCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOAuthAuthorizationCode", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcOAuthAuthorizationCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateOAuthAuthorizationCode indicates an expected call of CreateOAuthAuthorizationCode.
+func (mr *MockStoreMockRecorder) CreateOAuthAuthorizationCode(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOAuthAuthorizationCode", reflect.TypeOf((*MockStore)(nil).CreateOAuthAuthorizationCode), ctx, arg)
+}
+
+// CreatePasswordReset mocks base method.
+func (m *MockStore) CreatePasswordReset(ctx context.Context, arg db.CreatePasswordResetParams) (db.UserSvcPasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePasswordReset", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcPasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePasswordReset indicates an expected call of CreatePasswordReset.
+func (mr *MockStoreMockRecorder) CreatePasswordReset(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordReset", reflect.TypeOf((*MockStore)(nil).CreatePasswordReset), ctx, arg)
+}
+
+// CreatePermission mocks base method.
+func (m *MockStore) CreatePermission(ctx context.Context, arg db.CreatePermissionParams) (db.UserSvcPermission, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePermission", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcPermission)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePermission indicates an expected call of CreatePermission.
+func (mr *MockStoreMockRecorder) CreatePermission(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePermission", reflect.TypeOf((*MockStore)(nil).CreatePermission), ctx, arg)
+}
+
+// CreateRecoveryCode mocks base method.
+func (m *MockStore) CreateRecoveryCode(ctx context.Context, arg db.CreateRecoveryCodeParams) (db.UserSvcRecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRecoveryCode", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcRecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRecoveryCode indicates an expected call of CreateRecoveryCode.
+func (mr *MockStoreMockRecorder) CreateRecoveryCode(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRecoveryCode", reflect.TypeOf((*MockStore)(nil).CreateRecoveryCode), ctx, arg)
+}
+
+// CreateRole mocks base method.
+func (m *MockStore) CreateRole(ctx context.Context, arg db.CreateRoleParams) (db.UserSvcRole, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRole", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcRole)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRole indicates an expected call of CreateRole.
+func (mr *MockStoreMockRecorder) CreateRole(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRole", reflect.TypeOf((*MockStore)(nil).CreateRole), ctx, arg)
+}
+
 // CreateSession mocks base method.
 func (m *MockStore) CreateSession(ctx context.Context, arg db.CreateSessionParams) (db.UserSvcSession, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateSession", ctx, arg)
-	ret0, _ := ret[0].(db.UserSvcSession)
+	ret := m.ctrl.Call(m, "CreateSession", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcSession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockStoreMockRecorder) CreateSession(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockStore)(nil).CreateSession), ctx, arg)
+}
+
+// CreateTotpSecret mocks base method.
+func (m *MockStore) CreateTotpSecret(ctx context.Context, arg db.CreateTotpSecretParams) (db.UserSvcTotpSecret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTotpSecret", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcTotpSecret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTotpSecret indicates an expected call of CreateTotpSecret.
+func (mr *MockStoreMockRecorder) CreateTotpSecret(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTotpSecret", reflect.TypeOf((*MockStore)(nil).CreateTotpSecret), ctx, arg)
+}
+
+// CreateUser mocks base method.
+func (m *MockStore) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.UserSvcUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockStoreMockRecorder) CreateUser(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), ctx, arg)
+}
+
+// CreateUserTx mocks base method.
+func (m *MockStore) CreateUserTx(ctx context.Context, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUserTx", ctx, arg)
+	ret0, _ := ret[0].(db.CreateUserTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUserTx indicates an expected call of CreateUserTx.
+func (mr *MockStoreMockRecorder) CreateUserTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserTx", reflect.TypeOf((*MockStore)(nil).CreateUserTx), ctx, arg)
+}
+
+// CreateVerifyEmail mocks base method.
+func (m *MockStore) CreateVerifyEmail(ctx context.Context, arg db.CreateVerifyEmailParams) (db.UserSvcVerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateVerifyEmail", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcVerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateVerifyEmail indicates an expected call of CreateVerifyEmail.
+func (mr *MockStoreMockRecorder) CreateVerifyEmail(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateVerifyEmail", reflect.TypeOf((*MockStore)(nil).CreateVerifyEmail), ctx, arg)
+}
+
+// DeleteExpiredExternalLoginStates mocks base method.
+func (m *MockStore) DeleteExpiredExternalLoginStates(ctx context.Context, cutoff time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredExternalLoginStates", ctx, cutoff)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredExternalLoginStates indicates an expected call of DeleteExpiredExternalLoginStates.
+func (mr *MockStoreMockRecorder) DeleteExpiredExternalLoginStates(ctx, cutoff any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredExternalLoginStates", reflect.TypeOf((*MockStore)(nil).DeleteExpiredExternalLoginStates), ctx, cutoff)
+}
+
+// DeleteExpiredOAuthAuthorizationCodes mocks base method.
+func (m *MockStore) DeleteExpiredOAuthAuthorizationCodes(ctx context.Context, cutoff time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredOAuthAuthorizationCodes", ctx, cutoff)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredOAuthAuthorizationCodes indicates an expected call of DeleteExpiredOAuthAuthorizationCodes.
+func (mr *MockStoreMockRecorder) DeleteExpiredOAuthAuthorizationCodes(ctx, cutoff any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredOAuthAuthorizationCodes", reflect.TypeOf((*MockStore)(nil).DeleteExpiredOAuthAuthorizationCodes), ctx, cutoff)
+}
+
+// DeleteExpiredRevokedTokens mocks base method.
+func (m *MockStore) DeleteExpiredRevokedTokens(ctx context.Context, cutoff time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredRevokedTokens", ctx, cutoff)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredRevokedTokens indicates an expected call of DeleteExpiredRevokedTokens.
+func (mr *MockStoreMockRecorder) DeleteExpiredRevokedTokens(ctx, cutoff any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredRevokedTokens", reflect.TypeOf((*MockStore)(nil).DeleteExpiredRevokedTokens), ctx, cutoff)
+}
+
+// DeleteExternalIdentity mocks base method.
+func (m *MockStore) DeleteExternalIdentity(ctx context.Context, arg db.DeleteExternalIdentityParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExternalIdentity", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExternalIdentity indicates an expected call of DeleteExternalIdentity.
+func (mr *MockStoreMockRecorder) DeleteExternalIdentity(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExternalIdentity", reflect.TypeOf((*MockStore)(nil).DeleteExternalIdentity), ctx, arg)
+}
+
+// DeleteLoginAttempts mocks base method.
+func (m *MockStore) DeleteLoginAttempts(ctx context.Context, keys []string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLoginAttempts", ctx, keys)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteLoginAttempts indicates an expected call of DeleteLoginAttempts.
+func (mr *MockStoreMockRecorder) DeleteLoginAttempts(ctx, keys any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLoginAttempts", reflect.TypeOf((*MockStore)(nil).DeleteLoginAttempts), ctx, keys)
+}
+
+// DeleteRecoveryCodes mocks base method.
+func (m *MockStore) DeleteRecoveryCodes(ctx context.Context, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRecoveryCodes", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRecoveryCodes indicates an expected call of DeleteRecoveryCodes.
+func (mr *MockStoreMockRecorder) DeleteRecoveryCodes(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRecoveryCodes", reflect.TypeOf((*MockStore)(nil).DeleteRecoveryCodes), ctx, username)
+}
+
+// DeleteRole mocks base method.
+func (m *MockStore) DeleteRole(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRole", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRole indicates an expected call of DeleteRole.
+func (mr *MockStoreMockRecorder) DeleteRole(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRole", reflect.TypeOf((*MockStore)(nil).DeleteRole), ctx, id)
+}
+
+// DeleteStaleLoginAttempts mocks base method.
+func (m *MockStore) DeleteStaleLoginAttempts(ctx context.Context, cutoff time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteStaleLoginAttempts", ctx, cutoff)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteStaleLoginAttempts indicates an expected call of DeleteStaleLoginAttempts.
+func (mr *MockStoreMockRecorder) DeleteStaleLoginAttempts(ctx, cutoff any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteStaleLoginAttempts", reflect.TypeOf((*MockStore)(nil).DeleteStaleLoginAttempts), ctx, cutoff)
+}
+
+// DeleteStaleSessions mocks base method.
+func (m *MockStore) DeleteStaleSessions(ctx context.Context, arg db.DeleteStaleSessionsParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteStaleSessions", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteStaleSessions indicates an expected call of DeleteStaleSessions.
+func (mr *MockStoreMockRecorder) DeleteStaleSessions(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteStaleSessions", reflect.TypeOf((*MockStore)(nil).DeleteStaleSessions), ctx, arg)
+}
+
+// DeleteStaleTokenWatermarks mocks base method.
+func (m *MockStore) DeleteStaleTokenWatermarks(ctx context.Context, cutoff time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteStaleTokenWatermarks", ctx, cutoff)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteStaleTokenWatermarks indicates an expected call of DeleteStaleTokenWatermarks.
+func (mr *MockStoreMockRecorder) DeleteStaleTokenWatermarks(ctx, cutoff any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteStaleTokenWatermarks", reflect.TypeOf((*MockStore)(nil).DeleteStaleTokenWatermarks), ctx, cutoff)
+}
+
+// DeleteTotpSecret mocks base method.
+func (m *MockStore) DeleteTotpSecret(ctx context.Context, username string) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTotpSecret", ctx, username)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteTotpSecret indicates an expected call of DeleteTotpSecret.
+func (mr *MockStoreMockRecorder) DeleteTotpSecret(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTotpSecret", reflect.TypeOf((*MockStore)(nil).DeleteTotpSecret), ctx, username)
+}
+
+// DeleteUserById mocks base method.
+func (m *MockStore) DeleteUserById(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserById", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserById indicates an expected call of DeleteUserById.
+func (mr *MockStoreMockRecorder) DeleteUserById(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserById", reflect.TypeOf((*MockStore)(nil).DeleteUserById), ctx, id)
+}
+
+// DeleteUserByValue mocks base method.
+func (m *MockStore) DeleteUserByValue(ctx context.Context, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserByValue", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserByValue indicates an expected call of DeleteUserByValue.
+func (mr *MockStoreMockRecorder) DeleteUserByValue(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserByValue", reflect.TypeOf((*MockStore)(nil).DeleteUserByValue), ctx, username)
+}
+
+// DisableTotpTx mocks base method.
+func (m *MockStore) DisableTotpTx(ctx context.Context, username string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTotpTx", ctx, username)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DisableTotpTx indicates an expected call of DisableTotpTx.
+func (mr *MockStoreMockRecorder) DisableTotpTx(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTotpTx", reflect.TypeOf((*MockStore)(nil).DisableTotpTx), ctx, username)
+}
+
+// EnableTotpTx mocks base method.
+func (m *MockStore) EnableTotpTx(ctx context.Context, arg db.EnableTotpTxParams) (db.EnableTotpTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTotpTx", ctx, arg)
+	ret0, _ := ret[0].(db.EnableTotpTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnableTotpTx indicates an expected call of EnableTotpTx.
+func (mr *MockStoreMockRecorder) EnableTotpTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTotpTx", reflect.TypeOf((*MockStore)(nil).EnableTotpTx), ctx, arg)
+}
+
+// GetApiKeyByPrefix mocks base method.
+func (m *MockStore) GetApiKeyByPrefix(ctx context.Context, prefix string) (db.UserSvcApiKey, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetApiKeyByPrefix", ctx, prefix)
+	ret0, _ := ret[0].(db.UserSvcApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetApiKeyByPrefix indicates an expected call of GetApiKeyByPrefix.
+func (mr *MockStoreMockRecorder) GetApiKeyByPrefix(ctx, prefix any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetApiKeyByPrefix", reflect.TypeOf((*MockStore)(nil).GetApiKeyByPrefix), ctx, prefix)
+}
+
+// GetExternalIdentity mocks base method.
+func (m *MockStore) GetExternalIdentity(ctx context.Context, arg db.GetExternalIdentityParams) (db.UserSvcExternalIdentity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetExternalIdentity", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcExternalIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetExternalIdentity indicates an expected call of GetExternalIdentity.
+func (mr *MockStoreMockRecorder) GetExternalIdentity(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetExternalIdentity", reflect.TypeOf((*MockStore)(nil).GetExternalIdentity), ctx, arg)
+}
+
+// GetLoginAttempts mocks base method.
+func (m *MockStore) GetLoginAttempts(ctx context.Context, keys []string) ([]db.UserSvcLoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginAttempts", ctx, keys)
+	ret0, _ := ret[0].([]db.UserSvcLoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginAttempts indicates an expected call of GetLoginAttempts.
+func (mr *MockStoreMockRecorder) GetLoginAttempts(ctx, keys any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginAttempts", reflect.TypeOf((*MockStore)(nil).GetLoginAttempts), ctx, keys)
+}
+
+// GetMfaChallenge mocks base method.
+func (m *MockStore) GetMfaChallenge(ctx context.Context, tokenHash string) (db.UserSvcMfaChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMfaChallenge", ctx, tokenHash)
+	ret0, _ := ret[0].(db.UserSvcMfaChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMfaChallenge indicates an expected call of GetMfaChallenge.
+func (mr *MockStoreMockRecorder) GetMfaChallenge(ctx, tokenHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMfaChallenge", reflect.TypeOf((*MockStore)(nil).GetMfaChallenge), ctx, tokenHash)
+}
+
+// GetOAuthClient mocks base method.
+func (m *MockStore) GetOAuthClient(ctx context.Context, id string) (db.UserSvcOAuthClient, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOAuthClient", ctx, id)
+	ret0, _ := ret[0].(db.UserSvcOAuthClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOAuthClient indicates an expected call of GetOAuthClient.
+func (mr *MockStoreMockRecorder) GetOAuthClient(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOAuthClient", reflect.TypeOf((*MockStore)(nil).GetOAuthClient), ctx, id)
+}
+
+// GetRoleById mocks base method.
+func (m *MockStore) GetRoleById(ctx context.Context, id int64) (db.UserSvcRole, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoleById", ctx, id)
+	ret0, _ := ret[0].(db.UserSvcRole)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoleById indicates an expected call of GetRoleById.
+func (mr *MockStoreMockRecorder) GetRoleById(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoleById", reflect.TypeOf((*MockStore)(nil).GetRoleById), ctx, id)
+}
+
+// GetRoleByName mocks base method.
+func (m *MockStore) GetRoleByName(ctx context.Context, name string) (db.UserSvcRole, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoleByName", ctx, name)
+	ret0, _ := ret[0].(db.UserSvcRole)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoleByName indicates an expected call of GetRoleByName.
+func (mr *MockStoreMockRecorder) GetRoleByName(ctx, name any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoleByName", reflect.TypeOf((*MockStore)(nil).GetRoleByName), ctx, name)
+}
+
+// GetSession mocks base method.
+func (m *MockStore) GetSession(ctx context.Context, id uuid.UUID) (db.UserSvcSession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSession", ctx, id)
+	ret0, _ := ret[0].(db.UserSvcSession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSession indicates an expected call of GetSession.
+func (mr *MockStoreMockRecorder) GetSession(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSession", reflect.TypeOf((*MockStore)(nil).GetSession), ctx, id)
+}
+
+// GetTotpSecret mocks base method.
+func (m *MockStore) GetTotpSecret(ctx context.Context, username string) (db.UserSvcTotpSecret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTotpSecret", ctx, username)
+	ret0, _ := ret[0].(db.UserSvcTotpSecret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTotpSecret indicates an expected call of GetTotpSecret.
+func (mr *MockStoreMockRecorder) GetTotpSecret(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTotpSecret", reflect.TypeOf((*MockStore)(nil).GetTotpSecret), ctx, username)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockStore) GetUserByEmail(ctx context.Context, email string) (db.UserSvcUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", ctx, email)
+	ret0, _ := ret[0].(db.UserSvcUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockStoreMockRecorder) GetUserByEmail(ctx, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockStore)(nil).GetUserByEmail), ctx, email)
+}
+
+// GetUserById mocks base method.
+func (m *MockStore) GetUserById(ctx context.Context, id int64) (db.UserSvcUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserById", ctx, id)
+	ret0, _ := ret[0].(db.UserSvcUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserById indicates an expected call of GetUserById.
+func (mr *MockStoreMockRecorder) GetUserById(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserById", reflect.TypeOf((*MockStore)(nil).GetUserById), ctx, id)
+}
+
+// GetUserByValue mocks base method.
+func (m *MockStore) GetUserByValue(ctx context.Context, username string) (db.UserSvcUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByValue", ctx, username)
+	ret0, _ := ret[0].(db.UserSvcUser)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateSession indicates an expected call of CreateSession.
-func (mr *MockStoreMockRecorder) CreateSession(ctx, arg any) *gomock.Call {
+// GetUserByValue indicates an expected call of GetUserByValue.
+func (mr *MockStoreMockRecorder) GetUserByValue(ctx, username any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockStore)(nil).CreateSession), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByValue", reflect.TypeOf((*MockStore)(nil).GetUserByValue), ctx, username)
 }
 
-// CreateUser mocks base method.
-func (m *MockStore) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.UserSvcUser, error) {
+// InvalidatePasswordResets mocks base method.
+func (m *MockStore) InvalidatePasswordResets(ctx context.Context, username string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", ctx, arg)
-	ret0, _ := ret[0].(db.UserSvcUser)
+	ret := m.ctrl.Call(m, "InvalidatePasswordResets", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InvalidatePasswordResets indicates an expected call of InvalidatePasswordResets.
+func (mr *MockStoreMockRecorder) InvalidatePasswordResets(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidatePasswordResets", reflect.TypeOf((*MockStore)(nil).InvalidatePasswordResets), ctx, username)
+}
+
+// ListActiveSessionsByUsername mocks base method.
+func (m *MockStore) ListActiveSessionsByUsername(ctx context.Context, username string) ([]db.UserSvcSession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListActiveSessionsByUsername", ctx, username)
+	ret0, _ := ret[0].([]db.UserSvcSession)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateUser indicates an expected call of CreateUser.
-func (mr *MockStoreMockRecorder) CreateUser(ctx, arg any) *gomock.Call {
+// ListActiveSessionsByUsername indicates an expected call of ListActiveSessionsByUsername.
+func (mr *MockStoreMockRecorder) ListActiveSessionsByUsername(ctx, username any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListActiveSessionsByUsername", reflect.TypeOf((*MockStore)(nil).ListActiveSessionsByUsername), ctx, username)
 }
 
-// DeleteUserById mocks base method.
-func (m *MockStore) DeleteUserById(ctx context.Context, id int64) error {
+// ListApiKeysByUserId mocks base method.
+func (m *MockStore) ListApiKeysByUserId(ctx context.Context, userID int64) ([]db.UserSvcApiKey, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteUserById", ctx, id)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "ListApiKeysByUserId", ctx, userID)
+	ret0, _ := ret[0].([]db.UserSvcApiKey)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// DeleteUserById indicates an expected call of DeleteUserById.
-func (mr *MockStoreMockRecorder) DeleteUserById(ctx, id any) *gomock.Call {
+// ListApiKeysByUserId indicates an expected call of ListApiKeysByUserId.
+func (mr *MockStoreMockRecorder) ListApiKeysByUserId(ctx, userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserById", reflect.TypeOf((*MockStore)(nil).DeleteUserById), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListApiKeysByUserId", reflect.TypeOf((*MockStore)(nil).ListApiKeysByUserId), ctx, userID)
 }
 
-// DeleteUserByValue mocks base method.
-func (m *MockStore) DeleteUserByValue(ctx context.Context, username string) error {
+// ListExternalIdentitiesByUserId mocks base method.
+func (m *MockStore) ListExternalIdentitiesByUserId(ctx context.Context, userID int64) ([]db.UserSvcExternalIdentity, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteUserByValue", ctx, username)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "ListExternalIdentitiesByUserId", ctx, userID)
+	ret0, _ := ret[0].([]db.UserSvcExternalIdentity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// DeleteUserByValue indicates an expected call of DeleteUserByValue.
-func (mr *MockStoreMockRecorder) DeleteUserByValue(ctx, username any) *gomock.Call {
+// ListExternalIdentitiesByUserId indicates an expected call of ListExternalIdentitiesByUserId.
+func (mr *MockStoreMockRecorder) ListExternalIdentitiesByUserId(ctx, userID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserByValue", reflect.TypeOf((*MockStore)(nil).DeleteUserByValue), ctx, username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListExternalIdentitiesByUserId", reflect.TypeOf((*MockStore)(nil).ListExternalIdentitiesByUserId), ctx, userID)
 }
 
-// GetSession mocks base method.
-func (m *MockStore) GetSession(ctx context.Context, id uuid.UUID) (db.UserSvcSession, error) {
+// ListPermissions mocks base method.
+func (m *MockStore) ListPermissions(ctx context.Context) ([]db.UserSvcPermission, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetSession", ctx, id)
-	ret0, _ := ret[0].(db.UserSvcSession)
+	ret := m.ctrl.Call(m, "ListPermissions", ctx)
+	ret0, _ := ret[0].([]db.UserSvcPermission)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetSession indicates an expected call of GetSession.
-func (mr *MockStoreMockRecorder) GetSession(ctx, id any) *gomock.Call {
+// ListPermissions indicates an expected call of ListPermissions.
+func (mr *MockStoreMockRecorder) ListPermissions(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSession", reflect.TypeOf((*MockStore)(nil).GetSession), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPermissions", reflect.TypeOf((*MockStore)(nil).ListPermissions), ctx)
 }
 
-// GetUserById mocks base method.
-func (m *MockStore) GetUserById(ctx context.Context, id int64) (db.UserSvcUser, error) {
+// ListPermissionsByRoleId mocks base method.
+func (m *MockStore) ListPermissionsByRoleId(ctx context.Context, roleID int64) ([]db.UserSvcPermission, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserById", ctx, id)
-	ret0, _ := ret[0].(db.UserSvcUser)
+	ret := m.ctrl.Call(m, "ListPermissionsByRoleId", ctx, roleID)
+	ret0, _ := ret[0].([]db.UserSvcPermission)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserById indicates an expected call of GetUserById.
-func (mr *MockStoreMockRecorder) GetUserById(ctx, id any) *gomock.Call {
+// ListPermissionsByRoleId indicates an expected call of ListPermissionsByRoleId.
+func (mr *MockStoreMockRecorder) ListPermissionsByRoleId(ctx, roleID any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserById", reflect.TypeOf((*MockStore)(nil).GetUserById), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPermissionsByRoleId", reflect.TypeOf((*MockStore)(nil).ListPermissionsByRoleId), ctx, roleID)
 }
 
-// GetUserByValue mocks base method.
-func (m *MockStore) GetUserByValue(ctx context.Context, username string) (db.UserSvcUser, error) {
+// ListRevokedTokens mocks base method.
+func (m *MockStore) ListRevokedTokens(ctx context.Context) ([]db.UserSvcRevokedToken, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByValue", ctx, username)
-	ret0, _ := ret[0].(db.UserSvcUser)
+	ret := m.ctrl.Call(m, "ListRevokedTokens", ctx)
+	ret0, _ := ret[0].([]db.UserSvcRevokedToken)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserByValue indicates an expected call of GetUserByValue.
-func (mr *MockStoreMockRecorder) GetUserByValue(ctx, username any) *gomock.Call {
+// ListRevokedTokens indicates an expected call of ListRevokedTokens.
+func (mr *MockStoreMockRecorder) ListRevokedTokens(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByValue", reflect.TypeOf((*MockStore)(nil).GetUserByValue), ctx, username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRevokedTokens", reflect.TypeOf((*MockStore)(nil).ListRevokedTokens), ctx)
+}
+
+// ListRoles mocks base method.
+func (m *MockStore) ListRoles(ctx context.Context) ([]db.UserSvcRole, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListRoles", ctx)
+	ret0, _ := ret[0].([]db.UserSvcRole)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListRoles indicates an expected call of ListRoles.
+func (mr *MockStoreMockRecorder) ListRoles(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListRoles", reflect.TypeOf((*MockStore)(nil).ListRoles), ctx)
+}
+
+// ListTokenWatermarks mocks base method.
+func (m *MockStore) ListTokenWatermarks(ctx context.Context, cutoff time.Time) ([]db.UserSvcTokenWatermark, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTokenWatermarks", ctx, cutoff)
+	ret0, _ := ret[0].([]db.UserSvcTokenWatermark)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTokenWatermarks indicates an expected call of ListTokenWatermarks.
+func (mr *MockStoreMockRecorder) ListTokenWatermarks(ctx, cutoff any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTokenWatermarks", reflect.TypeOf((*MockStore)(nil).ListTokenWatermarks), ctx, cutoff)
 }
 
 // ListUsers mocks base method.
@@ -160,6 +951,35 @@ func (mr *MockStoreMockRecorder) ListUsers(ctx, arg any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockStore)(nil).ListUsers), ctx, arg)
 }
 
+// LockLogin mocks base method.
+func (m *MockStore) LockLogin(ctx context.Context, arg db.LockLoginParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockLogin", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockLogin indicates an expected call of LockLogin.
+func (mr *MockStoreMockRecorder) LockLogin(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockLogin", reflect.TypeOf((*MockStore)(nil).LockLogin), ctx, arg)
+}
+
+// MarkSessionReplaced mocks base method.
+func (m *MockStore) MarkSessionReplaced(ctx context.Context, arg db.MarkSessionReplacedParams) (db.UserSvcSession, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkSessionReplaced", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcSession)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MarkSessionReplaced indicates an expected call of MarkSessionReplaced.
+func (mr *MockStoreMockRecorder) MarkSessionReplaced(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkSessionReplaced", reflect.TypeOf((*MockStore)(nil).MarkSessionReplaced), ctx, arg)
+}
+
 // Ping mocks base method.
 func (m *MockStore) Ping(ctx context.Context, timeout time.Duration) error {
 	m.ctrl.T.Helper()
@@ -174,6 +994,153 @@ func (mr *MockStoreMockRecorder) Ping(ctx, timeout any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockStore)(nil).Ping), ctx, timeout)
 }
 
+// RecordLoginFailure mocks base method.
+func (m *MockStore) RecordLoginFailure(ctx context.Context, arg db.RecordLoginFailureParams) (db.UserSvcLoginAttempt, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginFailure", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcLoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordLoginFailure indicates an expected call of RecordLoginFailure.
+func (mr *MockStoreMockRecorder) RecordLoginFailure(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginFailure", reflect.TypeOf((*MockStore)(nil).RecordLoginFailure), ctx, arg)
+}
+
+// RecordMfaChallengeFailure mocks base method.
+func (m *MockStore) RecordMfaChallengeFailure(ctx context.Context, id int64) (db.UserSvcMfaChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordMfaChallengeFailure", ctx, id)
+	ret0, _ := ret[0].(db.UserSvcMfaChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordMfaChallengeFailure indicates an expected call of RecordMfaChallengeFailure.
+func (mr *MockStoreMockRecorder) RecordMfaChallengeFailure(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordMfaChallengeFailure", reflect.TypeOf((*MockStore)(nil).RecordMfaChallengeFailure), ctx, id)
+}
+
+// RehashUserPassword mocks base method.
+func (m *MockStore) RehashUserPassword(ctx context.Context, arg db.RehashUserPasswordParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RehashUserPassword", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RehashUserPassword indicates an expected call of RehashUserPassword.
+func (mr *MockStoreMockRecorder) RehashUserPassword(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RehashUserPassword", reflect.TypeOf((*MockStore)(nil).RehashUserPassword), ctx, arg)
+}
+
+// RemovePermissionFromRole mocks base method.
+func (m *MockStore) RemovePermissionFromRole(ctx context.Context, arg db.RemovePermissionFromRoleParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemovePermissionFromRole", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemovePermissionFromRole indicates an expected call of RemovePermissionFromRole.
+func (mr *MockStoreMockRecorder) RemovePermissionFromRole(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemovePermissionFromRole", reflect.TypeOf((*MockStore)(nil).RemovePermissionFromRole), ctx, arg)
+}
+
+// ResetPasswordTx mocks base method.
+func (m *MockStore) ResetPasswordTx(ctx context.Context, arg db.ResetPasswordTxParams) (db.ResetPasswordTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPasswordTx", ctx, arg)
+	ret0, _ := ret[0].(db.ResetPasswordTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResetPasswordTx indicates an expected call of ResetPasswordTx.
+func (mr *MockStoreMockRecorder) ResetPasswordTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPasswordTx", reflect.TypeOf((*MockStore)(nil).ResetPasswordTx), ctx, arg)
+}
+
+// RevokeApiKey mocks base method.
+func (m *MockStore) RevokeApiKey(ctx context.Context, arg db.RevokeApiKeyParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeApiKey", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeApiKey indicates an expected call of RevokeApiKey.
+func (mr *MockStoreMockRecorder) RevokeApiKey(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeApiKey", reflect.TypeOf((*MockStore)(nil).RevokeApiKey), ctx, arg)
+}
+
+// RevokeToken mocks base method.
+func (m *MockStore) RevokeToken(ctx context.Context, arg db.RevokeTokenParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockStoreMockRecorder) RevokeToken(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockStore)(nil).RevokeToken), ctx, arg)
+}
+
+// RotateSessionTx mocks base method.
+func (m *MockStore) RotateSessionTx(ctx context.Context, arg db.RotateSessionTxParams) (db.RotateSessionTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateSessionTx", ctx, arg)
+	ret0, _ := ret[0].(db.RotateSessionTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateSessionTx indicates an expected call of RotateSessionTx.
+func (mr *MockStoreMockRecorder) RotateSessionTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateSessionTx", reflect.TypeOf((*MockStore)(nil).RotateSessionTx), ctx, arg)
+}
+
+// SetTokenWatermark mocks base method.
+func (m *MockStore) SetTokenWatermark(ctx context.Context, arg db.SetTokenWatermarkParams) (db.UserSvcTokenWatermark, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetTokenWatermark", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcTokenWatermark)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetTokenWatermark indicates an expected call of SetTokenWatermark.
+func (mr *MockStoreMockRecorder) SetTokenWatermark(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTokenWatermark", reflect.TypeOf((*MockStore)(nil).SetTokenWatermark), ctx, arg)
+}
+
+// UpdateApiKeyLastUsed mocks base method.
+func (m *MockStore) UpdateApiKeyLastUsed(ctx context.Context, arg db.UpdateApiKeyLastUsedParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateApiKeyLastUsed", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateApiKeyLastUsed indicates an expected call of UpdateApiKeyLastUsed.
+func (mr *MockStoreMockRecorder) UpdateApiKeyLastUsed(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateApiKeyLastUsed", reflect.TypeOf((*MockStore)(nil).UpdateApiKeyLastUsed), ctx, arg)
+}
+
 // UpdateUser mocks base method.
 func (m *MockStore) UpdateUser(ctx context.Context, arg db.UpdateUserParams) (db.UserSvcUser, error) {
 	m.ctrl.T.Helper()
@@ -188,3 +1155,138 @@ func (mr *MockStoreMockRecorder) UpdateUser(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockStore)(nil).UpdateUser), ctx, arg)
 }
+
+// UpdateUserPassword mocks base method.
+func (m *MockStore) UpdateUserPassword(ctx context.Context, arg db.UpdateUserPasswordParams) (db.UserSvcUser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassword", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcUser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockStoreMockRecorder) UpdateUserPassword(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockStore)(nil).UpdateUserPassword), ctx, arg)
+}
+
+// UpdateUserTx mocks base method.
+func (m *MockStore) UpdateUserTx(ctx context.Context, arg db.UpdateUserTxParams) (db.UpdateUserTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserTx", ctx, arg)
+	ret0, _ := ret[0].(db.UpdateUserTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserTx indicates an expected call of UpdateUserTx.
+func (mr *MockStoreMockRecorder) UpdateUserTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserTx", reflect.TypeOf((*MockStore)(nil).UpdateUserTx), ctx, arg)
+}
+
+// UseMfaChallenge mocks base method.
+func (m *MockStore) UseMfaChallenge(ctx context.Context, id int64) (db.UserSvcMfaChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseMfaChallenge", ctx, id)
+	ret0, _ := ret[0].(db.UserSvcMfaChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseMfaChallenge indicates an expected call of UseMfaChallenge.
+func (mr *MockStoreMockRecorder) UseMfaChallenge(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseMfaChallenge", reflect.TypeOf((*MockStore)(nil).UseMfaChallenge), ctx, id)
+}
+
+// UseOAuthAuthorizationCode mocks base method.
+func (m *MockStore) UseOAuthAuthorizationCode(ctx context.Context, codeHash string) (db.UserSvcOAuthAuthorizationCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseOAuthAuthorizationCode", ctx, codeHash)
+	ret0, _ := ret[0].(db.UserSvcOAuthAuthorizationCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseOAuthAuthorizationCode indicates an expected call of UseOAuthAuthorizationCode.
+func (mr *MockStoreMockRecorder) UseOAuthAuthorizationCode(ctx, codeHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseOAuthAuthorizationCode", reflect.TypeOf((*MockStore)(nil).UseOAuthAuthorizationCode), ctx, codeHash)
+}
+
+// UsePasswordReset mocks base method.
+func (m *MockStore) UsePasswordReset(ctx context.Context, tokenHash string) (db.UserSvcPasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsePasswordReset", ctx, tokenHash)
+	ret0, _ := ret[0].(db.UserSvcPasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UsePasswordReset indicates an expected call of UsePasswordReset.
+func (mr *MockStoreMockRecorder) UsePasswordReset(ctx, tokenHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsePasswordReset", reflect.TypeOf((*MockStore)(nil).UsePasswordReset), ctx, tokenHash)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockStore) UseRecoveryCode(ctx context.Context, arg db.UseRecoveryCodeParams) (db.UserSvcRecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcRecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockStoreMockRecorder) UseRecoveryCode(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockStore)(nil).UseRecoveryCode), ctx, arg)
+}
+
+// UseTotpStep mocks base method.
+func (m *MockStore) UseTotpStep(ctx context.Context, arg db.UseTotpStepParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTotpStep", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTotpStep indicates an expected call of UseTotpStep.
+func (mr *MockStoreMockRecorder) UseTotpStep(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTotpStep", reflect.TypeOf((*MockStore)(nil).UseTotpStep), ctx, arg)
+}
+
+// UseVerifyEmail mocks base method.
+func (m *MockStore) UseVerifyEmail(ctx context.Context, arg db.UseVerifyEmailParams) (db.UserSvcVerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseVerifyEmail", ctx, arg)
+	ret0, _ := ret[0].(db.UserSvcVerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseVerifyEmail indicates an expected call of UseVerifyEmail.
+func (mr *MockStoreMockRecorder) UseVerifyEmail(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseVerifyEmail", reflect.TypeOf((*MockStore)(nil).UseVerifyEmail), ctx, arg)
+}
+
+// VerifyEmailTx mocks base method.
+func (m *MockStore) VerifyEmailTx(ctx context.Context, arg db.VerifyEmailTxParams) (db.VerifyEmailTxResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmailTx", ctx, arg)
+	ret0, _ := ret[0].(db.VerifyEmailTxResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyEmailTx indicates an expected call of VerifyEmailTx.
+func (mr *MockStoreMockRecorder) VerifyEmailTx(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmailTx", reflect.TypeOf((*MockStore)(nil).VerifyEmailTx), ctx, arg)
+}
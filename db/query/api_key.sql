@@ -0,0 +1,35 @@
+-- name: CreateApiKey :one
+INSERT INTO "user_svc"."ApiKeys" (
+ user_id,
+ name,
+ prefix,
+ key_hash,
+ scopes,
+ expires_at
+) VALUES (
+ $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: GetApiKeyByPrefix :one
+SELECT * FROM "user_svc"."ApiKeys"
+WHERE prefix = $1
+LIMIT 1;
+
+-- name: ListApiKeysByUserId :many
+SELECT * FROM "user_svc"."ApiKeys"
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: RevokeApiKey :execrows
+UPDATE "user_svc"."ApiKeys"
+SET revoked_at = now()
+WHERE id = $1
+  AND user_id = $2
+  AND revoked_at = '0001-01-01 00:00:00Z';
+
+-- name: UpdateApiKeyLastUsed :exec
+UPDATE "user_svc"."ApiKeys"
+SET last_used_at = now(),
+    last_used_ip = $2
+WHERE id = $1;
@@ -0,0 +1,48 @@
+-- name: CreateExternalIdentity :one
+INSERT INTO "user_svc"."ExternalIdentities" (
+ user_id,
+ provider,
+ subject,
+ email
+) VALUES (
+ $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetExternalIdentity :one
+SELECT * FROM "user_svc"."ExternalIdentities"
+WHERE provider = $1
+  AND subject = $2
+LIMIT 1;
+
+-- name: ListExternalIdentitiesByUserId :many
+SELECT * FROM "user_svc"."ExternalIdentities"
+WHERE user_id = $1
+ORDER BY provider;
+
+-- name: DeleteExternalIdentity :execrows
+DELETE FROM "user_svc"."ExternalIdentities"
+WHERE user_id = $1
+  AND provider = $2;
+
+-- name: CreateExternalLoginState :one
+INSERT INTO "user_svc"."ExternalLoginStates" (
+ state_hash,
+ provider,
+ nonce,
+ code_verifier,
+ user_id,
+ expired_at
+) VALUES (
+ $1, $2, $3, $4, $5, $6
+)
+RETURNING *;
+
+-- name: ConsumeExternalLoginState :one
+DELETE FROM "user_svc"."ExternalLoginStates"
+WHERE state_hash = $1
+RETURNING *;
+
+-- name: DeleteExpiredExternalLoginStates :execrows
+DELETE FROM "user_svc"."ExternalLoginStates"
+WHERE expired_at < sqlc.arg(cutoff);
@@ -0,0 +1,33 @@
+-- name: GetLoginAttempts :many
+SELECT * FROM "user_svc"."LoginAttempts"
+WHERE key = ANY(sqlc.arg(keys)::varchar[]);
+
+-- name: RecordLoginFailure :one
+INSERT INTO "user_svc"."LoginAttempts" (
+ key,
+ failed_attempts,
+ last_failed_at
+) VALUES (
+ sqlc.arg(key), 1, now()
+)
+ON CONFLICT (key) DO UPDATE
+SET failed_attempts = CASE
+    WHEN "LoginAttempts".last_failed_at < sqlc.arg(window_start) THEN 1
+    ELSE "LoginAttempts".failed_attempts + 1
+  END,
+  last_failed_at = now()
+RETURNING *;
+
+-- name: LockLogin :exec
+UPDATE "user_svc"."LoginAttempts"
+SET locked_until = sqlc.arg(locked_until)
+WHERE key = sqlc.arg(key);
+
+-- name: DeleteLoginAttempts :execrows
+DELETE FROM "user_svc"."LoginAttempts"
+WHERE key = ANY(sqlc.arg(keys)::varchar[]);
+
+-- name: DeleteStaleLoginAttempts :execrows
+DELETE FROM "user_svc"."LoginAttempts"
+WHERE last_failed_at < sqlc.arg(cutoff)
+  AND locked_until < now();
@@ -0,0 +1,84 @@
+-- name: CreateTotpSecret :one
+INSERT INTO "user_svc"."TotpSecrets" (
+ username,
+ secret_ciphertext
+) VALUES (
+ $1, $2
+)
+ON CONFLICT (username) DO UPDATE
+SET secret_ciphertext = EXCLUDED.secret_ciphertext,
+  last_used_step = 0,
+  created_at = now()
+WHERE "TotpSecrets".is_confirmed = false
+RETURNING *;
+
+-- name: GetTotpSecret :one
+SELECT * FROM "user_svc"."TotpSecrets"
+WHERE username = $1 LIMIT 1;
+
+-- name: ConfirmTotpSecret :one
+UPDATE "user_svc"."TotpSecrets"
+SET is_confirmed = true, last_used_step = sqlc.arg(step)
+WHERE username = sqlc.arg(username)
+  AND is_confirmed = false
+RETURNING *;
+
+-- name: UseTotpStep :execrows
+UPDATE "user_svc"."TotpSecrets"
+SET last_used_step = sqlc.arg(step)
+WHERE username = sqlc.arg(username)
+  AND is_confirmed = true
+  AND last_used_step < sqlc.arg(step);
+
+-- name: DeleteTotpSecret :execrows
+DELETE FROM "user_svc"."TotpSecrets"
+WHERE username = $1;
+
+-- name: CreateRecoveryCode :one
+INSERT INTO "user_svc"."RecoveryCodes" (
+ username,
+ code_hash
+) VALUES (
+ $1, $2
+)
+RETURNING *;
+
+-- name: UseRecoveryCode :one
+UPDATE "user_svc"."RecoveryCodes"
+SET is_used = true
+WHERE username = $1
+  AND code_hash = $2
+  AND is_used = false
+RETURNING *;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM "user_svc"."RecoveryCodes"
+WHERE username = $1;
+
+-- name: CreateMfaChallenge :one
+INSERT INTO "user_svc"."MfaChallenges" (
+ username,
+ token_hash,
+ expired_at
+) VALUES (
+ $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetMfaChallenge :one
+SELECT * FROM "user_svc"."MfaChallenges"
+WHERE token_hash = $1 LIMIT 1;
+
+-- name: RecordMfaChallengeFailure :one
+UPDATE "user_svc"."MfaChallenges"
+SET failed_attempts = failed_attempts + 1
+WHERE id = $1
+RETURNING *;
+
+-- name: UseMfaChallenge :one
+UPDATE "user_svc"."MfaChallenges"
+SET is_used = true
+WHERE id = $1
+  AND is_used = false
+  AND expired_at > now()
+RETURNING *;
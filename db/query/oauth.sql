@@ -0,0 +1,29 @@
+-- name: GetOAuthClient :one
+SELECT * FROM "user_svc"."OAuthClients"
+WHERE id = $1 LIMIT 1;
+
+-- name: CreateOAuthAuthorizationCode :one
+INSERT INTO "user_svc"."OAuthAuthorizationCodes" (
+ code_hash,
+ client_id,
+ username,
+ redirect_uri,
+ scopes,
+ code_challenge,
+ nonce,
+ expired_at
+) VALUES (
+ $1, $2, $3, $4, $5, $6, $7, $8
+)
+RETURNING *;
+
+-- name: UseOAuthAuthorizationCode :one
+UPDATE "user_svc"."OAuthAuthorizationCodes"
+SET is_used = true
+WHERE code_hash = $1
+  AND is_used = false
+RETURNING *;
+
+-- name: DeleteExpiredOAuthAuthorizationCodes :execrows
+DELETE FROM "user_svc"."OAuthAuthorizationCodes"
+WHERE expired_at < sqlc.arg(cutoff);
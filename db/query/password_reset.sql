@@ -0,0 +1,22 @@
+-- name: CreatePasswordReset :one
+INSERT INTO "user_svc"."PasswordResets" (
+ username,
+ token_hash,
+ expired_at
+) VALUES (
+ $1, $2, $3
+)
+RETURNING *;
+
+-- name: UsePasswordReset :one
+UPDATE "user_svc"."PasswordResets"
+SET is_used = true
+WHERE token_hash = $1
+  AND is_used = false
+  AND expired_at > now()
+RETURNING *;
+
+-- name: InvalidatePasswordResets :exec
+UPDATE "user_svc"."PasswordResets"
+SET is_used = true
+WHERE username = $1 AND is_used = false;
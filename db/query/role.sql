@@ -0,0 +1,56 @@
+-- name: CreateRole :one
+INSERT INTO "user_svc"."Roles" (
+ name,
+ description
+) VALUES (
+ $1, $2
+)
+RETURNING *;
+
+-- name: GetRoleById :one
+SELECT * FROM "user_svc"."Roles"
+WHERE id = $1 LIMIT 1;
+
+-- name: GetRoleByName :one
+SELECT * FROM "user_svc"."Roles"
+WHERE name = $1 LIMIT 1;
+
+-- name: ListRoles :many
+SELECT * FROM "user_svc"."Roles"
+ORDER BY id;
+
+-- name: DeleteRole :exec
+DELETE FROM "user_svc"."Roles"
+WHERE id = $1;
+
+-- name: CreatePermission :one
+INSERT INTO "user_svc"."Permissions" (
+ name,
+ description
+) VALUES (
+ $1, $2
+)
+RETURNING *;
+
+-- name: ListPermissions :many
+SELECT * FROM "user_svc"."Permissions"
+ORDER BY id;
+
+-- name: AddPermissionToRole :exec
+INSERT INTO "user_svc"."RolePermissions" (
+ role_id,
+ permission_id
+) VALUES (
+ $1, $2
+)
+ON CONFLICT DO NOTHING;
+
+-- name: RemovePermissionFromRole :exec
+DELETE FROM "user_svc"."RolePermissions"
+WHERE role_id = $1 AND permission_id = $2;
+
+-- name: ListPermissionsByRoleId :many
+SELECT p.* FROM "user_svc"."Permissions" p
+JOIN "user_svc"."RolePermissions" rp ON rp.permission_id = p.id
+WHERE rp.role_id = $1
+ORDER BY p.id;
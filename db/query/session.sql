@@ -1,6 +1,7 @@
 -- name: CreateSession :one
 INSERT INTO "user_svc"."Sessions" (
  id,
+ family_id,
  username,
  refresh_token,
  user_agent,
@@ -8,10 +9,56 @@ INSERT INTO "user_svc"."Sessions" (
  is_blocked,
  expires_at
 ) VALUES (
- $1, $2, $3, $4, $5, $6, $7
+ $1, $2, $3, $4, $5, $6, $7, $8
 )
 RETURNING *;
 
 -- name: GetSession :one
 SELECT * FROM "user_svc"."Sessions"
 WHERE id = $1 LIMIT 1;
+
+-- name: BlockSession :one
+UPDATE "user_svc"."Sessions"
+SET is_blocked = true
+WHERE id = $1
+RETURNING *;
+
+-- name: MarkSessionReplaced :one
+UPDATE "user_svc"."Sessions"
+SET replaced_by = sqlc.arg(replaced_by)
+WHERE id = sqlc.arg(id) AND replaced_by IS NULL
+RETURNING *;
+
+-- name: BlockSessionFamily :execrows
+UPDATE "user_svc"."Sessions"
+SET is_blocked = true
+WHERE family_id = $1 AND is_blocked = false;
+
+-- name: ListActiveSessionsByUsername :many
+SELECT * FROM "user_svc"."Sessions"
+WHERE username = $1
+  AND is_blocked = false
+  AND replaced_by IS NULL
+  AND expires_at > now()
+ORDER BY created_at DESC;
+
+-- name: BlockSessionsByUsername :execrows
+UPDATE "user_svc"."Sessions"
+SET is_blocked = true
+WHERE username = $1 AND is_blocked = false;
+
+-- name: BlockOtherSessionsByUsername :execrows
+UPDATE "user_svc"."Sessions"
+SET is_blocked = true
+WHERE username = sqlc.arg(username)
+  AND family_id <> sqlc.arg(keep_family_id)
+  AND is_blocked = false;
+
+-- name: DeleteStaleSessions :execrows
+DELETE FROM "user_svc"."Sessions"
+WHERE id IN (
+  SELECT id FROM "user_svc"."Sessions"
+  WHERE expires_at < sqlc.arg(cutoff)
+     OR (is_blocked = true AND created_at < sqlc.arg(cutoff))
+  LIMIT sqlc.arg(batch_size)
+);
@@ -0,0 +1,37 @@
+-- name: RevokeToken :exec
+INSERT INTO "user_svc"."RevokedTokens" (
+ id,
+ username,
+ expires_at
+) VALUES (
+ $1, $2, $3
+)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: ListRevokedTokens :many
+SELECT * FROM "user_svc"."RevokedTokens"
+WHERE expires_at > now();
+
+-- name: SetTokenWatermark :one
+INSERT INTO "user_svc"."TokenWatermarks" (
+ username,
+ not_before
+) VALUES (
+ $1, $2
+)
+ON CONFLICT (username) DO UPDATE
+SET not_before = GREATEST("TokenWatermarks".not_before, EXCLUDED.not_before),
+  updated_at = now()
+RETURNING *;
+
+-- name: ListTokenWatermarks :many
+SELECT * FROM "user_svc"."TokenWatermarks"
+WHERE not_before > sqlc.arg(cutoff);
+
+-- name: DeleteExpiredRevokedTokens :execrows
+DELETE FROM "user_svc"."RevokedTokens"
+WHERE expires_at < sqlc.arg(cutoff);
+
+-- name: DeleteStaleTokenWatermarks :execrows
+DELETE FROM "user_svc"."TokenWatermarks"
+WHERE not_before < sqlc.arg(cutoff);
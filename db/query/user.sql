@@ -29,6 +29,10 @@ RETURNING *;
 SELECT * FROM "user_svc"."Users"
 WHERE username = $1 LIMIT 1;
 
+-- name: GetUserByEmail :one
+SELECT * FROM "user_svc"."Users"
+WHERE email = $1 LIMIT 1;
+
 -- name: GetUserById :one
 SELECT * FROM "user_svc"."Users"
 WHERE id = $1 LIMIT 1;
@@ -56,15 +60,13 @@ SET
     username = COALESCE(sqlc.narg(username), username),
     full_name = COALESCE(sqlc.narg(full_name), full_name),
     email = COALESCE(sqlc.narg(email), email),
-    password_hash = COALESCE(sqlc.narg(password_hash), password_hash),
-    password_salt = COALESCE(sqlc.narg(password_salt), password_salt),
     country_code = COALESCE(sqlc.narg(country_code), country_code),
     role_id = COALESCE(sqlc.narg(role_id), role_id),
     status = COALESCE(sqlc.narg(status), status),
     last_login_at = COALESCE(sqlc.narg(last_login_at), last_login_at),
     username_changed_at = COALESCE(sqlc.narg(username_changed_at), username_changed_at),
-    email_changed_at = COALESCE(sqlc.narg(email_changed_at), email_changed_at),
-    password_changed_at = COALESCE(sqlc.narg(password_changed_at), password_changed_at),
+    email_changed_at = CASE WHEN sqlc.narg(email) IS NOT NULL AND sqlc.narg(email) <> email THEN NOW() ELSE COALESCE(sqlc.narg(email_changed_at), email_changed_at) END,
+    email_verified_at = CASE WHEN sqlc.narg(email) IS NOT NULL AND sqlc.narg(email) <> email THEN '0001-01-01 00:00:00Z' ELSE email_verified_at END,
     created_at = COALESCE(sqlc.narg(created_at), created_at),
     updated_at = NOW()
 WHERE "user_svc"."Users".id = sqlc.arg(id)
@@ -77,3 +79,27 @@ WHERE id = $1;
 -- name: DeleteUserByValue :exec
 DELETE FROM "user_svc"."Users"
 WHERE username = $1;
+
+-- name: ActivateUser :one
+UPDATE "user_svc"."Users"
+SET
+    status = CASE WHEN status = 'inactive' AND email_verified_at = '0001-01-01 00:00:00Z' AND email_changed_at = '0001-01-01 00:00:00Z' THEN 'active' ELSE status END,
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE username = sqlc.arg(username) AND email = sqlc.arg(email)
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE "user_svc"."Users"
+SET
+    password_hash = sqlc.arg(password_hash),
+    password_salt = sqlc.arg(password_salt),
+    password_changed_at = NOW(),
+    updated_at = NOW()
+WHERE username = sqlc.arg(username)
+RETURNING *;
+
+-- name: RehashUserPassword :execrows
+UPDATE "user_svc"."Users"
+SET password_hash = sqlc.arg(new_password_hash), password_salt = ''
+WHERE username = sqlc.arg(username) AND password_hash = sqlc.arg(old_password_hash);
@@ -0,0 +1,19 @@
+-- name: CreateVerifyEmail :one
+INSERT INTO "user_svc"."VerifyEmails" (
+ username,
+ email,
+ secret_code,
+ expired_at
+) VALUES (
+ $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: UseVerifyEmail :one
+UPDATE "user_svc"."VerifyEmails"
+SET is_used = true
+WHERE id = sqlc.arg(id)
+  AND secret_code = sqlc.arg(secret_code)
+  AND is_used = false
+  AND expired_at > now()
+RETURNING *;
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiKey(t *testing.T) {
+	user := createRandomUser(t)
+	prefix := util.RandomString(8)
+
+	key, err := testQueries.CreateApiKey(context.Background(), CreateApiKeyParams{
+		UserID:  user.ID,
+		Name:    "bot",
+		Prefix:  prefix,
+		KeyHash: util.HashSecretCode(util.RandomString(32)),
+		Scopes:  []string{"users:read"},
+	})
+	require.NoError(t, err)
+	require.True(t, key.ExpiresAt.IsZero())
+	require.True(t, key.RevokedAt.IsZero())
+	require.True(t, key.LastUsedAt.IsZero())
+
+	err = testQueries.UpdateApiKeyLastUsed(context.Background(), UpdateApiKeyLastUsedParams{ID: key.ID, LastUsedIp: "192.0.2.1"})
+	require.NoError(t, err)
+
+	found, err := testQueries.GetApiKeyByPrefix(context.Background(), prefix)
+	require.NoError(t, err)
+	require.Equal(t, []string{"users:read"}, found.Scopes)
+	require.Equal(t, "192.0.2.1", found.LastUsedIp)
+	require.WithinDuration(t, time.Now(), found.LastUsedAt, time.Minute)
+
+	// Keys are only revoked once and only by their user
+	revoked, err := testQueries.RevokeApiKey(context.Background(), RevokeApiKeyParams{ID: key.ID, UserID: user.ID + 1})
+	require.NoError(t, err)
+	require.Zero(t, revoked)
+
+	revoked, err = testQueries.RevokeApiKey(context.Background(), RevokeApiKeyParams{ID: key.ID, UserID: user.ID})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), revoked)
+
+	revoked, err = testQueries.RevokeApiKey(context.Background(), RevokeApiKeyParams{ID: key.ID, UserID: user.ID})
+	require.NoError(t, err)
+	require.Zero(t, revoked)
+
+	keys, err := testQueries.ListApiKeysByUserId(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.False(t, keys[0].RevokedAt.IsZero())
+}
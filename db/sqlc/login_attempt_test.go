@@ -0,0 +1,70 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordLoginFailure(t *testing.T) {
+	key := "user:" + util.RandomUsername()
+	windowStart := time.Now().Add(-time.Hour)
+
+	for i := int32(1); i <= 3; i++ {
+		attempt, err := testQueries.RecordLoginFailure(context.Background(), RecordLoginFailureParams{
+			Key:         key,
+			WindowStart: windowStart,
+		})
+		require.NoError(t, err)
+		require.Equal(t, key, attempt.Key)
+		require.Equal(t, i, attempt.FailedAttempts)
+		require.WithinDuration(t, time.Now(), attempt.LastFailedAt, time.Second)
+	}
+
+	// Failures before the window are forgotten
+	attempt, err := testQueries.RecordLoginFailure(context.Background(), RecordLoginFailureParams{
+		Key:         key,
+		WindowStart: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int32(1), attempt.FailedAttempts)
+}
+
+func TestLockLoginAndDeleteLoginAttempts(t *testing.T) {
+	userKey := "user:" + util.RandomUsername()
+	ipKey := "ip:10." + util.RandomString(6)
+	lockedUntil := time.Now().Add(time.Minute)
+
+	for _, key := range []string{userKey, ipKey} {
+		_, err := testQueries.RecordLoginFailure(context.Background(), RecordLoginFailureParams{
+			Key:         key,
+			WindowStart: time.Now().Add(-time.Hour),
+		})
+		require.NoError(t, err)
+	}
+
+	err := testQueries.LockLogin(context.Background(), LockLoginParams{LockedUntil: lockedUntil, Key: userKey})
+	require.NoError(t, err)
+
+	attempts, err := testQueries.GetLoginAttempts(context.Background(), []string{userKey, ipKey})
+	require.NoError(t, err)
+	require.Len(t, attempts, 2)
+	for _, attempt := range attempts {
+		if attempt.Key == userKey {
+			require.WithinDuration(t, lockedUntil, attempt.LockedUntil, time.Second)
+		} else {
+			require.True(t, attempt.LockedUntil.Before(time.Now()))
+		}
+	}
+
+	deleted, err := testQueries.DeleteLoginAttempts(context.Background(), []string{userKey, ipKey})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted)
+
+	attempts, err = testQueries.GetLoginAttempts(context.Background(), []string{userKey, ipKey})
+	require.NoError(t, err)
+	require.Empty(t, attempts)
+}
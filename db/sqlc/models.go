@@ -11,17 +11,149 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
-type UserSvcSession struct {
-	ID           uuid.UUID `json:"id"`
-	Username     string    `json:"username"`
-	RefreshToken string    `json:"refresh_token"`
-	UserAgent    string    `json:"user_agent"`
-	ClientIp     string    `json:"client_ip"`
-	IsBlocked    bool      `json:"is_blocked"`
-	ExpiresAt    time.Time `json:"expires_at"`
+type UserSvcApiKey struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	KeyHash    string    `json:"key_hash"`
+	Scopes     []string  `json:"scopes"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	LastUsedIp string    `json:"last_used_ip"`
+	RevokedAt  time.Time `json:"revoked_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type UserSvcExternalIdentity struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserSvcExternalLoginState struct {
+	StateHash    string      `json:"state_hash"`
+	Provider     string      `json:"provider"`
+	Nonce        string      `json:"nonce"`
+	CodeVerifier string      `json:"code_verifier"`
+	UserID       pgtype.Int8 `json:"user_id"`
+	CreatedAt    time.Time   `json:"created_at"`
+	ExpiredAt    time.Time   `json:"expired_at"`
+}
+
+type UserSvcLoginAttempt struct {
+	Key            string    `json:"key"`
+	FailedAttempts int32     `json:"failed_attempts"`
+	LastFailedAt   time.Time `json:"last_failed_at"`
+	LockedUntil    time.Time `json:"locked_until"`
+}
+
+type UserSvcMfaChallenge struct {
+	ID             int64     `json:"id"`
+	Username       string    `json:"username"`
+	TokenHash      string    `json:"token_hash"`
+	FailedAttempts int32     `json:"failed_attempts"`
+	IsUsed         bool      `json:"is_used"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiredAt      time.Time `json:"expired_at"`
+}
+
+type UserSvcOAuthAuthorizationCode struct {
+	CodeHash      string    `json:"code_hash"`
+	ClientID      string    `json:"client_id"`
+	Username      string    `json:"username"`
+	RedirectUri   string    `json:"redirect_uri"`
+	Scopes        []string  `json:"scopes"`
+	CodeChallenge string    `json:"code_challenge"`
+	IsUsed        bool      `json:"is_used"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiredAt     time.Time `json:"expired_at"`
+	Nonce         string    `json:"nonce"`
+}
+
+type UserSvcOAuthClient struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	SecretHash   string    `json:"secret_hash"`
+	RedirectUris []string  `json:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types"`
+	Scopes       []string  `json:"scopes"`
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+type UserSvcPasswordReset struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	TokenHash string    `json:"token_hash"`
+	IsUsed    bool      `json:"is_used"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
+type UserSvcPermission struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type UserSvcRecoveryCode struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	CodeHash  string    `json:"code_hash"`
+	IsUsed    bool      `json:"is_used"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserSvcRevokedToken struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+type UserSvcRole struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type UserSvcRolePermission struct {
+	RoleID       int64 `json:"role_id"`
+	PermissionID int64 `json:"permission_id"`
+}
+
+type UserSvcSession struct {
+	ID           uuid.UUID   `json:"id"`
+	Username     string      `json:"username"`
+	RefreshToken string      `json:"refresh_token"`
+	UserAgent    string      `json:"user_agent"`
+	ClientIp     string      `json:"client_ip"`
+	IsBlocked    bool        `json:"is_blocked"`
+	ExpiresAt    time.Time   `json:"expires_at"`
+	CreatedAt    time.Time   `json:"created_at"`
+	FamilyID     uuid.UUID   `json:"family_id"`
+	ReplacedBy   pgtype.UUID `json:"replaced_by"`
+}
+
+type UserSvcTokenWatermark struct {
+	Username  string    `json:"username"`
+	NotBefore time.Time `json:"not_before"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type UserSvcTotpSecret struct {
+	Username         string    `json:"username"`
+	SecretCiphertext string    `json:"secret_ciphertext"`
+	IsConfirmed      bool      `json:"is_confirmed"`
+	LastUsedStep     int64     `json:"last_used_step"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
 type UserSvcUser struct {
 	ID                int64       `json:"id"`
 	Username          string      `json:"username"`
@@ -38,4 +170,15 @@ type UserSvcUser struct {
 	PasswordChangedAt time.Time   `json:"password_changed_at"`
 	CreatedAt         time.Time   `json:"created_at"`
 	UpdatedAt         time.Time   `json:"updated_at"`
+	EmailVerifiedAt   time.Time   `json:"email_verified_at"`
+}
+
+type UserSvcVerifyEmail struct {
+	ID         int64     `json:"id"`
+	Username   string    `json:"username"`
+	Email      string    `json:"email"`
+	SecretCode string    `json:"secret_code"`
+	IsUsed     bool      `json:"is_used"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiredAt  time.Time `json:"expired_at"`
 }
@@ -6,20 +6,86 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 type Querier interface {
+	ActivateUser(ctx context.Context, arg ActivateUserParams) (UserSvcUser, error)
+	AddPermissionToRole(ctx context.Context, arg AddPermissionToRoleParams) error
+	BlockOtherSessionsByUsername(ctx context.Context, arg BlockOtherSessionsByUsernameParams) (int64, error)
+	BlockSession(ctx context.Context, id uuid.UUID) (UserSvcSession, error)
+	BlockSessionFamily(ctx context.Context, familyID uuid.UUID) (int64, error)
+	BlockSessionsByUsername(ctx context.Context, username string) (int64, error)
+	ConfirmTotpSecret(ctx context.Context, arg ConfirmTotpSecretParams) (UserSvcTotpSecret, error)
+	ConsumeExternalLoginState(ctx context.Context, stateHash string) (UserSvcExternalLoginState, error)
+	CreateApiKey(ctx context.Context, arg CreateApiKeyParams) (UserSvcApiKey, error)
+	CreateExternalIdentity(ctx context.Context, arg CreateExternalIdentityParams) (UserSvcExternalIdentity, error)
+	CreateExternalLoginState(ctx context.Context, arg CreateExternalLoginStateParams) (UserSvcExternalLoginState, error)
+	CreateMfaChallenge(ctx context.Context, arg CreateMfaChallengeParams) (UserSvcMfaChallenge, error)
+	CreateOAuthAuthorizationCode(ctx context.Context, arg CreateOAuthAuthorizationCodeParams) (UserSvcOAuthAuthorizationCode, error)
+	CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) (UserSvcPasswordReset, error)
+	CreatePermission(ctx context.Context, arg CreatePermissionParams) (UserSvcPermission, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) (UserSvcRecoveryCode, error)
+	CreateRole(ctx context.Context, arg CreateRoleParams) (UserSvcRole, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (UserSvcSession, error)
+	CreateTotpSecret(ctx context.Context, arg CreateTotpSecretParams) (UserSvcTotpSecret, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (UserSvcUser, error)
+	CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (UserSvcVerifyEmail, error)
+	DeleteExpiredExternalLoginStates(ctx context.Context, cutoff time.Time) (int64, error)
+	DeleteExpiredOAuthAuthorizationCodes(ctx context.Context, cutoff time.Time) (int64, error)
+	DeleteExpiredRevokedTokens(ctx context.Context, cutoff time.Time) (int64, error)
+	DeleteExternalIdentity(ctx context.Context, arg DeleteExternalIdentityParams) (int64, error)
+	DeleteLoginAttempts(ctx context.Context, keys []string) (int64, error)
+	DeleteRecoveryCodes(ctx context.Context, username string) error
+	DeleteRole(ctx context.Context, id int64) error
+	DeleteStaleLoginAttempts(ctx context.Context, cutoff time.Time) (int64, error)
+	DeleteStaleSessions(ctx context.Context, arg DeleteStaleSessionsParams) (int64, error)
+	DeleteStaleTokenWatermarks(ctx context.Context, cutoff time.Time) (int64, error)
+	DeleteTotpSecret(ctx context.Context, username string) (int64, error)
 	DeleteUserById(ctx context.Context, id int64) error
 	DeleteUserByValue(ctx context.Context, username string) error
+	GetApiKeyByPrefix(ctx context.Context, prefix string) (UserSvcApiKey, error)
+	GetExternalIdentity(ctx context.Context, arg GetExternalIdentityParams) (UserSvcExternalIdentity, error)
+	GetLoginAttempts(ctx context.Context, keys []string) ([]UserSvcLoginAttempt, error)
+	GetMfaChallenge(ctx context.Context, tokenHash string) (UserSvcMfaChallenge, error)
+	GetOAuthClient(ctx context.Context, id string) (UserSvcOAuthClient, error)
+	GetRoleById(ctx context.Context, id int64) (UserSvcRole, error)
+	GetRoleByName(ctx context.Context, name string) (UserSvcRole, error)
 	GetSession(ctx context.Context, id uuid.UUID) (UserSvcSession, error)
+	GetTotpSecret(ctx context.Context, username string) (UserSvcTotpSecret, error)
+	GetUserByEmail(ctx context.Context, email string) (UserSvcUser, error)
 	GetUserById(ctx context.Context, id int64) (UserSvcUser, error)
 	GetUserByValue(ctx context.Context, username string) (UserSvcUser, error)
+	InvalidatePasswordResets(ctx context.Context, username string) error
+	ListActiveSessionsByUsername(ctx context.Context, username string) ([]UserSvcSession, error)
+	ListApiKeysByUserId(ctx context.Context, userID int64) ([]UserSvcApiKey, error)
+	ListExternalIdentitiesByUserId(ctx context.Context, userID int64) ([]UserSvcExternalIdentity, error)
+	ListPermissions(ctx context.Context) ([]UserSvcPermission, error)
+	ListPermissionsByRoleId(ctx context.Context, roleID int64) ([]UserSvcPermission, error)
+	ListRevokedTokens(ctx context.Context) ([]UserSvcRevokedToken, error)
+	ListRoles(ctx context.Context) ([]UserSvcRole, error)
+	ListTokenWatermarks(ctx context.Context, cutoff time.Time) ([]UserSvcTokenWatermark, error)
 	ListUsers(ctx context.Context, arg ListUsersParams) ([]ListUsersRow, error)
+	LockLogin(ctx context.Context, arg LockLoginParams) error
+	MarkSessionReplaced(ctx context.Context, arg MarkSessionReplacedParams) (UserSvcSession, error)
+	RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (UserSvcLoginAttempt, error)
+	RecordMfaChallengeFailure(ctx context.Context, id int64) (UserSvcMfaChallenge, error)
+	RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) (int64, error)
+	RemovePermissionFromRole(ctx context.Context, arg RemovePermissionFromRoleParams) error
+	RevokeApiKey(ctx context.Context, arg RevokeApiKeyParams) (int64, error)
+	RevokeToken(ctx context.Context, arg RevokeTokenParams) error
+	SetTokenWatermark(ctx context.Context, arg SetTokenWatermarkParams) (UserSvcTokenWatermark, error)
+	UpdateApiKeyLastUsed(ctx context.Context, arg UpdateApiKeyLastUsedParams) error
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (UserSvcUser, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (UserSvcUser, error)
+	UseMfaChallenge(ctx context.Context, id int64) (UserSvcMfaChallenge, error)
+	UseOAuthAuthorizationCode(ctx context.Context, codeHash string) (UserSvcOAuthAuthorizationCode, error)
+	UsePasswordReset(ctx context.Context, tokenHash string) (UserSvcPasswordReset, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (UserSvcRecoveryCode, error)
+	UseTotpStep(ctx context.Context, arg UseTotpStepParams) (int64, error)
+	UseVerifyEmail(ctx context.Context, arg UseVerifyEmailParams) (UserSvcVerifyEmail, error)
 }
 
 var _ Querier = (*Queries)(nil)
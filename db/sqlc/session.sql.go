@@ -10,11 +10,87 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const blockOtherSessionsByUsername = `-- name: BlockOtherSessionsByUsername :execrows
+UPDATE "user_svc"."Sessions"
+SET is_blocked = true
+WHERE username = $1
+  AND family_id <> $2
+  AND is_blocked = false
+`
+
+type BlockOtherSessionsByUsernameParams struct {
+	Username     string    `json:"username"`
+	KeepFamilyID uuid.UUID `json:"keep_family_id"`
+}
+
+func (q *Queries) BlockOtherSessionsByUsername(ctx context.Context, arg BlockOtherSessionsByUsernameParams) (int64, error) {
+	result, err := q.db.Exec(ctx, blockOtherSessionsByUsername, arg.Username, arg.KeepFamilyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const blockSession = `-- name: BlockSession :one
+UPDATE "user_svc"."Sessions"
+SET is_blocked = true
+WHERE id = $1
+RETURNING id, username, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at, family_id, replaced_by
+`
+
+func (q *Queries) BlockSession(ctx context.Context, id uuid.UUID) (UserSvcSession, error) {
+	row := q.db.QueryRow(ctx, blockSession, id)
+	var i UserSvcSession
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.RefreshToken,
+		&i.UserAgent,
+		&i.ClientIp,
+		&i.IsBlocked,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.FamilyID,
+		&i.ReplacedBy,
+	)
+	return i, err
+}
+
+const blockSessionFamily = `-- name: BlockSessionFamily :execrows
+UPDATE "user_svc"."Sessions"
+SET is_blocked = true
+WHERE family_id = $1 AND is_blocked = false
+`
+
+func (q *Queries) BlockSessionFamily(ctx context.Context, familyID uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, blockSessionFamily, familyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const blockSessionsByUsername = `-- name: BlockSessionsByUsername :execrows
+UPDATE "user_svc"."Sessions"
+SET is_blocked = true
+WHERE username = $1 AND is_blocked = false
+`
+
+func (q *Queries) BlockSessionsByUsername(ctx context.Context, username string) (int64, error) {
+	result, err := q.db.Exec(ctx, blockSessionsByUsername, username)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const createSession = `-- name: CreateSession :one
 INSERT INTO "user_svc"."Sessions" (
  id,
+ family_id,
  username,
  refresh_token,
  user_agent,
@@ -22,13 +98,14 @@ INSERT INTO "user_svc"."Sessions" (
  is_blocked,
  expires_at
 ) VALUES (
- $1, $2, $3, $4, $5, $6, $7
+ $1, $2, $3, $4, $5, $6, $7, $8
 )
-RETURNING id, username, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at
+RETURNING id, username, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at, family_id, replaced_by
 `
 
 type CreateSessionParams struct {
 	ID           uuid.UUID `json:"id"`
+	FamilyID     uuid.UUID `json:"family_id"`
 	Username     string    `json:"username"`
 	RefreshToken string    `json:"refresh_token"`
 	UserAgent    string    `json:"user_agent"`
@@ -40,6 +117,7 @@ type CreateSessionParams struct {
 func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (UserSvcSession, error) {
 	row := q.db.QueryRow(ctx, createSession,
 		arg.ID,
+		arg.FamilyID,
 		arg.Username,
 		arg.RefreshToken,
 		arg.UserAgent,
@@ -57,12 +135,37 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (U
 		&i.IsBlocked,
 		&i.ExpiresAt,
 		&i.CreatedAt,
+		&i.FamilyID,
+		&i.ReplacedBy,
 	)
 	return i, err
 }
 
+const deleteStaleSessions = `-- name: DeleteStaleSessions :execrows
+DELETE FROM "user_svc"."Sessions"
+WHERE id IN (
+  SELECT id FROM "user_svc"."Sessions"
+  WHERE expires_at < $1
+     OR (is_blocked = true AND created_at < $1)
+  LIMIT $2
+)
+`
+
+type DeleteStaleSessionsParams struct {
+	Cutoff    time.Time `json:"cutoff"`
+	BatchSize int32     `json:"batch_size"`
+}
+
+func (q *Queries) DeleteStaleSessions(ctx context.Context, arg DeleteStaleSessionsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteStaleSessions, arg.Cutoff, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getSession = `-- name: GetSession :one
-SELECT id, username, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at FROM "user_svc"."Sessions"
+SELECT id, username, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at, family_id, replaced_by FROM "user_svc"."Sessions"
 WHERE id = $1 LIMIT 1
 `
 
@@ -78,6 +181,78 @@ func (q *Queries) GetSession(ctx context.Context, id uuid.UUID) (UserSvcSession,
 		&i.IsBlocked,
 		&i.ExpiresAt,
 		&i.CreatedAt,
+		&i.FamilyID,
+		&i.ReplacedBy,
+	)
+	return i, err
+}
+
+const listActiveSessionsByUsername = `-- name: ListActiveSessionsByUsername :many
+SELECT id, username, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at, family_id, replaced_by FROM "user_svc"."Sessions"
+WHERE username = $1
+  AND is_blocked = false
+  AND replaced_by IS NULL
+  AND expires_at > now()
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListActiveSessionsByUsername(ctx context.Context, username string) ([]UserSvcSession, error) {
+	rows, err := q.db.Query(ctx, listActiveSessionsByUsername, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserSvcSession{}
+	for rows.Next() {
+		var i UserSvcSession
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.RefreshToken,
+			&i.UserAgent,
+			&i.ClientIp,
+			&i.IsBlocked,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+			&i.FamilyID,
+			&i.ReplacedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markSessionReplaced = `-- name: MarkSessionReplaced :one
+UPDATE "user_svc"."Sessions"
+SET replaced_by = $1
+WHERE id = $2 AND replaced_by IS NULL
+RETURNING id, username, refresh_token, user_agent, client_ip, is_blocked, expires_at, created_at, family_id, replaced_by
+`
+
+type MarkSessionReplacedParams struct {
+	ReplacedBy pgtype.UUID `json:"replaced_by"`
+	ID         uuid.UUID   `json:"id"`
+}
+
+func (q *Queries) MarkSessionReplaced(ctx context.Context, arg MarkSessionReplacedParams) (UserSvcSession, error) {
+	row := q.db.QueryRow(ctx, markSessionReplaced, arg.ReplacedBy, arg.ID)
+	var i UserSvcSession
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.RefreshToken,
+		&i.UserAgent,
+		&i.ClientIp,
+		&i.IsBlocked,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.FamilyID,
+		&i.ReplacedBy,
 	)
 	return i, err
 }
@@ -13,6 +13,15 @@ import (
 type Store interface {
 	Querier
 	Ping(ctx context.Context, timeout time.Duration) error
+	RotateSessionTx(ctx context.Context, arg RotateSessionTxParams) (RotateSessionTxResult, error)
+	CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error)
+	CreateExternalUserTx(ctx context.Context, arg CreateExternalUserTxParams) (CreateExternalUserTxResult, error)
+	UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (UpdateUserTxResult, error)
+	VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error)
+	ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error)
+	ChangePasswordTx(ctx context.Context, arg ChangePasswordTxParams) (ChangePasswordTxResult, error)
+	EnableTotpTx(ctx context.Context, arg EnableTotpTxParams) (EnableTotpTxResult, error)
+	DisableTotpTx(ctx context.Context, username string) (bool, error)
 }
 
 // DB access layer: SQLStore provides all functions to execute SQL queries and transactions
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// ChangePasswordTxParams contains the input parameters of the change password transaction
+type ChangePasswordTxParams struct {
+	Username     string
+	PasswordHash string
+	PasswordSalt string
+	// RevokeOtherSessions signs the user out of all devices except the one of KeepFamilyID
+	RevokeOtherSessions bool
+	// KeepFamilyID is the token family of the session the password is changed from, uuid.Nil revokes all sessions
+	KeepFamilyID uuid.UUID
+}
+
+// ChangePasswordTxResult is the result of the change password transaction
+type ChangePasswordTxResult struct {
+	User            UserSvcUser `json:"user"`
+	RevokedSessions int64       `json:"revoked_sessions"`
+}
+
+// ChangePasswordTx sets the new password of a user within a database transaction.
+// Pending password reset tokens are invalidated and the other sessions of the user are revoked on request.
+func (store *SQLStore) ChangePasswordTx(ctx context.Context, arg ChangePasswordTxParams) (ChangePasswordTxResult, error) {
+	var result ChangePasswordTxResult
+
+	err := store.ExecTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			PasswordHash: arg.PasswordHash,
+			PasswordSalt: arg.PasswordSalt,
+			Username:     arg.Username,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.InvalidatePasswordResets(ctx, arg.Username)
+		if err != nil {
+			return err
+		}
+
+		if !arg.RevokeOtherSessions {
+			return nil
+		}
+
+		result.RevokedSessions, err = q.BlockOtherSessionsByUsername(ctx, BlockOtherSessionsByUsernameParams{
+			Username:     arg.Username,
+			KeepFamilyID: arg.KeepFamilyID,
+		})
+		return err
+	})
+
+	return result, err
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomSession(t *testing.T, username string) UserSvcSession {
+	id := uuid.New()
+	session, err := testQueries.CreateSession(context.Background(), CreateSessionParams{
+		ID:           id,
+		FamilyID:     id,
+		Username:     username,
+		RefreshToken: util.RandomString(32),
+		UserAgent:    "test",
+		ClientIp:     "127.0.0.1",
+		ExpiresAt:    time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	return session
+}
+
+func TestChangePasswordTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+
+	current := createRandomSession(t, user.Username)
+	other := createRandomSession(t, user.Username)
+
+	result, err := store.ChangePasswordTx(context.Background(), ChangePasswordTxParams{
+		Username:            user.Username,
+		PasswordHash:        util.RandomString(32),
+		PasswordSalt:        util.RandomString(16),
+		RevokeOtherSessions: true,
+		KeepFamilyID:        current.FamilyID,
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, user.PasswordHash, result.User.PasswordHash)
+	require.WithinDuration(t, time.Now(), result.User.PasswordChangedAt, time.Minute)
+	require.Equal(t, int64(1), result.RevokedSessions)
+
+	session, err := store.GetSession(context.Background(), current.ID)
+	require.NoError(t, err)
+	require.False(t, session.IsBlocked)
+
+	session, err = store.GetSession(context.Background(), other.ID)
+	require.NoError(t, err)
+	require.True(t, session.IsBlocked)
+}
@@ -0,0 +1,51 @@
+package db
+
+import "context"
+
+// CreateExternalUserTxParams contains the input parameters of the create external user transaction
+type CreateExternalUserTxParams struct {
+	CreateUserParams
+	// Provider and Subject identify the external account the user signed in with
+	Provider string
+	Subject  string
+}
+
+// CreateExternalUserTxResult is the result of the create external user transaction
+type CreateExternalUserTxResult struct {
+	User     UserSvcUser             `json:"user"`
+	Identity UserSvcExternalIdentity `json:"identity"`
+}
+
+// CreateExternalUserTx creates a user that signed in with an external identity provider together with the link
+// to the external account within a database transaction. The email address of the user counts as verified, the
+// provider vouched for it.
+func (store *SQLStore) CreateExternalUserTx(ctx context.Context, arg CreateExternalUserTxParams) (CreateExternalUserTxResult, error) {
+	var result CreateExternalUserTxResult
+
+	err := store.ExecTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.CreateUser(ctx, arg.CreateUserParams)
+		if err != nil {
+			return err
+		}
+
+		result.User, err = q.ActivateUser(ctx, ActivateUserParams{
+			Username: result.User.Username,
+			Email:    result.User.Email,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Identity, err = q.CreateExternalIdentity(ctx, CreateExternalIdentityParams{
+			UserID:   result.User.ID,
+			Provider: arg.Provider,
+			Subject:  arg.Subject,
+			Email:    result.User.Email,
+		})
+		return err
+	})
+
+	return result, err
+}
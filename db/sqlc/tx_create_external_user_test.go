@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateExternalUserTx(t *testing.T) {
+	store := NewStore(testDB)
+	subject := util.RandomString(16)
+
+	result, err := store.CreateExternalUserTx(context.Background(), CreateExternalUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:     util.RandomUsername(),
+			FullName:     util.RandomString(12),
+			Email:        util.RandomEmail(),
+			PasswordHash: util.RandomString(32),
+			RoleID:       util.ConvertToInt8(3),
+			Status:       util.ConvertToText(util.StatusActive),
+		},
+		Provider: "test",
+		Subject:  subject,
+	})
+	require.NoError(t, err)
+	require.Equal(t, result.User.ID, result.Identity.UserID)
+	require.Equal(t, result.User.Email, result.Identity.Email)
+	require.WithinDuration(t, time.Now(), result.User.EmailVerifiedAt, time.Second)
+
+	identity, err := store.GetExternalIdentity(context.Background(), GetExternalIdentityParams{
+		Provider: "test",
+		Subject:  subject,
+	})
+	require.NoError(t, err)
+	require.Equal(t, result.Identity.ID, identity.ID)
+
+	// The same external account can't be linked to a second user
+	_, err = store.CreateExternalIdentity(context.Background(), CreateExternalIdentityParams{
+		UserID:   createRandomUser(t).ID,
+		Provider: "test",
+		Subject:  subject,
+	})
+	require.Error(t, err)
+
+	deleted, err := store.DeleteExternalIdentity(context.Background(), DeleteExternalIdentityParams{
+		UserID:   result.User.ID,
+		Provider: "test",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), deleted)
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// CreateUserTxParams contains the input parameters of the create user transaction
+type CreateUserTxParams struct {
+	CreateUserParams
+	// SecretCode is the hash of the secret code of the email verification
+	SecretCode string
+	ExpiredAt  time.Time
+	// AfterCreate is called within the transaction, e.g. to send the verification email.
+	// If it fails, the user is not created.
+	AfterCreate func(user UserSvcUser, verifyEmail UserSvcVerifyEmail) error
+}
+
+// CreateUserTxResult is the result of the create user transaction
+type CreateUserTxResult struct {
+	User        UserSvcUser        `json:"user"`
+	VerifyEmail UserSvcVerifyEmail `json:"verify_email"`
+}
+
+// CreateUserTx creates a user together with the code to verify its email address within a database transaction.
+func (store *SQLStore) CreateUserTx(ctx context.Context, arg CreateUserTxParams) (CreateUserTxResult, error) {
+	var result CreateUserTxResult
+
+	err := store.ExecTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.CreateUser(ctx, arg.CreateUserParams)
+		if err != nil {
+			return err
+		}
+
+		result.VerifyEmail, err = q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			Username:   result.User.Username,
+			Email:      result.User.Email,
+			SecretCode: arg.SecretCode,
+			ExpiredAt:  arg.ExpiredAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		if arg.AfterCreate == nil {
+			return nil
+		}
+		return arg.AfterCreate(result.User, result.VerifyEmail)
+	})
+
+	return result, err
+}
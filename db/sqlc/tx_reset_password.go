@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrInvalidPasswordReset is returned when a password reset token does not exist, has already been used or is expired.
+var ErrInvalidPasswordReset = errors.New("invalid or expired password reset token")
+
+// ResetPasswordTxParams contains the input parameters of the reset password transaction
+type ResetPasswordTxParams struct {
+	// TokenHash is the hash of the password reset token
+	TokenHash    string
+	PasswordHash string
+	PasswordSalt string
+}
+
+// ResetPasswordTxResult is the result of the reset password transaction
+type ResetPasswordTxResult struct {
+	User            UserSvcUser `json:"user"`
+	RevokedSessions int64       `json:"revoked_sessions"`
+}
+
+// ResetPasswordTx consumes a password reset token and sets the new password of its user within a database transaction.
+// All other reset tokens and all sessions of the user are revoked.
+func (store *SQLStore) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (ResetPasswordTxResult, error) {
+	var result ResetPasswordTxResult
+
+	err := store.ExecTx(ctx, func(q *Queries) error {
+		passwordReset, err := q.UsePasswordReset(ctx, arg.TokenHash)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvalidPasswordReset
+			}
+			return err
+		}
+
+		result.User, err = q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			PasswordHash: arg.PasswordHash,
+			PasswordSalt: arg.PasswordSalt,
+			Username:     passwordReset.Username,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.InvalidatePasswordResets(ctx, passwordReset.Username)
+		if err != nil {
+			return err
+		}
+
+		result.RevokedSessions, err = q.BlockSessionsByUsername(ctx, passwordReset.Username)
+		return err
+	})
+
+	return result, err
+}
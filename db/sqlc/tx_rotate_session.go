@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// ErrSessionAlreadyRotated is returned when the refresh token of a session has already been exchanged for a new one.
+var ErrSessionAlreadyRotated = errors.New("session has already been rotated")
+
+// RotateSessionTxParams contains the input parameters of the rotate session transaction
+type RotateSessionTxParams struct {
+	OldSessionID uuid.UUID
+	NewSession   CreateSessionParams
+}
+
+// RotateSessionTxResult is the result of the rotate session transaction
+type RotateSessionTxResult struct {
+	Session UserSvcSession `json:"session"`
+}
+
+// RotateSessionTx replaces a session by a new session of the same token family within a database transaction.
+// It returns ErrSessionAlreadyRotated if the old session has already been replaced.
+func (store *SQLStore) RotateSessionTx(ctx context.Context, arg RotateSessionTxParams) (RotateSessionTxResult, error) {
+	var result RotateSessionTxResult
+
+	err := store.ExecTx(ctx, func(q *Queries) error {
+		var err error
+
+		// Locks the old session, so that concurrent rotations of the same refresh token can't both succeed
+		_, err = q.MarkSessionReplaced(ctx, MarkSessionReplacedParams{
+			ReplacedBy: pgtype.UUID{Bytes: arg.NewSession.ID, Valid: true},
+			ID:         arg.OldSessionID,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrSessionAlreadyRotated
+			}
+			return err
+		}
+
+		result.Session, err = q.CreateSession(ctx, arg.NewSession)
+		return err
+	})
+
+	return result, err
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrTotpNotPending is returned when a user has no TOTP secret that waits for its confirmation.
+var ErrTotpNotPending = errors.New("no pending TOTP enrolment")
+
+// EnableTotpTxParams contains the input parameters of the enable TOTP transaction
+type EnableTotpTxParams struct {
+	Username string
+	// Step is the time step of the code that confirmed the secret, it can't be used again
+	Step int64
+	// RecoveryCodeHashes are the hashes of the new recovery codes, they replace the existing ones
+	RecoveryCodeHashes []string
+}
+
+// EnableTotpTxResult is the result of the enable TOTP transaction
+type EnableTotpTxResult struct {
+	TotpSecret    UserSvcTotpSecret     `json:"totp_secret"`
+	RecoveryCodes []UserSvcRecoveryCode `json:"recovery_codes"`
+}
+
+// EnableTotpTx confirms the pending TOTP secret of a user and replaces the recovery codes within a database transaction.
+// It returns ErrTotpNotPending if the user has no unconfirmed secret.
+func (store *SQLStore) EnableTotpTx(ctx context.Context, arg EnableTotpTxParams) (EnableTotpTxResult, error) {
+	var result EnableTotpTxResult
+
+	err := store.ExecTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.TotpSecret, err = q.ConfirmTotpSecret(ctx, ConfirmTotpSecretParams{
+			Step:     arg.Step,
+			Username: arg.Username,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrTotpNotPending
+			}
+			return err
+		}
+
+		if err := q.DeleteRecoveryCodes(ctx, arg.Username); err != nil {
+			return err
+		}
+
+		result.RecoveryCodes = make([]UserSvcRecoveryCode, len(arg.RecoveryCodeHashes))
+		for i, codeHash := range arg.RecoveryCodeHashes {
+			result.RecoveryCodes[i], err = q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+				Username: arg.Username,
+				CodeHash: codeHash,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// DisableTotpTx removes the TOTP secret and the recovery codes of a user within a database transaction.
+// It returns whether the user had a TOTP secret.
+func (store *SQLStore) DisableTotpTx(ctx context.Context, username string) (bool, error) {
+	var deleted int64
+
+	err := store.ExecTx(ctx, func(q *Queries) error {
+		var err error
+
+		deleted, err = q.DeleteTotpSecret(ctx, username)
+		if err != nil {
+			return err
+		}
+		return q.DeleteRecoveryCodes(ctx, username)
+	})
+
+	return deleted > 0, err
+}
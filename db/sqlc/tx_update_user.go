@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// UpdateUserTxParams contains the input parameters of the update user transaction
+type UpdateUserTxParams struct {
+	UpdateUserParams
+	// SecretCode is the hash of the secret code to verify a changed email address
+	SecretCode string
+	ExpiredAt  time.Time
+	// AfterEmailChange is called within the transaction if the verification code was created, e.g. to send the
+	// verification email to the new address. If it fails, the user is not updated.
+	AfterEmailChange func(user UserSvcUser, verifyEmail UserSvcVerifyEmail) error
+}
+
+// UpdateUserTxResult is the result of the update user transaction. VerifyEmail is only set if a code was created.
+type UpdateUserTxResult struct {
+	User        UserSvcUser        `json:"user"`
+	VerifyEmail UserSvcVerifyEmail `json:"verify_email"`
+}
+
+// UpdateUserTx updates a user within a database transaction. A changed email address loses its verification,
+// so if the update sets an email address that isn't verified, a code to verify it is created together with the update.
+func (store *SQLStore) UpdateUserTx(ctx context.Context, arg UpdateUserTxParams) (UpdateUserTxResult, error) {
+	var result UpdateUserTxResult
+
+	err := store.ExecTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.User, err = q.UpdateUser(ctx, arg.UpdateUserParams)
+		if err != nil {
+			return err
+		}
+
+		if !result.User.EmailVerifiedAt.IsZero() || !arg.Email.Valid {
+			return nil
+		}
+
+		result.VerifyEmail, err = q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			Username:   result.User.Username,
+			Email:      result.User.Email,
+			SecretCode: arg.SecretCode,
+			ExpiredAt:  arg.ExpiredAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		if arg.AfterEmailChange == nil {
+			return nil
+		}
+		return arg.AfterEmailChange(result.User, result.VerifyEmail)
+	})
+
+	return result, err
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateUserTxVerifiesChangedEmail(t *testing.T) {
+	store := NewStore(testDB)
+	user := createRandomUser(t)
+	_, err := testQueries.ActivateUser(context.Background(), ActivateUserParams{
+		Username: user.Username,
+		Email:    user.Email,
+	})
+	require.NoError(t, err)
+
+	arg := UpdateUserTxParams{
+		UpdateUserParams: UpdateUserParams{
+			FullName: util.ConvertToText(util.RandomString(12)),
+			ID:       user.ID,
+		},
+		SecretCode: util.HashSecretCode(util.RandomString(32)),
+		ExpiredAt:  time.Now().Add(time.Minute),
+	}
+	var sentTo string
+	arg.AfterEmailChange = func(user UserSvcUser, verifyEmail UserSvcVerifyEmail) error {
+		sentTo = verifyEmail.Email
+		return nil
+	}
+
+	// Updates that keep the email address don't need a verification
+	updated, err := store.UpdateUserTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, updated.User.EmailVerifiedAt.IsZero())
+	require.Zero(t, updated.VerifyEmail.ID)
+	require.Empty(t, sentTo)
+
+	arg.Email = util.ConvertToText(util.RandomEmail())
+	updated, err = store.UpdateUserTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, updated.User.EmailVerifiedAt.IsZero())
+	require.Equal(t, arg.Email.String, updated.VerifyEmail.Email)
+	require.Equal(t, arg.Email.String, sentTo)
+}
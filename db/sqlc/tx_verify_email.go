@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ErrInvalidVerifyEmail is returned when a verification code does not exist, has already been used or is expired.
+var ErrInvalidVerifyEmail = errors.New("invalid or expired verification code")
+
+// VerifyEmailTxParams contains the input parameters of the verify email transaction
+type VerifyEmailTxParams struct {
+	EmailID int64
+	// SecretCode is the hash of the secret code of the email verification
+	SecretCode string
+}
+
+// VerifyEmailTxResult is the result of the verify email transaction
+type VerifyEmailTxResult struct {
+	User        UserSvcUser        `json:"user"`
+	VerifyEmail UserSvcVerifyEmail `json:"verify_email"`
+}
+
+// VerifyEmailTx consumes a verification code and marks the email address of its user as verified within a
+// database transaction. Only users that never verified an address are activated, verifying a changed address
+// doesn't reactivate a deactivated user. It returns ErrInvalidVerifyEmail if the code can't be used, or if the
+// email address of the user changed in the meantime.
+func (store *SQLStore) VerifyEmailTx(ctx context.Context, arg VerifyEmailTxParams) (VerifyEmailTxResult, error) {
+	var result VerifyEmailTxResult
+
+	err := store.ExecTx(ctx, func(q *Queries) error {
+		var err error
+
+		result.VerifyEmail, err = q.UseVerifyEmail(ctx, UseVerifyEmailParams{
+			ID:         arg.EmailID,
+			SecretCode: arg.SecretCode,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvalidVerifyEmail
+			}
+			return err
+		}
+
+		result.User, err = q.ActivateUser(ctx, ActivateUserParams{
+			Username: result.VerifyEmail.Username,
+			Email:    result.VerifyEmail.Email,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrInvalidVerifyEmail
+			}
+			return err
+		}
+		return nil
+	})
+
+	return result, err
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func randomCreateUserTxParams(secretCode string) CreateUserTxParams {
+	return CreateUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:     util.RandomUsername(),
+			FullName:     util.RandomString(12),
+			Email:        util.RandomEmail(),
+			PasswordHash: util.RandomString(32),
+			PasswordSalt: util.RandomString(16),
+			CountryCode:  util.RandomCountryCode(),
+			RoleID:       util.ConvertToInt8(3),
+			Status:       util.ConvertToText("inactive"),
+		},
+		SecretCode: util.HashSecretCode(secretCode),
+		ExpiredAt:  time.Now().Add(time.Minute),
+	}
+}
+
+func TestCreateUserTxAndVerifyEmailTx(t *testing.T) {
+	store := NewStore(testDB)
+	secretCode := util.RandomString(32)
+
+	arg := randomCreateUserTxParams(secretCode)
+	var sentTo string
+	arg.AfterCreate = func(user UserSvcUser, verifyEmail UserSvcVerifyEmail) error {
+		sentTo = verifyEmail.Email
+		return nil
+	}
+
+	created, err := store.CreateUserTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Email, sentTo)
+	require.Equal(t, "inactive", created.User.Status.String)
+	require.True(t, created.User.EmailVerifiedAt.IsZero())
+	require.Equal(t, created.User.Username, created.VerifyEmail.Username)
+	require.False(t, created.VerifyEmail.IsUsed)
+
+	// A wrong code must not activate the user
+	_, err = store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailID:    created.VerifyEmail.ID,
+		SecretCode: util.HashSecretCode(util.RandomString(32)),
+	})
+	require.ErrorIs(t, err, ErrInvalidVerifyEmail)
+
+	verified, err := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailID:    created.VerifyEmail.ID,
+		SecretCode: util.HashSecretCode(secretCode),
+	})
+	require.NoError(t, err)
+	require.True(t, verified.VerifyEmail.IsUsed)
+	require.Equal(t, "active", verified.User.Status.String)
+	require.WithinDuration(t, time.Now(), verified.User.EmailVerifiedAt, time.Second)
+
+	// The code can only be used once
+	_, err = store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailID:    created.VerifyEmail.ID,
+		SecretCode: util.HashSecretCode(secretCode),
+	})
+	require.ErrorIs(t, err, ErrInvalidVerifyEmail)
+}
+
+func TestCreateUserTxRollback(t *testing.T) {
+	store := NewStore(testDB)
+
+	arg := randomCreateUserTxParams(util.RandomString(32))
+	sendErr := errors.New("mail server unavailable")
+	arg.AfterCreate = func(user UserSvcUser, verifyEmail UserSvcVerifyEmail) error {
+		return sendErr
+	}
+
+	_, err := store.CreateUserTx(context.Background(), arg)
+	require.ErrorIs(t, err, sendErr)
+
+	_, err = testQueries.GetUserByValue(context.Background(), arg.Username)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestVerifyEmailTxKeepsDeactivatedUser(t *testing.T) {
+	store := NewStore(testDB)
+	secretCode := util.RandomString(32)
+
+	created, err := store.CreateUserTx(context.Background(), randomCreateUserTxParams(secretCode))
+	require.NoError(t, err)
+	_, err = testQueries.ActivateUser(context.Background(), ActivateUserParams{
+		Username: created.User.Username,
+		Email:    created.User.Email,
+	})
+	require.NoError(t, err)
+
+	// An admin deactivated the user, who changes the email address afterwards
+	arg := UpdateUserTxParams{
+		UpdateUserParams: UpdateUserParams{
+			Email:  util.ConvertToText(util.RandomEmail()),
+			Status: util.ConvertToText("inactive"),
+			ID:     created.User.ID,
+		},
+		SecretCode: util.HashSecretCode(secretCode),
+		ExpiredAt:  time.Now().Add(time.Minute),
+		AfterEmailChange: func(user UserSvcUser, verifyEmail UserSvcVerifyEmail) error {
+			return nil
+		},
+	}
+	updated, err := store.UpdateUserTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), updated.User.EmailChangedAt, time.Second)
+
+	// Verifying the new address doesn't reactivate the user
+	verified, err := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailID:    updated.VerifyEmail.ID,
+		SecretCode: util.HashSecretCode(secretCode),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "inactive", verified.User.Status.String)
+	require.WithinDuration(t, time.Now(), verified.User.EmailVerifiedAt, time.Second)
+}
@@ -12,6 +12,45 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const activateUser = `-- name: ActivateUser :one
+UPDATE "user_svc"."Users"
+SET
+    status = CASE WHEN status = 'inactive' AND email_verified_at = '0001-01-01 00:00:00Z' AND email_changed_at = '0001-01-01 00:00:00Z' THEN 'active' ELSE status END,
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE username = $1 AND email = $2
+RETURNING id, username, full_name, email, password_hash, password_salt, country_code, role_id, status, last_login_at, username_changed_at, email_changed_at, password_changed_at, created_at, updated_at, email_verified_at
+`
+
+type ActivateUserParams struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+func (q *Queries) ActivateUser(ctx context.Context, arg ActivateUserParams) (UserSvcUser, error) {
+	row := q.db.QueryRow(ctx, activateUser, arg.Username, arg.Email)
+	var i UserSvcUser
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordHash,
+		&i.PasswordSalt,
+		&i.CountryCode,
+		&i.RoleID,
+		&i.Status,
+		&i.LastLoginAt,
+		&i.UsernameChangedAt,
+		&i.EmailChangedAt,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO "user_svc"."Users" (
  username,
@@ -37,7 +76,7 @@ INSERT INTO "user_svc"."Users" (
     CURRENT_TIMESTAMP,
     CURRENT_TIMESTAMP
 )
-RETURNING id, username, full_name, email, password_hash, password_salt, country_code, role_id, status, last_login_at, username_changed_at, email_changed_at, password_changed_at, created_at, updated_at
+RETURNING id, username, full_name, email, password_hash, password_salt, country_code, role_id, status, last_login_at, username_changed_at, email_changed_at, password_changed_at, created_at, updated_at, email_verified_at
 `
 
 type CreateUserParams struct {
@@ -79,6 +118,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (UserSvc
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -103,8 +143,37 @@ func (q *Queries) DeleteUserByValue(ctx context.Context, username string) error
 	return err
 }
 
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, username, full_name, email, password_hash, password_salt, country_code, role_id, status, last_login_at, username_changed_at, email_changed_at, password_changed_at, created_at, updated_at, email_verified_at FROM "user_svc"."Users"
+WHERE email = $1 LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (UserSvcUser, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, email)
+	var i UserSvcUser
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordHash,
+		&i.PasswordSalt,
+		&i.CountryCode,
+		&i.RoleID,
+		&i.Status,
+		&i.LastLoginAt,
+		&i.UsernameChangedAt,
+		&i.EmailChangedAt,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
 const getUserById = `-- name: GetUserById :one
-SELECT id, username, full_name, email, password_hash, password_salt, country_code, role_id, status, last_login_at, username_changed_at, email_changed_at, password_changed_at, created_at, updated_at FROM "user_svc"."Users"
+SELECT id, username, full_name, email, password_hash, password_salt, country_code, role_id, status, last_login_at, username_changed_at, email_changed_at, password_changed_at, created_at, updated_at, email_verified_at FROM "user_svc"."Users"
 WHERE id = $1 LIMIT 1
 `
 
@@ -127,12 +196,13 @@ func (q *Queries) GetUserById(ctx context.Context, id int64) (UserSvcUser, error
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const getUserByValue = `-- name: GetUserByValue :one
-SELECT id, username, full_name, email, password_hash, password_salt, country_code, role_id, status, last_login_at, username_changed_at, email_changed_at, password_changed_at, created_at, updated_at FROM "user_svc"."Users"
+SELECT id, username, full_name, email, password_hash, password_salt, country_code, role_id, status, last_login_at, username_changed_at, email_changed_at, password_changed_at, created_at, updated_at, email_verified_at FROM "user_svc"."Users"
 WHERE username = $1 LIMIT 1
 `
 
@@ -155,6 +225,7 @@ func (q *Queries) GetUserByValue(ctx context.Context, username string) (UserSvcU
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -226,40 +297,55 @@ func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]ListUse
 	return items, nil
 }
 
+const rehashUserPassword = `-- name: RehashUserPassword :execrows
+UPDATE "user_svc"."Users"
+SET password_hash = $1, password_salt = ''
+WHERE username = $2 AND password_hash = $3
+`
+
+type RehashUserPasswordParams struct {
+	NewPasswordHash string `json:"new_password_hash"`
+	Username        string `json:"username"`
+	OldPasswordHash string `json:"old_password_hash"`
+}
+
+func (q *Queries) RehashUserPassword(ctx context.Context, arg RehashUserPasswordParams) (int64, error) {
+	result, err := q.db.Exec(ctx, rehashUserPassword, arg.NewPasswordHash, arg.Username, arg.OldPasswordHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE "user_svc"."Users"
 SET 
     username = COALESCE($1, username),
     full_name = COALESCE($2, full_name),
     email = COALESCE($3, email),
-    password_hash = COALESCE($4, password_hash),
-    password_salt = COALESCE($5, password_salt),
-    country_code = COALESCE($6, country_code),
-    role_id = COALESCE($7, role_id),
-    status = COALESCE($8, status),
-    last_login_at = COALESCE($9, last_login_at),
-    username_changed_at = COALESCE($10, username_changed_at),
-    email_changed_at = COALESCE($11, email_changed_at),
-    password_changed_at = COALESCE($12, password_changed_at),
-    created_at = COALESCE($13, created_at),
+    country_code = COALESCE($4, country_code),
+    role_id = COALESCE($5, role_id),
+    status = COALESCE($6, status),
+    last_login_at = COALESCE($7, last_login_at),
+    username_changed_at = COALESCE($8, username_changed_at),
+    email_changed_at = CASE WHEN $3 IS NOT NULL AND $3 <> email THEN NOW() ELSE COALESCE($9, email_changed_at) END,
+    email_verified_at = CASE WHEN $3 IS NOT NULL AND $3 <> email THEN '0001-01-01 00:00:00Z' ELSE email_verified_at END,
+    created_at = COALESCE($10, created_at),
     updated_at = NOW()
-WHERE "user_svc"."Users".id = $14
-RETURNING id, username, full_name, email, password_hash, password_salt, country_code, role_id, status, last_login_at, username_changed_at, email_changed_at, password_changed_at, created_at, updated_at
+WHERE "user_svc"."Users".id = $11
+RETURNING id, username, full_name, email, password_hash, password_salt, country_code, role_id, status, last_login_at, username_changed_at, email_changed_at, password_changed_at, created_at, updated_at, email_verified_at
 `
 
 type UpdateUserParams struct {
 	Username          pgtype.Text        `json:"username"`
 	FullName          pgtype.Text        `json:"full_name"`
 	Email             pgtype.Text        `json:"email"`
-	PasswordHash      pgtype.Text        `json:"password_hash"`
-	PasswordSalt      pgtype.Text        `json:"password_salt"`
 	CountryCode       pgtype.Text        `json:"country_code"`
 	RoleID            pgtype.Int8        `json:"role_id"`
 	Status            pgtype.Text        `json:"status"`
 	LastLoginAt       pgtype.Timestamptz `json:"last_login_at"`
 	UsernameChangedAt pgtype.Timestamptz `json:"username_changed_at"`
 	EmailChangedAt    pgtype.Timestamptz `json:"email_changed_at"`
-	PasswordChangedAt pgtype.Timestamptz `json:"password_changed_at"`
 	CreatedAt         pgtype.Timestamptz `json:"created_at"`
 	ID                int64              `json:"id"`
 }
@@ -269,15 +355,12 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (UserSvc
 		arg.Username,
 		arg.FullName,
 		arg.Email,
-		arg.PasswordHash,
-		arg.PasswordSalt,
 		arg.CountryCode,
 		arg.RoleID,
 		arg.Status,
 		arg.LastLoginAt,
 		arg.UsernameChangedAt,
 		arg.EmailChangedAt,
-		arg.PasswordChangedAt,
 		arg.CreatedAt,
 		arg.ID,
 	)
@@ -298,6 +381,48 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (UserSvc
 		&i.PasswordChangedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE "user_svc"."Users"
+SET
+    password_hash = $1,
+    password_salt = $2,
+    password_changed_at = NOW(),
+    updated_at = NOW()
+WHERE username = $3
+RETURNING id, username, full_name, email, password_hash, password_salt, country_code, role_id, status, last_login_at, username_changed_at, email_changed_at, password_changed_at, created_at, updated_at, email_verified_at
+`
+
+type UpdateUserPasswordParams struct {
+	PasswordHash string `json:"password_hash"`
+	PasswordSalt string `json:"password_salt"`
+	Username     string `json:"username"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (UserSvcUser, error) {
+	row := q.db.QueryRow(ctx, updateUserPassword, arg.PasswordHash, arg.PasswordSalt, arg.Username)
+	var i UserSvcUser
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Email,
+		&i.PasswordHash,
+		&i.PasswordSalt,
+		&i.CountryCode,
+		&i.RoleID,
+		&i.Status,
+		&i.LastLoginAt,
+		&i.UsernameChangedAt,
+		&i.EmailChangedAt,
+		&i.PasswordChangedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
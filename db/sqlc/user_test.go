@@ -2,7 +2,6 @@ package db
 
 import (
 	"context"
-	"encoding/base64"
 	"testing"
 	"time"
 
@@ -11,9 +10,7 @@ import (
 )
 
 func createRandomUser(t *testing.T) UserSvcUser {
-	byteHash, err := util.HashPassword(util.RandomPassword())
-	hashedPassword := base64.StdEncoding.EncodeToString(byteHash.Hash)
-	passwordSalt := base64.StdEncoding.EncodeToString(byteHash.Salt)
+	hashedPassword, err := util.HashPassword(util.RandomPassword(), util.DefaultArgon2idParams())
 	require.NoError(t, err)
 
 	arg := CreateUserParams{
@@ -21,7 +18,6 @@ func createRandomUser(t *testing.T) UserSvcUser {
 		FullName:     util.RandomString(12),
 		Email:        util.RandomEmail(),
 		PasswordHash: hashedPassword,
-		PasswordSalt: passwordSalt,
 		CountryCode:  util.RandomCountryCode(),
 		RoleID:       util.ConvertToInt8(util.RandomInt(1, 3)),
 		Status:       util.ConvertToText(util.RandomString(12)),
@@ -35,7 +31,7 @@ func createRandomUser(t *testing.T) UserSvcUser {
 	require.Equal(t, arg.FullName, user.FullName)
 	require.Equal(t, arg.Email, user.Email)
 	require.NotEmpty(t, user.PasswordHash)
-	require.NotEmpty(t, user.PasswordSalt)
+	require.Empty(t, user.PasswordSalt)
 	require.Equal(t, arg.CountryCode, user.CountryCode)
 	require.Equal(t, arg.RoleID, user.RoleID)
 	require.Equal(t, arg.Status, user.Status)
@@ -43,6 +39,7 @@ func createRandomUser(t *testing.T) UserSvcUser {
 	require.True(t, user.UsernameChangedAt.IsZero())
 	require.True(t, user.EmailChangedAt.IsZero())
 	require.True(t, user.PasswordChangedAt.IsZero())
+	require.True(t, user.EmailVerifiedAt.IsZero())
 	require.WithinDuration(t, time.Now(), user.CreatedAt, time.Second)
 	require.WithinDuration(t, time.Now(), user.UpdatedAt, time.Second)
 
@@ -64,7 +61,7 @@ func TestGetUserByID(t *testing.T) {
 	require.Equal(t, user.FullName, fetchedUser.FullName)
 	require.Equal(t, user.Email, fetchedUser.Email)
 	require.NotEmpty(t, fetchedUser.PasswordHash)
-	require.NotEmpty(t, fetchedUser.PasswordSalt)
+	require.Empty(t, fetchedUser.PasswordSalt)
 	require.Equal(t, user.CountryCode, fetchedUser.CountryCode)
 	require.Equal(t, user.RoleID, fetchedUser.RoleID)
 	require.Equal(t, user.Status, fetchedUser.Status)
@@ -87,7 +84,7 @@ func TestGetUserByUsername(t *testing.T) {
 	require.Equal(t, user.FullName, fetchedUser.FullName)
 	require.Equal(t, user.Email, fetchedUser.Email)
 	require.NotEmpty(t, fetchedUser.PasswordHash)
-	require.NotEmpty(t, fetchedUser.PasswordSalt)
+	require.Empty(t, fetchedUser.PasswordSalt)
 	require.Equal(t, user.CountryCode, fetchedUser.CountryCode)
 	require.Equal(t, user.RoleID, fetchedUser.RoleID)
 	require.Equal(t, user.Status, fetchedUser.Status)
@@ -146,7 +143,6 @@ func TestUpdateUser(t *testing.T) {
 		Username: util.ConvertToText(util.RandomUsername()),
 		FullName: util.ConvertToText(util.RandomString(12)),
 		Email:    util.ConvertToText(util.RandomEmail()),
-		PasswordHash: util.ConvertToText(base64.StdEncoding.EncodeToString([]byte(util.RandomString(32)))),
 		Status: util.ConvertToText("active"),
 		ID: user.ID,
 	}
@@ -157,8 +153,34 @@ func TestUpdateUser(t *testing.T) {
 	require.NotEqual(t, user.Username, updatedUser.Username)
 	require.NotEqual(t, user.FullName, updatedUser.FullName)
 	require.NotEqual(t, user.Email, updatedUser.Email)
-	require.NotEqual(t, user.PasswordHash, updatedUser.PasswordHash)
+	require.Equal(t, user.PasswordHash, updatedUser.PasswordHash)
 	require.NotEqual(t, user.Status, updatedUser.Status)
 	require.True(t, user.LastLoginAt.IsZero())
 	require.WithinDuration(t, time.Now(), updatedUser.UpdatedAt, time.Minute)
-}
\ No newline at end of file
+}
+func TestUpdateUserEmailClearsVerification(t *testing.T) {
+	user := createRandomUser(t)
+
+	verified, err := testQueries.ActivateUser(context.Background(), ActivateUserParams{
+		Username: user.Username,
+		Email:    user.Email,
+	})
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), verified.EmailVerifiedAt, time.Second)
+
+	// Updates that keep the email address keep its verification
+	updatedUser, err := testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		FullName: util.ConvertToText(util.RandomString(12)),
+		Email:    util.ConvertToText(user.Email),
+		ID:       user.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, verified.EmailVerifiedAt, updatedUser.EmailVerifiedAt)
+
+	updatedUser, err = testQueries.UpdateUser(context.Background(), UpdateUserParams{
+		Email: util.ConvertToText(util.RandomEmail()),
+		ID:    user.ID,
+	})
+	require.NoError(t, err)
+	require.True(t, updatedUser.EmailVerifiedAt.IsZero())
+}
@@ -0,0 +1,177 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Streamfair/streamfair_user_svc/apikey"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	authorizationHeader     = "authorization"
+	authorizationTypeBearer = "bearer"
+	authorizationTypeApiKey = "apikey"
+)
+
+// payloadContextKey is the context key under which the verified token payload is stored.
+type payloadContextKey struct{}
+
+// AuthInterceptor verifies the bearer token or API key of every unary request to a method that is not public
+// and stores the token payload in the context of the request.
+func (server *Server) AuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if server.isPublicMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	payload, err := server.authorizeUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(context.WithValue(ctx, payloadContextKey{}, payload), req)
+}
+
+// StreamAuthInterceptor verifies the bearer token or API key of every stream to a method that is not public
+// and stores the token payload in the context of the stream.
+func (server *Server) StreamAuthInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if server.isPublicMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
+	payload, err := server.authorizeUser(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authorizedStream{
+		ServerStream: ss,
+		ctx:          context.WithValue(ss.Context(), payloadContextKey{}, payload),
+	})
+}
+
+// authorizedStream wraps a grpc.ServerStream to carry the context with the token payload.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authorizedStream) Context() context.Context {
+	return s.ctx
+}
+
+// isPublicMethod reports whether the given full method name is one of the configured public methods.
+func (server *Server) isPublicMethod(fullMethod string) bool {
+	for _, method := range server.config.GrpcPublicMethods {
+		if fullMethod == method {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeUser extracts the authorization header from the incoming metadata and verifies it.
+func (server *Server) authorizeUser(ctx context.Context) (*token.Payload, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "missing metadata")
+	}
+
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 {
+		return nil, status.Errorf(codes.Unauthenticated, "authorization header is missing")
+	}
+
+	return server.verifyAuthorizationHeader(ctx, values[0], server.extractMetadata(ctx).ClientIP)
+}
+
+// verifyAuthorizationHeader verifies the bearer token of an authorization header value and checks that it
+// wasn't revoked, or authenticates the key of an ApiKey authorization header from the given client address.
+func (server *Server) verifyAuthorizationHeader(ctx context.Context, value, clientIP string) (*token.Payload, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return nil, status.Errorf(codes.Unauthenticated, "authorization header is invalid")
+	}
+
+	switch authorizationType := strings.ToLower(fields[0]); authorizationType {
+	case authorizationTypeBearer:
+	case authorizationTypeApiKey:
+		return server.authenticateApiKey(ctx, fields[1], clientIP)
+	default:
+		return nil, status.Errorf(codes.Unauthenticated, "authorization type '%s' is not supported", authorizationType)
+	}
+
+	payload, err := token.VerifyToken(server.tokenMaker, fields[1])
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid access token: %v", err)
+	}
+
+	// Tokens of the client credentials grant act on behalf of a client and not of a user
+	if payload.Username == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "access token was not issued to a user")
+	}
+
+	if err := server.revocations.Check(ctx, payload); err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid access token: %v", err)
+	}
+
+	return payload, nil
+}
+
+// authenticateApiKey verifies an API key. API keys are revoked one by one and aren't tracked by the token revocations.
+func (server *Server) authenticateApiKey(ctx context.Context, rawKey, clientIP string) (*token.Payload, error) {
+	payload, err := server.apiKeys.Authenticate(ctx, rawKey, clientIP)
+	if err != nil {
+		if errors.Is(err, apikey.ErrInvalidKey) ||
+			errors.Is(err, apikey.ErrKeyRevoked) ||
+			errors.Is(err, apikey.ErrKeyExpired) ||
+			errors.Is(err, apikey.ErrUserNotActive) {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid API key: %v", err)
+		}
+		log.Error().Err(err).Msg("failed to authenticate API key")
+		return nil, status.Errorf(codes.Internal, "failed to authenticate API key")
+	}
+
+	return payload, nil
+}
+
+// authPayloadFromContext returns the token payload stored in the context by the auth interceptors.
+func authPayloadFromContext(ctx context.Context) (*token.Payload, bool) {
+	payload, ok := ctx.Value(payloadContextKey{}).(*token.Payload)
+	return payload, ok
+}
+
+// authorize loads the policy subject of the authenticated caller and runs the given check against it.
+func (server *Server) authorize(ctx context.Context, check func(subject *policy.Subject) error) error {
+	payload, ok := authPayloadFromContext(ctx)
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "missing access token")
+	}
+
+	subject, err := server.policy.LoadSubject(ctx, payload.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return status.Errorf(codes.Unauthenticated, "user of the access token does not exist")
+		}
+		return handleDatabaseError(err)
+	}
+
+	// OAuth clients and API keys only get the permissions of the user that were granted to them
+	if payload.ClientID != "" {
+		subject = subject.WithScopes(payload.Scopes)
+	}
+
+	if err := check(subject); err != nil {
+		return status.Errorf(codes.PermissionDenied, "%v", err)
+	}
+
+	return nil
+}
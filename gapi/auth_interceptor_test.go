@@ -0,0 +1,193 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/apikey"
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func newContextWithBearerToken(t *testing.T, tokenMaker token.Maker, authorizationType string, username string, duration time.Duration) context.Context {
+	accessToken, _, err := tokenMaker.CreateLocalToken(token.Claims{Username: username}, duration)
+	require.NoError(t, err)
+
+	md := metadata.MD{
+		authorizationHeader: []string{fmt.Sprintf("%s %s", authorizationType, accessToken)},
+	}
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	server := newTestServer(t, nil, func(config *util.Config) {
+		config.GrpcPublicMethods = []string{"/pb.UserService/CreateUser", "/grpc.health.v1.Health/Check"}
+	})
+	tokenMaker := server.tokenMaker
+
+	username := util.RandomUsername()
+
+	testCases := []struct {
+		name       string
+		fullMethod string
+		buildCtx   func(t *testing.T) context.Context
+		checkCode  codes.Code
+	}{
+		{
+			name:       "OK",
+			fullMethod: "/pb.UserService/GetUserById",
+			buildCtx: func(t *testing.T) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, authorizationTypeBearer, username, time.Minute)
+			},
+			checkCode: codes.OK,
+		},
+		{
+			name:       "PublicMethod",
+			fullMethod: "/pb.UserService/CreateUser",
+			buildCtx: func(t *testing.T) context.Context {
+				return context.Background()
+			},
+			checkCode: codes.OK,
+		},
+		{
+			name:       "HealthCheck",
+			fullMethod: "/grpc.health.v1.Health/Check",
+			buildCtx: func(t *testing.T) context.Context {
+				return context.Background()
+			},
+			checkCode: codes.OK,
+		},
+		{
+			// Methods of other services that end like a public method aren't public
+			name:       "SuffixOfPublicMethod",
+			fullMethod: "/other.UserService/CreateUser",
+			buildCtx: func(t *testing.T) context.Context {
+				return context.Background()
+			},
+			checkCode: codes.Unauthenticated,
+		},
+		{
+			name:       "NoAuthorization",
+			fullMethod: "/pb.UserService/GetUserById",
+			buildCtx: func(t *testing.T) context.Context {
+				return metadata.NewIncomingContext(context.Background(), metadata.MD{})
+			},
+			checkCode: codes.Unauthenticated,
+		},
+		{
+			name:       "UnsupportedAuthorizationType",
+			fullMethod: "/pb.UserService/GetUserById",
+			buildCtx: func(t *testing.T) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, "unsupported", username, time.Minute)
+			},
+			checkCode: codes.Unauthenticated,
+		},
+		{
+			name:       "InvalidAuthorizationFormat",
+			fullMethod: "/pb.UserService/GetUserById",
+			buildCtx: func(t *testing.T) context.Context {
+				md := metadata.MD{authorizationHeader: []string{authorizationTypeBearer}}
+				return metadata.NewIncomingContext(context.Background(), md)
+			},
+			checkCode: codes.Unauthenticated,
+		},
+		{
+			name:       "ExpiredToken",
+			fullMethod: "/pb.UserService/GetUserById",
+			buildCtx: func(t *testing.T) context.Context {
+				return newContextWithBearerToken(t, tokenMaker, authorizationTypeBearer, username, -time.Minute)
+			},
+			checkCode: codes.Unauthenticated,
+		},
+		{
+			name:       "RefreshToken",
+			fullMethod: "/pb.UserService/GetUserById",
+			buildCtx: func(t *testing.T) context.Context {
+				refreshToken, _, err := tokenMaker.CreateRefreshToken(token.Claims{Username: username}, time.Minute)
+				require.NoError(t, err)
+				md := metadata.MD{authorizationHeader: []string{fmt.Sprintf("%s %s", authorizationTypeBearer, refreshToken)}}
+				return metadata.NewIncomingContext(context.Background(), md)
+			},
+			checkCode: codes.Unauthenticated,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			info := &grpc.UnaryServerInfo{FullMethod: tc.fullMethod}
+			handler := func(ctx context.Context, req any) (any, error) {
+				payload, ok := authPayloadFromContext(ctx)
+				if !server.isPublicMethod(tc.fullMethod) {
+					require.True(t, ok)
+					require.Equal(t, username, payload.Username)
+				}
+				return nil, nil
+			}
+
+			_, err := server.AuthInterceptor(tc.buildCtx(t), nil, info, handler)
+			require.Equal(t, tc.checkCode, status.Code(err))
+		})
+	}
+}
+
+func TestAuthInterceptorApiKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+
+	server := newTestServer(t, store)
+
+	user := db.UserSvcUser{ID: 7, Username: util.RandomUsername(), Status: util.ConvertToText(util.StatusActive)}
+	rawKey := "sf_abcd1234_" + util.RandomString(43)
+	key := db.UserSvcApiKey{
+		ID:      1,
+		UserID:  user.ID,
+		Prefix:  "abcd1234",
+		KeyHash: util.HashSecretCode(rawKey),
+		Scopes:  []string{policy.PermissionReadUsers},
+	}
+
+	store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Eq(key.Prefix)).Times(1).Return(key, nil)
+	store.EXPECT().GetUserById(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(user, nil)
+	store.EXPECT().
+		UpdateApiKeyLastUsed(gomock.Any(), gomock.Eq(db.UpdateApiKeyLastUsedParams{ID: key.ID, LastUsedIp: "192.0.2.1"})).
+		Times(1).
+		Return(nil)
+
+	md := metadata.MD{authorizationHeader: []string{"ApiKey " + rawKey}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 4711}})
+	ctx = metadata.NewIncomingContext(ctx, md)
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.UserService/GetUserById"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		payload, ok := authPayloadFromContext(ctx)
+		require.True(t, ok)
+		require.Equal(t, user.Username, payload.Username)
+		require.Equal(t, key.Scopes, payload.Scopes)
+		require.True(t, apikey.IsAPIKey(payload))
+		return nil, nil
+	}
+
+	_, err := server.AuthInterceptor(ctx, nil, info, handler)
+	require.NoError(t, err)
+
+	// Unknown keys
+	store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcApiKey{}, pgx.ErrNoRows)
+
+	md = metadata.MD{authorizationHeader: []string{"ApiKey sf_unknown1_" + util.RandomString(43)}}
+	_, err = server.AuthInterceptor(metadata.NewIncomingContext(context.Background(), md), nil, info, handler)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
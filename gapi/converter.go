@@ -1,19 +1,25 @@
 package gapi
 
 import (
+	"encoding/json"
+
+	sessionpb "github.com/Streamfair/common_proto/SessionService/pb/session"
 	pb "github.com/Streamfair/common_proto/UserService/pb/user"
 	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// ConvertUser converts a database user into its protobuf representation.
+// The password hash and salt never leave the server.
 func ConvertUser(user db.UserSvcUser) *pb.User {
 	return &pb.User{
 		Id:                user.ID,
 		Username:          user.Username,
 		FullName:          user.FullName,
 		Email:             user.Email,
-		PasswordHash:      user.PasswordHash,
-		PasswordSalt:      user.PasswordSalt,
 		CountryCode:       user.CountryCode,
 		RoleId:            user.RoleID.Int64,
 		Status:            user.Status.String,
@@ -44,3 +50,34 @@ func convertUsersList(users []db.ListUsersRow) []*pb.Users {
 	}
 	return userList
 }
+
+// ConvertSession converts a session without its refresh token.
+func ConvertSession(session db.UserSvcSession) *sessionpb.Session {
+	return &sessionpb.Session{
+		Uuid:      session.ID.String(),
+		Username:  session.Username,
+		UserAgent: session.UserAgent,
+		ClientIp:  session.ClientIp,
+		IsBlocked: session.IsBlocked,
+		ExpiresAt: timestamppb.New(session.ExpiresAt),
+		CreatedAt: timestamppb.New(session.CreatedAt),
+	}
+}
+
+// convertToStruct converts the JSON form of a response of a plain HTTP handler into a struct, so that the
+// hand-written services without messages in the shared protos return the same fields as the HTTP handlers.
+func convertToStruct(v any) (*structpb.Struct, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to marshal response: %v", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to marshal response: %v", err)
+	}
+	out, err := structpb.NewStruct(fields)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to marshal response: %v", err)
+	}
+	return out, nil
+}
@@ -3,12 +3,14 @@ package gapi
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	db_err "github.com/Streamfair/common_proto/error"
 	"github.com/jackc/pgx/v5/pgconn"
 	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 // API Error Handling
@@ -64,6 +66,16 @@ func invalidArgumentError(violation *CustomError) error {
 	return statusDetails.Err()
 }
 
+// retryError creates a status error with the given code, which tells the client when to retry in a RetryInfo detail.
+func retryError(code codes.Code, message string, retryAfter time.Duration) error {
+	statusRetry := status.New(code, message)
+	statusDetails, err := statusRetry.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if err != nil {
+		return statusRetry.Err()
+	}
+	return statusDetails.Err()
+}
+
 // handleDatabaseError is a function that takes an error and returns a new error with additional details.
 func handleDatabaseError(err error) error {
 	var pgErr *pgconn.PgError
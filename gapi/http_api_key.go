@@ -0,0 +1,148 @@
+package gapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/apikey"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/status"
+)
+
+type createApiKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is optional, keys without an expiry are valid until they are revoked.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type apiKeyResponse struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	Scopes     []string  `json:"scopes"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	LastUsedIP string    `json:"last_used_ip"`
+	RevokedAt  time.Time `json:"revoked_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type createApiKeyResponse struct {
+	// Key is only returned once, only the prefix of the key can be listed afterwards.
+	Key    string         `json:"key"`
+	ApiKey apiKeyResponse `json:"api_key"`
+}
+
+func newApiKeyResponse(key db.UserSvcApiKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIp,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+// ApiKeys is a plain HTTP handler of the gateway server for the API keys of the authenticated user. GET lists
+// the keys, POST creates a key and returns it once, DELETE revokes the key of the id query parameter.
+// Keys can't be created with API keys or the tokens of OAuth clients, so that a leaked key can't create more keys.
+func (server *Server) ApiKeys(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost && req.Method != http.MethodDelete {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	payload, err := server.verifyAuthorizationHeader(req.Context(), req.Header.Get(authorizationHeader), server.httpClientIP(req))
+	if err != nil {
+		writeError(res, http.StatusUnauthorized, status.Convert(err).Message())
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		keys, err := server.apiKeys.List(req.Context(), payload.UserID)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to list API keys")
+			writeError(res, http.StatusInternalServerError, "failed to list API keys")
+			return
+		}
+
+		rsp := make([]apiKeyResponse, 0, len(keys))
+		for _, key := range keys {
+			rsp = append(rsp, newApiKeyResponse(key))
+		}
+		writeJSON(res, http.StatusOK, rsp)
+
+	case http.MethodPost:
+		if payload.ClientID != "" {
+			writeError(res, http.StatusForbidden, "API keys can only be created with the access token of a login")
+			return
+		}
+
+		var body createApiKeyRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeError(res, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		key, rawKey, err := server.apiKeys.Create(req.Context(), apikey.CreateParams{
+			Username:  payload.Username,
+			Name:      body.Name,
+			Scopes:    body.Scopes,
+			ExpiresAt: body.ExpiresAt,
+		})
+		if err != nil {
+			writeApiKeyError(res, err)
+			return
+		}
+		writeJSON(res, http.StatusOK, createApiKeyResponse{Key: rawKey, ApiKey: newApiKeyResponse(key)})
+
+	case http.MethodDelete:
+		// A key can revoke itself, e.g. when a bot is shut down, but not the other keys of the user,
+		// and OAuth clients can't revoke the keys of the user
+		if payload.ClientID != "" && !apikey.IsAPIKey(payload) {
+			writeError(res, http.StatusForbidden, "OAuth clients can't revoke API keys")
+			return
+		}
+
+		keyID, err := strconv.ParseInt(req.URL.Query().Get("id"), 10, 64)
+		if err != nil || keyID < 1 {
+			writeError(res, http.StatusBadRequest, "invalid API key ID")
+			return
+		}
+
+		if err := server.apiKeys.Revoke(req.Context(), payload, keyID); err != nil {
+			writeApiKeyError(res, err)
+			return
+		}
+		writeJSON(res, http.StatusOK, map[string]string{"status": "API key revoked"})
+	}
+}
+
+// writeApiKeyError maps the errors of the API key management to HTTP responses.
+func writeApiKeyError(res http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, apikey.ErrKeyNotFound):
+		writeError(res, http.StatusNotFound, err.Error())
+	case errors.Is(err, apikey.ErrOtherKey):
+		writeError(res, http.StatusForbidden, err.Error())
+	case errors.Is(err, apikey.ErrInvalidName),
+		errors.Is(err, apikey.ErrInvalidScope),
+		errors.Is(err, apikey.ErrInvalidExpiry):
+		writeError(res, http.StatusBadRequest, err.Error())
+	case errors.Is(err, apikey.ErrTooManyKeys):
+		writeError(res, http.StatusConflict, err.Error())
+	default:
+		log.Error().Err(err).Msg("failed to manage API keys")
+		writeError(res, http.StatusInternalServerError, "failed to manage API keys")
+	}
+}
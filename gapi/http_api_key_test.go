@@ -0,0 +1,119 @@
+package gapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestApiKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+	tokenMaker := server.tokenMaker
+
+	user := db.UserSvcUser{
+		ID:       7,
+		Username: util.RandomUsername(),
+		RoleID:   util.ConvertToInt8(policy.RoleUser),
+		Status:   util.ConvertToText(util.StatusActive),
+	}
+	accessToken, _, err := tokenMaker.CreateLocalToken(token.Claims{UserID: user.ID, Username: user.Username}, time.Minute)
+	require.NoError(t, err)
+	authorization := fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken)
+
+	// Create
+	var created db.UserSvcApiKey
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().
+		ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).
+		Times(1).
+		Return([]db.UserSvcPermission{{Name: policy.PermissionReadUsers}}, nil)
+	store.EXPECT().ListApiKeysByUserId(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return([]db.UserSvcApiKey{}, nil)
+	store.EXPECT().
+		CreateApiKey(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateApiKeyParams) (db.UserSvcApiKey, error) {
+			created = db.UserSvcApiKey{ID: 1, UserID: arg.UserID, Name: arg.Name, Prefix: arg.Prefix, KeyHash: arg.KeyHash, Scopes: arg.Scopes}
+			return created, nil
+		})
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/streamfair/v1/api_keys", strings.NewReader(`{"name": "bot"}`))
+	request.Header.Set(authorizationHeader, authorization)
+	server.ApiKeys(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var createRsp createApiKeyResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &createRsp))
+	require.Equal(t, []string{policy.PermissionReadUsers}, createRsp.ApiKey.Scopes)
+
+	// List with the new key
+	store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Eq(created.Prefix)).Times(1).Return(created, nil)
+	store.EXPECT().GetUserById(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(user, nil)
+	store.EXPECT().UpdateApiKeyLastUsed(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+	store.EXPECT().ListApiKeysByUserId(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return([]db.UserSvcApiKey{created}, nil)
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/streamfair/v1/api_keys", nil)
+	request.Header.Set(authorizationHeader, "ApiKey "+createRsp.Key)
+	server.ApiKeys(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), created.Prefix)
+	require.NotContains(t, recorder.Body.String(), created.KeyHash)
+
+	// The key can neither revoke other keys nor change the security settings of the account
+	store.EXPECT().GetApiKeyByPrefix(gomock.Any(), gomock.Eq(created.Prefix)).Times(3).Return(created, nil)
+	store.EXPECT().GetUserById(gomock.Any(), gomock.Eq(user.ID)).Times(2).Return(user, nil)
+	store.EXPECT().UpdateApiKeyLastUsed(gomock.Any(), gomock.Any()).AnyTimes().Return(nil)
+	store.EXPECT().RevokeApiKey(gomock.Any(), gomock.Any()).Times(0)
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodDelete, "/streamfair/v1/api_keys?id=2", nil)
+	request.Header.Set(authorizationHeader, "ApiKey "+createRsp.Key)
+	server.ApiKeys(recorder, request)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodPost, "/streamfair/v1/mfa/totp/enroll", nil)
+	request.Header.Set(authorizationHeader, "ApiKey "+createRsp.Key)
+	server.EnrollTotp(recorder, request)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+
+	// Revoke
+	store.EXPECT().
+		RevokeApiKey(gomock.Any(), gomock.Eq(db.RevokeApiKeyParams{ID: 1, UserID: user.ID})).
+		Times(1).
+		Return(int64(1), nil)
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodDelete, "/streamfair/v1/api_keys?id=1", nil)
+	request.Header.Set(authorizationHeader, authorization)
+	server.ApiKeys(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// Invalid ID
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodDelete, "/streamfair/v1/api_keys?id=abc", nil)
+	request.Header.Set(authorizationHeader, authorization)
+	server.ApiKeys(recorder, request)
+	require.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	// Without a token
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/streamfair/v1/api_keys", nil)
+	server.ApiKeys(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
@@ -0,0 +1,210 @@
+package gapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/Streamfair/streamfair_user_svc/validator"
+	"github.com/google/uuid"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type changePasswordRequest struct {
+	OldPassword         string `json:"old_password"`
+	NewPassword         string `json:"new_password"`
+	RevokeOtherSessions bool   `json:"revoke_other_sessions"`
+	// SessionID is the session the password is changed from, it stays signed in when the other sessions are revoked.
+	SessionID string `json:"session_id"`
+}
+
+type changePasswordResponse struct {
+	Username        string `json:"username"`
+	RevokedSessions int64  `json:"revoked_sessions"`
+	// Session holds the new tokens of the current session when the other sessions are revoked, as the revocation
+	// includes the tokens the request was made with.
+	Session *renewAccessTokenResponse `json:"session,omitempty"`
+}
+
+// ChangePassword is a plain HTTP handler of the gateway server that sets a new password for the user of the
+// bearer token after checking the old one and optionally signs the user out of all other devices.
+func (server *Server) ChangePassword(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	payload, err := server.verifyAuthorizationHeader(req.Context(), req.Header.Get(authorizationHeader), server.httpClientIP(req))
+	if err != nil {
+		writeError(res, http.StatusUnauthorized, status.Convert(err).Message())
+		return
+	}
+	if payload.ClientID != "" {
+		writeError(res, http.StatusForbidden, errDelegatedAccountChange.Error())
+		return
+	}
+
+	var body changePasswordRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(res, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validator.ValidatePassword(body.NewPassword); err != nil {
+		writeError(res, http.StatusBadRequest, fmt.Sprintf("new_password: %v", err))
+		return
+	}
+	if body.NewPassword == body.OldPassword {
+		writeError(res, http.StatusBadRequest, "new password must differ from the old password")
+		return
+	}
+
+	var sessionID uuid.UUID
+	if body.SessionID != "" {
+		sessionID, err = uuid.Parse(body.SessionID)
+		if err != nil {
+			writeError(res, http.StatusBadRequest, "session_id must be a valid UUID")
+			return
+		}
+	}
+
+	user, err := server.store.GetUserByValue(req.Context(), payload.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(res, http.StatusUnauthorized, "user of the access token does not exist")
+			return
+		}
+		log.Error().Err(err).Msg("failed to get user")
+		writeError(res, http.StatusInternalServerError, "failed to change password")
+		return
+	}
+
+	if err := util.ComparePassword(user.PasswordHash, user.PasswordSalt, body.OldPassword); err != nil {
+		writeError(res, http.StatusUnauthorized, "old password is incorrect")
+		return
+	}
+
+	var currentSession db.UserSvcSession
+	if body.RevokeOtherSessions && sessionID != uuid.Nil {
+		session, err := server.store.GetSession(req.Context(), sessionID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msg("failed to get session")
+			writeError(res, http.StatusInternalServerError, "failed to change password")
+			return
+		}
+		if err != nil || session.Username != user.Username || session.IsBlocked || session.ReplacedBy.Valid {
+			writeError(res, http.StatusBadRequest, "session not found")
+			return
+		}
+		currentSession = session
+	}
+
+	hashedPassword, err := util.HashPassword(body.NewPassword, server.config.Argon2idParams())
+	if err != nil {
+		log.Error().Err(err).Msg("failed to hash password")
+		writeError(res, http.StatusInternalServerError, "failed to change password")
+		return
+	}
+
+	result, err := server.store.ChangePasswordTx(req.Context(), db.ChangePasswordTxParams{
+		Username:            user.Username,
+		PasswordHash:        hashedPassword,
+		RevokeOtherSessions: body.RevokeOtherSessions,
+		KeepFamilyID:        currentSession.FamilyID,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed to change password")
+		writeError(res, http.StatusInternalServerError, "failed to change password")
+		return
+	}
+
+	rsp := changePasswordResponse{
+		Username:        result.User.Username,
+		RevokedSessions: result.RevokedSessions,
+	}
+	if body.RevokeOtherSessions {
+		// Access tokens issued before the change are revoked along with the sessions. The password was changed
+		// already, so a failure is logged and the access tokens expire on their own.
+		if err := server.revocations.RevokeUserTokens(req.Context(), result.User.Username); err != nil {
+			log.Error().Err(err).Str("username", result.User.Username).Msg("failed to revoke access tokens after password change")
+		}
+
+		rsp.Session, err = server.reissueSessionTokens(req.Context(), result.User, currentSession, &Metadata{
+			UserAgent: req.UserAgent(),
+			ClientIP:  server.httpClientIP(req),
+		})
+		if err != nil {
+			writeError(res, runtime.HTTPStatusFromCode(status.Code(err)), status.Convert(err).Message())
+			return
+		}
+	}
+
+	writeJSON(res, http.StatusOK, rsp)
+}
+
+// reissueSessionTokens issues new tokens to the client that revoked the tokens of the user. The given session is
+// rotated to the new refresh token, without a session the client is signed in with a new session.
+func (server *Server) reissueSessionTokens(ctx context.Context, user db.UserSvcUser, current db.UserSvcSession, mtdt *Metadata) (*renewAccessTokenResponse, error) {
+	claims, err := server.tokenClaims(ctx, user)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load token claims")
+		return nil, status.Errorf(codes.Internal, "failed to issue new tokens")
+	}
+
+	accessToken, accessPayload, err := server.createAccessToken(claims)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create access token")
+		return nil, status.Errorf(codes.Internal, "failed to issue new tokens")
+	}
+
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateRefreshToken(claims, server.config.RefreshTokenDuration)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create refresh token")
+		return nil, status.Errorf(codes.Internal, "failed to issue new tokens")
+	}
+
+	newSession := db.CreateSessionParams{
+		ID:           refreshPayload.ID,
+		FamilyID:     refreshPayload.ID,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    mtdt.UserAgent,
+		ClientIp:     mtdt.ClientIP,
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	}
+	var session db.UserSvcSession
+	if current.ID == uuid.Nil {
+		session, err = server.store.CreateSession(ctx, newSession)
+	} else {
+		newSession.FamilyID = current.FamilyID
+		var result db.RotateSessionTxResult
+		result, err = server.store.RotateSessionTx(ctx, db.RotateSessionTxParams{
+			OldSessionID: current.ID,
+			NewSession:   newSession,
+		})
+		session = result.Session
+	}
+	if err != nil {
+		if errors.Is(err, db.ErrSessionAlreadyRotated) {
+			return nil, status.Errorf(codes.FailedPrecondition, "session has been renewed in the meantime")
+		}
+		log.Error().Err(err).Msg("failed to create session")
+		return nil, status.Errorf(codes.Internal, "failed to issue new tokens")
+	}
+
+	return &renewAccessTokenResponse{
+		SessionID:             session.ID.String(),
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+	}, nil
+}
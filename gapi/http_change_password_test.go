@@ -0,0 +1,230 @@
+package gapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/revocation"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestChangePassword(t *testing.T) {
+	symmetricKey := util.RandomString(32)
+	tokenMaker, err := token.NewLocalPasetoMaker(symmetricKey)
+	require.NoError(t, err)
+
+	password := util.RandomString(10)
+	hashedPassword, err := util.HashPassword(password, util.Argon2idParams{})
+	require.NoError(t, err)
+
+	user := db.UserSvcUser{
+		Username:     util.RandomUsername(),
+		PasswordHash: hashedPassword,
+	}
+	session := db.UserSvcSession{ID: uuid.New(), FamilyID: uuid.New(), Username: user.Username}
+
+	accessToken, _, err := tokenMaker.CreateLocalToken(token.Claims{UserID: user.ID, Username: user.Username}, time.Minute)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		authorization string
+		body          changePasswordRequest
+		buildStubs    func(store *mock_db.MockStore)
+		statusCode    int
+		checkResponse func(t *testing.T, server *Server, rsp changePasswordResponse)
+	}{
+		{
+			name:          "OK",
+			authorization: fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken),
+			body: changePasswordRequest{
+				OldPassword:         password,
+				NewPassword:         util.RandomString(12),
+				RevokeOtherSessions: true,
+				SessionID:           session.ID.String(),
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(session.ID)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.ChangePasswordTxParams) (db.ChangePasswordTxResult, error) {
+						require.Equal(t, session.FamilyID, arg.KeepFamilyID)
+						return db.ChangePasswordTxResult{User: user, RevokedSessions: 1}, nil
+					})
+				store.EXPECT().
+					SetTokenWatermark(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.SetTokenWatermarkParams) (db.UserSvcTokenWatermark, error) {
+						require.Equal(t, user.Username, arg.Username)
+						return db.UserSvcTokenWatermark{Username: arg.Username, NotBefore: arg.NotBefore}, nil
+					})
+				// The current session is rotated to the new refresh token
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RotateSessionTxParams) (db.RotateSessionTxResult, error) {
+						require.Equal(t, session.ID, arg.OldSessionID)
+						require.Equal(t, session.FamilyID, arg.NewSession.FamilyID)
+						return db.RotateSessionTxResult{Session: db.UserSvcSession{ID: arg.NewSession.ID}}, nil
+					})
+			},
+			statusCode: http.StatusOK,
+			checkResponse: func(t *testing.T, server *Server, rsp changePasswordResponse) {
+				require.Equal(t, int64(1), rsp.RevokedSessions)
+				require.NotNil(t, rsp.Session)
+
+				// The access token of the request is revoked, the new one is accepted
+				payload, err := server.tokenMaker.VerifyLocalToken(accessToken)
+				require.NoError(t, err)
+				require.ErrorIs(t, server.revocations.Check(context.Background(), payload), revocation.ErrTokenRevoked)
+				payload, err = server.tokenMaker.VerifyLocalToken(rsp.Session.AccessToken)
+				require.NoError(t, err)
+				require.NoError(t, server.revocations.Check(context.Background(), payload))
+			},
+		},
+		{
+			name:          "RevokeAllSessions",
+			authorization: fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken),
+			body: changePasswordRequest{
+				OldPassword:         password,
+				NewPassword:         util.RandomString(12),
+				RevokeOtherSessions: true,
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ChangePasswordTxResult{User: user, RevokedSessions: 2}, nil)
+				store.EXPECT().
+					SetTokenWatermark(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserSvcTokenWatermark{Username: user.Username, NotBefore: time.Now()}, nil)
+				// Without a session the client is signed in with a new session
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateSessionParams) (db.UserSvcSession, error) {
+						require.Equal(t, arg.ID, arg.FamilyID)
+						return db.UserSvcSession{ID: arg.ID}, nil
+					})
+			},
+			statusCode: http.StatusOK,
+			checkResponse: func(t *testing.T, server *Server, rsp changePasswordResponse) {
+				require.NotNil(t, rsp.Session)
+				require.NotEmpty(t, rsp.Session.SessionID)
+				require.NotEmpty(t, rsp.Session.RefreshToken)
+			},
+		},
+		{
+			name:          "KeepPassword",
+			authorization: fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken),
+			body: changePasswordRequest{
+				OldPassword: password,
+				NewPassword: util.RandomString(12),
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ChangePasswordTxResult{User: user}, nil)
+				store.EXPECT().
+					SetTokenWatermark(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			statusCode: http.StatusOK,
+			checkResponse: func(t *testing.T, server *Server, rsp changePasswordResponse) {
+				// The sessions stay signed in, so no new tokens are issued
+				require.Nil(t, rsp.Session)
+			},
+		},
+		{
+			name:          "WrongOldPassword",
+			authorization: fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken),
+			body: changePasswordRequest{
+				OldPassword: util.RandomString(10),
+				NewPassword: util.RandomString(12),
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ChangePasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "NoAuthorization",
+			body: changePasswordRequest{
+				OldPassword: password,
+				NewPassword: util.RandomString(12),
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store, withTokenSymmetricKey(symmetricKey))
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/streamfair/v1/change_password", bytes.NewReader(data))
+			if tc.authorization != "" {
+				request.Header.Set(authorizationHeader, tc.authorization)
+			}
+			server.ChangePassword(recorder, request)
+
+			require.Equal(t, tc.statusCode, recorder.Code)
+			if tc.checkResponse != nil {
+				var rsp changePasswordResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				tc.checkResponse(t, server, rsp)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package gapi
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/social"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/status"
+)
+
+type externalIdentityResponse struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type linkExternalIdentityResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// mfaRequiredResponse is the response of an external login of a user with two-factor authentication.
+// The MFA token is exchanged for the access and refresh tokens at the login_mfa endpoint.
+type mfaRequiredResponse struct {
+	MfaRequired       bool      `json:"mfa_required"`
+	MfaToken          string    `json:"mfa_token"`
+	MfaTokenExpiresAt time.Time `json:"mfa_token_expires_at"`
+}
+
+func newExternalIdentityResponse(identity db.UserSvcExternalIdentity) externalIdentityResponse {
+	return externalIdentityResponse{
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: identity.CreatedAt,
+	}
+}
+
+// LoginUserExternal is a plain HTTP handler of the gateway server that redirects the user to the login of the
+// identity provider of the provider query parameter.
+func (server *Server) LoginUserExternal(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	location, err := server.social.StartLogin(req.Context(), req.URL.Query().Get("provider"))
+	if err != nil {
+		writeSocialError(res, err)
+		return
+	}
+
+	http.Redirect(res, req, location, http.StatusFound)
+}
+
+// LoginUserExternalCallback is a plain HTTP handler of the gateway server that the identity provider redirects
+// the user back to. Logins respond like the LoginUser RPC, links of a user only return the linked identity.
+func (server *Server) LoginUserExternalCallback(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	query := req.URL.Query()
+	// Users that decline the login at the provider come back with an error instead of a code
+	if query.Get("error") != "" {
+		writeError(res, http.StatusUnauthorized, social.ErrProviderRejected.Error()+": "+query.Get("error"))
+		return
+	}
+
+	result, err := server.social.Complete(req.Context(), query.Get("provider"), query.Get("state"), query.Get("code"))
+	if err != nil {
+		writeSocialError(res, err)
+		return
+	}
+	if result.Linking && result.User.ID == 0 {
+		writeJSON(res, http.StatusOK, newExternalIdentityResponse(result.Identity))
+		return
+	}
+
+	user := result.User
+	if user.Status.String != util.StatusActive {
+		writeError(res, http.StatusForbidden, "user account is not active, the email address has to be verified first")
+		return
+	}
+
+	// The provider only replaces the password, users with two-factor authentication still need their code
+	mfaEnabled, err := server.mfa.IsEnabled(req.Context(), user.Username)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to check two-factor authentication")
+		writeError(res, http.StatusInternalServerError, "failed to complete login")
+		return
+	}
+	if mfaEnabled {
+		mfaToken, expiredAt, err := server.mfa.CreateChallenge(req.Context(), user.Username)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to create MFA challenge")
+			writeError(res, http.StatusInternalServerError, "failed to complete login")
+			return
+		}
+		writeJSON(res, http.StatusAccepted, mfaRequiredResponse{
+			MfaRequired:       true,
+			MfaToken:          mfaToken,
+			MfaTokenExpiresAt: expiredAt,
+		})
+		return
+	}
+
+	mtdt := &Metadata{
+		UserAgent: req.UserAgent(),
+		ClientIP:  server.httpClientIP(req),
+	}
+	rsp, err := server.createLoginSession(req.Context(), user, mtdt)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create session")
+		writeError(res, http.StatusInternalServerError, "failed to complete login")
+		return
+	}
+
+	writeProtoJSON(res, http.StatusOK, rsp)
+}
+
+// ExternalIdentities is a plain HTTP handler of the gateway server for the accounts of identity providers linked
+// to the user of the bearer token. GET lists the accounts, POST starts a link with the provider query parameter
+// and returns the URL the user is redirected to, DELETE unlinks the account of the provider.
+func (server *Server) ExternalIdentities(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost && req.Method != http.MethodDelete {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	payload, err := server.verifyAuthorizationHeader(req.Context(), req.Header.Get(authorizationHeader), server.httpClientIP(req))
+	if err != nil {
+		writeError(res, http.StatusUnauthorized, status.Convert(err).Message())
+		return
+	}
+
+	provider := req.URL.Query().Get("provider")
+
+	switch req.Method {
+	case http.MethodGet:
+		identities, err := server.social.ListIdentities(req.Context(), payload.UserID)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to list external identities")
+			writeError(res, http.StatusInternalServerError, "failed to list external identities")
+			return
+		}
+
+		rsp := make([]externalIdentityResponse, 0, len(identities))
+		for _, identity := range identities {
+			rsp = append(rsp, newExternalIdentityResponse(identity))
+		}
+		writeJSON(res, http.StatusOK, rsp)
+
+	case http.MethodPost:
+		if payload.ClientID != "" {
+			writeError(res, http.StatusForbidden, "OAuth clients can't link accounts of identity providers")
+			return
+		}
+
+		location, err := server.social.StartLink(req.Context(), provider, payload.UserID)
+		if err != nil {
+			writeSocialError(res, err)
+			return
+		}
+		writeJSON(res, http.StatusOK, linkExternalIdentityResponse{AuthorizationURL: location})
+
+	case http.MethodDelete:
+		if payload.ClientID != "" {
+			writeError(res, http.StatusForbidden, "OAuth clients can't unlink accounts of identity providers")
+			return
+		}
+
+		if err := server.social.Unlink(req.Context(), payload.UserID, provider); err != nil {
+			writeSocialError(res, err)
+			return
+		}
+		writeJSON(res, http.StatusOK, map[string]string{"status": "external identity unlinked"})
+	}
+}
+
+// writeSocialError maps the errors of the external login to HTTP responses.
+func writeSocialError(res http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, social.ErrUnknownProvider), errors.Is(err, social.ErrNotLinked):
+		writeError(res, http.StatusNotFound, err.Error())
+	case errors.Is(err, social.ErrInvalidState),
+		errors.Is(err, social.ErrProviderRejected),
+		errors.Is(err, social.ErrInvalidIDToken):
+		writeError(res, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, social.ErrEmailNotVerified), errors.Is(err, social.ErrAccountNotVerified):
+		writeError(res, http.StatusForbidden, err.Error())
+	case errors.Is(err, social.ErrIdentityLinked), errors.Is(err, social.ErrProviderLinked):
+		writeError(res, http.StatusConflict, err.Error())
+	default:
+		log.Error().Err(err).Msg("failed to complete external login")
+		writeError(res, http.StatusInternalServerError, "failed to complete external login")
+	}
+}
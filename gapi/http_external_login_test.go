@@ -0,0 +1,191 @@
+package gapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/social/socialtest"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// withExternalProvider lets users log in with the test provider, which is registered as "test".
+func withExternalProvider(t *testing.T, provider *socialtest.Server) testServerOption {
+	providersFile := provider.ProvidersFile(t, "test", "https://localhost:8080/streamfair/v1/login_external/callback?provider=test")
+	return func(config *util.Config) {
+		config.MfaChallengeDuration = time.Minute
+		config.ExternalProvidersFile = providersFile
+		config.ExternalLoginStateDuration = time.Minute
+	}
+}
+
+// expectExternalLoginState stores the state of the next started login and lets the callback consume it.
+func expectExternalLoginState(store *mock_db.MockStore) {
+	var loginState db.UserSvcExternalLoginState
+	store.EXPECT().
+		CreateExternalLoginState(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateExternalLoginStateParams) (db.UserSvcExternalLoginState, error) {
+			loginState = db.UserSvcExternalLoginState{
+				StateHash:    arg.StateHash,
+				Provider:     arg.Provider,
+				Nonce:        arg.Nonce,
+				CodeVerifier: arg.CodeVerifier,
+				UserID:       arg.UserID,
+				ExpiredAt:    arg.ExpiredAt,
+			}
+			return loginState, nil
+		})
+	store.EXPECT().
+		ConsumeExternalLoginState(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, stateHash string) (db.UserSvcExternalLoginState, error) {
+			if stateHash != loginState.StateHash {
+				return db.UserSvcExternalLoginState{}, pgx.ErrNoRows
+			}
+			return loginState, nil
+		})
+}
+
+func externalCallbackURL(code, state string) string {
+	query := url.Values{"provider": {"test"}, "code": {code}, "state": {state}}
+	return "/streamfair/v1/login_external/callback?" + query.Encode()
+}
+
+func TestLoginUserExternal(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	provider := socialtest.NewServer(t, "streamfair", util.RandomString(32))
+	server := newTestServer(t, store, withExternalProvider(t, provider))
+	email := util.RandomEmail()
+
+	// The first login creates the user
+	expectExternalLoginState(store)
+	store.EXPECT().GetExternalIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcExternalIdentity{}, pgx.ErrNoRows)
+	store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(email)).Times(1).Return(db.UserSvcUser{}, pgx.ErrNoRows)
+	store.EXPECT().
+		CreateExternalUserTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateExternalUserTxParams) (db.CreateExternalUserTxResult, error) {
+			user := db.UserSvcUser{ID: 7, Username: arg.Username, Email: arg.Email, RoleID: arg.RoleID, Status: arg.Status}
+			return db.CreateExternalUserTxResult{User: user}, nil
+		})
+	store.EXPECT().GetTotpSecret(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcTotpSecret{}, pgx.ErrNoRows)
+	store.EXPECT().ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).Times(1).Return([]db.UserSvcPermission{}, nil)
+	store.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateSessionParams) (db.UserSvcSession, error) {
+			return db.UserSvcSession{ID: arg.ID, Username: arg.Username, ExpiresAt: arg.ExpiresAt}, nil
+		})
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/streamfair/v1/login_external?provider=test", nil)
+	server.LoginUserExternal(recorder, request)
+	require.Equal(t, http.StatusFound, recorder.Code)
+
+	code, state := provider.Authorize(t, recorder.Header().Get("Location"), map[string]any{
+		"sub":            "12345",
+		"email":          email,
+		"email_verified": true,
+	})
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, externalCallbackURL(code, state), nil)
+	server.LoginUserExternalCallback(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var rsp map[string]any
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+	require.NotEmpty(t, rsp["access_token"])
+
+	// Unknown providers
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/streamfair/v1/login_external?provider=other", nil)
+	server.LoginUserExternal(recorder, request)
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestExternalIdentities(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	provider := socialtest.NewServer(t, "streamfair", util.RandomString(32))
+	server := newTestServer(t, store, withExternalProvider(t, provider))
+	userID := int64(7)
+
+	accessToken, _, err := server.tokenMaker.CreateLocalToken(token.Claims{UserID: userID, Username: util.RandomUsername()}, time.Minute)
+	require.NoError(t, err)
+	authorization := fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken)
+
+	// Link
+	expectExternalLoginState(store)
+	store.EXPECT().GetExternalIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcExternalIdentity{}, pgx.ErrNoRows)
+	store.EXPECT().
+		CreateExternalIdentity(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateExternalIdentityParams) (db.UserSvcExternalIdentity, error) {
+			require.Equal(t, userID, arg.UserID)
+			return db.UserSvcExternalIdentity{ID: 1, UserID: arg.UserID, Provider: arg.Provider, Subject: arg.Subject}, nil
+		})
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/streamfair/v1/external_identities?provider=test", nil)
+	request.Header.Set(authorizationHeader, authorization)
+	server.ExternalIdentities(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var linkRsp linkExternalIdentityResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &linkRsp))
+	code, state := provider.Authorize(t, linkRsp.AuthorizationURL, map[string]any{"sub": "12345"})
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, externalCallbackURL(code, state), nil)
+	server.LoginUserExternalCallback(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var identityRsp externalIdentityResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &identityRsp))
+	require.Equal(t, "12345", identityRsp.Subject)
+
+	// List
+	store.EXPECT().
+		ListExternalIdentitiesByUserId(gomock.Any(), gomock.Eq(userID)).
+		Times(1).
+		Return([]db.UserSvcExternalIdentity{{ID: 1, UserID: userID, Provider: "test", Subject: "12345"}}, nil)
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/streamfair/v1/external_identities", nil)
+	request.Header.Set(authorizationHeader, authorization)
+	server.ExternalIdentities(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `"provider":"test"`)
+
+	// Unlink
+	store.EXPECT().
+		DeleteExternalIdentity(gomock.Any(), gomock.Eq(db.DeleteExternalIdentityParams{UserID: userID, Provider: "test"})).
+		Times(1).
+		Return(int64(1), nil)
+
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodDelete, "/streamfair/v1/external_identities?provider=test", nil)
+	request.Header.Set(authorizationHeader, authorization)
+	server.ExternalIdentities(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// Without a token
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodGet, "/streamfair/v1/external_identities", nil)
+	server.ExternalIdentities(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
@@ -0,0 +1,68 @@
+package gapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// The gateway server serves a few plain HTTP endpoints for flows that have no RPC in the shared protos,
+// e.g. links in emails that are opened in a browser.
+
+type httpErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeJSON writes the given value as JSON response with the given status code.
+func writeJSON(res http.ResponseWriter, statusCode int, value any) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(statusCode)
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		log.Error().Err(err).Msg("failed to write HTTP response")
+	}
+}
+
+// writeError writes an error response with the given status code.
+func writeError(res http.ResponseWriter, statusCode int, message string) {
+	writeJSON(res, statusCode, httpErrorResponse{Error: message})
+}
+
+// writeProtoJSON writes the given message as JSON response in the format of the gateway's responses.
+func writeProtoJSON(res http.ResponseWriter, statusCode int, message proto.Message) {
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(message)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal HTTP response")
+		writeError(res, http.StatusInternalServerError, "failed to write response")
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(statusCode)
+	if _, err := res.Write(data); err != nil {
+		log.Error().Err(err).Msg("failed to write HTTP response")
+	}
+}
+
+// errDelegatedAccountChange is reported when an OAuth client or an API key tries to change the password or
+// the two-factor authentication of its user. Only the tokens of a login may change the security of the account.
+var errDelegatedAccountChange = errors.New("OAuth clients and API keys can't change the security settings of the account")
+
+// authorizeHTTP checks the permissions of the user of the payload like the gRPC handlers do and writes the error
+// response if the check fails. It reports whether the request is authorized.
+func (server *Server) authorizeHTTP(res http.ResponseWriter, req *http.Request, payload *token.Payload, check func(subject *policy.Subject) error) bool {
+	ctx := context.WithValue(req.Context(), payloadContextKey{}, payload)
+	if err := server.authorize(ctx, check); err != nil {
+		writeError(res, runtime.HTTPStatusFromCode(status.Code(err)), status.Convert(err).Message())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package gapi
+
+import (
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// IntrospectTokenHTTP is a plain HTTP handler of the gateway server that tells other services whether a token is active
+// and who it was issued to, as defined by RFC 7662. The token is sent as form parameter and callers authenticate
+// with the client certificate of an introspection client.
+func (server *Server) IntrospectTokenHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if _, err := server.introspector.AuthorizeClient(req.TLS); err != nil {
+		writeError(res, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	tokenString := req.PostFormValue("token")
+	if tokenString == "" {
+		writeError(res, http.StatusBadRequest, "token is required")
+		return
+	}
+
+	rsp, err := server.introspector.Introspect(req.Context(), tokenString)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to introspect token")
+		writeError(res, http.StatusInternalServerError, "failed to introspect token")
+		return
+	}
+
+	writeJSON(res, http.StatusOK, rsp)
+}
@@ -0,0 +1,68 @@
+package gapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestIntrospectTokenHTTP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		client     string
+		token      string
+		statusCode int
+		body       string
+	}{
+		{
+			name:       "InactiveToken",
+			client:     "stream-svc",
+			token:      "invalid",
+			statusCode: http.StatusOK,
+			body:       `{"active": false}`,
+		},
+		{
+			name:       "UnknownClient",
+			client:     "chat-svc",
+			token:      "invalid",
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "MissingToken",
+			client:     "stream-svc",
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := newTestServer(t, mock_db.NewMockStore(ctrl), withIntrospectionClient)
+
+			form := url.Values{}
+			if tc.token != "" {
+				form.Set("token", tc.token)
+			}
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/streamfair/v1/introspect", strings.NewReader(form.Encode()))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			state := verifiedClientState(tc.client)
+			request.TLS = &state
+
+			server.IntrospectTokenHTTP(recorder, request)
+			require.Equal(t, tc.statusCode, recorder.Code)
+			if tc.body != "" {
+				require.JSONEq(t, tc.body, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package gapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Streamfair/streamfair_user_svc/apikey"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/status"
+)
+
+type logoutUserRequest struct {
+	// RefreshToken is optional, the token family of its session is revoked along with the access token.
+	RefreshToken string `json:"refresh_token"`
+}
+
+type logoutUserResponse struct {
+	RevokedSessions int64 `json:"revoked_sessions"`
+}
+
+// LogoutUser is a plain HTTP handler of the gateway server that revokes the bearer token, so that it can't be
+// used until it expires, and signs the device of the given refresh token out.
+func (server *Server) LogoutUser(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	payload, err := server.verifyAuthorizationHeader(req.Context(), req.Header.Get(authorizationHeader), server.httpClientIP(req))
+	if err != nil {
+		writeError(res, http.StatusUnauthorized, status.Convert(err).Message())
+		return
+	}
+	if apikey.IsAPIKey(payload) {
+		writeError(res, http.StatusBadRequest, "API keys can't log out, revoke the API key instead")
+		return
+	}
+
+	var body logoutUserRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		writeError(res, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	var familyID uuid.UUID
+	if body.RefreshToken != "" {
+		refreshPayload, err := server.tokenMaker.VerifyRefreshToken(body.RefreshToken)
+		if err != nil {
+			writeError(res, http.StatusBadRequest, "invalid refresh token")
+			return
+		}
+
+		session, err := server.store.GetSession(req.Context(), refreshPayload.ID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msg("failed to get session")
+			writeError(res, http.StatusInternalServerError, "failed to log out")
+			return
+		}
+		// Users can only sign out their own sessions
+		if err != nil || session.Username != payload.Username {
+			writeError(res, http.StatusBadRequest, "session not found")
+			return
+		}
+		familyID = session.FamilyID
+	}
+
+	if err := server.revocations.RevokeToken(req.Context(), payload); err != nil {
+		log.Error().Err(err).Msg("failed to revoke access token")
+		writeError(res, http.StatusInternalServerError, "failed to log out")
+		return
+	}
+
+	var revoked int64
+	if familyID != uuid.Nil {
+		revoked, err = server.store.BlockSessionFamily(req.Context(), familyID)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to block session family")
+			writeError(res, http.StatusInternalServerError, "failed to log out")
+			return
+		}
+	}
+
+	writeJSON(res, http.StatusOK, logoutUserResponse{RevokedSessions: revoked})
+}
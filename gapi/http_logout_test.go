@@ -0,0 +1,125 @@
+package gapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestLogoutUser(t *testing.T) {
+	symmetricKey := util.RandomString(32)
+	tokenMaker, err := token.NewLocalPasetoMaker(symmetricKey)
+	require.NoError(t, err)
+
+	username := util.RandomUsername()
+	accessToken, accessPayload, err := tokenMaker.CreateLocalToken(token.Claims{Username: username}, time.Minute)
+	require.NoError(t, err)
+	refreshToken, refreshPayload, err := tokenMaker.CreateRefreshToken(token.Claims{Username: username}, time.Hour)
+	require.NoError(t, err)
+	otherToken, otherPayload, err := tokenMaker.CreateRefreshToken(token.Claims{Username: util.RandomUsername()}, time.Hour)
+	require.NoError(t, err)
+	familyID := uuid.New()
+
+	testCases := []struct {
+		name          string
+		authorization string
+		body          string
+		buildStubs    func(store *mock_db.MockStore)
+		statusCode    int
+	}{
+		{
+			name:          "OK",
+			authorization: fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken),
+			body:          fmt.Sprintf(`{"refresh_token": %q}`, refreshToken),
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(refreshPayload.ID)).
+					Times(1).
+					Return(db.UserSvcSession{ID: refreshPayload.ID, FamilyID: familyID, Username: username}, nil)
+				store.EXPECT().
+					RevokeToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RevokeTokenParams) error {
+						require.Equal(t, accessPayload.ID, arg.ID)
+						return nil
+					})
+				store.EXPECT().
+					BlockSessionFamily(gomock.Any(), gomock.Eq(familyID)).
+					Times(1).
+					Return(int64(1), nil)
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:          "WithoutRefreshToken",
+			authorization: fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken),
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					RevokeToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+				store.EXPECT().
+					BlockSessionFamily(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:          "SessionOfOtherUser",
+			authorization: fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken),
+			body:          fmt.Sprintf(`{"refresh_token": %q}`, otherToken),
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetSession(gomock.Any(), gomock.Eq(otherPayload.ID)).
+					Times(1).
+					Return(db.UserSvcSession{ID: otherPayload.ID, Username: otherPayload.Username}, nil)
+				store.EXPECT().
+					RevokeToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "NoAuthorization",
+			buildStubs: func(store *mock_db.MockStore) {},
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store, withTokenSymmetricKey(symmetricKey))
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/streamfair/v1/logout", strings.NewReader(tc.body))
+			if tc.authorization != "" {
+				request.Header.Set(authorizationHeader, tc.authorization)
+			}
+			server.LogoutUser(recorder, request)
+			require.Equal(t, tc.statusCode, recorder.Code)
+
+			// The access token is rejected after the logout
+			if tc.statusCode == http.StatusOK {
+				_, err := server.verifyAuthorizationHeader(request.Context(), tc.authorization, "")
+				require.Error(t, err)
+			}
+		})
+	}
+}
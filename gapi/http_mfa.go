@@ -0,0 +1,277 @@
+package gapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/lockout"
+	"github.com/Streamfair/streamfair_user_svc/mfa"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mfaRequiredReason is the reason of the ErrorInfo detail that tells clients to complete the login with a code.
+const mfaRequiredReason = "MFA_REQUIRED"
+
+// mfaRequiredError creates the MFA challenge of a login with a correct password and returns it as FailedPrecondition,
+// because the login response of the shared protos has no field for it. The MFA token is exchanged for the access
+// and refresh tokens at the login_mfa endpoint of the gateway.
+func (server *Server) mfaRequiredError(ctx context.Context, username string) error {
+	mfaToken, expiredAt, err := server.mfa.CreateChallenge(ctx, username)
+	if err != nil {
+		return handleDatabaseError(err)
+	}
+
+	statusMfa := status.New(codes.FailedPrecondition, "two-factor authentication required")
+	statusDetails, err := statusMfa.WithDetails(&errdetails.ErrorInfo{
+		Reason: mfaRequiredReason,
+		Domain: server.config.ServerName,
+		Metadata: map[string]string{
+			"mfa_token":            mfaToken,
+			"mfa_token_expires_at": expiredAt.Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return status.Errorf(codes.Internal, "failed to create MFA challenge: %v", err)
+	}
+	return statusDetails.Err()
+}
+
+type loginUserMfaRequest struct {
+	MfaToken string `json:"mfa_token"`
+	// Code is a TOTP code of the authenticator app or one of the recovery codes.
+	Code string `json:"code"`
+}
+
+// LoginUserMfa is a plain HTTP handler of the gateway server that completes a login with two-factor authentication
+// and responds like the LoginUser RPC.
+func (server *Server) LoginUserMfa(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var body loginUserMfaRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(res, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if body.MfaToken == "" || body.Code == "" {
+		writeError(res, http.StatusBadRequest, "mfa_token and code are required")
+		return
+	}
+
+	mtdt := &Metadata{
+		UserAgent: req.UserAgent(),
+		ClientIP:  server.httpClientIP(req),
+	}
+
+	challenge, err := server.mfa.GetChallenge(req.Context(), body.MfaToken)
+	if err != nil {
+		writeMfaError(res, err)
+		return
+	}
+
+	if err := server.loginGuard.Check(req.Context(), challenge.Username, mtdt.ClientIP); err != nil {
+		var lockedErr *lockout.LockedError
+		if errors.As(err, &lockedErr) {
+			res.Header().Set("Retry-After", strconv.Itoa(int(lockedErr.RetryAfter.Seconds())))
+			writeError(res, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		log.Error().Err(err).Msg("failed to check login lockout")
+		writeError(res, http.StatusInternalServerError, "failed to complete login")
+		return
+	}
+
+	if err := server.mfa.CompleteChallenge(req.Context(), challenge, body.Code); err != nil {
+		if errors.Is(err, mfa.ErrInvalidCode) {
+			if err := server.loginGuard.RecordFailure(req.Context(), challenge.Username, mtdt.ClientIP); err != nil {
+				log.Error().Err(err).Str("username", challenge.Username).Msg("failed to record failed login attempt")
+			}
+		}
+		writeMfaError(res, err)
+		return
+	}
+
+	user, err := server.store.GetUserByValue(req.Context(), challenge.Username)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get user")
+		writeError(res, http.StatusInternalServerError, "failed to complete login")
+		return
+	}
+
+	if err := server.loginGuard.RecordSuccess(req.Context(), user.Username); err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to reset failed login attempts")
+	}
+
+	rsp, err := server.createLoginSession(req.Context(), user, mtdt)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create session")
+		writeError(res, http.StatusInternalServerError, "failed to complete login")
+		return
+	}
+
+	writeProtoJSON(res, http.StatusOK, rsp)
+}
+
+type enrollTotpResponse struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth URI of the secret, to be shown as QR code.
+	URI string `json:"uri"`
+}
+
+// EnrollTotp is a plain HTTP handler of the gateway server that generates a TOTP secret for the user of the
+// bearer token. Two-factor authentication is only enabled once the secret was confirmed with a code.
+func (server *Server) EnrollTotp(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	payload, err := server.verifyAuthorizationHeader(req.Context(), req.Header.Get(authorizationHeader), server.httpClientIP(req))
+	if err != nil {
+		writeError(res, http.StatusUnauthorized, status.Convert(err).Message())
+		return
+	}
+	if payload.ClientID != "" {
+		writeError(res, http.StatusForbidden, errDelegatedAccountChange.Error())
+		return
+	}
+
+	enrollment, err := server.mfa.Enroll(req.Context(), payload.Username)
+	if err != nil {
+		writeMfaError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, enrollTotpResponse{
+		Secret: enrollment.Secret,
+		URI:    enrollment.URI,
+	})
+}
+
+type confirmTotpRequest struct {
+	Code string `json:"code"`
+}
+
+type confirmTotpResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ConfirmTotp is a plain HTTP handler of the gateway server that enables two-factor authentication
+// for the user of the bearer token and returns the recovery codes.
+func (server *Server) ConfirmTotp(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	payload, err := server.verifyAuthorizationHeader(req.Context(), req.Header.Get(authorizationHeader), server.httpClientIP(req))
+	if err != nil {
+		writeError(res, http.StatusUnauthorized, status.Convert(err).Message())
+		return
+	}
+	if payload.ClientID != "" {
+		writeError(res, http.StatusForbidden, errDelegatedAccountChange.Error())
+		return
+	}
+
+	var body confirmTotpRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(res, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if body.Code == "" {
+		writeError(res, http.StatusBadRequest, "code is required")
+		return
+	}
+
+	recoveryCodes, err := server.mfa.Confirm(req.Context(), payload.Username, body.Code)
+	if err != nil {
+		writeMfaError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, confirmTotpResponse{RecoveryCodes: recoveryCodes})
+}
+
+type disableTotpRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// DisableTotp is a plain HTTP handler of the gateway server that turns two-factor authentication off for the user
+// of the bearer token, which requires both the password and a TOTP or recovery code.
+func (server *Server) DisableTotp(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	payload, err := server.verifyAuthorizationHeader(req.Context(), req.Header.Get(authorizationHeader), server.httpClientIP(req))
+	if err != nil {
+		writeError(res, http.StatusUnauthorized, status.Convert(err).Message())
+		return
+	}
+	if payload.ClientID != "" {
+		writeError(res, http.StatusForbidden, errDelegatedAccountChange.Error())
+		return
+	}
+
+	var body disableTotpRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(res, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if body.Password == "" || body.Code == "" {
+		writeError(res, http.StatusBadRequest, "password and code are required")
+		return
+	}
+
+	user, err := server.store.GetUserByValue(req.Context(), payload.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(res, http.StatusUnauthorized, "user of the access token does not exist")
+			return
+		}
+		log.Error().Err(err).Msg("failed to get user")
+		writeError(res, http.StatusInternalServerError, "failed to disable two-factor authentication")
+		return
+	}
+
+	if err := util.ComparePassword(user.PasswordHash, user.PasswordSalt, body.Password); err != nil {
+		writeError(res, http.StatusUnauthorized, "password is incorrect")
+		return
+	}
+
+	if err := server.mfa.Disable(req.Context(), user.Username, body.Code); err != nil {
+		writeMfaError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, map[string]string{"status": "two-factor authentication disabled"})
+}
+
+// writeMfaError maps the errors of the MFA manager to their HTTP response.
+func writeMfaError(res http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, mfa.ErrInvalidCode), errors.Is(err, mfa.ErrInvalidChallenge):
+		writeError(res, http.StatusUnauthorized, err.Error())
+	case errors.Is(err, mfa.ErrAlreadyEnabled), errors.Is(err, mfa.ErrNotEnabled):
+		writeError(res, http.StatusConflict, err.Error())
+	case errors.Is(err, mfa.ErrNotConfigured):
+		writeError(res, http.StatusNotImplemented, err.Error())
+	default:
+		log.Error().Err(err).Msg("two-factor authentication failed")
+		writeError(res, http.StatusInternalServerError, "two-factor authentication failed")
+	}
+}
@@ -0,0 +1,287 @@
+package gapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/common_proto/IdentityProvider/pb/login"
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/mfa"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// withMfa configures two-factor authentication.
+func withMfa(config *util.Config) {
+	config.MfaEncryptionKey = util.RandomString(32)
+	config.MfaIssuer = "Streamfair"
+	config.MfaChallengeDuration = time.Minute
+}
+
+// newTestMfaServer creates a server with two-factor authentication and returns the stored, confirmed
+// TOTP secret of the user together with its plaintext.
+func newTestMfaServer(t *testing.T, store db.Store, username string) (*Server, db.UserSvcTotpSecret, string) {
+	server := newTestServer(t, store, withMfa)
+
+	box, err := mfa.NewSecretBox(server.config.MfaEncryptionKey)
+	require.NoError(t, err)
+	secret, err := mfa.GenerateTotpSecret()
+	require.NoError(t, err)
+	ciphertext, err := box.Encrypt(secret, username)
+	require.NoError(t, err)
+
+	totpSecret := db.UserSvcTotpSecret{Username: username, SecretCiphertext: ciphertext, IsConfirmed: true}
+	return server, totpSecret, secret
+}
+
+func TestLoginUserMfaRequired(t *testing.T) {
+	password := util.RandomString(12)
+	hashedPassword, err := util.HashPassword(password, util.Argon2idParams{})
+	require.NoError(t, err)
+	user := db.UserSvcUser{
+		Username:     util.RandomUsername(),
+		PasswordHash: hashedPassword,
+		Status:       util.ConvertToText(util.StatusActive),
+	}
+
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	server, totpSecret, _ := newTestMfaServer(t, store, user.Username)
+
+	store.EXPECT().
+		GetLoginAttempts(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return([]db.UserSvcLoginAttempt{}, nil)
+	store.EXPECT().
+		GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(totpSecret, nil)
+	store.EXPECT().
+		CreateMfaChallenge(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateMfaChallengeParams) (db.UserSvcMfaChallenge, error) {
+			return db.UserSvcMfaChallenge{Username: arg.Username, TokenHash: arg.TokenHash, ExpiredAt: arg.ExpiredAt}, nil
+		})
+	// Neither the failed attempts are reset nor a session is created before the second step
+	store.EXPECT().
+		DeleteLoginAttempts(gomock.Any(), gomock.Any()).
+		Times(0)
+	store.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	_, err = server.LoginUser(context.Background(), &login.LoginUserRequest{Username: user.Username, Password: password})
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.FailedPrecondition, st.Code())
+
+	require.Len(t, st.Details(), 1)
+	errorInfo, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	require.True(t, ok)
+	require.Equal(t, mfaRequiredReason, errorInfo.GetReason())
+	require.NotEmpty(t, errorInfo.GetMetadata()["mfa_token"])
+	require.NotEmpty(t, errorInfo.GetMetadata()["mfa_token_expires_at"])
+}
+
+func TestLoginUserMfa(t *testing.T) {
+	user := db.UserSvcUser{Username: util.RandomUsername()}
+	mfaToken := util.RandomString(32)
+	challenge := db.UserSvcMfaChallenge{
+		ID:        util.RandomInt(1, 1000),
+		Username:  user.Username,
+		TokenHash: util.HashSecretCode(mfaToken),
+		ExpiredAt: time.Now().Add(time.Minute),
+	}
+
+	testCases := []struct {
+		name       string
+		code       func(secret string) string
+		buildStubs func(store *mock_db.MockStore, totpSecret db.UserSvcTotpSecret)
+		statusCode int
+	}{
+		{
+			name: "OK",
+			code: func(secret string) string {
+				code, err := mfa.TotpCode(secret, mfa.TotpStep(time.Now()))
+				require.NoError(t, err)
+				return code
+			},
+			buildStubs: func(store *mock_db.MockStore, totpSecret db.UserSvcTotpSecret) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Eq(challenge.TokenHash)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.UserSvcLoginAttempt{}, nil)
+				store.EXPECT().
+					GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(totpSecret, nil)
+				store.EXPECT().
+					UseTotpStep(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(int64(1), nil)
+				store.EXPECT().
+					UseMfaChallenge(gomock.Any(), gomock.Eq(challenge.ID)).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					DeleteLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1)
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			name: "InvalidCode",
+			code: func(string) string {
+				return "abcd-efgh-ijkl-mnop"
+			},
+			buildStubs: func(store *mock_db.MockStore, totpSecret db.UserSvcTotpSecret) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.UserSvcLoginAttempt{}, nil)
+				store.EXPECT().
+					UseRecoveryCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserSvcRecoveryCode{}, pgx.ErrNoRows)
+				store.EXPECT().
+					RecordMfaChallengeFailure(gomock.Any(), gomock.Eq(challenge.ID)).
+					Times(1)
+				// The wrong code counts as failed login of the user
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserSvcLoginAttempt{FailedAttempts: 1}, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name: "Locked",
+			code: func(string) string {
+				return "123456"
+			},
+			buildStubs: func(store *mock_db.MockStore, totpSecret db.UserSvcTotpSecret) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(challenge, nil)
+				store.EXPECT().
+					GetLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.UserSvcLoginAttempt{{FailedAttempts: 3, LockedUntil: time.Now().Add(time.Minute)}}, nil)
+				store.EXPECT().
+					GetTotpSecret(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			statusCode: http.StatusTooManyRequests,
+		},
+		{
+			name: "UnknownToken",
+			code: func(string) string {
+				return "123456"
+			},
+			buildStubs: func(store *mock_db.MockStore, totpSecret db.UserSvcTotpSecret) {
+				store.EXPECT().
+					GetMfaChallenge(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserSvcMfaChallenge{}, pgx.ErrNoRows)
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			server, totpSecret, secret := newTestMfaServer(t, store, user.Username)
+			tc.buildStubs(store, totpSecret)
+
+			data, err := json.Marshal(loginUserMfaRequest{MfaToken: mfaToken, Code: tc.code(secret)})
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/streamfair/v1/login_mfa", bytes.NewReader(data))
+			server.LoginUserMfa(recorder, request)
+			require.Equal(t, tc.statusCode, recorder.Code)
+
+			if tc.statusCode == http.StatusOK {
+				var rsp login.LoginUserResponse
+				require.NoError(t, protojson.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.NotEmpty(t, rsp.GetAccessToken())
+				require.NotEmpty(t, rsp.GetRefreshToken())
+			}
+		})
+	}
+}
+
+func TestDelegatedTokensCantChangeAccountSecurity(t *testing.T) {
+	username := util.RandomUsername()
+
+	handlers := map[string]func(server *Server) http.HandlerFunc{
+		"EnrollTotp":     func(server *Server) http.HandlerFunc { return server.EnrollTotp },
+		"ConfirmTotp":    func(server *Server) http.HandlerFunc { return server.ConfirmTotp },
+		"DisableTotp":    func(server *Server) http.HandlerFunc { return server.DisableTotp },
+		"ChangePassword": func(server *Server) http.HandlerFunc { return server.ChangePassword },
+	}
+
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// The store is only asked for the token revocations
+			store := mock_db.NewMockStore(ctrl)
+			server, _, _ := newTestMfaServer(t, store, username)
+
+			// The token of an OAuth client acting on behalf of the user
+			accessToken, _, err := server.tokenMaker.CreateLocalToken(token.Claims{Username: username, ClientID: "client"}, time.Minute)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{}`)))
+			request.Header.Set(authorizationHeader, authorizationTypeBearer+" "+accessToken)
+			handler(server)(recorder, request)
+
+			require.Equal(t, http.StatusForbidden, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package gapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Streamfair/streamfair_user_svc/oauth"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/status"
+)
+
+// AuthorizeOAuthClient is a plain HTTP handler of the gateway server for the authorization page, on which users
+// sign in and allow or deny the request of an OAuth client, and which redirects them back to the client.
+func (server *Server) AuthorizeOAuthClient(res http.ResponseWriter, req *http.Request) {
+	server.oauth.HandleAuthorize(res, req, server.httpClientIP(req))
+}
+
+// IssueOAuthToken is a plain HTTP handler of the gateway server for the token endpoint of the OAuth clients.
+// The request is form encoded as defined by RFC 6749.
+func (server *Server) IssueOAuthToken(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	tokenRequest, err := oauth.ParseTokenRequest(req)
+	if err != nil {
+		writeOAuthError(res, err)
+		return
+	}
+	tokenRequest.ClientIP = server.httpClientIP(req)
+
+	rsp, err := server.oauth.Token(req.Context(), tokenRequest)
+	if err != nil {
+		writeOAuthError(res, err)
+		return
+	}
+
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Pragma", "no-cache")
+	writeJSON(res, http.StatusOK, rsp)
+}
+
+// GetOAuthUserInfo is a plain HTTP handler of the gateway server that returns the OpenID Connect claims
+// of the user of the bearer token.
+func (server *Server) GetOAuthUserInfo(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	payload, err := server.verifyAuthorizationHeader(req.Context(), req.Header.Get(authorizationHeader), server.httpClientIP(req))
+	if err != nil {
+		writeError(res, http.StatusUnauthorized, status.Convert(err).Message())
+		return
+	}
+
+	info, err := server.oauth.UserInfo(req.Context(), payload)
+	if err != nil {
+		writeOAuthError(res, err)
+		return
+	}
+
+	writeJSON(res, http.StatusOK, info)
+}
+
+// GetOpenIDConfiguration is a plain HTTP handler of the gateway server that publishes the OpenID Connect
+// discovery document.
+func (server *Server) GetOpenIDConfiguration(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	res.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(res, http.StatusOK, server.oauth.Discovery())
+}
+
+// writeOAuthError writes OAuth errors in the format of RFC 6749 and other errors as internal errors.
+func writeOAuthError(res http.ResponseWriter, err error) {
+	var oauthErr *oauth.Error
+	if errors.As(err, &oauthErr) {
+		writeJSON(res, oauthErr.StatusCode, oauthErr)
+		return
+	}
+
+	log.Error().Err(err).Msg("failed to process OAuth request")
+	writeError(res, http.StatusInternalServerError, "failed to process request")
+}
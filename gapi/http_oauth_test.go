@@ -0,0 +1,174 @@
+package gapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/oauth"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// withOAuth configures the OAuth authorization server.
+func withOAuth(config *util.Config) {
+	config.OAuthIssuerURL = "https://auth.example.com"
+	config.OAuthCodeDuration = time.Minute
+}
+
+func TestAuthorizeOAuthClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store, withOAuth)
+
+	password := util.RandomString(12)
+	hashedPassword, err := util.HashPassword(password, util.Config{}.Argon2idParams())
+	require.NoError(t, err)
+	user := db.UserSvcUser{
+		Username:     util.RandomUsername(),
+		PasswordHash: hashedPassword,
+		Status:       util.ConvertToText(util.StatusActive),
+	}
+
+	client := db.UserSvcOAuthClient{
+		ID:           "web-app",
+		Name:         "Web App",
+		RedirectUris: []string{"https://app.example.com/callback"},
+		GrantTypes:   []string{oauth.GrantTypeAuthorizationCode},
+		Scopes:       []string{oauth.ScopeOpenID},
+	}
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).Times(3).Return(client, nil)
+	store.EXPECT().GetLoginAttempts(gomock.Any(), gomock.Any()).Times(1).Return([]db.UserSvcLoginAttempt{}, nil)
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserSvcTotpSecret{}, pgx.ErrNoRows)
+	store.EXPECT().DeleteLoginAttempts(gomock.Any(), gomock.Any()).Times(1).Return(int64(0), nil)
+	store.EXPECT().
+		CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.UserSvcOAuthAuthorizationCode{}, nil)
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ID},
+		"code_challenge":        {oauth.CodeChallenge(util.RandomString(43))},
+		"code_challenge_method": {oauth.CodeChallengeMethodS256},
+	}
+
+	// The page is served to the browser without a token
+	req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	res := httptest.NewRecorder()
+	server.AuthorizeOAuthClient(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Contains(t, res.Body.String(), client.Name+" wants to access your Streamfair account")
+
+	form := url.Values{
+		"username": {user.Username},
+		"password": {password},
+		"decision": {"allow"},
+	}
+	for key, values := range query {
+		form[key] = values
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res = httptest.NewRecorder()
+	server.AuthorizeOAuthClient(res, req)
+	require.Equal(t, http.StatusSeeOther, res.Code)
+
+	location, err := url.Parse(res.Header().Get("Location"))
+	require.NoError(t, err)
+	require.NotEmpty(t, location.Query().Get("code"))
+}
+
+func TestIssueOAuthToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store, withOAuth)
+
+	secret := util.RandomString(32)
+	client := db.UserSvcOAuthClient{
+		ID:         "stream-svc",
+		SecretHash: util.HashSecretCode(secret),
+		GrantTypes: []string{oauth.GrantTypeClientCredentials},
+		Scopes:     []string{policy.PermissionReadUsers},
+	}
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).Times(2).Return(client, nil)
+
+	form := url.Values{
+		"grant_type":    {oauth.GrantTypeClientCredentials},
+		"client_id":     {client.ID},
+		"client_secret": {secret},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+
+	server.IssueOAuthToken(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var rsp oauth.TokenResponse
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &rsp))
+
+	// Tokens of the client have no user and are rejected where a user is required
+	_, err := server.verifyAuthorizationHeader(req.Context(), fmt.Sprintf("%s %s", authorizationTypeBearer, rsp.AccessToken), "")
+	require.Error(t, err)
+
+	form.Set("grant_type", oauth.GrantTypeAuthorizationCode)
+	req = httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res = httptest.NewRecorder()
+
+	server.IssueOAuthToken(res, req)
+	require.Equal(t, http.StatusBadRequest, res.Code)
+	require.Contains(t, res.Body.String(), `"error":"unauthorized_client"`)
+}
+
+func TestGetOAuthUserInfo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store, withOAuth)
+
+	user := db.UserSvcUser{ID: 42, Username: util.RandomUsername(), Email: util.RandomEmail()}
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+
+	accessToken, _, err := server.tokenMaker.CreateLocalToken(token.Claims{
+		Username: user.Username,
+		Scopes:   []string{oauth.ScopeOpenID, oauth.ScopeEmail},
+		ClientID: "web-app",
+	}, time.Minute)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/oauth/userinfo", nil)
+	req.Header.Set(authorizationHeader, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+	res := httptest.NewRecorder()
+
+	server.GetOAuthUserInfo(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+	require.JSONEq(t, fmt.Sprintf(`{"sub": "42", "email": %q, "email_verified": false}`, user.Email), res.Body.String())
+}
+
+func TestGetOpenIDConfiguration(t *testing.T) {
+	server := newTestServer(t, nil, withOAuth)
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+	res := httptest.NewRecorder()
+
+	server.GetOpenIDConfiguration(res, req)
+	require.Equal(t, http.StatusOK, res.Code)
+
+	var metadata oauth.Metadata
+	require.NoError(t, json.Unmarshal(res.Body.Bytes(), &metadata))
+	require.Equal(t, "https://auth.example.com/oauth/authorize", metadata.AuthorizationEndpoint)
+}
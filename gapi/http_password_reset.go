@@ -0,0 +1,97 @@
+package gapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/passwordreset"
+	"github.com/Streamfair/streamfair_user_svc/validator"
+	"github.com/rs/zerolog/log"
+)
+
+type requestPasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type requestPasswordResetResponse struct {
+	Message string `json:"message"`
+}
+
+// RequestPasswordReset is a plain HTTP handler of the gateway server that sends a single-use password reset link
+// to the email address of the user.
+func (server *Server) RequestPasswordReset(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var body requestPasswordResetRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(res, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validator.ValidateEmail(body.Email); err != nil {
+		writeError(res, http.StatusBadRequest, fmt.Sprintf("email: %v", err))
+		return
+	}
+
+	if err := server.resetter.Request(req.Context(), body.Email); err != nil {
+		log.Error().Err(err).Msg("failed to request password reset")
+		writeError(res, http.StatusInternalServerError, "failed to request password reset")
+		return
+	}
+
+	writeJSON(res, http.StatusOK, requestPasswordResetResponse{Message: passwordreset.RequestedMessage})
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+type resetPasswordResponse struct {
+	Username        string `json:"username"`
+	RevokedSessions int64  `json:"revoked_sessions"`
+}
+
+// ResetPassword is a plain HTTP handler of the gateway server that sets a new password using a password reset token
+// and signs the user out of all devices.
+func (server *Server) ResetPassword(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var body resetPasswordRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(res, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(body.Token) < 32 || len(body.Token) > 128 {
+		writeError(res, http.StatusBadRequest, "token must contain from 32-128 characters")
+		return
+	}
+	if err := validator.ValidatePassword(body.NewPassword); err != nil {
+		writeError(res, http.StatusBadRequest, fmt.Sprintf("new_password: %v", err))
+		return
+	}
+
+	result, err := server.resetter.Reset(req.Context(), body.Token, body.NewPassword)
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidPasswordReset) {
+			writeError(res, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Error().Err(err).Msg("failed to reset password")
+		writeError(res, http.StatusInternalServerError, "failed to reset password")
+		return
+	}
+
+	writeJSON(res, http.StatusOK, resetPasswordResponse{
+		Username:        result.User.Username,
+		RevokedSessions: result.RevokedSessions,
+	})
+}
@@ -0,0 +1,142 @@
+package gapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/mail"
+	"github.com/Streamfair/streamfair_user_svc/passwordreset"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// withPasswordReset configures the links of password reset emails.
+func withPasswordReset(config *util.Config) {
+	config.PasswordResetURL = "https://localhost:8080/reset_password"
+	config.PasswordResetDuration = time.Hour
+}
+
+func TestRequestPasswordReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store, withPasswordReset)
+
+	user := db.UserSvcUser{Username: util.RandomUsername(), Email: util.RandomEmail()}
+	unknownEmail := util.RandomEmail()
+
+	store.EXPECT().
+		GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		GetUserByEmail(gomock.Any(), gomock.Eq(unknownEmail)).
+		Times(1).
+		Return(db.UserSvcUser{}, pgx.ErrNoRows)
+	store.EXPECT().
+		CreatePasswordReset(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.UserSvcPasswordReset{}, nil)
+
+	// Known and unknown email addresses get the same response
+	for _, email := range []string{user.Email, unknownEmail} {
+		data, err := json.Marshal(requestPasswordResetRequest{Email: email})
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/streamfair/v1/request_password_reset", bytes.NewReader(data))
+		server.RequestPasswordReset(recorder, request)
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var rsp requestPasswordResetResponse
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+		require.Equal(t, passwordreset.RequestedMessage, rsp.Message)
+	}
+
+	// The emails are sent after the response
+	server.resetter.Wait()
+	emails := server.mailer.(*mail.InMemorySender).Emails()
+	require.Len(t, emails, 1)
+	require.Equal(t, []string{user.Email}, emails[0].To)
+}
+
+func TestResetPassword(t *testing.T) {
+	token, err := util.GenerateSecretCode()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name       string
+		body       resetPasswordRequest
+		buildStubs func(store *mock_db.MockStore)
+		statusCode int
+	}{
+		{
+			name: "OK",
+			body: resetPasswordRequest{Token: token, NewPassword: util.RandomString(12)},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ResetPasswordTxResult{RevokedSessions: 1}, nil)
+				store.EXPECT().
+					SetTokenWatermark(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserSvcTokenWatermark{}, nil)
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			name: "InvalidToken",
+			body: resetPasswordRequest{Token: token, NewPassword: util.RandomString(12)},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.ResetPasswordTxResult{}, db.ErrInvalidPasswordReset)
+			},
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name: "InvalidPassword",
+			body: resetPasswordRequest{Token: token, NewPassword: "123"},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					ResetPasswordTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store, withPasswordReset)
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/streamfair/v1/reset_password", bytes.NewReader(data))
+			server.ResetPassword(recorder, request)
+
+			require.Equal(t, tc.statusCode, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package gapi
+
+import (
+	"net/http"
+
+	"github.com/Streamfair/streamfair_user_svc/token"
+)
+
+// createAccessToken creates a public access token if signing keys are configured, so that other services can
+// verify it without the symmetric key, and a local access token otherwise.
+func (server *Server) createAccessToken(claims token.Claims) (string, *token.Payload, error) {
+	if len(server.tokenMaker.PublicKeys()) > 0 {
+		return server.tokenMaker.CreatePublicToken(claims, server.config.AccessTokenDuration)
+	}
+	return server.tokenMaker.CreateLocalToken(claims, server.config.AccessTokenDuration)
+}
+
+// ListPublicKeys is a plain HTTP handler of the gateway server that publishes the public keys that verify
+// public access tokens as JSON web key set. The set is empty if the service only issues local tokens.
+func (server *Server) ListPublicKeys(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	res.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(res, http.StatusOK, token.NewJSONWebKeySet(server.tokenMaker.PublicKeys()))
+}
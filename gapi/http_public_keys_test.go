@@ -0,0 +1,44 @@
+package gapi
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListPublicKeys(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	server := newTestServer(t, nil, func(config *util.Config) {
+		config.TokenPrivateKeys = []string{hex.EncodeToString(privateKey.Seed())}
+	})
+
+	recorder := httptest.NewRecorder()
+	server.ListPublicKeys(recorder, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var set token.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 1)
+
+	// Access tokens are signed with the published key
+	accessToken, _, err := server.createAccessToken(token.Claims{Username: util.RandomUsername()})
+	require.NoError(t, err)
+	require.True(t, token.IsPublicToken(accessToken))
+
+	payload, err := server.verifyAuthorizationHeader(context.Background(), authorizationTypeBearer+" "+accessToken, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, payload.Username)
+
+	recorder = httptest.NewRecorder()
+	server.ListPublicKeys(recorder, httptest.NewRequest(http.MethodPost, "/.well-known/jwks.json", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
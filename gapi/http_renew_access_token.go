@@ -0,0 +1,172 @@
+package gapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type renewAccessTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type renewAccessTokenResponse struct {
+	SessionID             string    `json:"session_id"`
+	AccessToken           string    `json:"access_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
+
+// RenewAccessTokenHTTP is a plain HTTP handler of the gateway server that exchanges a refresh token for a new access
+// token and a new refresh token of the same token family. Presenting a rotated refresh token again revokes the family.
+func (server *Server) RenewAccessTokenHTTP(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var body renewAccessTokenRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.RefreshToken == "" {
+		writeError(res, http.StatusBadRequest, "refresh_token is required")
+		return
+	}
+
+	rsp, err := server.renewAccessToken(req.Context(), body.RefreshToken, &Metadata{
+		UserAgent: req.UserAgent(),
+		ClientIP:  server.httpClientIP(req),
+	})
+	if err != nil {
+		writeError(res, runtime.HTTPStatusFromCode(status.Code(err)), status.Convert(err).Message())
+		return
+	}
+
+	writeJSON(res, http.StatusOK, rsp)
+}
+
+// renewAccessToken rotates the session of the refresh token and returns the new tokens. Errors are gRPC status errors,
+// so that the RPC and the HTTP handler report the same failures.
+func (server *Server) renewAccessToken(ctx context.Context, refreshTokenString string, mtdt *Metadata) (*renewAccessTokenResponse, error) {
+	refreshPayload, err := server.tokenMaker.VerifyRefreshToken(refreshTokenString)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "invalid refresh token")
+	}
+
+	// Refresh tokens of OAuth clients are limited to their scopes and can only be renewed at the token endpoint
+	if refreshPayload.ClientID != "" {
+		return nil, status.Errorf(codes.Unauthenticated, "refresh token was issued to an OAuth client")
+	}
+
+	session, err := server.store.GetSession(ctx, refreshPayload.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "session not found")
+		}
+		log.Error().Err(err).Msg("failed to get session")
+		return nil, status.Errorf(codes.Internal, "failed to renew access token")
+	}
+
+	// A refresh token can only be exchanged once. Presenting a rotated token again means
+	// that it has been stolen, so the whole token family is revoked.
+	if session.ReplacedBy.Valid {
+		server.revokeSessionFamily(ctx, session, mtdt.ClientIP, mtdt.UserAgent)
+		return nil, status.Errorf(codes.Unauthenticated, "refresh token has already been used")
+	}
+
+	switch {
+	case session.IsBlocked:
+		return nil, status.Errorf(codes.Unauthenticated, "session is blocked")
+	case session.Username != refreshPayload.Username:
+		return nil, status.Errorf(codes.Unauthenticated, "incorrect session user")
+	case session.RefreshToken != refreshTokenString:
+		return nil, status.Errorf(codes.Unauthenticated, "mismatched session token")
+	case time.Now().After(session.ExpiresAt):
+		return nil, status.Errorf(codes.Unauthenticated, "expired session")
+	}
+
+	// The claims are loaded again, so that a changed role takes effect with the next access token
+	user, err := server.store.GetUserByValue(ctx, session.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Errorf(codes.Unauthenticated, "user of the session does not exist")
+		}
+		log.Error().Err(err).Msg("failed to get user of session")
+		return nil, status.Errorf(codes.Internal, "failed to renew access token")
+	}
+
+	claims, err := server.tokenClaims(ctx, user)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to load token claims")
+		return nil, status.Errorf(codes.Internal, "failed to renew access token")
+	}
+
+	accessToken, accessPayload, err := server.createAccessToken(claims)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create access token")
+		return nil, status.Errorf(codes.Internal, "failed to renew access token")
+	}
+
+	refreshToken, newRefreshPayload, err := server.tokenMaker.CreateRefreshToken(claims, server.config.RefreshTokenDuration)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to create refresh token")
+		return nil, status.Errorf(codes.Internal, "failed to renew access token")
+	}
+
+	result, err := server.store.RotateSessionTx(ctx, db.RotateSessionTxParams{
+		OldSessionID: session.ID,
+		NewSession: db.CreateSessionParams{
+			ID:           newRefreshPayload.ID,
+			FamilyID:     session.FamilyID,
+			Username:     session.Username,
+			RefreshToken: refreshToken,
+			UserAgent:    mtdt.UserAgent,
+			ClientIp:     mtdt.ClientIP,
+			IsBlocked:    false,
+			ExpiresAt:    newRefreshPayload.ExpiredAt,
+		},
+	})
+	if err != nil {
+		// Another request rotated the same refresh token in the meantime
+		if errors.Is(err, db.ErrSessionAlreadyRotated) {
+			server.revokeSessionFamily(ctx, session, mtdt.ClientIP, mtdt.UserAgent)
+			return nil, status.Errorf(codes.Unauthenticated, "refresh token has already been used")
+		}
+		log.Error().Err(err).Msg("failed to rotate session")
+		return nil, status.Errorf(codes.Internal, "failed to renew access token")
+	}
+
+	return &renewAccessTokenResponse{
+		SessionID:             result.Session.ID.String(),
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: newRefreshPayload.ExpiredAt,
+	}, nil
+}
+
+// revokeSessionFamily blocks all sessions of the token family of the given session and reports the reuse of its refresh token.
+func (server *Server) revokeSessionFamily(ctx context.Context, session db.UserSvcSession, clientIP, userAgent string) {
+	revoked, err := server.store.BlockSessionFamily(ctx, session.FamilyID)
+
+	event := log.Warn()
+	if err != nil {
+		event = log.Error().Err(err)
+	}
+	event.Str("event", "refresh_token_reuse").
+		Str("username", session.Username).
+		Str("session_id", session.ID.String()).
+		Str("family_id", session.FamilyID.String()).
+		Str("client_ip", clientIP).
+		Str("user_agent", userAgent).
+		Int64("revoked_sessions", revoked).
+		Msg("security: reuse of a rotated refresh token detected, revoking token family")
+}
@@ -0,0 +1,154 @@
+package gapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRenewAccessToken(t *testing.T) {
+	symmetricKey := util.RandomString(32)
+	tokenMaker, err := token.NewLocalPasetoMaker(symmetricKey)
+	require.NoError(t, err)
+
+	user := db.UserSvcUser{ID: util.RandomInt(1, 1000), Username: util.RandomUsername()}
+	refreshToken, refreshPayload, err := tokenMaker.CreateRefreshToken(token.Claims{UserID: user.ID, Username: user.Username}, time.Hour)
+	require.NoError(t, err)
+	clientToken, _, err := tokenMaker.CreateRefreshToken(token.Claims{Username: user.Username, ClientID: "client"}, time.Hour)
+	require.NoError(t, err)
+	accessToken, _, err := tokenMaker.CreateLocalToken(token.Claims{UserID: user.ID, Username: user.Username}, time.Hour)
+	require.NoError(t, err)
+
+	session := db.UserSvcSession{
+		ID:           refreshPayload.ID,
+		FamilyID:     uuid.New(),
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	}
+	rotated := session
+	rotated.ReplacedBy = pgtype.UUID{Bytes: uuid.New(), Valid: true}
+
+	testCases := []struct {
+		name         string
+		refreshToken string
+		buildStubs   func(store *mock_db.MockStore)
+		statusCode   int
+	}{
+		{
+			name:         "OK",
+			refreshToken: refreshToken,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(session, nil)
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.RotateSessionTxParams) (db.RotateSessionTxResult, error) {
+						require.Equal(t, session.ID, arg.OldSessionID)
+						require.Equal(t, session.FamilyID, arg.NewSession.FamilyID)
+						require.Equal(t, "192.0.2.1", arg.NewSession.ClientIp)
+						return db.RotateSessionTxResult{Session: db.UserSvcSession{ID: arg.NewSession.ID}}, nil
+					})
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:         "ReusedRefreshToken",
+			refreshToken: refreshToken,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(rotated, nil)
+				store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Eq(session.FamilyID)).Times(1).Return(int64(2), nil)
+				store.EXPECT().RotateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "ConcurrentRotation",
+			refreshToken: refreshToken,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(session, nil)
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					RotateSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.RotateSessionTxResult{}, db.ErrSessionAlreadyRotated)
+				store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Eq(session.FamilyID)).Times(1).Return(int64(2), nil)
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "SessionNotFound",
+			refreshToken: refreshToken,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcSession{}, pgx.ErrNoRows)
+			},
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:         "OAuthClientToken",
+			refreshToken: clientToken,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "AccessToken",
+			refreshToken: accessToken,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "InvalidToken",
+			refreshToken: "invalid",
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store, withTokenSymmetricKey(symmetricKey))
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/streamfair/v1/renew_access_token", strings.NewReader(fmt.Sprintf(`{"refresh_token": %q}`, tc.refreshToken)))
+			request.RemoteAddr = "192.0.2.1:1234"
+			server.RenewAccessTokenHTTP(recorder, request)
+			require.Equal(t, tc.statusCode, recorder.Code)
+
+			if tc.statusCode == http.StatusOK {
+				var rsp renewAccessTokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.NotEqual(t, refreshToken, rsp.RefreshToken)
+
+				payload, err := tokenMaker.VerifyLocalToken(rsp.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, user.Username, payload.Username)
+			}
+		})
+	}
+}
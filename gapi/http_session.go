@@ -0,0 +1,120 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/validator"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type sessionResponse struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	UserAgent string    `json:"user_agent"`
+	ClientIP  string    `json:"client_ip"`
+	IsBlocked bool      `json:"is_blocked"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newSessionResponse(session db.UserSvcSession) sessionResponse {
+	return sessionResponse{
+		ID:        session.ID.String(),
+		Username:  session.Username,
+		UserAgent: session.UserAgent,
+		ClientIP:  session.ClientIp,
+		IsBlocked: session.IsBlocked,
+		ExpiresAt: session.ExpiresAt,
+		CreatedAt: session.CreatedAt,
+	}
+}
+
+type revokeAllSessionsResponse struct {
+	RevokedSessions int64 `json:"revoked_sessions"`
+}
+
+// Sessions is a plain HTTP handler of the gateway server for the sessions, i.e. the signed in devices, of the user
+// of the username query parameter. GET lists the active sessions, DELETE signs the user out of all devices.
+// Users may manage their own sessions, admins the sessions of every user.
+func (server *Server) Sessions(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodDelete {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	payload, err := server.verifyAuthorizationHeader(req.Context(), req.Header.Get(authorizationHeader), server.httpClientIP(req))
+	if err != nil {
+		writeError(res, http.StatusUnauthorized, status.Convert(err).Message())
+		return
+	}
+
+	username := req.URL.Query().Get("username")
+	if err := validator.ValidateUsername(username); err != nil {
+		writeError(res, http.StatusBadRequest, fmt.Sprintf("username: %v", err))
+		return
+	}
+
+	if !server.authorizeHTTP(res, req, payload, func(subject *policy.Subject) error {
+		return subject.CanManageSessions(username)
+	}) {
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet:
+		rsp, err := server.listSessions(req.Context(), username)
+		if err != nil {
+			writeError(res, runtime.HTTPStatusFromCode(status.Code(err)), status.Convert(err).Message())
+			return
+		}
+		writeJSON(res, http.StatusOK, rsp)
+
+	case http.MethodDelete:
+		rsp, err := server.revokeAllSessions(req.Context(), username)
+		if err != nil {
+			writeError(res, runtime.HTTPStatusFromCode(status.Code(err)), status.Convert(err).Message())
+			return
+		}
+		writeJSON(res, http.StatusOK, rsp)
+	}
+}
+
+// listSessions returns the active sessions of the user. The caller has to be authorized to manage them.
+func (server *Server) listSessions(ctx context.Context, username string) ([]sessionResponse, error) {
+	sessions, err := server.store.ListActiveSessionsByUsername(ctx, username)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list sessions")
+		return nil, status.Errorf(codes.Internal, "failed to list sessions")
+	}
+
+	rsp := make([]sessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		rsp = append(rsp, newSessionResponse(session))
+	}
+	return rsp, nil
+}
+
+// revokeAllSessions signs the user out of all devices. The caller has to be authorized to manage the sessions.
+func (server *Server) revokeAllSessions(ctx context.Context, username string) (*revokeAllSessionsResponse, error) {
+	revoked, err := server.store.BlockSessionsByUsername(ctx, username)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to block sessions")
+		return nil, status.Errorf(codes.Internal, "failed to revoke sessions")
+	}
+
+	// Blocking the sessions only stops the renewal, the issued access tokens are revoked as well
+	if err := server.revocations.RevokeUserTokens(ctx, username); err != nil {
+		log.Error().Err(err).Msg("failed to revoke access tokens")
+		return nil, status.Errorf(codes.Internal, "failed to revoke sessions")
+	}
+
+	return &revokeAllSessionsResponse{RevokedSessions: revoked}, nil
+}
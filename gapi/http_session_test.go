@@ -0,0 +1,199 @@
+package gapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSessions(t *testing.T) {
+	symmetricKey := util.RandomString(32)
+	tokenMaker, err := token.NewLocalPasetoMaker(symmetricKey)
+	require.NoError(t, err)
+
+	user := db.UserSvcUser{
+		ID:       util.RandomInt(1, 1000),
+		Username: util.RandomUsername(),
+		RoleID:   pgtype.Int8{Int64: policy.RoleUser, Valid: true},
+	}
+	admin := db.UserSvcUser{
+		ID:       user.ID + 1,
+		Username: util.RandomUsername(),
+		RoleID:   pgtype.Int8{Int64: policy.RoleAdmin, Valid: true},
+	}
+	other := util.RandomUsername()
+
+	userToken, _, err := tokenMaker.CreateLocalToken(token.Claims{UserID: user.ID, Username: user.Username}, time.Minute)
+	require.NoError(t, err)
+	adminToken, _, err := tokenMaker.CreateLocalToken(token.Claims{UserID: admin.ID, Username: admin.Username}, time.Minute)
+	require.NoError(t, err)
+
+	sessions := []db.UserSvcSession{
+		{ID: uuid.New(), FamilyID: uuid.New(), Username: user.Username, ExpiresAt: time.Now().Add(time.Hour)},
+		{ID: uuid.New(), FamilyID: uuid.New(), Username: user.Username, ExpiresAt: time.Now().Add(time.Hour)},
+	}
+
+	testCases := []struct {
+		name          string
+		method        string
+		authorization string
+		username      string
+		buildStubs    func(store *mock_db.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:          "ListOwnSessions",
+			method:        http.MethodGet,
+			authorization: userToken,
+			username:      user.Username,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).Times(1).Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					ListActiveSessionsByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(sessions, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp []sessionResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Len(t, rsp, len(sessions))
+				require.Equal(t, sessions[0].ID.String(), rsp[0].ID)
+			},
+		},
+		{
+			name:          "RevokeOwnSessions",
+			method:        http.MethodDelete,
+			authorization: userToken,
+			username:      user.Username,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).Times(1).Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					BlockSessionsByUsername(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(int64(2), nil)
+				store.EXPECT().
+					SetTokenWatermark(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserSvcTokenWatermark{Username: user.Username, NotBefore: time.Now()}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var rsp revokeAllSessionsResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &rsp))
+				require.Equal(t, int64(2), rsp.RevokedSessions)
+			},
+		},
+		{
+			name:          "AdminRevokesSessionsOfOtherUser",
+			method:        http.MethodDelete,
+			authorization: adminToken,
+			username:      other,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(admin.Username)).Times(1).Return(admin, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleAdmin)).
+					Times(1).
+					Return([]db.UserSvcPermission{{Name: policy.PermissionManageSessions}}, nil)
+				store.EXPECT().BlockSessionsByUsername(gomock.Any(), gomock.Eq(other)).Times(1).Return(int64(1), nil)
+				store.EXPECT().
+					SetTokenWatermark(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserSvcTokenWatermark{Username: other, NotBefore: time.Now()}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:          "SessionsOfOtherUser",
+			method:        http.MethodGet,
+			authorization: userToken,
+			username:      other,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().ListActiveSessionsByUsername(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:     "NoAuthorization",
+			method:   http.MethodGet,
+			username: user.Username,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().ListActiveSessionsByUsername(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:          "InvalidUsername",
+			method:        http.MethodGet,
+			authorization: userToken,
+			username:      "ab",
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().ListActiveSessionsByUsername(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name:          "MethodNotAllowed",
+			method:        http.MethodPost,
+			authorization: userToken,
+			username:      user.Username,
+			buildStubs:    func(store *mock_db.MockStore) {},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store, withTokenSymmetricKey(symmetricKey))
+
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(tc.method, "/streamfair/v1/sessions?username="+tc.username, nil)
+			if tc.authorization != "" {
+				request.Header.Set(authorizationHeader, fmt.Sprintf("%s %s", authorizationTypeBearer, tc.authorization))
+			}
+			server.Sessions(recorder, request)
+
+			tc.checkResponse(recorder)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package gapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/rs/zerolog/log"
+)
+
+type verifyEmailResponse struct {
+	IsVerified bool   `json:"is_verified"`
+	Username   string `json:"username"`
+}
+
+// VerifyEmail is a plain HTTP handler of the gateway server that consumes the code of a verification link
+// and activates the user.
+func (server *Server) VerifyEmail(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	emailID, err := strconv.ParseInt(req.URL.Query().Get("email_id"), 10, 64)
+	if err != nil || emailID < 1 {
+		writeError(res, http.StatusBadRequest, "email_id must be a positive integer")
+		return
+	}
+
+	secretCode := req.URL.Query().Get("secret_code")
+	if len(secretCode) < 32 || len(secretCode) > 128 {
+		writeError(res, http.StatusBadRequest, "secret_code must contain from 32-128 characters")
+		return
+	}
+
+	result, err := server.store.VerifyEmailTx(req.Context(), db.VerifyEmailTxParams{
+		EmailID:    emailID,
+		SecretCode: util.HashSecretCode(secretCode),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidVerifyEmail) {
+			writeError(res, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Error().Err(err).Msg("failed to verify email")
+		writeError(res, http.StatusInternalServerError, "failed to verify email")
+		return
+	}
+
+	writeJSON(res, http.StatusOK, verifyEmailResponse{
+		IsVerified: true,
+		Username:   result.User.Username,
+	})
+}
@@ -0,0 +1,98 @@
+package gapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/Streamfair/streamfair_user_svc/lockout"
+	"github.com/Streamfair/streamfair_user_svc/validator"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// rejectLogin counts a failed login and returns the same error for unknown usernames and wrong passwords.
+func (server *Server) rejectLogin(ctx context.Context, username, clientIP string) error {
+	if err := server.loginGuard.RecordFailure(ctx, username, clientIP); err != nil {
+		log.Error().Err(err).Str("username", username).Msg("failed to record failed login attempt")
+	}
+	return status.Error(codes.Unauthenticated, lockout.ErrInvalidCredentials.Error())
+}
+
+// lockoutError converts the error of a login guard check into a status error.
+// Locked logins are ResourceExhausted and tell the client when to retry.
+func lockoutError(err error) error {
+	var lockedErr *lockout.LockedError
+	if errors.As(err, &lockedErr) {
+		return retryError(codes.ResourceExhausted, err.Error(), lockedErr.RetryAfter)
+	}
+	return handleDatabaseError(err)
+}
+
+type unlockUserRequest struct {
+	Username string `json:"username"`
+	// ClientIP optionally lifts the lockout of a client address together with the one of the user.
+	ClientIP string `json:"client_ip"`
+}
+
+type unlockUserResponse struct {
+	Cleared int64 `json:"cleared"`
+}
+
+// UnlockUser is a plain HTTP handler of the gateway server that lifts the login lockout of a user before it expires.
+// The user of the bearer token needs the permission to unlock users.
+func (server *Server) UnlockUser(res http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		writeError(res, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	payload, err := server.verifyAuthorizationHeader(req.Context(), req.Header.Get(authorizationHeader), server.httpClientIP(req))
+	if err != nil {
+		writeError(res, http.StatusUnauthorized, status.Convert(err).Message())
+		return
+	}
+
+	var body unlockUserRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeError(res, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := validator.ValidateUsername(body.Username); err != nil {
+		writeError(res, http.StatusBadRequest, fmt.Sprintf("username: %v", err))
+		return
+	}
+	if body.ClientIP != "" && net.ParseIP(body.ClientIP) == nil {
+		writeError(res, http.StatusBadRequest, "client_ip must be a valid IP address")
+		return
+	}
+
+	subject, err := server.policy.LoadSubject(req.Context(), payload.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeError(res, http.StatusUnauthorized, "user of the access token does not exist")
+			return
+		}
+		log.Error().Err(err).Msg("failed to load subject")
+		writeError(res, http.StatusInternalServerError, "failed to unlock user")
+		return
+	}
+	if err := subject.CanUnlockUsers(); err != nil {
+		writeError(res, http.StatusForbidden, err.Error())
+		return
+	}
+
+	cleared, err := server.loginGuard.Unlock(req.Context(), body.Username, body.ClientIP)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to unlock user")
+		writeError(res, http.StatusInternalServerError, "failed to unlock user")
+		return
+	}
+
+	writeJSON(res, http.StatusOK, unlockUserResponse{Cleared: cleared})
+}
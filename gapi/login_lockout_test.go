@@ -0,0 +1,202 @@
+package gapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/common_proto/IdentityProvider/pb/login"
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/lockout"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLoginUserLockout(t *testing.T) {
+	username := util.RandomUsername()
+	req := &login.LoginUserRequest{Username: username, Password: util.RandomString(12)}
+
+	testCases := []struct {
+		name       string
+		buildStubs func(store *mock_db.MockStore)
+		checkError func(t *testing.T, err error)
+	}{
+		{
+			name: "UnknownUsername",
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetLoginAttempts(gomock.Any(), gomock.Eq([]string{lockout.UsernameKey(username)})).
+					Times(1).
+					Return([]db.UserSvcLoginAttempt{}, nil)
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(username)).
+					Times(1).
+					Return(db.UserSvcUser{}, pgx.ErrNoRows)
+				store.EXPECT().
+					RecordLoginFailure(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.UserSvcLoginAttempt{Key: lockout.UsernameKey(username), FailedAttempts: 1}, nil)
+			},
+			checkError: func(t *testing.T, err error) {
+				st, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, codes.Unauthenticated, st.Code())
+				require.Equal(t, "invalid credentials", st.Message())
+			},
+		},
+		{
+			name: "Locked",
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.UserSvcLoginAttempt{{
+						Key:            lockout.UsernameKey(username),
+						FailedAttempts: 4,
+						LockedUntil:    time.Now().Add(2 * time.Minute),
+					}}, nil)
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkError: func(t *testing.T, err error) {
+				st, ok := status.FromError(err)
+				require.True(t, ok)
+				require.Equal(t, codes.ResourceExhausted, st.Code())
+
+				require.Len(t, st.Details(), 1)
+				retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+				require.True(t, ok)
+				require.InDelta(t, (2 * time.Minute).Seconds(), retryInfo.GetRetryDelay().AsDuration().Seconds(), 1)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store)
+
+			_, err := server.LoginUser(context.Background(), req)
+			tc.checkError(t, err)
+		})
+	}
+}
+
+func TestUnlockUser(t *testing.T) {
+	symmetricKey := util.RandomString(32)
+	tokenMaker, err := token.NewLocalPasetoMaker(symmetricKey)
+	require.NoError(t, err)
+
+	admin := db.UserSvcUser{ID: 1, Username: util.RandomUsername(), RoleID: util.ConvertToInt8(policy.RoleAdmin)}
+	user := db.UserSvcUser{ID: 2, Username: util.RandomUsername(), RoleID: util.ConvertToInt8(policy.RoleUser)}
+
+	testCases := []struct {
+		name       string
+		caller     db.UserSvcUser
+		body       unlockUserRequest
+		buildStubs func(store *mock_db.MockStore)
+		statusCode int
+	}{
+		{
+			name:   "OK",
+			caller: admin,
+			body:   unlockUserRequest{Username: user.Username, ClientIP: "10.0.0.1"},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(admin.Username)).
+					Times(1).
+					Return(admin, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleAdmin)).
+					Times(1).
+					Return([]db.UserSvcPermission{{Name: policy.PermissionUnlockUsers}}, nil)
+				store.EXPECT().
+					DeleteLoginAttempts(gomock.Any(), gomock.Eq([]string{
+						lockout.UsernameKey(user.Username),
+						lockout.ClientIPKey("10.0.0.1"),
+					})).
+					Times(1).
+					Return(int64(2), nil)
+			},
+			statusCode: http.StatusOK,
+		},
+		{
+			name:   "PermissionDenied",
+			caller: user,
+			body:   unlockUserRequest{Username: admin.Username},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().
+					ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).
+					Times(1).
+					Return([]db.UserSvcPermission{}, nil)
+				store.EXPECT().
+					DeleteLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:   "InvalidClientIP",
+			caller: admin,
+			body:   unlockUserRequest{Username: user.Username, ClientIP: "nope"},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					DeleteLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			statusCode: http.StatusBadRequest,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+
+			server := newTestServer(t, store, withTokenSymmetricKey(symmetricKey))
+
+			accessToken, _, err := tokenMaker.CreateLocalToken(token.Claims{UserID: tc.caller.ID, Username: tc.caller.Username}, time.Minute)
+			require.NoError(t, err)
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			request := httptest.NewRequest(http.MethodPost, "/streamfair/v1/unlock_user", bytes.NewReader(data))
+			request.Header.Set(authorizationHeader, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+			recorder := httptest.NewRecorder()
+
+			server.UnlockUser(recorder, request)
+			require.Equal(t, tc.statusCode, recorder.Code)
+		})
+	}
+}
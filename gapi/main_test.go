@@ -1,24 +1,133 @@
 package gapi
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
 	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/mail"
+	"github.com/Streamfair/streamfair_user_svc/revocation"
+	"github.com/Streamfair/streamfair_user_svc/token"
 	"github.com/Streamfair/streamfair_user_svc/util"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
 )
 
-func newTestServer(t *testing.T, store db.Store) *Server {
-	config, err := util.LoadConfig()
-	require.NoError(t, err)
+// testServerOption changes the configuration of a test server before it is created.
+type testServerOption func(config *util.Config)
+
+// newTestServer creates a server with NewServer, so that the tests exercise the same wiring as the service.
+// The configuration only holds what the handlers need, the options add the settings of the tested feature.
+func newTestServer(t *testing.T, store db.Store, options ...testServerOption) *Server {
+	certPath, keyPath := writeTestCertificate(t)
+	config := util.Config{
+		TokenSymmetricKey:    util.RandomString(32),
+		TokenIssuer:          token.DefaultIssuer,
+		TokenAudience:        token.DefaultAudience,
+		AccessTokenDuration:  time.Minute,
+		RefreshTokenDuration: time.Hour,
+		CertPem:              certPath,
+		KeyPem:               keyPath,
+		CaCertPem:            certPath,
+		GrpcPublicMethods: []string{
+			"/pb.IdentityProvider/LoginUser",
+			"/pb.IdentityProvider/RegisterUser",
+			"/grpc.health.v1.Health/Check",
+			"/pb.TokenIntrospection/IntrospectToken",
+		},
+		LoginMaxAttempts:        3,
+		LoginLockoutDuration:    time.Minute,
+		LoginLockoutMaxDuration: time.Hour,
+		LoginAttemptWindow:      time.Hour,
+		TokenRevocationInterval: time.Minute,
+	}
+	for _, option := range options {
+		option(&config)
+	}
+
+	if store == nil {
+		store = mock_db.NewMockStore(gomock.NewController(t))
+	}
+	if mockStore, ok := store.(*mock_db.MockStore); ok {
+		stubTokenRevocations(mockStore)
+	}
 
-	server, err := NewServer(config, store)
+	server, err := NewServer(config, store, mail.NewInMemorySender())
 	require.NoError(t, err)
 
 	return server
 }
 
+// withTokenSymmetricKey lets the server verify the tokens of a maker created with token.NewLocalPasetoMaker from
+// the same key, for test cases that create their tokens before the server.
+func withTokenSymmetricKey(symmetricKey string) testServerOption {
+	return func(config *util.Config) {
+		config.TokenSymmetricKey = symmetricKey
+	}
+}
+
+// withTrustedProxies lets the server take the client addresses forwarded by the given proxies.
+func withTrustedProxies(proxies ...string) testServerOption {
+	return func(config *util.Config) {
+		config.TrustedProxies = proxies
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate for localhost, which is its own CA, and its key.
+func writeTestCertificate(t *testing.T) (certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certPath, keyPath
+}
+
+// stubTokenRevocations lets the server load an empty list of token revocations.
+func stubTokenRevocations(store *mock_db.MockStore) {
+	store.EXPECT().ListRevokedTokens(gomock.Any()).AnyTimes().Return([]db.UserSvcRevokedToken{}, nil)
+	store.EXPECT().ListTokenWatermarks(gomock.Any(), gomock.Any()).AnyTimes().Return([]db.UserSvcTokenWatermark{}, nil)
+}
+
+// newTestRevocationList creates a revocation list without any revoked tokens.
+func newTestRevocationList(t *testing.T) *revocation.List {
+	store := mock_db.NewMockStore(gomock.NewController(t))
+	stubTokenRevocations(store)
+
+	return revocation.NewList(util.Config{TokenRevocationInterval: time.Minute}, store)
+}
+
 func TestMain(m *testing.M) {
 	os.Chdir("../")
 	os.Exit(m.Run())
@@ -2,6 +2,7 @@ package gapi
 
 import (
 	"context"
+	"net/http"
 
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
@@ -21,25 +22,34 @@ type Metadata struct {
 func (server *Server) extractMetadata(ctx context.Context) *Metadata {
 	mtdt := &Metadata{}
 
+	var peerAddress string
+	if p, ok := peer.FromContext(ctx); ok {
+		peerAddress = p.Addr.String()
+	}
+
+	// The gateway forwards requests over gRPC, so its metadata takes precedence
+	// over the user agent and peer address of the gateway's own connection.
+	var forwardedFor []string
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		// gRPC-Gateway metadata
-		if userAgents := md.Get(grpcGatewayUserAgentHeader); len(userAgents) > 0 {
-			mtdt.UserAgent = userAgents[0]
-		}
 		// gRPC metadata
 		if userAgents := md.Get(userAgentHeader); len(userAgents) > 0 {
 			mtdt.UserAgent = userAgents[0]
 		}
-
 		// gRPC-Gateway metadata
-		if clientIPs := md.Get(xForwardedForHeader); len(clientIPs) > 0 {
-			mtdt.ClientIP = clientIPs[0]
+		if userAgents := md.Get(grpcGatewayUserAgentHeader); len(userAgents) > 0 {
+			mtdt.UserAgent = userAgents[0]
 		}
-	}
 
-	if p, ok := peer.FromContext(ctx); ok {
-		mtdt.ClientIP = p.Addr.String()
+		// gRPC-Gateway metadata, only trusted if the peer is the gateway or another trusted proxy
+		forwardedFor = md.Get(xForwardedForHeader)
 	}
+	mtdt.ClientIP = server.trustedProxies.ClientIP(peerAddress, forwardedFor)
 
 	return mtdt
 }
+
+// httpClientIP returns the address of the client of a request to a plain HTTP handler of the gateway server.
+// X-Forwarded-For is only trusted if the request comes from a trusted proxy.
+func (server *Server) httpClientIP(req *http.Request) string {
+	return server.trustedProxies.ClientIP(req.RemoteAddr, req.Header.Values(xForwardedForHeader))
+}
@@ -0,0 +1,41 @@
+package gapi
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestExtractMetadataClientIP(t *testing.T) {
+	server := newTestServer(t, nil, withTrustedProxies("127.0.0.1"))
+
+	newContext := func(peerIP net.IP, forwardedFor ...string) context.Context {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: peerIP, Port: 50000}})
+		return metadata.NewIncomingContext(ctx, metadata.MD{xForwardedForHeader: forwardedFor})
+	}
+
+	// The gateway appends the address of its client to the header the client sent
+	mtdt := server.extractMetadata(newContext(net.IPv4(127, 0, 0, 1), "198.51.100.1, 203.0.113.7"))
+	require.Equal(t, "203.0.113.7", mtdt.ClientIP)
+
+	// Direct callers can't choose their address
+	mtdt = server.extractMetadata(newContext(net.IPv4(203, 0, 113, 7), "198.51.100.1"))
+	require.Equal(t, "203.0.113.7", mtdt.ClientIP)
+}
+
+func TestHttpClientIP(t *testing.T) {
+	server := newTestServer(t, nil, withTrustedProxies("10.0.0.0/8"))
+
+	request := httptest.NewRequest("GET", "/", nil)
+	request.RemoteAddr = "203.0.113.7:4711"
+	request.Header.Set("X-Forwarded-For", "198.51.100.1")
+	require.Equal(t, "203.0.113.7", server.httpClientIP(request))
+
+	request.RemoteAddr = "10.1.2.3:4711"
+	require.Equal(t, "198.51.100.1", server.httpClientIP(request))
+}
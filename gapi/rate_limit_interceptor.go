@@ -0,0 +1,89 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Streamfair/streamfair_user_svc/ratelimit"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+const retryAfterHeader = "retry-after"
+
+// RateLimitInterceptor limits the unary requests per method and client address. It runs before the auth
+// interceptor, so that requests with invalid credentials are limited as well.
+func (server *Server) RateLimitInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := server.checkRateLimit(ctx, info.FullMethod, func(md metadata.MD) error {
+		return grpc.SetHeader(ctx, md)
+	}); err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+// StreamRateLimitInterceptor limits the streams per method and client address like RateLimitInterceptor.
+func (server *Server) StreamRateLimitInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := server.checkRateLimit(ss.Context(), info.FullMethod, ss.SetHeader); err != nil {
+		return err
+	}
+
+	return handler(srv, ss)
+}
+
+// checkRateLimit takes a token from the bucket of the client address for the method. The header with the
+// wait time of a limited client is set with setHeader.
+func (server *Server) checkRateLimit(ctx context.Context, fullMethod string, setHeader func(md metadata.MD) error) error {
+	method := methodName(fullMethod)
+	err := server.rateLimiter.Allow(ctx, method, ratelimit.ClientKey(server.extractMetadata(ctx).ClientIP))
+
+	var limitedErr *ratelimit.LimitedError
+	if errors.As(err, &limitedErr) {
+		// The gateway forwards the header as Grpc-Metadata-Retry-After
+		_ = setHeader(metadata.Pairs(retryAfterHeader, strconv.Itoa(limitedErr.RetryAfterSeconds())))
+		return retryError(codes.ResourceExhausted, err.Error(), limitedErr.RetryAfter)
+	}
+	if err != nil {
+		// A failing backend must not take the service down, the request is let through
+		log.Error().Err(err).Str("method", method).Msg("failed to check rate limit")
+	}
+	return nil
+}
+
+// HttpRateLimiter limits the requests of the plain HTTP handlers of the gateway per route and client address,
+// as they don't pass the gRPC interceptors. Routes are "<METHOD> <path>" like the routes of the HTTP API.
+func (server *Server) HttpRateLimiter(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		route := req.Method + " " + req.URL.Path
+		err := server.rateLimiter.Allow(req.Context(), route, ratelimit.ClientKey(server.httpClientIP(req)))
+
+		var limitedErr *ratelimit.LimitedError
+		if errors.As(err, &limitedErr) {
+			res.Header().Set("Retry-After", strconv.Itoa(limitedErr.RetryAfterSeconds()))
+			writeError(res, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		if err != nil {
+			// A failing backend must not take the service down, the request is let through
+			log.Error().Err(err).Str("route", route).Msg("failed to check rate limit")
+		}
+
+		handler.ServeHTTP(res, req)
+	})
+}
+
+// methodName returns the service and method of a full method name without the package,
+// e.g. "/pb.UserService/CreateUser" becomes "UserService/CreateUser".
+func methodName(fullMethod string) string {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if i := strings.LastIndex(service, "."); i >= 0 {
+		service = service[i+1:]
+	}
+	return service + "/" + method
+}
@@ -0,0 +1,131 @@
+package gapi
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestRateLimitInterceptor(t *testing.T) {
+	server := newTestServer(t, nil, withTrustedProxies("127.0.0.1"), func(config *util.Config) {
+		config.RateLimits = []string{"IdentityProvider/LoginUser=1/m"}
+	})
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/pb.IdentityProvider/LoginUser"}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return nil, nil
+	}
+	// Requests forwarded by the gateway
+	newClientContext := func(clientIP string) context.Context {
+		md := metadata.MD{xForwardedForHeader: []string{clientIP}}
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 50000}})
+		return metadata.NewIncomingContext(ctx, md)
+	}
+
+	_, err := server.RateLimitInterceptor(newClientContext("10.0.0.1"), nil, info, handler)
+	require.NoError(t, err)
+
+	_, err = server.RateLimitInterceptor(newClientContext("10.0.0.1"), nil, info, handler)
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.ResourceExhausted, st.Code())
+
+	require.Len(t, st.Details(), 1)
+	retryInfo, ok := st.Details()[0].(*errdetails.RetryInfo)
+	require.True(t, ok)
+	require.InDelta(t, time.Minute.Seconds(), retryInfo.GetRetryDelay().AsDuration().Seconds(), 1)
+
+	// Other clients and methods without a limit are not affected
+	_, err = server.RateLimitInterceptor(newClientContext("10.0.0.2"), nil, info, handler)
+	require.NoError(t, err)
+
+	otherInfo := &grpc.UnaryServerInfo{FullMethod: "/pb.UserService/GetUserById"}
+	_, err = server.RateLimitInterceptor(newClientContext("10.0.0.1"), nil, otherInfo, handler)
+	require.NoError(t, err)
+}
+
+// testServerStream is a server stream that only has a context and records the headers set on it.
+type testServerStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *testServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestStreamRateLimitInterceptor(t *testing.T) {
+	server := newTestServer(t, nil, func(config *util.Config) {
+		config.RateLimits = []string{"Health/Watch=1/m"}
+	})
+
+	info := &grpc.StreamServerInfo{FullMethod: "/grpc.health.v1.Health/Watch", IsServerStream: true}
+	handler := func(srv any, stream grpc.ServerStream) error {
+		return nil
+	}
+	newStream := func(clientIP net.IP) *testServerStream {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: clientIP, Port: 50000}})
+		return &testServerStream{ctx: ctx}
+	}
+
+	require.NoError(t, server.StreamRateLimitInterceptor(nil, newStream(net.IPv4(203, 0, 113, 7)), info, handler))
+
+	stream := newStream(net.IPv4(203, 0, 113, 7))
+	err := server.StreamRateLimitInterceptor(nil, stream, info, handler)
+	require.Equal(t, codes.ResourceExhausted, status.Code(err))
+	require.Equal(t, []string{"60"}, stream.header.Get(retryAfterHeader))
+
+	// Other clients are not affected
+	require.NoError(t, server.StreamRateLimitInterceptor(nil, newStream(net.IPv4(203, 0, 113, 8)), info, handler))
+}
+
+func TestHttpRateLimiter(t *testing.T) {
+	server := newTestServer(t, nil, func(config *util.Config) {
+		config.RateLimits = []string{"POST /streamfair/v1/reset_password=1/m"}
+	})
+
+	handler := server.HttpRateLimiter(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+	}))
+	serve := func(method, path, clientAddress string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(method, path, nil)
+		request.RemoteAddr = clientAddress
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/streamfair/v1/reset_password", "203.0.113.7:4711").Code)
+
+	recorder := serve(http.MethodPost, "/streamfair/v1/reset_password", "203.0.113.7:4712")
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	require.Equal(t, "60", recorder.Header().Get("Retry-After"))
+
+	// Other clients and routes without a limit are not affected
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/streamfair/v1/reset_password", "203.0.113.8:4711").Code)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/streamfair/v1/logout", "203.0.113.7:4711").Code)
+}
+
+func TestMethodName(t *testing.T) {
+	require.Equal(t, "UserService/CreateUser", methodName("/pb.UserService/CreateUser"))
+	require.Equal(t, "Health/Check", methodName("/grpc.health.v1.Health/Check"))
+	require.Equal(t, "Service/Method", methodName("/Service/Method"))
+}
@@ -2,49 +2,47 @@ package gapi
 
 import (
 	"context"
-	"fmt"
-	"github.com/rs/zerolog/log"
-	"strings"
+	"errors"
 
 	pb "github.com/Streamfair/common_proto/UserService/pb/user"
 	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
 	"github.com/Streamfair/streamfair_user_svc/util"
 	"github.com/Streamfair/streamfair_user_svc/validator"
 	"google.golang.org/grpc/codes"
 )
 
+// errPasswordSalt is reported when a client sends a salt, which is part of the hash computed by the server.
+var errPasswordSalt = errors.New("must be empty, the server hashes the plaintext password itself")
+
+// CreateUser creates a user for an authenticated caller. The UserService protos only have a password_hash field,
+// it carries the plaintext password, which is hashed by the server like IdentityProvider/RegisterUser does.
+// Users other than those allowed to assign roles can only create users with the user role.
 func (server *Server) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
 	violations := validateCreateUserRequest(req)
-	arg := db.CreateUserParams{
-		Username:     req.GetUsername(),
-		FullName:     req.GetFullName(),
-		Email:        req.GetEmail(),
-		PasswordHash: req.GetPasswordHash(),
-		PasswordSalt: req.GetPasswordSalt(),
-		CountryCode:  req.GetCountryCode(),
-		RoleID:       util.ConvertToInt8(req.GetRoleId()),
-		Status:       util.ConvertToText(req.GetStatus()),
-	}
 	if len(violations) > 0 {
-		log.Fatal().Msgf("INVALID REQUEST: %v\nPARAMS: %v\n", violations, arg)
 		return nil, invalidArgumentErrors(violations)
 	}
 
-	user, err := server.store.CreateUser(ctx, arg)
-	if err != nil {
-		if strings.Contains(err.Error(), "Users_email_key") {
-			violation := (&CustomError{
-				StatusCode: codes.AlreadyExists,
-			}).WithDetails("email", fmt.Errorf("user with email %s already exists", req.GetEmail()))
-			return nil, invalidArgumentError(violation)
-		} else if strings.Contains(err.Error(), "Users_username_key") {
-			violation := (&CustomError{
-				StatusCode: codes.AlreadyExists,
-			}).WithDetails("username", fmt.Errorf("user with username %s already exists", req.GetUsername()))
-			return nil, invalidArgumentError(violation)
+	if req.GetRoleId() != policy.RoleUser {
+		if err := server.authorize(ctx, func(subject *policy.Subject) error {
+			return subject.CanAssignRole()
+		}); err != nil {
+			return nil, err
 		}
-		return nil, handleDatabaseError(err)
 	}
+
+	user, err := server.createUserWithPassword(ctx, db.CreateUserParams{
+		Username:    req.GetUsername(),
+		FullName:    req.GetFullName(),
+		Email:       req.GetEmail(),
+		CountryCode: req.GetCountryCode(),
+		RoleID:      util.ConvertToInt8(req.GetRoleId()),
+	}, req.GetPasswordHash())
+	if err != nil {
+		return nil, err
+	}
+
 	rsp := &pb.CreateUserResponse{
 		User: ConvertUser(user),
 	}
@@ -52,7 +50,7 @@ func (server *Server) CreateUser(ctx context.Context, req *pb.CreateUserRequest)
 	return rsp, nil
 }
 
-// validateCreateTokenRequest validates the create token request and returns a slice of custom errors.
+// validateCreateUserRequest validates the create user request and returns a slice of custom errors.
 func validateCreateUserRequest(req *pb.CreateUserRequest) (violations []*CustomError) {
 	if err := validator.ValidateUsername(req.GetUsername()); err != nil {
 		violations = append(violations, (&CustomError{
@@ -72,22 +70,28 @@ func validateCreateUserRequest(req *pb.CreateUserRequest) (violations []*CustomE
 		}).WithDetails("email", err))
 	}
 
-	if err := validator.ValidateCountryCode(req.GetCountryCode()); err != nil {
+	if err := validator.ValidatePassword(req.GetPasswordHash()); err != nil {
 		violations = append(violations, (&CustomError{
 			StatusCode: codes.InvalidArgument,
-		}).WithDetails("country_code", err))
+		}).WithDetails("password_hash", err))
 	}
 
-	if err := validator.ValidateRoleId(req.GetRoleId()); err != nil {
+	if req.GetPasswordSalt() != "" {
 		violations = append(violations, (&CustomError{
 			StatusCode: codes.InvalidArgument,
-		}).WithDetails("role_id", err))
+		}).WithDetails("password_salt", errPasswordSalt))
 	}
 
-	if err := validator.ValidateStatus(req.GetStatus()); err != nil {
+	if err := validator.ValidateCountryCode(req.GetCountryCode()); err != nil {
 		violations = append(violations, (&CustomError{
 			StatusCode: codes.InvalidArgument,
-		}).WithDetails("status", err))
+		}).WithDetails("country_code", err))
+	}
+
+	if err := validator.ValidateRoleId(req.GetRoleId()); err != nil {
+		violations = append(violations, (&CustomError{
+			StatusCode: codes.InvalidArgument,
+		}).WithDetails("role_id", err))
 	}
 
 	return violations
@@ -3,8 +3,10 @@ package gapi
 import (
 	"context"
 
+	"github.com/Streamfair/streamfair_user_svc/policy"
 	"github.com/Streamfair/streamfair_user_svc/validator"
 	pb "github.com/Streamfair/common_proto/UserService/pb/user"
+	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
@@ -20,8 +22,16 @@ func (server *Server) DeleteUserById(ctx context.Context, req *pb.DeleteUserById
 		return nil, invalidArgumentError(violation)
 	}
 
+	// Only users with the permission to delete users may proceed
+	err = server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanDeleteUsers()
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// Verify the user exists in the database
-	_, err = server.store.GetUserById(ctx, idParam)
+	user, err := server.store.GetUserById(ctx, idParam)
 	if err != nil {
 		// Handle database errors
 		return nil, handleDatabaseError(err)
@@ -34,5 +44,10 @@ func (server *Server) DeleteUserById(ctx context.Context, req *pb.DeleteUserById
 		return nil, handleDatabaseError(err)
 	}
 
+	// The access tokens of the deleted user must not be accepted until they expire
+	if err := server.revocations.RevokeUserTokens(ctx, user.Username); err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to revoke access tokens of deleted user")
+	}
+
 	return &emptypb.Empty{}, nil
 }
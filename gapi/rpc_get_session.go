@@ -0,0 +1,59 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Streamfair/common_proto/SessionService/pb/session"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetSession returns the device information of a session. The refresh token of the session is never exposed.
+func (server *Server) GetSession(ctx context.Context, req *session.GetSessionRequest) (*session.GetSessionResponse, error) {
+	// Perform field validation
+	sessionID, err := uuid.Parse(req.GetUuid())
+	if err != nil {
+		violation := (&CustomError{
+			StatusCode: codes.InvalidArgument,
+		}).WithDetails("uuid", fmt.Errorf("must be a valid uuid"))
+		return nil, invalidArgumentError(violation)
+	}
+
+	sess, err := server.getManagedSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session.GetSessionResponse{
+		Session: ConvertSession(sess),
+	}, nil
+}
+
+// getManagedSession returns the session if the caller may manage it. Sessions of other users are reported as not
+// found, so that callers can't find out which session IDs exist.
+func (server *Server) getManagedSession(ctx context.Context, sessionID uuid.UUID) (db.UserSvcSession, error) {
+	sess, err := server.store.GetSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.UserSvcSession{}, status.Errorf(codes.NotFound, "session not found")
+		}
+		return db.UserSvcSession{}, handleDatabaseError(err)
+	}
+
+	if err := server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanManageSessions(sess.Username)
+	}); err != nil {
+		if status.Code(err) == codes.PermissionDenied {
+			return db.UserSvcSession{}, status.Errorf(codes.NotFound, "session not found")
+		}
+		return db.UserSvcSession{}, err
+	}
+
+	return sess, nil
+}
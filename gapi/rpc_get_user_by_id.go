@@ -4,6 +4,7 @@ import (
 	"context"
 
 	pb "github.com/Streamfair/common_proto/UserService/pb/user"
+	"github.com/Streamfair/streamfair_user_svc/policy"
 	"github.com/Streamfair/streamfair_user_svc/validator"
 	"google.golang.org/grpc/codes"
 )
@@ -20,6 +21,14 @@ func (server *Server) GetUserById(ctx context.Context, req *pb.GetUserByIdReques
 		return nil, invalidArgumentError(violation)
 	}
 
+	// Users may read their own account, others need the permission to read users
+	err = server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanReadUser(idParam)
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// Fetch user from the database
 	user, err := server.store.GetUserById(ctx, idParam)
 	if err != nil {
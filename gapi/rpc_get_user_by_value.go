@@ -4,6 +4,7 @@ import (
 	"context"
 
 	pb "github.com/Streamfair/common_proto/UserService/pb/user"
+	"github.com/Streamfair/streamfair_user_svc/policy"
 	"github.com/Streamfair/streamfair_user_svc/validator"
 	"google.golang.org/grpc/codes"
 )
@@ -27,6 +28,14 @@ func (server *Server) GetUserByValue(ctx context.Context, req *pb.GetUserByValue
 		return nil, handleDatabaseError(err)
 	}
 
+	// Users may read their own account, others need the permission to read users
+	err = server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanReadUser(user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	rsp := &pb.GetUserByValueResponse{
 		User: ConvertUser(user),
 	}
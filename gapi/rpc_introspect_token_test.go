@@ -0,0 +1,74 @@
+package gapi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// withIntrospectionClient lets the service of the client certificate "stream-svc" introspect tokens.
+func withIntrospectionClient(config *util.Config) {
+	config.IntrospectionClients = []string{"stream-svc"}
+}
+
+// verifiedClientState returns the state of a TLS connection whose client certificate was verified against the CA.
+func verifiedClientState(client string) tls.ConnectionState {
+	return tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{DNSNames: []string{client}}}},
+	}
+}
+
+func TestIntrospectTokenRPC(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store, withIntrospectionClient)
+
+	claims := token.Claims{UserID: 5, Username: util.RandomUsername(), Scopes: []string{"users:read"}}
+	accessToken, payload, err := server.tokenMaker.CreateLocalToken(claims, time.Minute)
+	require.NoError(t, err)
+
+	store.EXPECT().
+		GetSession(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	req, err := structpb.NewStruct(map[string]any{"token": accessToken})
+	require.NoError(t, err)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: verifiedClientState("stream-svc")},
+	})
+	rsp, err := server.IntrospectToken(ctx, req)
+	require.NoError(t, err)
+
+	fields := rsp.GetFields()
+	require.True(t, fields["active"].GetBoolValue())
+	require.Equal(t, claims.Username, fields["sub"].GetStringValue())
+	require.Equal(t, float64(claims.UserID), fields["user_id"].GetNumberValue())
+	require.Equal(t, "users:read", fields["scope"].GetStringValue())
+	require.Equal(t, float64(payload.ExpiredAt.Unix()), fields["exp"].GetNumberValue())
+
+	// Callers without the certificate of an introspection client are rejected
+	ctx = peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{State: verifiedClientState("chat-svc")},
+	})
+	_, err = server.IntrospectToken(ctx, req)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = server.IntrospectToken(context.Background(), req)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -5,6 +5,7 @@ import (
 
 	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
 	pb "github.com/Streamfair/common_proto/UserService/pb/user"
+	"github.com/Streamfair/streamfair_user_svc/policy"
 	"github.com/Streamfair/streamfair_user_svc/validator"
 	"google.golang.org/grpc/codes"
 )
@@ -16,6 +17,14 @@ func (server *Server) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (
 		return nil, invalidArgumentErrors(violations)
 	}
 
+	// Only users with the permission to list users may proceed
+	err := server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanListUsers()
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	users, err := server.store.ListUsers(ctx, db.ListUsersParams{
 		Limit:  req.GetLimit(),
 		Offset: req.GetOffset(),
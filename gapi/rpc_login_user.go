@@ -0,0 +1,166 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Streamfair/common_proto/IdentityProvider/pb/login"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/Streamfair/streamfair_user_svc/validator"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (server *Server) LoginUser(ctx context.Context, req *login.LoginUserRequest) (*login.LoginUserResponse, error) {
+	// Validate the request
+	violations := validateLoginUserRequest(req)
+	if len(violations) > 0 {
+		return nil, invalidArgumentErrors(violations)
+	}
+
+	mtdt := server.extractMetadata(ctx)
+	if err := server.loginGuard.Check(ctx, req.GetUsername(), mtdt.ClientIP); err != nil {
+		return nil, lockoutError(err)
+	}
+
+	// Fetch user from the database
+	user, err := server.store.GetUserByValue(ctx, req.GetUsername())
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Spend the time of a password check, so that unknown usernames can't be told apart by the response time
+			_, _ = util.HashPassword(req.GetPassword(), server.config.Argon2idParams())
+			return nil, server.rejectLogin(ctx, req.GetUsername(), mtdt.ClientIP)
+		}
+		return nil, handleDatabaseError(err)
+	}
+
+	err = util.ComparePassword(user.PasswordHash, user.PasswordSalt, req.GetPassword())
+	if err != nil {
+		return nil, server.rejectLogin(ctx, req.GetUsername(), mtdt.ClientIP)
+	}
+
+	if user.Status.String != util.StatusActive {
+		return nil, status.Errorf(codes.PermissionDenied, "user account is not active, the email address has to be verified first")
+	}
+
+	server.rehashPassword(ctx, user, req.GetPassword())
+
+	mfaEnabled, err := server.mfa.IsEnabled(ctx, user.Username)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+	if mfaEnabled {
+		// The failed attempts are only reset after the second step, so that codes can't be guessed with the password
+		return nil, server.mfaRequiredError(ctx, user.Username)
+	}
+
+	if err := server.loginGuard.RecordSuccess(ctx, user.Username); err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to reset failed login attempts")
+	}
+
+	return server.createLoginSession(ctx, user, mtdt)
+}
+
+// createLoginSession creates the access and refresh tokens of a successful login together with its session.
+func (server *Server) createLoginSession(ctx context.Context, user db.UserSvcUser, mtdt *Metadata) (*login.LoginUserResponse, error) {
+	claims, err := server.tokenClaims(ctx, user)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	accessToken, accessPayload, err := server.createAccessToken(claims)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create access token: %v", err)
+	}
+
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateRefreshToken(
+		claims,
+		server.config.RefreshTokenDuration,
+	)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create refresh token: %v", err)
+	}
+
+	// Record the client of the session
+	session, err := server.store.CreateSession(ctx, db.CreateSessionParams{
+		ID:           refreshPayload.ID,
+		FamilyID:     refreshPayload.ID,
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		UserAgent:    mtdt.UserAgent,
+		ClientIp:     mtdt.ClientIP,
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	})
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	rsp := &login.LoginUserResponse{
+		User:                  ConvertUser(user),
+		SessionId:             session.ID.String(),
+		AccessToken:           accessToken,
+		RefreshToken:          refreshToken,
+		AccessTokenExpiresAt:  timestamppb.New(accessPayload.ExpiredAt),
+		RefreshTokenExpiresAt: timestamppb.New(refreshPayload.ExpiredAt),
+	}
+	return rsp, nil
+}
+
+// tokenClaims returns the claims of the tokens issued to the user, with the permissions of its role as scopes.
+func (server *Server) tokenClaims(ctx context.Context, user db.UserSvcUser) (token.Claims, error) {
+	subject, err := server.policy.SubjectOf(ctx, user)
+	if err != nil {
+		return token.Claims{}, err
+	}
+
+	return token.Claims{
+		UserID:   subject.UserID,
+		Username: subject.Username,
+		RoleID:   subject.RoleID,
+		Scopes:   subject.Permissions(),
+	}, nil
+}
+
+// rehashPassword hashes the password again if the stored hash doesn't use the configured Argon2id parameters.
+// It is called after a successful login, a failure is logged but doesn't fail the login.
+func (server *Server) rehashPassword(ctx context.Context, user db.UserSvcUser, password string) {
+	params := server.config.Argon2idParams()
+	if !util.NeedsRehash(user.PasswordHash, params) {
+		return
+	}
+
+	hashedPassword, err := util.HashPassword(password, params)
+	if err == nil {
+		_, err = server.store.RehashUserPassword(ctx, db.RehashUserPasswordParams{
+			NewPasswordHash: hashedPassword,
+			Username:        user.Username,
+			OldPasswordHash: user.PasswordHash,
+		})
+	}
+	if err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to rehash password")
+	}
+}
+
+// validateLoginUserRequest validates the login user request and returns a slice of custom errors.
+func validateLoginUserRequest(req *login.LoginUserRequest) (violations []*CustomError) {
+	if err := validator.ValidateUsername(req.GetUsername()); err != nil {
+		violations = append(violations, (&CustomError{
+			StatusCode: codes.InvalidArgument,
+		}).WithDetails("username", err))
+	}
+
+	if err := validator.ValidatePassword(req.GetPassword()); err != nil {
+		violations = append(violations, (&CustomError{
+			StatusCode: codes.InvalidArgument,
+		}).WithDetails("password", err))
+	}
+
+	return violations
+}
@@ -0,0 +1,123 @@
+package gapi
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/Streamfair/common_proto/IdentityProvider/pb/login"
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestLoginUserRPC(t *testing.T) {
+	password := util.RandomString(12)
+	hashedPassword, err := util.HashPassword(password, util.Config{}.Argon2idParams())
+	require.NoError(t, err)
+
+	user := db.UserSvcUser{
+		ID:           util.RandomInt(1, 1000),
+		Username:     util.RandomUsername(),
+		PasswordHash: hashedPassword,
+		Status:       util.ConvertToText(util.StatusActive),
+	}
+	inactiveUser := user
+	inactiveUser.Status = util.ConvertToText(util.StatusInactive)
+
+	testCases := []struct {
+		name       string
+		password   string
+		buildStubs func(store *mock_db.MockStore)
+		checkCode  codes.Code
+	}{
+		{
+			name:     "OK",
+			password: password,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetLoginAttempts(gomock.Any(), gomock.Any()).Times(1).Return([]db.UserSvcLoginAttempt{}, nil)
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserSvcTotpSecret{}, pgx.ErrNoRows)
+				store.EXPECT().DeleteLoginAttempts(gomock.Any(), gomock.Any()).Times(1).Return(int64(1), nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateSessionParams) (db.UserSvcSession, error) {
+						// The session records the client of the request
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, "192.0.2.1", arg.ClientIp)
+						require.Equal(t, "test-client", arg.UserAgent)
+						return db.UserSvcSession{ID: arg.ID, Username: arg.Username}, nil
+					})
+			},
+			checkCode: codes.OK,
+		},
+		{
+			name:     "WrongPassword",
+			password: util.RandomString(12),
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetLoginAttempts(gomock.Any(), gomock.Any()).Times(1).Return([]db.UserSvcLoginAttempt{}, nil)
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcLoginAttempt{FailedAttempts: 1}, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkCode: codes.Unauthenticated,
+		},
+		{
+			name:     "InactiveUser",
+			password: password,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetLoginAttempts(gomock.Any(), gomock.Any()).Times(1).Return([]db.UserSvcLoginAttempt{}, nil)
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(inactiveUser, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkCode: codes.PermissionDenied,
+		},
+		{
+			name:     "UserNotFound",
+			password: password,
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetLoginAttempts(gomock.Any(), gomock.Any()).Times(1).Return([]db.UserSvcLoginAttempt{}, nil)
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserSvcUser{}, pgx.ErrNoRows)
+				// Unknown usernames fail like wrong passwords
+				store.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcLoginAttempt{FailedAttempts: 1}, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkCode: codes.Unauthenticated,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			tc.buildStubs(store)
+			server := newTestServer(t, store)
+
+			ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 4711}})
+			ctx = metadata.NewIncomingContext(ctx, metadata.MD{userAgentHeader: []string{"test-client"}})
+			rsp, err := server.LoginUser(ctx, &login.LoginUserRequest{Username: user.Username, Password: tc.password})
+			require.Equal(t, tc.checkCode, status.Code(err))
+			if tc.checkCode != codes.OK {
+				return
+			}
+
+			require.Equal(t, user.Username, rsp.GetUser().GetUsername())
+			require.NotEmpty(t, rsp.GetSessionId())
+			payload, err := server.tokenMaker.VerifyLocalToken(rsp.GetAccessToken())
+			require.NoError(t, err)
+			require.Equal(t, user.Username, payload.Username)
+			_, err = server.tokenMaker.VerifyRefreshToken(rsp.GetRefreshToken())
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Streamfair/common_proto/IdentityProvider/pb/register"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/mail"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/Streamfair/streamfair_user_svc/validator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RegisterUser creates a user from a plaintext password, which is hashed by the server
+// with the configured Argon2id parameters like the REST API does.
+func (server *Server) RegisterUser(ctx context.Context, req *register.RegisterUserRequest) (*register.RegisterUserResponse, error) {
+	violations := validateRegisterUserRequest(req)
+	if len(violations) > 0 {
+		return nil, invalidArgumentErrors(violations)
+	}
+
+	// Clients can't choose their role, other roles can only be assigned by users allowed to do so
+	user, err := server.createUserWithPassword(ctx, db.CreateUserParams{
+		Username:    req.GetUsername(),
+		FullName:    req.GetFullName(),
+		Email:       req.GetEmail(),
+		CountryCode: req.GetCountryCode(),
+		RoleID:      util.ConvertToInt8(policy.RoleUser),
+	}, req.GetPassword())
+	if err != nil {
+		return nil, err
+	}
+
+	rsp := &register.RegisterUserResponse{
+		User: ConvertUser(user),
+	}
+
+	return rsp, nil
+}
+
+// createUserWithPassword hashes the plaintext password with the configured Argon2id parameters and creates
+// the user together with the verification of its email address.
+func (server *Server) createUserWithPassword(ctx context.Context, arg db.CreateUserParams, password string) (db.UserSvcUser, error) {
+	hashedPassword, err := util.HashPassword(password, server.config.Argon2idParams())
+	if err != nil {
+		return db.UserSvcUser{}, status.Errorf(codes.Internal, "failed to hash password: %v", err)
+	}
+
+	secretCode, err := util.GenerateSecretCode()
+	if err != nil {
+		return db.UserSvcUser{}, status.Errorf(codes.Internal, "failed to generate verification code: %v", err)
+	}
+
+	// New users stay inactive until they verified their email address, the requested status is ignored
+	arg.PasswordHash = hashedPassword
+	arg.Status = util.ConvertToText(util.StatusInactive)
+	result, err := server.store.CreateUserTx(ctx, db.CreateUserTxParams{
+		CreateUserParams: arg,
+		SecretCode:       util.HashSecretCode(secretCode),
+		ExpiredAt:        time.Now().Add(server.config.VerifyEmailDuration),
+		AfterCreate: func(user db.UserSvcUser, verifyEmail db.UserSvcVerifyEmail) error {
+			return mail.SendVerifyEmail(server.mailer, server.config.VerifyEmailURL, user.FullName, user.Email, verifyEmail.ID, secretCode)
+		},
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "Users_email_key") {
+			violation := (&CustomError{
+				StatusCode: codes.AlreadyExists,
+			}).WithDetails("email", fmt.Errorf("user with email %s already exists", arg.Email))
+			return db.UserSvcUser{}, invalidArgumentError(violation)
+		} else if strings.Contains(err.Error(), "Users_username_key") {
+			violation := (&CustomError{
+				StatusCode: codes.AlreadyExists,
+			}).WithDetails("username", fmt.Errorf("user with username %s already exists", arg.Username))
+			return db.UserSvcUser{}, invalidArgumentError(violation)
+		}
+		return db.UserSvcUser{}, handleDatabaseError(err)
+	}
+
+	return result.User, nil
+}
+
+// validateRegisterUserRequest validates the register user request and returns a slice of custom errors.
+func validateRegisterUserRequest(req *register.RegisterUserRequest) (violations []*CustomError) {
+	if err := validator.ValidateUsername(req.GetUsername()); err != nil {
+		violations = append(violations, (&CustomError{
+			StatusCode: codes.InvalidArgument,
+		}).WithDetails("username", err))
+	}
+
+	if err := validator.ValidateFullName(req.GetFullName()); err != nil {
+		violations = append(violations, (&CustomError{
+			StatusCode: codes.InvalidArgument,
+		}).WithDetails("full_name", err))
+	}
+
+	if err := validator.ValidateEmail(req.GetEmail()); err != nil {
+		violations = append(violations, (&CustomError{
+			StatusCode: codes.InvalidArgument,
+		}).WithDetails("email", err))
+	}
+
+	if err := validator.ValidatePassword(req.GetPassword()); err != nil {
+		violations = append(violations, (&CustomError{
+			StatusCode: codes.InvalidArgument,
+		}).WithDetails("password", err))
+	}
+
+	if err := validator.ValidateCountryCode(req.GetCountryCode()); err != nil {
+		violations = append(violations, (&CustomError{
+			StatusCode: codes.InvalidArgument,
+		}).WithDetails("country_code", err))
+	}
+
+	return violations
+}
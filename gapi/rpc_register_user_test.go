@@ -0,0 +1,127 @@
+package gapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/common_proto/IdentityProvider/pb/register"
+	pb "github.com/Streamfair/common_proto/UserService/pb/user"
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRegisterUserHashesPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store, func(config *util.Config) {
+		config.VerifyEmailURL = "https://localhost:8080/streamfair/v1/verify_email"
+		config.VerifyEmailDuration = time.Hour
+	})
+
+	req := &register.RegisterUserRequest{
+		Username:    util.RandomUsername(),
+		FullName:    util.RandomString(6) + " " + util.RandomString(8),
+		Email:       util.RandomEmail(),
+		Password:    util.RandomString(12),
+		CountryCode: util.RandomCountryCode(),
+		// Clients can't choose their role
+		RoleId: int32(policy.RoleAdmin),
+	}
+
+	store.EXPECT().
+		CreateUserTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+			require.NotEqual(t, req.Password, arg.PasswordHash)
+
+			require.NoError(t, util.ComparePassword(arg.PasswordHash, arg.PasswordSalt, req.Password))
+			require.Equal(t, util.StatusInactive, arg.Status.String)
+			require.Equal(t, policy.RoleUser, arg.RoleID.Int64)
+
+			return db.CreateUserTxResult{User: db.UserSvcUser{
+				Username:     arg.Username,
+				PasswordHash: arg.PasswordHash,
+				PasswordSalt: arg.PasswordSalt,
+			}}, nil
+		})
+
+	rsp, err := server.RegisterUser(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, req.Username, rsp.GetUser().GetUsername())
+	require.Empty(t, rsp.GetUser().GetPasswordHash())
+	require.Empty(t, rsp.GetUser().GetPasswordSalt())
+}
+
+func TestCreateUserHashesPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	req := &pb.CreateUserRequest{
+		Username: util.RandomUsername(),
+		FullName: util.RandomString(6) + " " + util.RandomString(8),
+		Email:    util.RandomEmail(),
+		// The field carries the plaintext password
+		PasswordHash: util.RandomString(12),
+		CountryCode:  util.RandomCountryCode(),
+		RoleId:       policy.RoleUser,
+		Status:       util.StatusActive,
+	}
+
+	store.EXPECT().
+		CreateUserTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateUserTxParams) (db.CreateUserTxResult, error) {
+			require.NotEqual(t, req.PasswordHash, arg.PasswordHash)
+			require.NoError(t, util.ComparePassword(arg.PasswordHash, arg.PasswordSalt, req.PasswordHash))
+			require.Equal(t, util.StatusInactive, arg.Status.String)
+			require.Equal(t, policy.RoleUser, arg.RoleID.Int64)
+
+			return db.CreateUserTxResult{User: db.UserSvcUser{Username: arg.Username, PasswordHash: arg.PasswordHash}}, nil
+		})
+
+	rsp, err := server.CreateUser(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, req.Username, rsp.GetUser().GetUsername())
+	require.Empty(t, rsp.GetUser().GetPasswordHash())
+
+	// Precomputed hashes come with a salt
+	req.PasswordSalt = util.RandomString(16)
+	_, err = server.CreateUser(context.Background(), req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestCreateUserWithRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	user := db.UserSvcUser{ID: 1, Username: util.RandomUsername(), RoleID: util.ConvertToInt8(policy.RoleUser)}
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).Times(1).Return([]db.UserSvcPermission{}, nil)
+	store.EXPECT().CreateUserTx(gomock.Any(), gomock.Any()).Times(0)
+
+	// Only users allowed to assign roles can create users with other roles
+	_, err := server.CreateUser(newContextWithAuthPayload(user.Username), &pb.CreateUserRequest{
+		Username:     util.RandomUsername(),
+		FullName:     util.RandomString(6) + " " + util.RandomString(8),
+		Email:        util.RandomEmail(),
+		PasswordHash: util.RandomString(12),
+		CountryCode:  util.RandomCountryCode(),
+		RoleId:       policy.RoleAdmin,
+	})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
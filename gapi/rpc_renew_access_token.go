@@ -0,0 +1,62 @@
+package gapi
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// tokenRenewalServer is the server API of the token renewal service. The shared protos don't define the service,
+// so it is described by hand with well-known types: the request carries the field "refresh_token" and the response
+// the fields of the response of the renew_access_token endpoint of the gateway.
+type tokenRenewalServer interface {
+	RenewAccessToken(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+}
+
+var tokenRenewalServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.TokenRenewal",
+	HandlerType: (*tokenRenewalServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RenewAccessToken",
+			Handler:    renewAccessTokenHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func renewAccessTokenHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(tokenRenewalServer).RenewAccessToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.TokenRenewal/RenewAccessToken",
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(tokenRenewalServer).RenewAccessToken(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RenewAccessToken exchanges a refresh token for a new access token and a new refresh token of the same token
+// family. The method doesn't take a bearer token, the refresh token authenticates the caller.
+func (server *Server) RenewAccessToken(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	refreshToken := req.GetFields()["refresh_token"].GetStringValue()
+	if refreshToken == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "refresh_token is required")
+	}
+
+	rsp, err := server.renewAccessToken(ctx, refreshToken, server.extractMetadata(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return convertToStruct(rsp)
+}
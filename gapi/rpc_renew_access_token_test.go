@@ -0,0 +1,81 @@
+package gapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestRenewAccessTokenRPC(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	user := db.UserSvcUser{ID: util.RandomInt(1, 1000), Username: util.RandomUsername()}
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateRefreshToken(token.Claims{UserID: user.ID, Username: user.Username}, time.Hour)
+	require.NoError(t, err)
+
+	session := db.UserSvcSession{
+		ID:           refreshPayload.ID,
+		FamilyID:     uuid.New(),
+		Username:     user.Username,
+		RefreshToken: refreshToken,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	}
+
+	store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(session, nil)
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().
+		RotateSessionTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.RotateSessionTxParams) (db.RotateSessionTxResult, error) {
+			require.Equal(t, session.ID, arg.OldSessionID)
+			require.Equal(t, "192.0.2.1", arg.NewSession.ClientIp)
+			require.Equal(t, "test-client", arg.NewSession.UserAgent)
+			return db.RotateSessionTxResult{Session: db.UserSvcSession{ID: arg.NewSession.ID}}, nil
+		})
+
+	req, err := structpb.NewStruct(map[string]any{"refresh_token": refreshToken})
+	require.NoError(t, err)
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 4711}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.MD{userAgentHeader: []string{"test-client"}})
+	rsp, err := server.RenewAccessToken(ctx, req)
+	require.NoError(t, err)
+
+	fields := rsp.GetFields()
+	require.NotEqual(t, refreshToken, fields["refresh_token"].GetStringValue())
+	payload, err := server.tokenMaker.VerifyLocalToken(fields["access_token"].GetStringValue())
+	require.NoError(t, err)
+	require.Equal(t, user.Username, payload.Username)
+
+	// The errors of the HTTP handler are reported with the matching codes
+	store.EXPECT().GetSession(gomock.Any(), gomock.Eq(session.ID)).Times(1).Return(db.UserSvcSession{}, pgx.ErrNoRows)
+	_, err = server.RenewAccessToken(ctx, req)
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	req, err = structpb.NewStruct(map[string]any{"refresh_token": "invalid"})
+	require.NoError(t, err)
+	_, err = server.RenewAccessToken(ctx, req)
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = server.RenewAccessToken(ctx, &structpb.Struct{})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
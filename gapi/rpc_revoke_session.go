@@ -0,0 +1,43 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Streamfair/common_proto/SessionService/pb/session"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// RevokeSession logs a user out of a device by blocking the token family of the given session.
+// Users may revoke their own sessions, admins may revoke the sessions of every user.
+func (server *Server) RevokeSession(ctx context.Context, req *session.RevokeSessionRequest) (*emptypb.Empty, error) {
+	// Perform field validation
+	sessionID, err := uuid.Parse(req.GetUuid())
+	if err != nil {
+		violation := (&CustomError{
+			StatusCode: codes.InvalidArgument,
+		}).WithDetails("uuid", fmt.Errorf("must be a valid uuid"))
+		return nil, invalidArgumentError(violation)
+	}
+
+	// Verify the session exists in the database and belongs to a user whose sessions the caller may manage
+	sess, err := server.getManagedSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if sess.IsBlocked {
+		return nil, status.Errorf(codes.FailedPrecondition, "session is already blocked")
+	}
+
+	// Block the whole token family so no refresh token issued to the device can be used anymore
+	_, err = server.store.BlockSessionFamily(ctx, sess.FamilyID)
+	if err != nil {
+		return nil, handleDatabaseError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
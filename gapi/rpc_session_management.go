@@ -0,0 +1,123 @@
+package gapi
+
+import (
+	"context"
+
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/validator"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// sessionManagementServer is the server API of the session management service. The shared protos don't define
+// the service, so it is described by hand with well-known types: the requests carry the field "username" and the
+// responses the fields of the responses of the sessions endpoint of the gateway.
+type sessionManagementServer interface {
+	ListSessions(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	RevokeAllSessions(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+}
+
+var sessionManagementServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pb.SessionManagement",
+	HandlerType: (*sessionManagementServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSessions",
+			Handler:    listSessionsHandler,
+		},
+		{
+			MethodName: "RevokeAllSessions",
+			Handler:    revokeAllSessionsHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func listSessionsHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(sessionManagementServer).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SessionManagement/ListSessions",
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(sessionManagementServer).ListSessions(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func revokeAllSessionsHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(sessionManagementServer).RevokeAllSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pb.SessionManagement/RevokeAllSessions",
+	}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return srv.(sessionManagementServer).RevokeAllSessions(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+type listSessionsResponse struct {
+	Sessions []sessionResponse `json:"sessions"`
+}
+
+// ListSessions returns the active sessions, i.e. the signed in devices, of the user of the request.
+// Users may list their own sessions, admins the sessions of every user.
+func (server *Server) ListSessions(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	username, err := server.authorizeSessionManagement(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := server.listSessions(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return convertToStruct(listSessionsResponse{Sessions: sessions})
+}
+
+// RevokeAllSessions signs the user of the request out of all devices and revokes the issued access tokens.
+// Users may revoke their own sessions, admins the sessions of every user.
+func (server *Server) RevokeAllSessions(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error) {
+	username, err := server.authorizeSessionManagement(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := server.revokeAllSessions(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return convertToStruct(rsp)
+}
+
+// authorizeSessionManagement validates the username of the request and checks that the caller may manage its sessions.
+func (server *Server) authorizeSessionManagement(ctx context.Context, req *structpb.Struct) (string, error) {
+	username := req.GetFields()["username"].GetStringValue()
+	if err := validator.ValidateUsername(username); err != nil {
+		violation := (&CustomError{
+			StatusCode: codes.InvalidArgument,
+		}).WithDetails("username", err)
+		return "", invalidArgumentError(violation)
+	}
+
+	if err := server.authorize(ctx, func(subject *policy.Subject) error {
+		return subject.CanManageSessions(username)
+	}); err != nil {
+		return "", err
+	}
+	return username, nil
+}
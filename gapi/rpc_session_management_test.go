@@ -0,0 +1,98 @@
+package gapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/common_proto/SessionService/pb/session"
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// newContextWithAuthPayload returns the context of a request the auth interceptor accepted for the user.
+func newContextWithAuthPayload(username string) context.Context {
+	return context.WithValue(context.Background(), payloadContextKey{}, &token.Payload{Username: username})
+}
+
+func TestSessionManagementRPC(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	user := db.UserSvcUser{ID: 1, Username: util.RandomUsername(), RoleID: util.ConvertToInt8(policy.RoleUser)}
+	other := util.RandomUsername()
+	sessions := []db.UserSvcSession{
+		{ID: uuid.New(), Username: user.Username, UserAgent: "test-client", ExpiresAt: time.Now().Add(time.Hour)},
+	}
+
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).AnyTimes().Return(user, nil)
+	store.EXPECT().ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).AnyTimes().Return([]db.UserSvcPermission{}, nil)
+
+	ctx := newContextWithAuthPayload(user.Username)
+	newRequest := func(username string) *structpb.Struct {
+		req, err := structpb.NewStruct(map[string]any{"username": username})
+		require.NoError(t, err)
+		return req
+	}
+
+	store.EXPECT().ListActiveSessionsByUsername(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(sessions, nil)
+	rsp, err := server.ListSessions(ctx, newRequest(user.Username))
+	require.NoError(t, err)
+	listed := rsp.GetFields()["sessions"].GetListValue().GetValues()
+	require.Len(t, listed, 1)
+	require.Equal(t, sessions[0].ID.String(), listed[0].GetStructValue().GetFields()["id"].GetStringValue())
+
+	store.EXPECT().BlockSessionsByUsername(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(int64(1), nil)
+	store.EXPECT().SetTokenWatermark(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcTokenWatermark{}, nil)
+	rsp, err = server.RevokeAllSessions(ctx, newRequest(user.Username))
+	require.NoError(t, err)
+	require.Equal(t, float64(1), rsp.GetFields()["revoked_sessions"].GetNumberValue())
+
+	// The sessions of other users can only be managed by admins
+	store.EXPECT().ListActiveSessionsByUsername(gomock.Any(), gomock.Any()).Times(0)
+	store.EXPECT().BlockSessionsByUsername(gomock.Any(), gomock.Any()).Times(0)
+	_, err = server.ListSessions(ctx, newRequest(other))
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = server.RevokeAllSessions(ctx, newRequest(other))
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = server.ListSessions(ctx, newRequest(""))
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = server.ListSessions(context.Background(), newRequest(user.Username))
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestRevokeSessionOfOtherUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	user := db.UserSvcUser{ID: 1, Username: util.RandomUsername(), RoleID: util.ConvertToInt8(policy.RoleUser)}
+	otherSession := db.UserSvcSession{ID: uuid.New(), FamilyID: uuid.New(), Username: util.RandomUsername()}
+
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(2).Return(user, nil)
+	store.EXPECT().ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).Times(2).Return([]db.UserSvcPermission{}, nil)
+	store.EXPECT().GetSession(gomock.Any(), gomock.Eq(otherSession.ID)).Times(2).Return(otherSession, nil)
+	store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Any()).Times(0)
+
+	// Sessions of other users can't be told apart from sessions that don't exist
+	ctx := newContextWithAuthPayload(user.Username)
+	_, err := server.RevokeSession(ctx, &session.RevokeSessionRequest{Uuid: otherSession.ID.String()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+	_, err = server.GetSession(ctx, &session.GetSessionRequest{Uuid: otherSession.ID.String()})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -2,13 +2,18 @@ package gapi
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
 	pb "github.com/Streamfair/common_proto/UserService/pb/user"
+	"github.com/Streamfair/streamfair_user_svc/mail"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/util"
 	"github.com/Streamfair/streamfair_user_svc/validator"
 	"github.com/jackc/pgx/v5/pgtype"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func (server *Server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
@@ -18,6 +23,26 @@ func (server *Server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest)
 		return nil, invalidArgumentErrors(violations)
 	}
 
+	// Users may update their own account, others need the permission to update users.
+	// Changing the role or the status of a user always requires the permission to do so.
+	err := server.authorize(ctx, func(subject *policy.Subject) error {
+		if err := subject.CanUpdateUser(req.GetId()); err != nil {
+			return err
+		}
+		if req.GetRoleId() != 0 {
+			if err := subject.CanAssignRole(); err != nil {
+				return err
+			}
+		}
+		if req.GetStatus() != "" {
+			return subject.CanChangeStatus()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	user, err := server.store.GetUserById(ctx, req.GetId())
 	if err != nil {
 		return nil, handleDatabaseError(err)
@@ -28,30 +53,42 @@ func (server *Server) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest)
 		Username:          pgtype.Text{String: req.GetUsername(), Valid: req.Username != "" && req.Username != user.Username},
 		FullName:          pgtype.Text{String: req.GetFullName(), Valid: req.FullName != ""},
 		Email:             pgtype.Text{String: req.GetEmail(), Valid: req.Email != "" && req.Email != user.Email},
-		PasswordHash:      pgtype.Text{String: req.GetPasswordHash(), Valid: req.PasswordHash != ""},
-		PasswordSalt:      pgtype.Text{String: req.GetPasswordSalt(), Valid: req.PasswordSalt != ""},
 		CountryCode:       pgtype.Text{String: req.GetCountryCode(), Valid: req.CountryCode != ""},
 		RoleID:            pgtype.Int8{Int64: req.GetRoleId(), Valid: req.RoleId != 0},
 		Status:            pgtype.Text{String: req.GetStatus(), Valid: req.Status != ""},
-		PasswordChangedAt: pgtype.Timestamptz{Time: time.Now(), Valid: req.PasswordChangedAt != nil && req.PasswordChangedAt.AsTime() != user.PasswordChangedAt},
 		EmailChangedAt:    pgtype.Timestamptz{Time: time.Now(), Valid: req.EmailChangedAt != nil && req.EmailChangedAt.AsTime() != user.EmailChangedAt},
 		UsernameChangedAt: pgtype.Timestamptz{Time: time.Now(), Valid: req.UsernameChangedAt != nil && req.UsernameChangedAt.AsTime() != user.UsernameChangedAt},
 	}
 
-	// Update the user in the database
-	user, err = server.store.UpdateUser(ctx, arg)
+	secretCode, err := util.GenerateSecretCode()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to generate verification code: %v", err)
+	}
+
+	// Update the user in the database, a changed email address has to be verified again
+	result, err := server.store.UpdateUserTx(ctx, db.UpdateUserTxParams{
+		UpdateUserParams: arg,
+		SecretCode:       util.HashSecretCode(secretCode),
+		ExpiredAt:        time.Now().Add(server.config.VerifyEmailDuration),
+		AfterEmailChange: func(user db.UserSvcUser, verifyEmail db.UserSvcVerifyEmail) error {
+			return mail.SendVerifyEmail(server.mailer, server.config.VerifyEmailURL, user.FullName, user.Email, verifyEmail.ID, secretCode)
+		},
+	})
 	if err != nil {
 		return nil, handleDatabaseError(err)
 	}
 
 	rsp := &pb.UpdateUserResponse{
-		User: ConvertUser(user),
+		User: ConvertUser(result.User),
 	}
 
 	return rsp, nil
 }
 
-// validateCreateTokenRequest validates the create token request and returns a slice of custom errors.
+// errPasswordUpdateNotAllowed is reported when the password is sent with a generic user update.
+var errPasswordUpdateNotAllowed = errors.New("password can't be updated, use the change password endpoint")
+
+// validateUpdateUserRequest validates the update user request and returns a slice of custom errors.
 func validateUpdateUserRequest(req *pb.UpdateUserRequest) (violations []*CustomError) {
 	if err := validator.ValidateUsername(req.GetUsername()); err != nil {
 		violations = append(violations, (&CustomError{
@@ -83,10 +120,20 @@ func validateUpdateUserRequest(req *pb.UpdateUserRequest) (violations []*CustomE
 		}).WithDetails("id", err))
 	}
 
-	if err := validator.ValidateStatus(req.GetStatus()); err != nil {
+	// The status is optional, only users allowed to change it may send it
+	if req.GetStatus() != "" {
+		if err := validator.ValidateStatus(req.GetStatus()); err != nil {
+			violations = append(violations, (&CustomError{
+				StatusCode: codes.InvalidArgument,
+			}).WithDetails("status", err))
+		}
+	}
+
+	// Passwords are changed via the change password endpoint of the gateway, which requires the old password
+	if req.GetPasswordHash() != "" || req.GetPasswordSalt() != "" || req.GetPasswordChangedAt() != nil {
 		violations = append(violations, (&CustomError{
 			StatusCode: codes.InvalidArgument,
-		}).WithDetails("status", err))
+		}).WithDetails("password_hash", errPasswordUpdateNotAllowed))
 	}
 
 	return violations
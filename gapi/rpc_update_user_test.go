@@ -0,0 +1,39 @@
+package gapi
+
+import (
+	"testing"
+
+	pb "github.com/Streamfair/common_proto/UserService/pb/user"
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUpdateUserStatusRequiresPermission(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	server := newTestServer(t, store)
+
+	user := db.UserSvcUser{ID: 1, Username: util.RandomUsername(), RoleID: util.ConvertToInt8(policy.RoleUser)}
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleUser)).Times(1).Return([]db.UserSvcPermission{}, nil)
+	store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(0)
+
+	// Users can't activate their own account without verifying the email address
+	_, err := server.UpdateUser(newContextWithAuthPayload(user.Username), &pb.UpdateUserRequest{
+		Id:          user.ID,
+		Username:    user.Username,
+		FullName:    util.RandomString(6) + " " + util.RandomString(8),
+		Email:       util.RandomEmail(),
+		CountryCode: util.RandomCountryCode(),
+		Status:      util.StatusActive,
+	})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
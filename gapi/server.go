@@ -10,9 +10,23 @@ import (
 	"net/http"
 	"os"
 
+	idp "github.com/Streamfair/common_proto/IdentityProvider/pb"
+	sessionpb "github.com/Streamfair/common_proto/SessionService/pb"
+	"github.com/Streamfair/streamfair_user_svc/apikey"
 	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
 	_ "github.com/Streamfair/streamfair_user_svc/doc/statik"
+	"github.com/Streamfair/streamfair_user_svc/introspection"
 	"github.com/Streamfair/common_proto/UserService/pb"
+	"github.com/Streamfair/streamfair_user_svc/lockout"
+	"github.com/Streamfair/streamfair_user_svc/mail"
+	"github.com/Streamfair/streamfair_user_svc/mfa"
+	"github.com/Streamfair/streamfair_user_svc/oauth"
+	"github.com/Streamfair/streamfair_user_svc/passwordreset"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/ratelimit"
+	"github.com/Streamfair/streamfair_user_svc/revocation"
+	"github.com/Streamfair/streamfair_user_svc/social"
+	"github.com/Streamfair/streamfair_user_svc/tlscert"
 	"github.com/Streamfair/streamfair_user_svc/token"
 	"github.com/Streamfair/streamfair_user_svc/util"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
@@ -33,36 +47,125 @@ type Server struct {
 	grpcServer *grpc.Server
 	httpServer *http.Server
 	pb.UnimplementedUserServiceServer
-	config          util.Config
-	store           db.Store
-	healthSrv       *health.Server
-	localTokenMaker token.Maker
+	idp.UnimplementedIdentityProviderServer
+	sessionpb.UnimplementedSessionServiceServer
+	config       util.Config
+	store        db.Store
+	healthSrv    *health.Server
+	keyring      *token.Keyring
+	tokenMaker   token.Maker
+	policy       *policy.Policy
+	loginGuard   *lockout.Guard
+	rateLimiter  *ratelimit.Limiter
+	mfa          *mfa.Manager
+	revocations  *revocation.List
+	introspector *introspection.Introspector
+	oauth        *oauth.Provider
+	social       *social.Manager
+	apiKeys      *apikey.Manager
+	mailer       mail.EmailSender
+	resetter     *passwordreset.Resetter
+	// serviceAllowlist restricts methods to the services identified by their client certificates.
+	serviceAllowlist serviceAllowlist
+	// trustedProxies are the proxies whose forwarded client addresses are trusted, e.g. the gateway.
+	trustedProxies util.TrustedProxies
+	// keypair holds the certificate of the gRPC and HTTP servers, which can be reloaded at runtime.
+	keypair *tlscert.Keypair
 }
 
 // NewServer creates a new gRPC server.
-func NewServer(config util.Config, store db.Store) (*Server, error) {
-	localTokenMaker, err := token.NewLocalPasetoMaker(config.TokenSymmetricKey)
+func NewServer(config util.Config, store db.Store, mailer mail.EmailSender) (*Server, error) {
+	keyring, err := token.LoadKeyring(config.TokenKeyringFile, config.TokenSymmetricKey, config.TokenPreviousSymmetricKeys)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create local token maker: %w", err)
+		return nil, fmt.Errorf("failed to load token keyring: %w", err)
+	}
+
+	tokenMaker, err := token.NewPasetoMaker(keyring, config.TokenPrivateKeys, config.TokenIssuer, config.TokenAudience)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token maker: %w", err)
 	}
 
 	tlsConfig, err := LoadTLSConfigWithTrustedCerts(config.CertPem, config.KeyPem, config.CaCertPem)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load TLS config for 'NewServer': %w", err)
 	}
+	keypair, err := newKeypair(config, tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	keypair.Apply(tlsConfig)
+	if len(config.IntrospectionClients) > 0 {
+		requestClientCertificates(tlsConfig)
+	}
+	for _, method := range config.GrpcPublicMethods {
+		if !isFullMethodName(method) {
+			return nil, fmt.Errorf("invalid public gRPC method %q, expected /package.Service/Method", method)
+		}
+	}
+	allowlist, err := parseServiceAllowlist(config.GrpcServiceAllowlist)
+	if err != nil {
+		return nil, err
+	}
+	if err := configureClientAuth(tlsConfig, config.GrpcClientAuth, allowlist); err != nil {
+		return nil, err
+	}
+	trustedProxies, err := util.ParseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
 
 	creds := credentials.NewTLS(tlsConfig)
-	grpcLogger := grpc.UnaryInterceptor(GrpcLogger)
+
+	rateLimits, err := ratelimit.ParseLimits(config.RateLimits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rate limits: %w", err)
+	}
+
+	mfaManager, err := mfa.NewManager(config, store)
+	if err != nil {
+		return nil, err
+	}
+
+	socialManager, err := social.NewManager(config, store)
+	if err != nil {
+		return nil, err
+	}
+
+	revocations := revocation.NewList(config, store)
+	authPolicy := policy.NewPolicy(store)
+	loginGuard := lockout.NewGuard(config, store)
 
 	server := &Server{
-		config:          config,
-		store:           store,
-		grpcServer:      grpc.NewServer(grpc.Creds(creds), grpcLogger),
-		httpServer:      &http.Server{},
-		healthSrv:       health.NewServer(),
-		localTokenMaker: localTokenMaker,
+		config:       config,
+		store:        store,
+		httpServer:   &http.Server{},
+		healthSrv:    health.NewServer(),
+		keyring:      keyring,
+		tokenMaker:   tokenMaker,
+		policy:       authPolicy,
+		loginGuard:   loginGuard,
+		rateLimiter:  ratelimit.NewLimiter(ratelimit.NewMemoryBackend(), rateLimits),
+		mfa:          mfaManager,
+		revocations:  revocations,
+		introspector: introspection.NewIntrospector(config, tokenMaker, store, revocations),
+		oauth:        oauth.NewProvider(config, store, tokenMaker, authPolicy, loginGuard, mfaManager),
+		social:       socialManager,
+		apiKeys:      apikey.NewManager(store, authPolicy),
+		mailer:       mailer,
+		resetter:     passwordreset.NewResetter(config, store, mailer, revocations),
+		keypair:      keypair,
+
+		serviceAllowlist: allowlist,
+		trustedProxies:   trustedProxies,
 	}
 
+	// The logger runs first so that rejected requests are logged as well. The rate limiter runs before the
+	// credentials are checked, so that guessing tokens is limited too. Callers are checked against the service
+	// allowlist before their tokens are verified.
+	unaryInterceptors := grpc.ChainUnaryInterceptor(GrpcLogger, server.RateLimitInterceptor, server.ServiceIdentityInterceptor, server.AuthInterceptor)
+	streamInterceptors := grpc.ChainStreamInterceptor(server.StreamRateLimitInterceptor, server.StreamServiceIdentityInterceptor, server.StreamAuthInterceptor)
+	server.grpcServer = grpc.NewServer(grpc.Creds(creds), unaryInterceptors, streamInterceptors)
+
 	grpc_health_v1.RegisterHealthServer(server.grpcServer, server.healthSrv)
 
 	return server, nil
@@ -71,6 +174,11 @@ func NewServer(config util.Config, store db.Store) (*Server, error) {
 // RunGrpcServer: runs a gRPC server on the given address.
 func (server *Server) RunGrpcServer() {
 	pb.RegisterUserServiceServer(server.grpcServer, server)
+	idp.RegisterIdentityProviderServer(server.grpcServer, server)
+	sessionpb.RegisterSessionServiceServer(server.grpcServer, server)
+	server.grpcServer.RegisterService(&tokenIntrospectionServiceDesc, server)
+	server.grpcServer.RegisterService(&tokenRenewalServiceDesc, server)
+	server.grpcServer.RegisterService(&sessionManagementServiceDesc, server)
 	reflection.Register(server.grpcServer)
 
 	server.healthSrv.SetServingStatus("", grpc_health_v1.HealthCheckResponse_SERVING)
@@ -94,6 +202,8 @@ func (server *Server) RunGrpcGatewayServer() {
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to load TLS config:")
 	}
+	// The gateway presents the certificate of the keypair to its clients and to the gRPC server
+	server.keypair.Apply(tlsConfig)
 
 	healthClient, err := CreateHealthClient(context.Background(), server.config.GrpcServerAddress, tlsConfig)
 	if err != nil {
@@ -112,15 +222,50 @@ func (server *Server) RunGrpcGatewayServer() {
 		runtime.WithHealthEndpointAt(healthClient, "/streamfair/v1/healthz"),
 	)
 
-	if err := pb.RegisterUserServiceHandlerServer(context.Background(), grpcMux, server); err != nil {
+	// The gateway calls the gRPC server over the network instead of in-process,
+	// so that gateway requests pass through the same interceptors as gRPC requests.
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))}
+
+	if err := pb.RegisterUserServiceHandlerFromEndpoint(context.Background(), grpcMux, server.config.GrpcServerAddress, dialOpts); err != nil {
 		log.Fatal().Err(err).Msg("server: error while registering gRPC server:")
 	}
 
+	if err := idp.RegisterIdentityProviderHandlerFromEndpoint(context.Background(), grpcMux, server.config.GrpcServerAddress, dialOpts); err != nil {
+		log.Fatal().Err(err).Msg("server: error while registering identity provider gRPC server:")
+	}
+
+	if err := sessionpb.RegisterSessionServiceHandlerFromEndpoint(context.Background(), grpcMux, server.config.GrpcServerAddress, dialOpts); err != nil {
+		log.Fatal().Err(err).Msg("server: error while registering session gRPC server:")
+	}
+
 	// Add the HTTP logger middleware
 	httpLogger := HttpLogger(grpcMux)
 
 	mux := http.NewServeMux()
 	mux.Handle("/", httpLogger)
+	// Plain HTTP endpoints for flows the shared protos don't define RPCs for
+	mux.Handle("/streamfair/v1/verify_email", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.VerifyEmail))))
+	mux.Handle("/streamfair/v1/request_password_reset", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.RequestPasswordReset))))
+	mux.Handle("/streamfair/v1/reset_password", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.ResetPassword))))
+	mux.Handle("/streamfair/v1/change_password", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.ChangePassword))))
+	mux.Handle("/streamfair/v1/logout", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.LogoutUser))))
+	mux.Handle("/streamfair/v1/renew_access_token", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.RenewAccessTokenHTTP))))
+	mux.Handle("/streamfair/v1/sessions", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.Sessions))))
+	mux.Handle("/streamfair/v1/introspect", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.IntrospectTokenHTTP))))
+	mux.Handle("/streamfair/v1/unlock_user", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.UnlockUser))))
+	mux.Handle("/.well-known/jwks.json", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.ListPublicKeys))))
+	mux.Handle("/.well-known/openid-configuration", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.GetOpenIDConfiguration))))
+	mux.Handle("/oauth/authorize", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.AuthorizeOAuthClient))))
+	mux.Handle("/oauth/token", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.IssueOAuthToken))))
+	mux.Handle("/oauth/userinfo", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.GetOAuthUserInfo))))
+	mux.Handle("/streamfair/v1/login_mfa", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.LoginUserMfa))))
+	mux.Handle("/streamfair/v1/login_external", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.LoginUserExternal))))
+	mux.Handle("/streamfair/v1/login_external/callback", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.LoginUserExternalCallback))))
+	mux.Handle("/streamfair/v1/external_identities", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.ExternalIdentities))))
+	mux.Handle("/streamfair/v1/api_keys", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.ApiKeys))))
+	mux.Handle("/streamfair/v1/mfa/totp/enroll", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.EnrollTotp))))
+	mux.Handle("/streamfair/v1/mfa/totp/confirm", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.ConfirmTotp))))
+	mux.Handle("/streamfair/v1/mfa/totp/disable", HttpLogger(server.HttpRateLimiter(http.HandlerFunc(server.DisableTotp))))
 
 	if err := ServeSwaggerUI(mux); err != nil {
 		log.Fatal().Err(err).Msg("Failed to serve Swagger UI:")
@@ -129,7 +274,14 @@ func (server *Server) RunGrpcGatewayServer() {
 	handler := h2c.NewHandler(mux, &http2.Server{})
 	server.httpServer.Handler = handler
 
-	if err := StartHTTPServer(server.httpServer, server.config, server.config.CertPem, server.config.KeyPem); err != nil {
+	httpTLSConfig := tlsConfig.Clone()
+	if len(server.config.IntrospectionClients) > 0 {
+		requestClientCertificates(httpTLSConfig)
+	}
+	server.httpServer.TLSConfig = httpTLSConfig
+
+	// The certificate comes from the TLS config, so that the files aren't loaded once more and kept until a restart
+	if err := StartHTTPServer(server.httpServer, server.config, "", ""); err != nil {
 		log.Fatal().Err(err).Msg("Failed to start HTTP server:")
 	}
 }
@@ -176,6 +328,22 @@ func LoadTLSConfigWithTrustedCerts(certPath, keyPath, caCertPath string) (*tls.C
 	}, nil
 }
 
+// newKeypair creates the keypair of the certificate of the server in the TLS config. Certificates given as
+// raw PEM data in CI can't be reloaded, certificate files are loaded again to be reloaded later.
+func newKeypair(config util.Config, tlsConfig *tls.Config) (*tlscert.Keypair, error) {
+	if viper.GetString("CI") == "true" {
+		return tlscert.NewKeypair(tlsConfig.Certificates[0])
+	}
+	return tlscert.LoadKeypair(config.CertPem, config.KeyPem, tlsConfig.RootCAs)
+}
+
+// requestClientCertificates makes the server ask its callers for a client certificate issued by the CA,
+// which authenticates the services that introspect tokens. Callers without a certificate are still accepted.
+func requestClientCertificates(tlsConfig *tls.Config) {
+	tlsConfig.ClientCAs = tlsConfig.RootCAs
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+}
+
 // CreateHealthClient creates a gRPC health client to be used for health checks.
 func CreateHealthClient(ctx context.Context, grpcServerAddress string, tlsConfig *tls.Config) (grpc_health_v1.HealthClient, error) {
 	creds := credentials.NewTLS(tlsConfig)
@@ -204,13 +372,15 @@ func ServeSwaggerUI(mux *http.ServeMux) error {
 // StartHTTPServer starts the HTTP server with TLS enabled.
 func StartHTTPServer(server *http.Server, config util.Config, certPath, keyPath string) error {
 	if viper.GetString("CI") == "true" {
-		tlsConfig, err := LoadTLSConfigWithTrustedCerts(config.CertPem, config.KeyPem, config.CaCertPem)
-		if err != nil {
-			log.Fatal().Err(err).Msg("Failed to load TLS config:")
+		if server.TLSConfig == nil {
+			tlsConfig, err := LoadTLSConfigWithTrustedCerts(config.CertPem, config.KeyPem, config.CaCertPem)
+			if err != nil {
+				log.Fatal().Err(err).Msg("Failed to load TLS config:")
+			}
+
+			// Set the TLSConfig on the http.Server
+			server.TLSConfig = tlsConfig
 		}
-
-		// Set the TLSConfig on the http.Server
-		server.TLSConfig = tlsConfig
 		certPath = ""
 		keyPath = ""
 	}
@@ -230,4 +400,15 @@ func StartHTTPServer(server *http.Server, config util.Config, certPath, keyPath
 
 func (server *Server) Shutdown() {
 	server.grpcServer.GracefulStop()
+	server.resetter.Wait()
+}
+
+// Keypair returns the certificate of the server, so that it can be reloaded while the server is running.
+func (server *Server) Keypair() *tlscert.Keypair {
+	return server.keypair
+}
+
+// Keyring returns the keyring of local tokens, so that it can be reloaded while the server is running.
+func (server *Server) Keyring() *token.Keyring {
+	return server.keyring
 }
@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"testing"
@@ -21,6 +22,22 @@ import (
 	"google.golang.org/grpc/health/grpc_health_v1"
 )
 
+// withLocalAddresses lets the gRPC and HTTP servers listen on free ports of localhost.
+func withLocalAddresses(t *testing.T) testServerOption {
+	freeAddress := func() string {
+		listener, err := net.Listen("tcp", "localhost:0")
+		require.NoError(t, err)
+		defer listener.Close()
+		return fmt.Sprintf("localhost:%d", listener.Addr().(*net.TCPAddr).Port)
+	}
+	grpcAddress, httpAddress := freeAddress(), freeAddress()
+
+	return func(config *util.Config) {
+		config.GrpcServerAddress = grpcAddress
+		config.HttpServerAddress = httpAddress
+	}
+}
+
 func TestGRPCServer(t *testing.T) {
 	// Use a sub-test to isolate the environment and ensure cleanup is executed
 	t.Run("gRPC Server Test", func(t *testing.T) {
@@ -30,7 +47,7 @@ func TestGRPCServer(t *testing.T) {
 		mockStore := mock_db.NewMockStore(ctrl)
 
 		// Start the gRPC server
-		server := newTestServer(t, mockStore)
+		server := newTestServer(t, mockStore, withLocalAddresses(t))
 		defer server.Shutdown()
 
 		// Start the gRPC server in a goroutine
@@ -90,11 +107,9 @@ func TestGRPCGatewayServer(t *testing.T) {
 		defer ctrl.Finish()
 		mockStore := mock_db.NewMockStore(ctrl)
 
-		server := newTestServer(t, mockStore)
+		server := newTestServer(t, mockStore, withLocalAddresses(t))
 		defer server.Shutdown()
-
-		config, err := util.LoadConfig()
-		require.NoError(t, err)
+		config := server.config
 
 		// Start the gRPC server and the grpc gateway server in goroutines
 		go server.RunGrpcServer()
@@ -0,0 +1,153 @@
+package gapi
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/Streamfair/streamfair_user_svc/introspection"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Client authentication modes of the gRPC server. With "request" callers without a client certificate are
+// still accepted, with "require" every caller has to present a client certificate issued by the CA.
+const (
+	ClientAuthNone    = "none"
+	ClientAuthRequest = "request"
+	ClientAuthRequire = "require"
+)
+
+// serviceIdentityContextKey is the context key under which the identities of the calling service are stored.
+type serviceIdentityContextKey struct{}
+
+// serviceAllowlist maps methods to the identities of the services that may call them.
+// Methods that aren't on the allowlist may be called by any service.
+type serviceAllowlist map[string]map[string]bool
+
+// parseServiceAllowlist parses allowlist entries of the form /package.Service/Method=identity. Entries of the same
+// method add up, so that a method can be allowed for several services.
+func parseServiceAllowlist(entries []string) (serviceAllowlist, error) {
+	allowlist := make(serviceAllowlist)
+	for _, entry := range entries {
+		method, identity, ok := strings.Cut(entry, "=")
+		method = strings.TrimSpace(method)
+		identity = strings.TrimSpace(identity)
+		if !ok || !isFullMethodName(method) || identity == "" {
+			return nil, fmt.Errorf("invalid service allowlist entry %q, expected /package.Service/Method=identity", entry)
+		}
+		if allowlist[method] == nil {
+			allowlist[method] = make(map[string]bool)
+		}
+		allowlist[method][identity] = true
+	}
+	return allowlist, nil
+}
+
+// allows reports whether the given full method name is on the allowlist, and if so whether one of the identities
+// may call it.
+func (a serviceAllowlist) allows(fullMethod string, identities []string) (restricted bool, allowed bool) {
+	allowedIdentities, restricted := a[fullMethod]
+	if !restricted {
+		return false, false
+	}
+	for _, identity := range identities {
+		if allowedIdentities[identity] {
+			return true, true
+		}
+	}
+	return true, false
+}
+
+// isFullMethodName reports whether the method is a full gRPC method name of the form /package.Service/Method.
+func isFullMethodName(method string) bool {
+	if !strings.HasPrefix(method, "/") {
+		return false
+	}
+	service, name, ok := strings.Cut(method[1:], "/")
+	return ok && service != "" && name != "" && !strings.Contains(name, "/")
+}
+
+// configureClientAuth sets up the verification of client certificates against the CA for the given mode.
+// Allowlisted methods need the certificates of their callers, so they are requested at least. The gateway
+// presents the certificate of the server to the gRPC server, so with "require" that certificate has to be
+// valid for client authentication as well.
+func configureClientAuth(tlsConfig *tls.Config, mode string, allowlist serviceAllowlist) error {
+	switch mode {
+	case ClientAuthRequire:
+		tlsConfig.ClientCAs = tlsConfig.RootCAs
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	case ClientAuthRequest:
+		requestClientCertificates(tlsConfig)
+	case ClientAuthNone, "":
+		if len(allowlist) > 0 {
+			requestClientCertificates(tlsConfig)
+		}
+	default:
+		return fmt.Errorf("unknown gRPC client auth mode %q, expected %q, %q or %q", mode, ClientAuthNone, ClientAuthRequest, ClientAuthRequire)
+	}
+	return nil
+}
+
+// ServiceIdentityInterceptor stores the identities of the verified client certificate of the caller in the context
+// of every unary request and rejects calls of allowlisted methods by other services.
+func (server *Server) ServiceIdentityInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := server.authorizeService(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// StreamServiceIdentityInterceptor stores the identities of the verified client certificate of the caller in the
+// context of every stream and rejects streams of allowlisted methods by other services.
+func (server *Server) StreamServiceIdentityInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := server.authorizeService(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authorizedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authorizeService returns the context with the identities of the calling service and checks them against
+// the allowlist of the method.
+func (server *Server) authorizeService(ctx context.Context, fullMethod string) (context.Context, error) {
+	identities := peerIdentities(ctx)
+	if len(identities) > 0 {
+		ctx = context.WithValue(ctx, serviceIdentityContextKey{}, identities)
+	}
+
+	restricted, allowed := server.serviceAllowlist.allows(fullMethod, identities)
+	switch {
+	case !restricted, allowed:
+		return ctx, nil
+	case len(identities) == 0:
+		return nil, status.Errorf(codes.Unauthenticated, "method requires the client certificate of an allowed service")
+	default:
+		return nil, status.Errorf(codes.PermissionDenied, "service %q is not allowed to call this method", identities[0])
+	}
+}
+
+// ServiceIdentitiesFromContext returns the DNS names, URIs and common name of the verified client certificate
+// the caller presented, or nil for callers without a client certificate.
+func ServiceIdentitiesFromContext(ctx context.Context) []string {
+	identities, _ := ctx.Value(serviceIdentityContextKey{}).([]string)
+	return identities
+}
+
+// peerIdentities returns the identities of the verified client certificate of the connection of the request.
+func peerIdentities(ctx context.Context) []string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	return introspection.ClientIdentities(&tlsInfo.State)
+}
@@ -0,0 +1,145 @@
+package gapi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// newContextWithClientCertificate returns the context of a request over a connection whose client certificate
+// was verified for the given identity. Without an identity the caller didn't present a certificate.
+func newContextWithClientCertificate(identity string) context.Context {
+	state := tls.ConnectionState{}
+	if identity != "" {
+		state = verifiedClientState(identity)
+	}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr:     &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1234},
+		AuthInfo: credentials.TLSInfo{State: state},
+	})
+}
+
+func TestParseServiceAllowlist(t *testing.T) {
+	allowlist, err := parseServiceAllowlist([]string{
+		"/pb.UserService/DeleteUserByValue=stream-svc",
+		"/pb.UserService/DeleteUserByValue = admin-svc",
+		"/pb.UserService/DeleteUserById=stream-svc",
+	})
+	require.NoError(t, err)
+	require.Equal(t, serviceAllowlist{
+		"/pb.UserService/DeleteUserByValue": {"stream-svc": true, "admin-svc": true},
+		"/pb.UserService/DeleteUserById":    {"stream-svc": true},
+	}, allowlist)
+
+	for _, entry := range []string{
+		"/pb.UserService/DeleteUserByValue",
+		"DeleteUserByValue=stream-svc",
+		"/pb.UserService/DeleteUserByValue=",
+		"UserService/DeleteUserByValue=stream-svc",
+		"/pb.UserService/=stream-svc",
+	} {
+		_, err := parseServiceAllowlist([]string{entry})
+		require.Error(t, err, entry)
+	}
+}
+
+func TestServiceIdentityInterceptor(t *testing.T) {
+	server := newTestServer(t, nil, func(config *util.Config) {
+		config.GrpcServiceAllowlist = []string{"/pb.UserService/DeleteUserByValue=stream-svc"}
+	})
+
+	testCases := []struct {
+		name       string
+		fullMethod string
+		identity   string
+		checkCode  codes.Code
+	}{
+		{
+			name:       "AllowedService",
+			fullMethod: "/pb.UserService/DeleteUserByValue",
+			identity:   "stream-svc",
+			checkCode:  codes.OK,
+		},
+		{
+			name:       "OtherService",
+			fullMethod: "/pb.UserService/DeleteUserByValue",
+			identity:   "chat-svc",
+			checkCode:  codes.PermissionDenied,
+		},
+		{
+			name:       "NoCertificate",
+			fullMethod: "/pb.UserService/DeleteUserByValue",
+			checkCode:  codes.Unauthenticated,
+		},
+		{
+			// Only the full method name matches, not methods of other services with the same suffix
+			name:       "OtherPackage",
+			fullMethod: "/other.UserService/DeleteUserByValue",
+			checkCode:  codes.OK,
+		},
+		{
+			name:       "OpenMethod",
+			fullMethod: "/pb.UserService/GetUserById",
+			identity:   "chat-svc",
+			checkCode:  codes.OK,
+		},
+		{
+			name:       "OpenMethodNoCertificate",
+			fullMethod: "/pb.UserService/GetUserById",
+			checkCode:  codes.OK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			info := &grpc.UnaryServerInfo{FullMethod: tc.fullMethod}
+			handler := func(ctx context.Context, req any) (any, error) {
+				if tc.identity != "" {
+					require.Equal(t, []string{tc.identity}, ServiceIdentitiesFromContext(ctx))
+				} else {
+					require.Empty(t, ServiceIdentitiesFromContext(ctx))
+				}
+				return nil, nil
+			}
+
+			_, err := server.ServiceIdentityInterceptor(newContextWithClientCertificate(tc.identity), nil, info, handler)
+			require.Equal(t, tc.checkCode, status.Code(err))
+		})
+	}
+}
+
+func TestConfigureClientAuth(t *testing.T) {
+	roots := x509.NewCertPool()
+
+	tlsConfig := &tls.Config{RootCAs: roots}
+	require.NoError(t, configureClientAuth(tlsConfig, ClientAuthNone, nil))
+	require.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+	require.Nil(t, tlsConfig.ClientCAs)
+
+	// The allowlist needs the certificates of the callers
+	tlsConfig = &tls.Config{RootCAs: roots}
+	require.NoError(t, configureClientAuth(tlsConfig, ClientAuthNone, serviceAllowlist{"/pb.UserService/DeleteUserByValue": {"stream-svc": true}}))
+	require.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+	require.Same(t, roots, tlsConfig.ClientCAs)
+
+	tlsConfig = &tls.Config{RootCAs: roots}
+	require.NoError(t, configureClientAuth(tlsConfig, ClientAuthRequest, nil))
+	require.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+
+	tlsConfig = &tls.Config{RootCAs: roots}
+	require.NoError(t, configureClientAuth(tlsConfig, ClientAuthRequire, nil))
+	require.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+	require.Same(t, roots, tlsConfig.ClientCAs)
+
+	require.Error(t, configureClientAuth(&tls.Config{}, "always", nil))
+}
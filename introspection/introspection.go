@@ -0,0 +1,146 @@
+package introspection
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"strings"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/revocation"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+)
+
+// Token types of the introspection response.
+const (
+	TokenTypeAccess  = "access_token"
+	TokenTypeRefresh = "refresh_token"
+)
+
+// ErrUnauthorizedClient is returned for callers that didn't present the client certificate of an introspection client.
+var ErrUnauthorizedClient = errors.New("caller is not allowed to introspect tokens")
+
+// Response is the introspection response of RFC 7662. Inactive tokens only carry the active flag,
+// so that callers can't learn anything about tokens that are invalid, expired or revoked.
+type Response struct {
+	Active    bool   `json:"active"`
+	TokenType string `json:"token_type,omitempty"`
+	TokenID   string `json:"jti,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	UserID    int64  `json:"user_id,omitempty"`
+	RoleID    int64  `json:"role_id,omitempty"`
+	// ClientID is the OAuth client the token was issued to.
+	ClientID string `json:"client_id,omitempty"`
+	// Scope is the space separated list of the scopes of the token.
+	Scope     string `json:"scope,omitempty"`
+	Issuer    string `json:"iss,omitempty"`
+	Audience  string `json:"aud,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	NotBefore int64  `json:"nbf,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// Introspector answers whether a token is valid and who it was issued to, for services that don't hold the keys
+// of the tokens. Callers are authenticated with the client certificate they present.
+type Introspector struct {
+	tokenMaker  token.Maker
+	store       db.Store
+	revocations *revocation.List
+	clients     map[string]bool
+}
+
+// NewIntrospector creates a new Introspector that accepts the introspection clients of the configuration.
+func NewIntrospector(config util.Config, tokenMaker token.Maker, store db.Store, revocations *revocation.List) *Introspector {
+	clients := make(map[string]bool, len(config.IntrospectionClients))
+	for _, client := range config.IntrospectionClients {
+		clients[client] = true
+	}
+
+	return &Introspector{
+		tokenMaker:  tokenMaker,
+		store:       store,
+		revocations: revocations,
+		clients:     clients,
+	}
+}
+
+// AuthorizeClient returns the identity of the caller of a TLS connection if it presented a verified client
+// certificate of one of the introspection clients, and ErrUnauthorizedClient otherwise.
+func (i *Introspector) AuthorizeClient(state *tls.ConnectionState) (string, error) {
+	for _, identity := range ClientIdentities(state) {
+		if i.clients[identity] {
+			return identity, nil
+		}
+	}
+	return "", ErrUnauthorizedClient
+}
+
+// Introspect verifies the token and reports it as active if it wasn't revoked. Refresh tokens are active as long as
+// their session is neither blocked, rotated nor expired. An error is only returned if the state of the token
+// couldn't be checked.
+func (i *Introspector) Introspect(ctx context.Context, tokenString string) (Response, error) {
+	payload, err := token.VerifyToken(i.tokenMaker, tokenString)
+	if errors.Is(err, token.ErrInvalidTokenType) {
+		payload, err = i.tokenMaker.VerifyRefreshToken(tokenString)
+	}
+	if err != nil {
+		return Response{}, nil
+	}
+	if err := i.revocations.Check(ctx, payload); err != nil {
+		return Response{}, nil
+	}
+
+	tokenType := TokenTypeAccess
+	if payload.TokenType == token.TokenTypeRefresh {
+		// Refresh tokens have the ID of their session
+		tokenType = TokenTypeRefresh
+		session, err := i.store.GetSession(ctx, payload.ID)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			return Response{}, nil
+		case err != nil:
+			return Response{}, err
+		case session.IsBlocked || session.ReplacedBy.Valid || time.Now().After(session.ExpiresAt):
+			return Response{}, nil
+		case session.Username != payload.Username || session.RefreshToken != tokenString:
+			return Response{}, nil
+		}
+	}
+
+	return Response{
+		Active:    true,
+		TokenType: tokenType,
+		TokenID:   payload.ID.String(),
+		Subject:   payload.Username,
+		UserID:    payload.UserID,
+		RoleID:    payload.RoleID,
+		ClientID:  payload.ClientID,
+		Scope:     strings.Join(payload.Scopes, " "),
+		Issuer:    payload.Issuer,
+		Audience:  payload.Audience,
+		IssuedAt:  payload.IssuedAt.Unix(),
+		NotBefore: payload.NotBefore.Unix(),
+		ExpiresAt: payload.ExpiredAt.Unix(),
+	}, nil
+}
+
+// ClientIdentities returns the DNS names, URIs and the common name of the verified client certificate
+// of a TLS connection. Connections without a verified client certificate have no identities.
+func ClientIdentities(state *tls.ConnectionState) []string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	cert := state.VerifiedChains[0][0]
+	identities := append([]string{}, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	return identities
+}
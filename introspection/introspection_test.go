@@ -0,0 +1,201 @@
+package introspection
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/revocation"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestIntrospector(t *testing.T, store *mock_db.MockStore) (*Introspector, token.Maker) {
+	tokenMaker, err := token.NewLocalPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	config := util.Config{
+		TokenRevocationInterval: time.Minute,
+		IntrospectionClients:    []string{"stream-svc"},
+	}
+	store.EXPECT().ListRevokedTokens(gomock.Any()).AnyTimes().Return([]db.UserSvcRevokedToken{}, nil)
+	store.EXPECT().ListTokenWatermarks(gomock.Any(), gomock.Any()).AnyTimes().Return([]db.UserSvcTokenWatermark{}, nil)
+
+	return NewIntrospector(config, tokenMaker, store, revocation.NewList(config, store)), tokenMaker
+}
+
+func connectionState(cert *x509.Certificate) *tls.ConnectionState {
+	return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
+
+func TestIntrospectAccessToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	introspector, tokenMaker := newTestIntrospector(t, store)
+
+	claims := token.Claims{UserID: 7, Username: util.RandomUsername(), RoleID: 2, Scopes: []string{"a", "b"}}
+	accessToken, payload, err := tokenMaker.CreateLocalToken(claims, time.Minute)
+	require.NoError(t, err)
+
+	// Access tokens don't have a session of their own
+	store.EXPECT().
+		GetSession(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	rsp, err := introspector.Introspect(context.Background(), accessToken)
+	require.NoError(t, err)
+	require.True(t, rsp.Active)
+	require.Equal(t, TokenTypeAccess, rsp.TokenType)
+	require.Equal(t, claims.Username, rsp.Subject)
+	require.Equal(t, claims.UserID, rsp.UserID)
+	require.Equal(t, claims.RoleID, rsp.RoleID)
+	require.Equal(t, "a b", rsp.Scope)
+	require.Equal(t, payload.ExpiredAt.Unix(), rsp.ExpiresAt)
+
+	// Revoked access tokens are inactive
+	store.EXPECT().
+		RevokeToken(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(nil)
+	require.NoError(t, introspector.revocations.RevokeToken(context.Background(), payload))
+
+	rsp, err = introspector.Introspect(context.Background(), accessToken)
+	require.NoError(t, err)
+	require.Equal(t, Response{}, rsp)
+}
+
+func TestIntrospectRefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	introspector, tokenMaker := newTestIntrospector(t, store)
+
+	username := util.RandomUsername()
+	refreshToken, payload, err := tokenMaker.CreateRefreshToken(token.Claims{Username: username}, time.Hour)
+	require.NoError(t, err)
+
+	session := db.UserSvcSession{
+		ID:           payload.ID,
+		Username:     username,
+		RefreshToken: refreshToken,
+		ExpiresAt:    payload.ExpiredAt,
+	}
+
+	testCases := []struct {
+		name   string
+		update func(session *db.UserSvcSession)
+		active bool
+	}{
+		{"Active", func(session *db.UserSvcSession) {}, true},
+		{"Blocked", func(session *db.UserSvcSession) { session.IsBlocked = true }, false},
+		{"Rotated", func(session *db.UserSvcSession) { session.ReplacedBy = pgtype.UUID{Bytes: uuid.New(), Valid: true} }, false},
+		{"Expired", func(session *db.UserSvcSession) { session.ExpiresAt = time.Now().Add(-time.Minute) }, false},
+		{"OtherToken", func(session *db.UserSvcSession) { session.RefreshToken = "other" }, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			session := session
+			tc.update(&session)
+
+			store.EXPECT().
+				GetSession(gomock.Any(), gomock.Eq(payload.ID)).
+				Times(1).
+				Return(session, nil)
+
+			rsp, err := introspector.Introspect(context.Background(), refreshToken)
+			require.NoError(t, err)
+			require.Equal(t, tc.active, rsp.Active)
+			if tc.active {
+				require.Equal(t, TokenTypeRefresh, rsp.TokenType)
+			}
+		})
+	}
+
+	// Refresh tokens without a session are inactive
+	store.EXPECT().
+		GetSession(gomock.Any(), gomock.Eq(payload.ID)).
+		Times(1).
+		Return(db.UserSvcSession{}, pgx.ErrNoRows)
+
+	rsp, err := introspector.Introspect(context.Background(), refreshToken)
+	require.NoError(t, err)
+	require.Equal(t, Response{}, rsp)
+
+	// Refresh tokens are inactive once all tokens of the user were revoked, e.g. after a password change
+	store.EXPECT().
+		SetTokenWatermark(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.SetTokenWatermarkParams) (db.UserSvcTokenWatermark, error) {
+			return db.UserSvcTokenWatermark{Username: arg.Username, NotBefore: arg.NotBefore}, nil
+		})
+	require.NoError(t, introspector.revocations.RevokeUserTokens(context.Background(), username))
+
+	rsp, err = introspector.Introspect(context.Background(), refreshToken)
+	require.NoError(t, err)
+	require.Equal(t, Response{}, rsp)
+}
+
+func TestIntrospectInvalidToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	introspector, _ := newTestIntrospector(t, store)
+
+	// Tokens of other keys are inactive without querying the sessions
+	otherMaker, err := token.NewLocalPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+	otherToken, _, err := otherMaker.CreateLocalToken(token.Claims{Username: util.RandomUsername()}, time.Minute)
+	require.NoError(t, err)
+
+	store.EXPECT().
+		GetSession(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	for _, tokenString := range []string{"", "invalid", otherToken} {
+		rsp, err := introspector.Introspect(context.Background(), tokenString)
+		require.NoError(t, err)
+		require.Equal(t, Response{}, rsp)
+	}
+}
+
+func TestAuthorizeClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	introspector, _ := newTestIntrospector(t, mock_db.NewMockStore(ctrl))
+
+	identity, err := introspector.AuthorizeClient(connectionState(&x509.Certificate{DNSNames: []string{"stream-svc"}}))
+	require.NoError(t, err)
+	require.Equal(t, "stream-svc", identity)
+
+	identity, err = introspector.AuthorizeClient(connectionState(&x509.Certificate{Subject: pkix.Name{CommonName: "stream-svc"}}))
+	require.NoError(t, err)
+	require.Equal(t, "stream-svc", identity)
+
+	_, err = introspector.AuthorizeClient(connectionState(&x509.Certificate{DNSNames: []string{"chat-svc"}}))
+	require.ErrorIs(t, err, ErrUnauthorizedClient)
+
+	// Certificates that weren't verified against the CA don't identify the caller
+	_, err = introspector.AuthorizeClient(&tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{{DNSNames: []string{"stream-svc"}}},
+	})
+	require.ErrorIs(t, err, ErrUnauthorizedClient)
+
+	_, err = introspector.AuthorizeClient(nil)
+	require.ErrorIs(t, err, ErrUnauthorizedClient)
+}
@@ -0,0 +1,170 @@
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+)
+
+// ErrInvalidCredentials is returned for every failed login, whether the username exists or the password is wrong,
+// so that the response doesn't reveal which usernames are registered.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// LockedError is returned when the username or the client address of a login is locked after too many failures.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+// Error returns the string representation of the error.
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, try again in %s", e.RetryAfter)
+}
+
+// Guard tracks failed logins per username and per client address in the database and locks further
+// attempts once the configured number of failures is reached. Every failure while locked doubles the lockout.
+type Guard struct {
+	store         db.Store
+	maxAttempts   int32
+	maxIPAttempts int32
+	lockout       time.Duration
+	maxLockout    time.Duration
+	window        time.Duration
+}
+
+// NewGuard creates a new Guard from the login throttling settings of the configuration.
+func NewGuard(config util.Config, store db.Store) *Guard {
+	return &Guard{
+		store:         store,
+		maxAttempts:   config.LoginMaxAttempts,
+		maxIPAttempts: config.LoginIPMaxAttempts,
+		lockout:       config.LoginLockoutDuration,
+		maxLockout:    config.LoginLockoutMaxDuration,
+		window:        config.LoginAttemptWindow,
+	}
+}
+
+// Check returns a LockedError if the username or the client address may not attempt to log in right now.
+func (g *Guard) Check(ctx context.Context, username, clientIP string) error {
+	keys := g.keys(username, clientIP)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	attempts, err := g.store.GetLoginAttempts(ctx, keys)
+	if err != nil {
+		return err
+	}
+
+	var lockedUntil time.Time
+	for _, attempt := range attempts {
+		if attempt.LockedUntil.After(lockedUntil) {
+			lockedUntil = attempt.LockedUntil
+		}
+	}
+
+	if retryAfter := time.Until(lockedUntil); retryAfter > 0 {
+		return &LockedError{RetryAfter: retryAfter.Truncate(time.Second) + time.Second}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login of the username from the client address and locks them once their limit is reached.
+func (g *Guard) RecordFailure(ctx context.Context, username, clientIP string) error {
+	windowStart := time.Now().Add(-g.window)
+
+	if g.maxAttempts > 0 {
+		if err := g.recordFailure(ctx, UsernameKey(username), g.maxAttempts, windowStart); err != nil {
+			return err
+		}
+	}
+	if g.maxIPAttempts > 0 && clientIP != "" {
+		if err := g.recordFailure(ctx, ClientIPKey(clientIP), g.maxIPAttempts, windowStart); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordSuccess forgets the failed logins of the username after a successful login.
+// The failures of the client address are kept, so that one valid account doesn't lift the limit of an attacker.
+func (g *Guard) RecordSuccess(ctx context.Context, username string) error {
+	if g.maxAttempts <= 0 {
+		return nil
+	}
+	_, err := g.store.DeleteLoginAttempts(ctx, []string{UsernameKey(username)})
+	return err
+}
+
+// Unlock lifts the lockout of the username and the client address and forgets their failed logins.
+// Either of them may be empty, the number of cleared entries is returned.
+func (g *Guard) Unlock(ctx context.Context, username, clientIP string) (int64, error) {
+	var keys []string
+	if username != "" {
+		keys = append(keys, UsernameKey(username))
+	}
+	if clientIP != "" {
+		keys = append(keys, ClientIPKey(clientIP))
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	return g.store.DeleteLoginAttempts(ctx, keys)
+}
+
+// LockoutDuration returns the lockout after the given number of failures beyond the limit:
+// the configured duration, doubled with every further failure and capped at the maximum.
+func (g *Guard) LockoutDuration(excessAttempts int32) time.Duration {
+	duration := g.lockout
+	for i := int32(0); i < excessAttempts && duration < g.maxLockout; i++ {
+		duration *= 2
+	}
+	if g.maxLockout > 0 && duration > g.maxLockout {
+		duration = g.maxLockout
+	}
+	return duration
+}
+
+func (g *Guard) recordFailure(ctx context.Context, key string, maxAttempts int32, windowStart time.Time) error {
+	attempt, err := g.store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+		Key:         key,
+		WindowStart: windowStart,
+	})
+	if err != nil {
+		return err
+	}
+	if attempt.FailedAttempts < maxAttempts {
+		return nil
+	}
+
+	return g.store.LockLogin(ctx, db.LockLoginParams{
+		LockedUntil: time.Now().Add(g.LockoutDuration(attempt.FailedAttempts - maxAttempts)),
+		Key:         key,
+	})
+}
+
+// keys returns the keys of the username and the client address whose throttling is enabled.
+func (g *Guard) keys(username, clientIP string) []string {
+	var keys []string
+	if g.maxAttempts > 0 {
+		keys = append(keys, UsernameKey(username))
+	}
+	if g.maxIPAttempts > 0 && clientIP != "" {
+		keys = append(keys, ClientIPKey(clientIP))
+	}
+	return keys
+}
+
+// UsernameKey returns the key under which the failed logins of a username are stored.
+func UsernameKey(username string) string {
+	return "user:" + username
+}
+
+// ClientIPKey returns the key under which the failed logins of a client address are stored.
+// The address may contain a port or be a forwarded list, only the first host is used.
+func ClientIPKey(clientIP string) string {
+	return "ip:" + util.NormalizeClientIP(clientIP)
+}
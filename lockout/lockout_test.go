@@ -0,0 +1,171 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestGuard(store db.Store) *Guard {
+	return NewGuard(util.Config{
+		LoginMaxAttempts:        3,
+		LoginIPMaxAttempts:      10,
+		LoginLockoutDuration:    time.Minute,
+		LoginLockoutMaxDuration: 10 * time.Minute,
+		LoginAttemptWindow:      time.Hour,
+	}, store)
+}
+
+func TestCheck(t *testing.T) {
+	username := util.RandomUsername()
+	clientIP := "10.0.0.1"
+
+	testCases := []struct {
+		name       string
+		attempts   []db.UserSvcLoginAttempt
+		retryAfter time.Duration
+	}{
+		{
+			name: "NotLocked",
+			attempts: []db.UserSvcLoginAttempt{
+				{Key: UsernameKey(username), FailedAttempts: 2},
+			},
+		},
+		{
+			name: "LockoutExpired",
+			attempts: []db.UserSvcLoginAttempt{
+				{Key: UsernameKey(username), FailedAttempts: 3, LockedUntil: time.Now().Add(-time.Second)},
+			},
+		},
+		{
+			name: "UsernameLocked",
+			attempts: []db.UserSvcLoginAttempt{
+				{Key: UsernameKey(username), FailedAttempts: 3, LockedUntil: time.Now().Add(time.Minute)},
+			},
+			retryAfter: time.Minute,
+		},
+		{
+			name: "LongestLockoutWins",
+			attempts: []db.UserSvcLoginAttempt{
+				{Key: UsernameKey(username), FailedAttempts: 3, LockedUntil: time.Now().Add(time.Minute)},
+				{Key: ClientIPKey(clientIP), FailedAttempts: 12, LockedUntil: time.Now().Add(4 * time.Minute)},
+			},
+			retryAfter: 4 * time.Minute,
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock_db.NewMockStore(ctrl)
+			store.EXPECT().
+				GetLoginAttempts(gomock.Any(), gomock.Eq([]string{UsernameKey(username), ClientIPKey(clientIP)})).
+				Times(1).
+				Return(tc.attempts, nil)
+
+			err := newTestGuard(store).Check(context.Background(), username, clientIP)
+			if tc.retryAfter == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var lockedErr *LockedError
+			require.ErrorAs(t, err, &lockedErr)
+			require.InDelta(t, tc.retryAfter.Seconds(), lockedErr.RetryAfter.Seconds(), 1)
+		})
+	}
+}
+
+func TestCheckDisabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	store.EXPECT().GetLoginAttempts(gomock.Any(), gomock.Any()).Times(0)
+
+	guard := NewGuard(util.Config{}, store)
+	require.NoError(t, guard.Check(context.Background(), util.RandomUsername(), "10.0.0.1"))
+	require.NoError(t, guard.RecordFailure(context.Background(), util.RandomUsername(), "10.0.0.1"))
+	require.NoError(t, guard.RecordSuccess(context.Background(), util.RandomUsername()))
+}
+
+func TestRecordFailure(t *testing.T) {
+	username := util.RandomUsername()
+	clientIP := "10.0.0.1:52814"
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+
+	// The third failure of the username reaches its limit, the client address is still below its own
+	gomock.InOrder(
+		store.EXPECT().
+			RecordLoginFailure(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, arg db.RecordLoginFailureParams) (db.UserSvcLoginAttempt, error) {
+				require.Equal(t, UsernameKey(username), arg.Key)
+				require.WithinDuration(t, time.Now().Add(-time.Hour), arg.WindowStart, time.Second)
+				return db.UserSvcLoginAttempt{Key: arg.Key, FailedAttempts: 3}, nil
+			}),
+		store.EXPECT().
+			LockLogin(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, arg db.LockLoginParams) error {
+				require.Equal(t, UsernameKey(username), arg.Key)
+				require.WithinDuration(t, time.Now().Add(time.Minute), arg.LockedUntil, time.Second)
+				return nil
+			}),
+		store.EXPECT().
+			RecordLoginFailure(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, arg db.RecordLoginFailureParams) (db.UserSvcLoginAttempt, error) {
+				require.Equal(t, "ip:10.0.0.1", arg.Key)
+				return db.UserSvcLoginAttempt{Key: arg.Key, FailedAttempts: 4}, nil
+			}),
+	)
+
+	err := newTestGuard(store).RecordFailure(context.Background(), username, clientIP)
+	require.NoError(t, err)
+}
+
+func TestLockoutDuration(t *testing.T) {
+	guard := newTestGuard(nil)
+
+	require.Equal(t, time.Minute, guard.LockoutDuration(0))
+	require.Equal(t, 2*time.Minute, guard.LockoutDuration(1))
+	require.Equal(t, 8*time.Minute, guard.LockoutDuration(3))
+	require.Equal(t, 10*time.Minute, guard.LockoutDuration(4))
+	require.Equal(t, 10*time.Minute, guard.LockoutDuration(1000))
+}
+
+func TestUnlock(t *testing.T) {
+	username := util.RandomUsername()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	store.EXPECT().
+		DeleteLoginAttempts(gomock.Any(), gomock.Eq([]string{UsernameKey(username), "ip:2001:db8::1"})).
+		Times(1).
+		Return(int64(2), nil)
+
+	cleared, err := newTestGuard(store).Unlock(context.Background(), username, "[2001:db8::1]:443")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), cleared)
+}
+
+func TestClientIPKey(t *testing.T) {
+	require.Equal(t, "ip:10.0.0.1", ClientIPKey("10.0.0.1"))
+	require.Equal(t, "ip:10.0.0.1", ClientIPKey("10.0.0.1:8080"))
+	require.Equal(t, "ip:::1", ClientIPKey("::1"))
+	require.Equal(t, "ip:::1", ClientIPKey("[::1]:50051"))
+}
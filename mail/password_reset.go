@@ -0,0 +1,31 @@
+package mail
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+)
+
+// SendPasswordResetEmail sends the email with the link to reset the password of a user.
+func SendPasswordResetEmail(sender EmailSender, resetURL string, fullName string, email string, token string) error {
+	link, err := url.Parse(resetURL)
+	if err != nil {
+		return fmt.Errorf("invalid password reset url: %w", err)
+	}
+
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+
+	subject := "Reset your Streamfair password"
+	content := fmt.Sprintf(`Hello %s,<br/>
+	We received a request to reset your password.<br/>
+	Please <a href="%s">click here</a> to choose a new password.<br/>
+	If you did not request a password reset, you can ignore this email.<br/>
+	`, html.EscapeString(fullName), html.EscapeString(link.String()))
+
+	if err := sender.SendEmail(subject, content, []string{email}); err != nil {
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package mail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendPasswordResetEmail(t *testing.T) {
+	sender := NewInMemorySender()
+
+	err := SendPasswordResetEmail(sender, "https://localhost:8080/reset_password", "Jane Doe", "jane@example.com", "secret")
+	require.NoError(t, err)
+
+	emails := sender.Emails()
+	require.Len(t, emails, 1)
+	require.Equal(t, []string{"jane@example.com"}, emails[0].To)
+	require.Contains(t, emails[0].Content, "https://localhost:8080/reset_password?token=secret")
+}
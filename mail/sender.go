@@ -0,0 +1,71 @@
+package mail
+
+import (
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Email is a message sent by an EmailSender.
+type Email struct {
+	Subject string
+	Content string
+	To      []string
+}
+
+// EmailSender is the interface implemented by all mail delivery backends.
+type EmailSender interface {
+	SendEmail(subject string, content string, to []string) error
+}
+
+// LogSender is an EmailSender that writes the emails to the log instead of delivering them.
+// It is meant for local development where no mail server is available. Only the recipients and the subject
+// are logged, the content holds the secret links of the verification and password reset emails.
+type LogSender struct{}
+
+// NewLogSender creates a new LogSender.
+func NewLogSender() EmailSender {
+	return &LogSender{}
+}
+
+// SendEmail logs the recipients and the subject of the email.
+func (sender *LogSender) SendEmail(subject string, content string, to []string) error {
+	log.Info().
+		Strs("to", to).
+		Str("subject", subject).
+		Int("content_length", len(content)).
+		Msg("mail: email sent to log, content omitted")
+	return nil
+}
+
+// InMemorySender is an EmailSender that keeps all sent emails in memory, so that tests can inspect them.
+type InMemorySender struct {
+	mu     sync.Mutex
+	emails []Email
+}
+
+// NewInMemorySender creates a new InMemorySender.
+func NewInMemorySender() *InMemorySender {
+	return &InMemorySender{}
+}
+
+// SendEmail stores the email.
+func (sender *InMemorySender) SendEmail(subject string, content string, to []string) error {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	sender.emails = append(sender.emails, Email{
+		Subject: subject,
+		Content: content,
+		To:      append([]string(nil), to...),
+	})
+	return nil
+}
+
+// Emails returns a copy of all emails sent so far.
+func (sender *InMemorySender) Emails() []Email {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	return append([]Email(nil), sender.emails...)
+}
@@ -0,0 +1,42 @@
+package mail
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemorySender(t *testing.T) {
+	sender := NewInMemorySender()
+	require.Empty(t, sender.Emails())
+
+	to := []string{"user@example.com"}
+	require.NoError(t, sender.SendEmail("subject", "content", to))
+
+	// The recipients are copied, so later changes by the caller don't alter the stored email
+	to[0] = "other@example.com"
+
+	emails := sender.Emails()
+	require.Len(t, emails, 1)
+	require.Equal(t, "subject", emails[0].Subject)
+	require.Equal(t, "content", emails[0].Content)
+	require.Equal(t, []string{"user@example.com"}, emails[0].To)
+}
+
+func TestLogSender(t *testing.T) {
+	var output bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&output)
+	defer func() { log.Logger = logger }()
+
+	link := "https://localhost:8080/reset_password?token=secret"
+	require.NoError(t, NewLogSender().SendEmail("subject", link, []string{"user@example.com"}))
+
+	// The secret links must never end up in the log
+	require.Contains(t, output.String(), "user@example.com")
+	require.NotContains(t, output.String(), "secret")
+	require.NotContains(t, output.String(), "reset_password")
+}
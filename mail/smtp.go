@@ -0,0 +1,110 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+)
+
+const (
+	// SenderLog writes the emails to the log, only allowed in development.
+	SenderLog = "log"
+	// SenderSMTP delivers the emails through an SMTP server.
+	SenderSMTP = "smtp"
+)
+
+const developmentEnvironment = "development"
+
+// NewEmailSender creates the EmailSender selected by the configuration. The log sender is refused outside
+// of development, so that a missing mail configuration in production fails the startup instead of
+// silently dropping all verification and password reset emails.
+func NewEmailSender(config util.Config) (EmailSender, error) {
+	switch config.EmailSender {
+	case SenderLog:
+		if config.Environment != developmentEnvironment {
+			return nil, fmt.Errorf("email sender %q is only allowed in the development environment, configure EMAIL_SENDER=smtp", SenderLog)
+		}
+		return NewLogSender(), nil
+	case SenderSMTP:
+		return NewSMTPSender(config.SMTPAddress, config.SMTPUsername, config.SMTPPassword, config.EmailFromName, config.EmailFromAddress)
+	default:
+		return nil, fmt.Errorf("unknown email sender %q, expected %q or %q", config.EmailSender, SenderLog, SenderSMTP)
+	}
+}
+
+// SMTPSender is an EmailSender that delivers the emails through an SMTP server.
+// The connection is upgraded with STARTTLS if the server supports it, credentials are only sent over TLS.
+type SMTPSender struct {
+	address string
+	auth    smtp.Auth
+	from    mail.Address
+}
+
+// NewSMTPSender creates a new SMTPSender for the server at address (host:port). The username and password
+// are optional, without a username the emails are sent unauthenticated.
+func NewSMTPSender(address string, username string, password string, fromName string, fromAddress string) (*SMTPSender, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp address %q: %w", address, err)
+	}
+	if _, err := mail.ParseAddress(fromAddress); err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", fromAddress, err)
+	}
+
+	sender := &SMTPSender{
+		address: address,
+		from:    mail.Address{Name: fromName, Address: fromAddress},
+	}
+	if username != "" {
+		sender.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return sender, nil
+}
+
+// SendEmail sends the email with HTML content to the recipients.
+func (sender *SMTPSender) SendEmail(subject string, content string, to []string) error {
+	if len(to) == 0 {
+		return errors.New("email has no recipients")
+	}
+
+	message, err := sender.message(subject, content, to)
+	if err != nil {
+		return err
+	}
+
+	if err := smtp.SendMail(sender.address, sender.auth, sender.from.Address, to, message); err != nil {
+		return fmt.Errorf("failed to send email via smtp: %w", err)
+	}
+	return nil
+}
+
+// message builds the MIME message of an email. Recipients are validated, so that they can't inject headers.
+func (sender *SMTPSender) message(subject string, content string, to []string) ([]byte, error) {
+	recipients := make([]string, len(to))
+	for i, address := range to {
+		recipient, err := mail.ParseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", address, err)
+		}
+		recipients[i] = recipient.String()
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", sender.from.String())
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n")
+	message.WriteString("\r\n")
+	message.WriteString(content)
+	return message.Bytes(), nil
+}
@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"testing"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEmailSender(t *testing.T) {
+	sender, err := NewEmailSender(util.Config{Environment: "development", EmailSender: SenderLog})
+	require.NoError(t, err)
+	require.IsType(t, &LogSender{}, sender)
+
+	// Emails must not end up in the log of a production system
+	_, err = NewEmailSender(util.Config{Environment: "production", EmailSender: SenderLog})
+	require.Error(t, err)
+
+	sender, err = NewEmailSender(util.Config{
+		EmailSender:      SenderSMTP,
+		SMTPAddress:      "smtp.example.com:587",
+		EmailFromAddress: "noreply@example.com",
+	})
+	require.NoError(t, err)
+	require.IsType(t, &SMTPSender{}, sender)
+
+	_, err = NewEmailSender(util.Config{EmailSender: SenderSMTP, SMTPAddress: "smtp.example.com", EmailFromAddress: "noreply@example.com"})
+	require.Error(t, err)
+	_, err = NewEmailSender(util.Config{EmailSender: SenderSMTP, SMTPAddress: "smtp.example.com:587"})
+	require.Error(t, err)
+	_, err = NewEmailSender(util.Config{EmailSender: "carrier-pigeon"})
+	require.Error(t, err)
+}
+
+func TestSMTPSenderMessage(t *testing.T) {
+	sender, err := NewSMTPSender("smtp.example.com:587", "user", "secret", "Streamfair", "noreply@example.com")
+	require.NoError(t, err)
+
+	message, err := sender.message("Welcome to Streamfair", "<b>Hello</b>", []string{"jane@example.com"})
+	require.NoError(t, err)
+	require.Contains(t, string(message), "From: \"Streamfair\" <noreply@example.com>\r\n")
+	require.Contains(t, string(message), "To: <jane@example.com>\r\n")
+	require.Contains(t, string(message), "Subject: Welcome to Streamfair\r\n")
+	require.Contains(t, string(message), "Content-Type: text/html; charset=\"UTF-8\"\r\n\r\n<b>Hello</b>")
+
+	// Recipients can't smuggle additional headers into the message
+	_, err = sender.message("subject", "content", []string{"jane@example.com\r\nBcc: eve@example.com"})
+	require.Error(t, err)
+
+	require.Error(t, sender.SendEmail("subject", "content", nil))
+}
@@ -0,0 +1,31 @@
+package mail
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+)
+
+// SendVerifyEmail sends the email with the link to verify the email address of a newly registered user.
+func SendVerifyEmail(sender EmailSender, verifyURL string, fullName string, email string, emailID int64, secretCode string) error {
+	link, err := url.Parse(verifyURL)
+	if err != nil {
+		return fmt.Errorf("invalid verify email url: %w", err)
+	}
+
+	query := link.Query()
+	query.Set("email_id", fmt.Sprint(emailID))
+	query.Set("secret_code", secretCode)
+	link.RawQuery = query.Encode()
+
+	subject := "Welcome to Streamfair"
+	content := fmt.Sprintf(`Hello %s,<br/>
+	Thank you for registering with us!<br/>
+	Please <a href="%s">click here</a> to verify your email address.<br/>
+	`, html.EscapeString(fullName), html.EscapeString(link.String()))
+
+	if err := sender.SendEmail(subject, content, []string{email}); err != nil {
+		return fmt.Errorf("failed to send verify email: %w", err)
+	}
+	return nil
+}
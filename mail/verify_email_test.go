@@ -0,0 +1,20 @@
+package mail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendVerifyEmail(t *testing.T) {
+	sender := NewInMemorySender()
+
+	err := SendVerifyEmail(sender, "https://localhost:8080/verify_email", "Jane <b>Doe</b>", "jane@example.com", 42, "secret")
+	require.NoError(t, err)
+
+	emails := sender.Emails()
+	require.Len(t, emails, 1)
+	require.Equal(t, []string{"jane@example.com"}, emails[0].To)
+	require.Contains(t, emails[0].Content, "Jane &lt;b&gt;Doe&lt;/b&gt;")
+	require.Contains(t, emails[0].Content, "https://localhost:8080/verify_email?email_id=42&amp;secret_code=secret")
+}
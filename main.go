@@ -3,14 +3,17 @@ package main
 import (
 	"context"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"github.com/spf13/viper"
 
 	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
 	"github.com/Streamfair/streamfair_user_svc/gapi"
+	"github.com/Streamfair/streamfair_user_svc/mail"
 	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/Streamfair/streamfair_user_svc/worker"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
@@ -23,7 +26,7 @@ func main() {
 		log.Fatal().Err(err).Msg("config: error while loading config:")
 	}
 
-	if viper.GetString("ENVIRONMENT") == "development" {
+	if config.Environment == "development" {
 		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 	}
 
@@ -39,16 +42,51 @@ func main() {
 		log.Fatal().Err(err).Msg("db connection: unable to create connection pool:")
 	}
 
+	emailSender, err := mail.NewEmailSender(config)
+	if err != nil {
+		log.Fatal().Err(err).Msg("mail: error while creating email sender:")
+	}
+
 	store := db.NewStore(conn)
-	server, err := gapi.NewServer(config, store)
+	server, err := gapi.NewServer(config, store, emailSender)
 	if err != nil {
 		log.Fatal().Err(err).Msg("server: error while creating server:")
 	}
 
 	runDBMigration(config.MigrationURL, config.DBSource)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	maintenanceDone := make(chan struct{})
+	go func() {
+		defer close(maintenanceDone)
+		worker.NewMaintenanceWorker(config, store).Run(ctx)
+	}()
+
+	reloaderDone := make(chan struct{})
+	go func() {
+		defer close(reloaderDone)
+		worker.NewKeyringReloader(config, server.Keyring()).Run(ctx)
+	}()
+
+	certificateReloaderDone := make(chan struct{})
+	go func() {
+		defer close(certificateReloaderDone)
+		worker.NewCertificateReloader(config, server.Keypair()).Run(ctx)
+	}()
+
 	go server.RunGrpcGatewayServer()
-	server.RunGrpcServer()
+	go server.RunGrpcServer()
+
+	<-ctx.Done()
+	log.Info().Msg("shutting down Streamfair User Service")
+
+	server.Shutdown()
+	<-maintenanceDone
+	<-reloaderDone
+	<-certificateReloaderDone
+	conn.Close()
 }
 
 func runDBMigration(migrationURL string, dbSource string) {
@@ -0,0 +1,274 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+)
+
+// maxChallengeAttempts is the number of wrong codes after which an MFA challenge can't be used anymore.
+const maxChallengeAttempts = 5
+
+var (
+	ErrNotConfigured    = errors.New("two-factor authentication is not configured")
+	ErrAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrNotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrInvalidChallenge = errors.New("invalid or expired MFA token")
+)
+
+// Enrollment is a new TOTP secret that waits for its confirmation with a code of the authenticator app.
+type Enrollment struct {
+	Secret string
+	URI    string
+}
+
+// Manager enrols users in TOTP two-factor authentication and verifies the second step of their logins.
+type Manager struct {
+	store             db.Store
+	box               *SecretBox
+	issuer            string
+	challengeDuration time.Duration
+}
+
+// NewManager creates a new Manager from the MFA settings of the configuration.
+// Without an encryption key users can't enrol, the second step of existing enrolments fails.
+func NewManager(config util.Config, store db.Store) (*Manager, error) {
+	manager := &Manager{
+		store:             store,
+		issuer:            config.MfaIssuer,
+		challengeDuration: config.MfaChallengeDuration,
+	}
+
+	if config.MfaEncryptionKey != "" {
+		box, err := NewSecretBox(config.MfaEncryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create MFA secret box: %w", err)
+		}
+		manager.box = box
+	}
+	return manager, nil
+}
+
+// Enroll generates a new TOTP secret for the user. It replaces a previous enrolment that was never confirmed.
+func (m *Manager) Enroll(ctx context.Context, username string) (*Enrollment, error) {
+	if m.box == nil {
+		return nil, ErrNotConfigured
+	}
+
+	secret, err := GenerateTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := m.box.Encrypt(secret, username)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = m.store.CreateTotpSecret(ctx, db.CreateTotpSecretParams{
+		Username:         username,
+		SecretCiphertext: ciphertext,
+	})
+	if err != nil {
+		// The upsert doesn't overwrite confirmed secrets
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAlreadyEnabled
+		}
+		return nil, err
+	}
+
+	return &Enrollment{
+		Secret: secret,
+		URI:    TotpURI(m.issuer, username, secret),
+	}, nil
+}
+
+// Confirm enables two-factor authentication with the first code of the authenticator app
+// and returns the recovery codes, which are only shown to the user this once.
+func (m *Manager) Confirm(ctx context.Context, username, code string) ([]string, error) {
+	totpSecret, err := m.store.GetTotpSecret(ctx, username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotEnabled
+		}
+		return nil, err
+	}
+	if totpSecret.IsConfirmed {
+		return nil, ErrAlreadyEnabled
+	}
+
+	secret, err := m.decrypt(totpSecret)
+	if err != nil {
+		return nil, err
+	}
+	step, err := ValidateTotpCode(secret, code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(recoveryCodes))
+	for i, recoveryCode := range recoveryCodes {
+		hashes[i] = HashRecoveryCode(recoveryCode)
+	}
+
+	_, err = m.store.EnableTotpTx(ctx, db.EnableTotpTxParams{
+		Username:           username,
+		Step:               step,
+		RecoveryCodeHashes: hashes,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrTotpNotPending) {
+			return nil, ErrAlreadyEnabled
+		}
+		return nil, err
+	}
+	return recoveryCodes, nil
+}
+
+// Disable turns two-factor authentication off after checking a TOTP or recovery code of the user.
+func (m *Manager) Disable(ctx context.Context, username, code string) error {
+	if err := m.VerifyCode(ctx, username, code); err != nil {
+		return err
+	}
+
+	disabled, err := m.store.DisableTotpTx(ctx, username)
+	if err != nil {
+		return err
+	}
+	if !disabled {
+		return ErrNotEnabled
+	}
+	return nil
+}
+
+// IsEnabled reports whether the user has to complete a second step to log in.
+func (m *Manager) IsEnabled(ctx context.Context, username string) (bool, error) {
+	totpSecret, err := m.store.GetTotpSecret(ctx, username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return totpSecret.IsConfirmed, nil
+}
+
+// CreateChallenge creates the MFA token a user exchanges for the access and refresh tokens
+// together with a valid code, after the password was checked.
+func (m *Manager) CreateChallenge(ctx context.Context, username string) (string, time.Time, error) {
+	mfaToken, err := util.GenerateSecretCode()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	challenge, err := m.store.CreateMfaChallenge(ctx, db.CreateMfaChallengeParams{
+		Username:  username,
+		TokenHash: util.HashSecretCode(mfaToken),
+		ExpiredAt: time.Now().Add(m.challengeDuration),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return mfaToken, challenge.ExpiredAt, nil
+}
+
+// GetChallenge returns the pending MFA challenge of a token, so that the login of its user can be checked
+// for a lockout before the code is verified.
+func (m *Manager) GetChallenge(ctx context.Context, mfaToken string) (db.UserSvcMfaChallenge, error) {
+	challenge, err := m.store.GetMfaChallenge(ctx, util.HashSecretCode(mfaToken))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.UserSvcMfaChallenge{}, ErrInvalidChallenge
+		}
+		return db.UserSvcMfaChallenge{}, err
+	}
+	if challenge.IsUsed || time.Now().After(challenge.ExpiredAt) || challenge.FailedAttempts >= maxChallengeAttempts {
+		return db.UserSvcMfaChallenge{}, ErrInvalidChallenge
+	}
+	return challenge, nil
+}
+
+// CompleteChallenge completes the second step of a login with a TOTP or recovery code.
+// A wrong code counts against the challenge, which can't be used anymore after too many of them.
+func (m *Manager) CompleteChallenge(ctx context.Context, challenge db.UserSvcMfaChallenge, code string) error {
+	if err := m.VerifyCode(ctx, challenge.Username, code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			if _, err := m.store.RecordMfaChallengeFailure(ctx, challenge.ID); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+
+	// The challenge is single-use, a concurrent request with the same token loses
+	if _, err := m.store.UseMfaChallenge(ctx, challenge.ID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidChallenge
+		}
+		return err
+	}
+	return nil
+}
+
+// VerifyCode checks a TOTP code or a recovery code of a user with enabled two-factor authentication.
+// Both can only be used once: the time step of a TOTP code is recorded and recovery codes are marked as used.
+func (m *Manager) VerifyCode(ctx context.Context, username, code string) error {
+	if !isTotpCode(code) {
+		_, err := m.store.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+			Username: username,
+			CodeHash: HashRecoveryCode(code),
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrInvalidCode
+		}
+		return err
+	}
+
+	totpSecret, err := m.store.GetTotpSecret(ctx, username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotEnabled
+		}
+		return err
+	}
+	if !totpSecret.IsConfirmed {
+		return ErrNotEnabled
+	}
+
+	secret, err := m.decrypt(totpSecret)
+	if err != nil {
+		return err
+	}
+	step, err := ValidateTotpCode(secret, code, time.Now())
+	if err != nil {
+		return err
+	}
+
+	used, err := m.store.UseTotpStep(ctx, db.UseTotpStepParams{
+		Step:     step,
+		Username: username,
+	})
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		// The code or a newer one has already been used
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+func (m *Manager) decrypt(totpSecret db.UserSvcTotpSecret) (string, error) {
+	if m.box == nil {
+		return "", ErrNotConfigured
+	}
+	return m.box.Decrypt(totpSecret.SecretCiphertext, totpSecret.Username)
+}
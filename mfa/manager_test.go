@@ -0,0 +1,221 @@
+package mfa
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestManager(t *testing.T, store db.Store) *Manager {
+	manager, err := NewManager(util.Config{
+		MfaEncryptionKey:     util.RandomString(32),
+		MfaIssuer:            "Streamfair",
+		MfaChallengeDuration: time.Minute,
+	}, store)
+	require.NoError(t, err)
+	return manager
+}
+
+func newTestTotpSecret(t *testing.T, manager *Manager, username string, confirmed bool) (db.UserSvcTotpSecret, string) {
+	secret, err := GenerateTotpSecret()
+	require.NoError(t, err)
+	ciphertext, err := manager.box.Encrypt(secret, username)
+	require.NoError(t, err)
+
+	return db.UserSvcTotpSecret{
+		Username:         username,
+		SecretCiphertext: ciphertext,
+		IsConfirmed:      confirmed,
+	}, secret
+}
+
+func TestNewManager(t *testing.T) {
+	manager, err := NewManager(util.Config{}, nil)
+	require.NoError(t, err)
+
+	_, err = manager.Enroll(context.Background(), util.RandomUsername())
+	require.ErrorIs(t, err, ErrNotConfigured)
+
+	_, err = NewManager(util.Config{MfaEncryptionKey: "short"}, nil)
+	require.Error(t, err)
+}
+
+func TestEnroll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager := newTestManager(t, store)
+	username := util.RandomUsername()
+
+	var ciphertext string
+	store.EXPECT().
+		CreateTotpSecret(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateTotpSecretParams) (db.UserSvcTotpSecret, error) {
+			ciphertext = arg.SecretCiphertext
+			return db.UserSvcTotpSecret{Username: arg.Username, SecretCiphertext: arg.SecretCiphertext}, nil
+		})
+
+	enrollment, err := manager.Enroll(context.Background(), username)
+	require.NoError(t, err)
+	require.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+
+	// Only the encrypted secret is stored
+	require.NotContains(t, ciphertext, enrollment.Secret)
+	plaintext, err := manager.box.Decrypt(ciphertext, username)
+	require.NoError(t, err)
+	require.Equal(t, enrollment.Secret, plaintext)
+
+	store.EXPECT().
+		CreateTotpSecret(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.UserSvcTotpSecret{}, pgx.ErrNoRows)
+
+	_, err = manager.Enroll(context.Background(), username)
+	require.ErrorIs(t, err, ErrAlreadyEnabled)
+}
+
+func TestConfirm(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager := newTestManager(t, store)
+	username := util.RandomUsername()
+
+	totpSecret, secret := newTestTotpSecret(t, manager, username, false)
+	step := TotpStep(time.Now())
+	code, err := TotpCode(secret, step)
+	require.NoError(t, err)
+
+	store.EXPECT().
+		GetTotpSecret(gomock.Any(), gomock.Eq(username)).
+		Times(2).
+		Return(totpSecret, nil)
+
+	var hashes []string
+	store.EXPECT().
+		EnableTotpTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.EnableTotpTxParams) (db.EnableTotpTxResult, error) {
+			require.Equal(t, username, arg.Username)
+			require.Equal(t, step, arg.Step)
+			hashes = arg.RecoveryCodeHashes
+			return db.EnableTotpTxResult{}, nil
+		})
+
+	recoveryCodes, err := manager.Confirm(context.Background(), username, code)
+	require.NoError(t, err)
+	require.Len(t, recoveryCodes, recoveryCodeCount)
+	for i, recoveryCode := range recoveryCodes {
+		require.Equal(t, HashRecoveryCode(recoveryCode), hashes[i])
+	}
+
+	// Codes outside of the accepted time steps are rejected
+	staleCode, err := TotpCode(secret, step-totpSkew-1)
+	require.NoError(t, err)
+	_, err = manager.Confirm(context.Background(), username, staleCode)
+	require.ErrorIs(t, err, ErrInvalidCode)
+}
+
+func TestVerifyCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager := newTestManager(t, store)
+	username := util.RandomUsername()
+
+	totpSecret, secret := newTestTotpSecret(t, manager, username, true)
+	step := TotpStep(time.Now())
+	code, err := TotpCode(secret, step)
+	require.NoError(t, err)
+
+	store.EXPECT().
+		GetTotpSecret(gomock.Any(), gomock.Eq(username)).
+		Times(2).
+		Return(totpSecret, nil)
+	gomock.InOrder(
+		store.EXPECT().
+			UseTotpStep(gomock.Any(), gomock.Eq(db.UseTotpStepParams{Step: step, Username: username})).
+			Return(int64(1), nil),
+		// The step was used, the code can't be replayed
+		store.EXPECT().
+			UseTotpStep(gomock.Any(), gomock.Eq(db.UseTotpStepParams{Step: step, Username: username})).
+			Return(int64(0), nil),
+	)
+
+	require.NoError(t, manager.VerifyCode(context.Background(), username, code))
+	require.ErrorIs(t, manager.VerifyCode(context.Background(), username, code), ErrInvalidCode)
+
+	recoveryCode := "ABCD-efgh-ijkl-mnop"
+	gomock.InOrder(
+		store.EXPECT().
+			UseRecoveryCode(gomock.Any(), gomock.Eq(db.UseRecoveryCodeParams{
+				Username: username,
+				CodeHash: HashRecoveryCode(recoveryCode),
+			})).
+			Return(db.UserSvcRecoveryCode{}, nil),
+		store.EXPECT().
+			UseRecoveryCode(gomock.Any(), gomock.Any()).
+			Return(db.UserSvcRecoveryCode{}, pgx.ErrNoRows),
+	)
+
+	require.NoError(t, manager.VerifyCode(context.Background(), username, recoveryCode))
+	require.ErrorIs(t, manager.VerifyCode(context.Background(), username, recoveryCode), ErrInvalidCode)
+}
+
+func TestChallenge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager := newTestManager(t, store)
+	username := util.RandomUsername()
+
+	var tokenHash string
+	store.EXPECT().
+		CreateMfaChallenge(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateMfaChallengeParams) (db.UserSvcMfaChallenge, error) {
+			require.Equal(t, username, arg.Username)
+			require.WithinDuration(t, time.Now().Add(time.Minute), arg.ExpiredAt, time.Second)
+			tokenHash = arg.TokenHash
+			return db.UserSvcMfaChallenge{Username: arg.Username, TokenHash: arg.TokenHash, ExpiredAt: arg.ExpiredAt}, nil
+		})
+
+	mfaToken, _, err := manager.CreateChallenge(context.Background(), username)
+	require.NoError(t, err)
+	require.Equal(t, util.HashSecretCode(mfaToken), tokenHash)
+
+	challenge := db.UserSvcMfaChallenge{ID: 1, Username: username, ExpiredAt: time.Now().Add(time.Minute)}
+	expired := challenge
+	expired.ExpiredAt = time.Now().Add(-time.Second)
+	exhausted := challenge
+	exhausted.FailedAttempts = maxChallengeAttempts
+
+	for _, stored := range []db.UserSvcMfaChallenge{expired, exhausted} {
+		store.EXPECT().
+			GetMfaChallenge(gomock.Any(), gomock.Eq(tokenHash)).
+			Times(1).
+			Return(stored, nil)
+
+		_, err = manager.GetChallenge(context.Background(), mfaToken)
+		require.ErrorIs(t, err, ErrInvalidChallenge)
+	}
+
+	// A wrong code counts against the challenge
+	store.EXPECT().
+		UseRecoveryCode(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.UserSvcRecoveryCode{}, pgx.ErrNoRows)
+	store.EXPECT().
+		RecordMfaChallengeFailure(gomock.Any(), gomock.Eq(challenge.ID)).
+		Times(1)
+	store.EXPECT().
+		UseMfaChallenge(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	err = manager.CompleteChallenge(context.Background(), challenge, "wrong-code")
+	require.ErrorIs(t, err, ErrInvalidCode)
+}
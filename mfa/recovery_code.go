@@ -0,0 +1,42 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"strings"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+)
+
+const (
+	// recoveryCodeCount is the number of recovery codes generated when two-factor authentication is enabled.
+	recoveryCodeCount = 10
+	// recoveryCodeLength is the number of random bytes of a recovery code, 80 bits encode to 16 base32 characters.
+	recoveryCodeLength = 10
+)
+
+// GenerateRecoveryCodes generates the single-use recovery codes of a user, formatted in groups of four
+// characters for readability, e.g. "abcd-efgh-ijkl-mnop".
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		secret := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+
+		encoded := strings.ToLower(totpEncoding.EncodeToString(secret))
+		groups := make([]string, 0, len(encoded)/4)
+		for j := 0; j < len(encoded); j += 4 {
+			groups = append(groups, encoded[j:j+4])
+		}
+		codes[i] = strings.Join(groups, "-")
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the hash under which a recovery code is stored. The code is normalized first,
+// so that it may be typed without dashes or in upper case.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return util.HashSecretCode(normalized)
+}
@@ -0,0 +1,34 @@
+package mfa
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	require.NoError(t, err)
+	require.Len(t, codes, recoveryCodeCount)
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		require.Regexp(t, `^[a-z2-7]{4}(-[a-z2-7]{4}){3}$`, code)
+		require.False(t, isTotpCode(code))
+		require.False(t, seen[code])
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	require.NoError(t, err)
+	code := codes[0]
+
+	hash := HashRecoveryCode(code)
+	require.Equal(t, hash, HashRecoveryCode(strings.ToUpper(code)))
+	require.Equal(t, hash, HashRecoveryCode(strings.ReplaceAll(code, "-", "")))
+	require.Equal(t, hash, HashRecoveryCode(strings.ReplaceAll(code, "-", " ")))
+	require.NotEqual(t, hash, HashRecoveryCode(codes[1]))
+}
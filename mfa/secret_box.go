@@ -0,0 +1,65 @@
+package mfa
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// secretBoxKeyLength is the key length of AES-256.
+const secretBoxKeyLength = 32
+
+// SecretBox encrypts the TOTP secrets before they are stored in the database with AES-256-GCM.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+// NewSecretBox creates a new SecretBox with a key of exactly 32 characters.
+func NewSecretBox(key string) (*SecretBox, error) {
+	if len(key) != secretBoxKeyLength {
+		return nil, fmt.Errorf("invalid key size: must be exactly %d characters", secretBoxKeyLength)
+	}
+
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Encrypt encrypts the plaintext with a random nonce and returns nonce and ciphertext base64 encoded.
+// The additional data binds the ciphertext to its owner, so that it can't be copied to another user.
+func (b *SecretBox) Encrypt(plaintext, additionalData string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(additionalData))
+	return base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a ciphertext returned by Encrypt with the same additional data.
+func (b *SecretBox) Decrypt(ciphertext, additionalData string) (string, error) {
+	sealed, err := base64.RawStdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("invalid ciphertext: %w", err)
+	}
+	if len(sealed) < b.aead.NonceSize() {
+		return "", errors.New("invalid ciphertext: too short")
+	}
+
+	nonce, sealed := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, sealed, []byte(additionalData))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
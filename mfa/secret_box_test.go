@@ -0,0 +1,38 @@
+package mfa
+
+import (
+	"testing"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretBox(t *testing.T) {
+	box, err := NewSecretBox(util.RandomString(32))
+	require.NoError(t, err)
+
+	ciphertext, err := box.Encrypt("secret", "alice")
+	require.NoError(t, err)
+	require.NotContains(t, ciphertext, "secret")
+
+	plaintext, err := box.Decrypt(ciphertext, "alice")
+	require.NoError(t, err)
+	require.Equal(t, "secret", plaintext)
+
+	// The ciphertext is bound to its owner
+	_, err = box.Decrypt(ciphertext, "bob")
+	require.Error(t, err)
+
+	other, err := NewSecretBox(util.RandomString(32))
+	require.NoError(t, err)
+	_, err = other.Decrypt(ciphertext, "alice")
+	require.Error(t, err)
+
+	_, err = box.Decrypt("abc", "alice")
+	require.Error(t, err)
+}
+
+func TestNewSecretBoxInvalidKey(t *testing.T) {
+	_, err := NewSecretBox(util.RandomString(16))
+	require.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package mfa
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the base32 encoded SHA1 secret "12345678901234567890" of the RFC 6238 test vectors.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCode(t *testing.T) {
+	// RFC 6238 Appendix B, truncated to 6 digits
+	testCases := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+	}
+
+	for _, tc := range testCases {
+		code, err := TotpCode(rfcSecret, TotpStep(time.Unix(tc.unix, 0)))
+		require.NoError(t, err)
+		require.Equal(t, tc.code, code, tc.unix)
+	}
+
+	_, err := TotpCode("not base32!", 1)
+	require.Error(t, err)
+}
+
+func TestValidateTotpCode(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	step := TotpStep(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, err := TotpCode(rfcSecret, step+offset)
+		require.NoError(t, err)
+
+		gotStep, err := ValidateTotpCode(rfcSecret, code, now)
+		require.NoError(t, err)
+		require.Equal(t, step+offset, gotStep)
+	}
+
+	code, err := TotpCode(rfcSecret, step+2)
+	require.NoError(t, err)
+	_, err = ValidateTotpCode(rfcSecret, code, now)
+	require.ErrorIs(t, err, ErrInvalidCode)
+
+	_, err = ValidateTotpCode(rfcSecret, "12345", now)
+	require.ErrorIs(t, err, ErrInvalidCode)
+}
+
+func TestTotpURI(t *testing.T) {
+	secret, err := GenerateTotpSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, 32)
+
+	uri, err := url.Parse(TotpURI("Streamfair", "alice", secret))
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", uri.Scheme)
+	require.Equal(t, "totp", uri.Host)
+	require.Equal(t, "/Streamfair:alice", uri.Path)
+	require.Equal(t, secret, uri.Query().Get("secret"))
+	require.Equal(t, "Streamfair", uri.Query().Get("issuer"))
+	require.Equal(t, "6", uri.Query().Get("digits"))
+	require.Equal(t, "30", uri.Query().Get("period"))
+}
@@ -0,0 +1,238 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"net/http"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/lockout"
+	"github.com/Streamfair/streamfair_user_svc/mfa"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// decisionAllow is the decision of a user who allows the request on the authorization page, any other denies it.
+const decisionAllow = "allow"
+
+// authorizePage is the data of the authorization page. Without a client only the error is shown.
+type authorizePage struct {
+	Client  string
+	Scopes  []string
+	Request AuthorizeRequest
+	Error   string
+}
+
+// authorizePageTemplate asks the user to sign in and to allow the client the requested scopes. The parameters of the
+// authorization request are posted back with the form, so that the page doesn't need a session of its own.
+var authorizePageTemplate = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Sign in to Streamfair</title>
+</head>
+<body>
+{{if .Client}}
+<h1>{{.Client}} wants to access your Streamfair account</h1>
+{{if .Scopes}}
+<p>It will be allowed to:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>
+{{end}}
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post">
+<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+<label>Username or email <input name="username" autocomplete="username"></label>
+<label>Password <input type="password" name="password" autocomplete="current-password"></label>
+<label>Two-factor code, if enabled <input name="code" autocomplete="one-time-code"></label>
+<button type="submit" name="decision" value="allow">Allow</button>
+<button type="submit" name="decision" value="deny">Deny</button>
+</form>
+{{else}}
+<h1>Authorization failed</h1>
+<p role="alert">{{.Error}}</p>
+{{end}}
+</body>
+</html>
+`))
+
+// HandleAuthorize serves the authorization endpoint to the browser of the user. GET shows the sign-in and consent
+// page of the authorization request, POST checks the credentials and the decision of the user and redirects the
+// user back to the client with an authorization code or the denial. The clientIP throttles failed sign-ins.
+func (p *Provider) HandleAuthorize(res http.ResponseWriter, req *http.Request, clientIP string) {
+	// The page takes credentials, it must not be cached or framed by other sites
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("X-Frame-Options", "DENY")
+	res.Header().Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+
+	switch req.Method {
+	case http.MethodGet:
+		p.showAuthorizePage(res, req, ParseAuthorizeRequest(req.URL.Query()))
+	case http.MethodPost:
+		if err := req.ParseForm(); err != nil {
+			writeAuthorizePage(res, http.StatusBadRequest, authorizePage{Error: "invalid form body"})
+			return
+		}
+		p.submitAuthorizePage(res, req, ParseAuthorizeRequest(req.PostForm), clientIP)
+	default:
+		res.Header().Set("Allow", "GET, POST")
+		writeAuthorizePage(res, http.StatusMethodNotAllowed, authorizePage{Error: "method not allowed"})
+	}
+}
+
+func (p *Provider) showAuthorizePage(res http.ResponseWriter, req *http.Request, authorizeRequest AuthorizeRequest) {
+	auth, location, err := p.checkAuthorizeRequest(req.Context(), authorizeRequest)
+	if err != nil {
+		writeAuthorizeError(res, err)
+		return
+	}
+	if location != "" {
+		http.Redirect(res, req, location, http.StatusFound)
+		return
+	}
+
+	writeAuthorizePage(res, http.StatusOK, authorizePage{
+		Client:  auth.client.Name,
+		Scopes:  auth.scopes,
+		Request: authorizeRequest,
+	})
+}
+
+func (p *Provider) submitAuthorizePage(res http.ResponseWriter, req *http.Request, authorizeRequest AuthorizeRequest, clientIP string) {
+	ctx := req.Context()
+
+	if req.PostForm.Get("decision") != decisionAllow {
+		location, err := p.Deny(ctx, authorizeRequest)
+		if err != nil {
+			writeAuthorizeError(res, err)
+			return
+		}
+		http.Redirect(res, req, location, http.StatusSeeOther)
+		return
+	}
+
+	auth, location, err := p.checkAuthorizeRequest(ctx, authorizeRequest)
+	if err != nil {
+		writeAuthorizeError(res, err)
+		return
+	}
+	if location != "" {
+		http.Redirect(res, req, location, http.StatusSeeOther)
+		return
+	}
+
+	user, statusCode, err := p.authenticate(ctx, req.PostForm.Get("username"), req.PostForm.Get("password"), req.PostForm.Get("code"), clientIP)
+	if err != nil {
+		if statusCode == http.StatusInternalServerError {
+			log.Error().Err(err).Str("client_id", auth.client.ID).Msg("failed to sign in on the authorization page")
+			err = errors.New("sign-in failed, please try again later")
+		}
+		writeAuthorizePage(res, statusCode, authorizePage{
+			Client:  auth.client.Name,
+			Scopes:  auth.scopes,
+			Request: authorizeRequest,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	location, err = p.Authorize(ctx, user.Username, authorizeRequest)
+	if err != nil {
+		writeAuthorizeError(res, err)
+		return
+	}
+	http.Redirect(res, req, location, http.StatusSeeOther)
+}
+
+// authenticate checks the credentials of a sign-in on the authorization page like a login: failures count against
+// the lockout of the username and the client address, the user has to be active and to enter the code of the
+// authenticator app if two-factor authentication is enabled. Failures come with the status code of the page.
+func (p *Provider) authenticate(ctx context.Context, username, password, code, clientIP string) (db.UserSvcUser, int, error) {
+	if username == "" || password == "" {
+		return db.UserSvcUser{}, http.StatusBadRequest, errors.New("username and password are required")
+	}
+
+	if err := p.loginGuard.Check(ctx, username, clientIP); err != nil {
+		var lockedErr *lockout.LockedError
+		if errors.As(err, &lockedErr) {
+			return db.UserSvcUser{}, http.StatusTooManyRequests, err
+		}
+		return db.UserSvcUser{}, http.StatusInternalServerError, err
+	}
+
+	user, err := p.store.GetUserByValue(ctx, username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Spend the time of a password check, so that unknown usernames can't be told apart by the response time
+			_, _ = util.HashPassword(password, p.argon2idParams)
+			return db.UserSvcUser{}, http.StatusUnauthorized, p.rejectSignIn(ctx, username, clientIP)
+		}
+		return db.UserSvcUser{}, http.StatusInternalServerError, err
+	}
+
+	if err := util.ComparePassword(user.PasswordHash, user.PasswordSalt, password); err != nil {
+		return db.UserSvcUser{}, http.StatusUnauthorized, p.rejectSignIn(ctx, username, clientIP)
+	}
+
+	if user.Status.String != util.StatusActive {
+		return db.UserSvcUser{}, http.StatusForbidden, errors.New("user account is not active, the email address has to be verified first")
+	}
+
+	mfaEnabled, err := p.mfa.IsEnabled(ctx, user.Username)
+	if err != nil {
+		return db.UserSvcUser{}, http.StatusInternalServerError, err
+	}
+	if mfaEnabled {
+		if code == "" {
+			return db.UserSvcUser{}, http.StatusUnauthorized, errors.New("enter the code of your authenticator app or a recovery code")
+		}
+		if err := p.mfa.VerifyCode(ctx, user.Username, code); err != nil {
+			if errors.Is(err, mfa.ErrInvalidCode) {
+				return db.UserSvcUser{}, http.StatusUnauthorized, p.rejectSignIn(ctx, username, clientIP)
+			}
+			return db.UserSvcUser{}, http.StatusInternalServerError, err
+		}
+	}
+
+	if err := p.loginGuard.RecordSuccess(ctx, user.Username); err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to reset failed login attempts")
+	}
+	return user, http.StatusOK, nil
+}
+
+// rejectSignIn records a failed sign-in and returns the error that doesn't reveal what was wrong.
+func (p *Provider) rejectSignIn(ctx context.Context, username, clientIP string) error {
+	if err := p.loginGuard.RecordFailure(ctx, username, clientIP); err != nil {
+		log.Error().Err(err).Str("username", username).Msg("failed to record failed login attempt")
+	}
+	return lockout.ErrInvalidCredentials
+}
+
+// writeAuthorizeError shows an error that can't be reported to the client, e.g. of an unknown client.
+func writeAuthorizeError(res http.ResponseWriter, err error) {
+	var oauthErr *Error
+	if errors.As(err, &oauthErr) {
+		writeAuthorizePage(res, oauthErr.StatusCode, authorizePage{Error: oauthErr.Error()})
+		return
+	}
+
+	log.Error().Err(err).Msg("failed to process authorization request")
+	writeAuthorizePage(res, http.StatusInternalServerError, authorizePage{Error: "the request could not be processed"})
+}
+
+func writeAuthorizePage(res http.ResponseWriter, statusCode int, page authorizePage) {
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	res.WriteHeader(statusCode)
+	if err := authorizePageTemplate.Execute(res, page); err != nil {
+		log.Error().Err(err).Msg("failed to write authorization page")
+	}
+}
@@ -0,0 +1,221 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const testClientIP = "192.0.2.1"
+
+func newTestAuthorizeRequest(client db.UserSvcOAuthClient) url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ID},
+		"scope":                 {ScopeOpenID},
+		"state":                 {"xyz"},
+		"code_challenge":        {CodeChallenge(util.RandomString(43))},
+		"code_challenge_method": {CodeChallengeMethodS256},
+		"nonce":                 {"n-0S6_WzA2Mj"},
+	}
+}
+
+func postAuthorizePage(provider *Provider, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+	provider.HandleAuthorize(res, req, testClientIP)
+	return res
+}
+
+func TestShowAuthorizePage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	provider, _ := newTestProvider(t, store)
+
+	client := newTestClient("")
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).Times(1).Return(client, nil)
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq("unknown")).Times(1).Return(db.UserSvcOAuthClient{}, pgx.ErrNoRows)
+
+	query := newTestAuthorizeRequest(client)
+	req := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	res := httptest.NewRecorder()
+	provider.HandleAuthorize(res, req, testClientIP)
+
+	// The page asks for the credentials and the consent and carries the request in the form
+	require.Equal(t, http.StatusOK, res.Code)
+	require.Equal(t, "text/html; charset=utf-8", res.Header().Get("Content-Type"))
+	require.Equal(t, "DENY", res.Header().Get("X-Frame-Options"))
+	body := res.Body.String()
+	require.Contains(t, body, client.Name+" wants to access your Streamfair account")
+	require.Contains(t, body, `name="password"`)
+	require.Contains(t, body, `name="state" value="xyz"`)
+	require.Contains(t, body, `name="nonce" value="n-0S6_WzA2Mj"`)
+	require.Contains(t, body, `value="`+query.Get("code_challenge")+`"`)
+
+	// Requests of unknown clients are not redirected
+	query.Set("client_id", "unknown")
+	req = httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil)
+	res = httptest.NewRecorder()
+	provider.HandleAuthorize(res, req, testClientIP)
+
+	require.Equal(t, http.StatusBadRequest, res.Code)
+	require.Empty(t, res.Header().Get("Location"))
+	require.NotContains(t, res.Body.String(), "<form")
+}
+
+func TestSubmitAuthorizePage(t *testing.T) {
+	client := newTestClient("")
+	password := util.RandomString(12)
+	hashedPassword, err := util.HashPassword(password, util.Argon2idParams{})
+	require.NoError(t, err)
+
+	user := newTestUser()
+	user.PasswordHash = hashedPassword
+	inactiveUser := user
+	inactiveUser.Status = util.ConvertToText(util.StatusInactive)
+
+	testCases := []struct {
+		name          string
+		form          func(form url.Values)
+		buildStubs    func(store *mock_db.MockStore)
+		checkResponse func(t *testing.T, res *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Allow",
+			form: func(form url.Values) {},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetLoginAttempts(gomock.Any(), gomock.Any()).Times(1).Return([]db.UserSvcLoginAttempt{}, nil)
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserSvcTotpSecret{}, pgx.ErrNoRows)
+				store.EXPECT().DeleteLoginAttempts(gomock.Any(), gomock.Any()).Times(1).Return(int64(0), nil)
+				store.EXPECT().
+					CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ any, arg db.CreateOAuthAuthorizationCodeParams) (db.UserSvcOAuthAuthorizationCode, error) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, testRedirectURI, arg.RedirectUri)
+						require.Equal(t, []string{ScopeOpenID}, arg.Scopes)
+						require.Equal(t, "n-0S6_WzA2Mj", arg.Nonce)
+						return db.UserSvcOAuthAuthorizationCode{}, nil
+					})
+			},
+			checkResponse: func(t *testing.T, res *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusSeeOther, res.Code)
+				location, err := url.Parse(res.Header().Get("Location"))
+				require.NoError(t, err)
+				require.Equal(t, "app.example.com", location.Host)
+				require.NotEmpty(t, location.Query().Get("code"))
+				require.Equal(t, "xyz", location.Query().Get("state"))
+			},
+		},
+		{
+			name: "Deny",
+			form: func(form url.Values) {
+				form.Set("decision", "deny")
+				form.Del("password")
+			},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, res *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusSeeOther, res.Code)
+				location, err := url.Parse(res.Header().Get("Location"))
+				require.NoError(t, err)
+				require.Equal(t, "access_denied", location.Query().Get("error"))
+				require.Equal(t, "xyz", location.Query().Get("state"))
+				require.Empty(t, location.Query().Get("code"))
+			},
+		},
+		{
+			name: "WrongPassword",
+			form: func(form url.Values) { form.Set("password", util.RandomString(12)) },
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetLoginAttempts(gomock.Any(), gomock.Any()).Times(1).Return([]db.UserSvcLoginAttempt{}, nil)
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().RecordLoginFailure(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcLoginAttempt{FailedAttempts: 1}, nil)
+				store.EXPECT().CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, res *httptest.ResponseRecorder) {
+				// The page is shown again with the error
+				require.Equal(t, http.StatusUnauthorized, res.Code)
+				require.Empty(t, res.Header().Get("Location"))
+				require.Contains(t, res.Body.String(), "invalid credentials")
+				require.Contains(t, res.Body.String(), "<form")
+			},
+		},
+		{
+			name: "InactiveUser",
+			form: func(form url.Values) {},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetLoginAttempts(gomock.Any(), gomock.Any()).Times(1).Return([]db.UserSvcLoginAttempt{}, nil)
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(inactiveUser, nil)
+				store.EXPECT().CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, res *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, res.Code)
+			},
+		},
+		{
+			name: "MfaCodeRequired",
+			form: func(form url.Values) {},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().GetLoginAttempts(gomock.Any(), gomock.Any()).Times(1).Return([]db.UserSvcLoginAttempt{}, nil)
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().
+					GetTotpSecret(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(db.UserSvcTotpSecret{Username: user.Username, IsConfirmed: true}, nil)
+				store.EXPECT().CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, res *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, res.Code)
+				require.Contains(t, res.Body.String(), "authenticator app")
+			},
+		},
+		{
+			name: "Locked",
+			form: func(form url.Values) {},
+			buildStubs: func(store *mock_db.MockStore) {
+				store.EXPECT().
+					GetLoginAttempts(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return([]db.UserSvcLoginAttempt{{LockedUntil: time.Now().Add(time.Hour)}}, nil)
+				store.EXPECT().GetUserByValue(gomock.Any(), gomock.Any()).Times(0)
+			},
+			checkResponse: func(t *testing.T, res *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, res.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mock_db.NewMockStore(ctrl)
+			provider, _ := newTestProvider(t, store)
+
+			store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).AnyTimes().Return(client, nil)
+			tc.buildStubs(store)
+
+			form := newTestAuthorizeRequest(client)
+			form.Set("username", user.Username)
+			form.Set("password", password)
+			form.Set("decision", decisionAllow)
+			tc.form(form)
+
+			tc.checkResponse(t, postAuthorizePage(provider, form))
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Grant types of the token endpoint.
+const (
+	GrantTypeAuthorizationCode = "authorization_code"
+	GrantTypeRefreshToken      = "refresh_token"
+	GrantTypeClientCredentials = "client_credentials"
+)
+
+// OpenID Connect scopes. They grant access to the claims of the userinfo endpoint and are not permissions of the user.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// CodeChallengeMethodS256 is the only supported PKCE method, plain challenges are rejected.
+const CodeChallengeMethodS256 = "S256"
+
+// Error is an error response of RFC 6749. The status code is the one of the token endpoint, errors of the
+// authorization endpoint are sent to the redirect URI of the client if it could be verified.
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	StatusCode  int    `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+func invalidRequest(description string) *Error {
+	return &Error{Code: "invalid_request", Description: description, StatusCode: http.StatusBadRequest}
+}
+
+func invalidClient(description string) *Error {
+	return &Error{Code: "invalid_client", Description: description, StatusCode: http.StatusUnauthorized}
+}
+
+func invalidGrant(description string) *Error {
+	return &Error{Code: "invalid_grant", Description: description, StatusCode: http.StatusBadRequest}
+}
+
+func invalidScope(description string) *Error {
+	return &Error{Code: "invalid_scope", Description: description, StatusCode: http.StatusBadRequest}
+}
+
+func unauthorizedClient(description string) *Error {
+	return &Error{Code: "unauthorized_client", Description: description, StatusCode: http.StatusBadRequest}
+}
+
+func unsupportedGrantType(grantType string) *Error {
+	return &Error{
+		Code:        "unsupported_grant_type",
+		Description: fmt.Sprintf("grant type '%s' is not supported", grantType),
+		StatusCode:  http.StatusBadRequest,
+	}
+}
+
+func unsupportedResponseType(responseType string) *Error {
+	return &Error{
+		Code:        "unsupported_response_type",
+		Description: fmt.Sprintf("response type '%s' is not supported", responseType),
+		StatusCode:  http.StatusBadRequest,
+	}
+}
+
+func accessDenied() *Error {
+	return &Error{Code: "access_denied", Description: "the user denied the authorization request", StatusCode: http.StatusForbidden}
+}
+
+func insufficientScope(description string) *Error {
+	return &Error{Code: "insufficient_scope", Description: description, StatusCode: http.StatusForbidden}
+}
+
+// AuthorizeRequest is an authorization request of the authorization code grant.
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+}
+
+// ParseAuthorizeRequest reads an authorization request from the query parameters.
+func ParseAuthorizeRequest(query url.Values) AuthorizeRequest {
+	return AuthorizeRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+		Nonce:               query.Get("nonce"),
+	}
+}
+
+// TokenRequest is a request of the token endpoint. The fields of the grants that aren't requested are empty.
+type TokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+	// UserAgent and ClientIP are stored with the sessions of the refresh tokens.
+	UserAgent string
+	ClientIP  string
+}
+
+// ParseTokenRequest reads a form encoded token request. Clients authenticate with HTTP basic authentication
+// or with the client_id and client_secret parameters of the form, public clients only send their ID.
+func ParseTokenRequest(req *http.Request) (TokenRequest, error) {
+	if err := req.ParseForm(); err != nil {
+		return TokenRequest{}, invalidRequest("invalid form body")
+	}
+
+	tokenRequest := TokenRequest{
+		GrantType:    req.PostForm.Get("grant_type"),
+		ClientID:     req.PostForm.Get("client_id"),
+		ClientSecret: req.PostForm.Get("client_secret"),
+		Code:         req.PostForm.Get("code"),
+		RedirectURI:  req.PostForm.Get("redirect_uri"),
+		CodeVerifier: req.PostForm.Get("code_verifier"),
+		RefreshToken: req.PostForm.Get("refresh_token"),
+		Scope:        req.PostForm.Get("scope"),
+		UserAgent:    req.UserAgent(),
+	}
+
+	if clientID, clientSecret, ok := req.BasicAuth(); ok {
+		if tokenRequest.ClientSecret != "" {
+			return TokenRequest{}, invalidRequest("client authenticated with more than one method")
+		}
+
+		// The credentials are form encoded before they are put into the header
+		var errID, errSecret error
+		tokenRequest.ClientID, errID = url.QueryUnescape(clientID)
+		tokenRequest.ClientSecret, errSecret = url.QueryUnescape(clientSecret)
+		if errID != nil || errSecret != nil {
+			return TokenRequest{}, invalidClient("invalid client credentials")
+		}
+	}
+	return tokenRequest, nil
+}
+
+// CodeChallenge returns the S256 code challenge of a PKCE code verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// verifyCodeChallenge checks the code verifier of the token request against the challenge of the authorization request.
+func verifyCodeChallenge(challenge, verifier string) bool {
+	// RFC 7636 requires verifiers of 43 to 128 characters
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(CodeChallenge(verifier)), []byte(challenge)) == 1
+}
+
+// parseScopes splits a space separated list of scopes.
+func parseScopes(scope string) []string {
+	return strings.Fields(scope)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,609 @@
+package oauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/lockout"
+	"github.com/Streamfair/streamfair_user_svc/mfa"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// TokenResponse is the successful response of the token endpoint. Tokens of the client credentials grant
+// have no refresh token, only the exchange of an authorization code for the openid scope has an ID token.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+// UserInfo holds the claims of the userinfo endpoint. The subject is the ID of the user, because usernames
+// can change. Profile and email claims are only returned for the matching scopes.
+type UserInfo struct {
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+// Metadata is the discovery document of the provider. The OpenID Connect fields are only set if the provider
+// has a signing key for ID tokens, without one it is a plain OAuth2 authorization server.
+type Metadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint,omitempty"`
+	JwksURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported,omitempty"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	ScopesSupported                   []string `json:"scopes_supported,omitempty"`
+	ClaimsSupported                   []string `json:"claims_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+}
+
+// Provider is the OAuth2 authorization server of the service. It issues the tokens of the registered clients
+// with the token maker of the service and keeps the refresh tokens of users in their sessions, so that they can
+// be listed and revoked like the ones of a login. Users sign in on its authorization page with the same
+// lockout and two-factor authentication as a login.
+type Provider struct {
+	store                db.Store
+	tokenMaker           token.Maker
+	policy               *policy.Policy
+	loginGuard           *lockout.Guard
+	mfa                  *mfa.Manager
+	argon2idParams       util.Argon2idParams
+	issuer               string
+	codeDuration         time.Duration
+	accessTokenDuration  time.Duration
+	refreshTokenDuration time.Duration
+}
+
+// NewProvider creates a new Provider from the OAuth settings of the configuration.
+func NewProvider(config util.Config, store db.Store, tokenMaker token.Maker, policy *policy.Policy, loginGuard *lockout.Guard, mfaManager *mfa.Manager) *Provider {
+	return &Provider{
+		store:                store,
+		tokenMaker:           tokenMaker,
+		policy:               policy,
+		loginGuard:           loginGuard,
+		mfa:                  mfaManager,
+		argon2idParams:       config.Argon2idParams(),
+		issuer:               strings.TrimSuffix(config.OAuthIssuerURL, "/"),
+		codeDuration:         config.OAuthCodeDuration,
+		accessTokenDuration:  config.AccessTokenDuration,
+		refreshTokenDuration: config.RefreshTokenDuration,
+	}
+}
+
+// Discovery returns the discovery document of the provider.
+func (p *Provider) Discovery() Metadata {
+	metadata := Metadata{
+		Issuer:                            p.issuer,
+		AuthorizationEndpoint:             p.issuer + "/oauth/authorize",
+		TokenEndpoint:                     p.issuer + "/oauth/token",
+		JwksURI:                           p.issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken, GrantTypeClientCredentials},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{CodeChallengeMethodS256},
+	}
+	if p.issuesIDTokens() {
+		metadata.UserinfoEndpoint = p.issuer + "/oauth/userinfo"
+		metadata.SubjectTypesSupported = []string{"public"}
+		metadata.IDTokenSigningAlgValuesSupported = []string{"EdDSA"}
+		metadata.ScopesSupported = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+		metadata.ClaimsSupported = []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "preferred_username", "name", "email", "email_verified"}
+	}
+	return metadata
+}
+
+// authorization is a valid authorization request of a registered client.
+type authorization struct {
+	client      db.UserSvcOAuthClient
+	redirectURI string
+	scopes      []string
+}
+
+// checkAuthorizeRequest validates an authorization request. Requests with an unknown client or redirect URI fail
+// with an *Error and must not be redirected, the other failures are reported to the client in the returned URL.
+func (p *Provider) checkAuthorizeRequest(ctx context.Context, req AuthorizeRequest) (authorization, string, error) {
+	if req.ClientID == "" {
+		return authorization{}, "", invalidRequest("client_id is required")
+	}
+
+	client, err := p.store.GetOAuthClient(ctx, req.ClientID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return authorization{}, "", invalidRequest("unknown client")
+		}
+		return authorization{}, "", err
+	}
+
+	redirectURI := req.RedirectURI
+	switch {
+	case redirectURI == "" && len(client.RedirectUris) == 1:
+		redirectURI = client.RedirectUris[0]
+	case redirectURI == "":
+		return authorization{}, "", invalidRequest("redirect_uri is required")
+	case !contains(client.RedirectUris, redirectURI):
+		return authorization{}, "", invalidRequest("redirect_uri is not registered for the client")
+	}
+
+	if req.ResponseType != "code" {
+		return authorization{}, redirectURL(redirectURI, url.Values{}, req.State, unsupportedResponseType(req.ResponseType)), nil
+	}
+	if !contains(client.GrantTypes, GrantTypeAuthorizationCode) {
+		return authorization{}, redirectURL(redirectURI, url.Values{}, req.State, unauthorizedClient("client may not use the authorization code grant")), nil
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != CodeChallengeMethodS256 {
+		return authorization{}, redirectURL(redirectURI, url.Values{}, req.State, invalidRequest("an S256 code challenge is required")), nil
+	}
+
+	scopes, oauthErr := requestedScopes(req.Scope, client.Scopes)
+	if oauthErr != nil {
+		return authorization{}, redirectURL(redirectURI, url.Values{}, req.State, oauthErr), nil
+	}
+
+	return authorization{client: client, redirectURI: redirectURI, scopes: scopes}, "", nil
+}
+
+// Authorize issues an authorization code of the client to the authenticated user and returns the URL the user is
+// redirected to. Requests with an unknown client or redirect URI fail with an *Error and must not be redirected,
+// all other failures are reported to the client in the redirect URL.
+func (p *Provider) Authorize(ctx context.Context, username string, req AuthorizeRequest) (string, error) {
+	auth, location, err := p.checkAuthorizeRequest(ctx, req)
+	if err != nil || location != "" {
+		return location, err
+	}
+
+	code, err := util.GenerateSecretCode()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = p.store.CreateOAuthAuthorizationCode(ctx, db.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      util.HashSecretCode(code),
+		ClientID:      auth.client.ID,
+		Username:      username,
+		RedirectUri:   auth.redirectURI,
+		Scopes:        auth.scopes,
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		ExpiredAt:     time.Now().Add(p.codeDuration),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return redirectURL(auth.redirectURI, url.Values{"code": {code}}, req.State, nil), nil
+}
+
+// Deny returns the URL that tells the client that the user denied the authorization request.
+func (p *Provider) Deny(ctx context.Context, req AuthorizeRequest) (string, error) {
+	auth, location, err := p.checkAuthorizeRequest(ctx, req)
+	if err != nil || location != "" {
+		return location, err
+	}
+	return redirectURL(auth.redirectURI, url.Values{}, req.State, accessDenied()), nil
+}
+
+// Token authenticates the client and exchanges the grant of the request for tokens. OAuth errors are returned
+// as *Error, other errors mean that the request couldn't be processed.
+func (p *Provider) Token(ctx context.Context, req TokenRequest) (*TokenResponse, error) {
+	client, err := p.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode, GrantTypeRefreshToken, GrantTypeClientCredentials:
+	case "":
+		return nil, invalidRequest("grant_type is required")
+	default:
+		return nil, unsupportedGrantType(req.GrantType)
+	}
+
+	if !contains(client.GrantTypes, req.GrantType) {
+		return nil, unauthorizedClient("client may not use the " + req.GrantType + " grant")
+	}
+
+	switch req.GrantType {
+	case GrantTypeAuthorizationCode:
+		return p.exchangeAuthorizationCode(ctx, client, req)
+	case GrantTypeRefreshToken:
+		return p.refreshAccessToken(ctx, client, req)
+	default:
+		return p.issueClientToken(client, req)
+	}
+}
+
+// UserInfo returns the claims of the user of an access token that was granted the openid scope.
+func (p *Provider) UserInfo(ctx context.Context, payload *token.Payload) (UserInfo, error) {
+	if !payload.HasScope(ScopeOpenID) {
+		return UserInfo{}, insufficientScope("the access token was not granted the openid scope")
+	}
+
+	user, err := p.store.GetUserByValue(ctx, payload.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return UserInfo{}, &Error{Code: "invalid_token", Description: "user of the access token does not exist", StatusCode: http.StatusUnauthorized}
+		}
+		return UserInfo{}, err
+	}
+
+	info := UserInfo{Subject: strconv.FormatInt(user.ID, 10)}
+	if payload.HasScope(ScopeProfile) {
+		info.PreferredUsername = user.Username
+		info.Name = user.FullName
+	}
+	if payload.HasScope(ScopeEmail) {
+		verified := !user.EmailVerifiedAt.IsZero()
+		info.Email = user.Email
+		info.EmailVerified = &verified
+	}
+	return info, nil
+}
+
+// authenticateClient checks the credentials of a client. Public clients are registered without a secret
+// and authenticate with their ID alone, they have to prove the authorization request with PKCE instead.
+func (p *Provider) authenticateClient(ctx context.Context, clientID, clientSecret string) (db.UserSvcOAuthClient, error) {
+	if clientID == "" {
+		return db.UserSvcOAuthClient{}, invalidClient("client authentication is required")
+	}
+
+	client, err := p.store.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return db.UserSvcOAuthClient{}, invalidClient("invalid client credentials")
+		}
+		return db.UserSvcOAuthClient{}, err
+	}
+
+	if client.SecretHash == "" {
+		if clientSecret != "" {
+			return db.UserSvcOAuthClient{}, invalidClient("invalid client credentials")
+		}
+		return client, nil
+	}
+
+	secretHash := util.HashSecretCode(clientSecret)
+	if clientSecret == "" || subtle.ConstantTimeCompare([]byte(secretHash), []byte(client.SecretHash)) != 1 {
+		return db.UserSvcOAuthClient{}, invalidClient("invalid client credentials")
+	}
+	return client, nil
+}
+
+func (p *Provider) exchangeAuthorizationCode(ctx context.Context, client db.UserSvcOAuthClient, req TokenRequest) (*TokenResponse, error) {
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, invalidRequest("code and code_verifier are required")
+	}
+
+	// The code is marked as used before it is checked, so that it can't be exchanged twice
+	code, err := p.store.UseOAuthAuthorizationCode(ctx, util.HashSecretCode(req.Code))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, invalidGrant("invalid authorization code")
+		}
+		return nil, err
+	}
+
+	switch {
+	case code.ClientID != client.ID:
+		return nil, invalidGrant("authorization code was issued to another client")
+	case time.Now().After(code.ExpiredAt):
+		return nil, invalidGrant("authorization code has expired")
+	case code.RedirectUri != "" && req.RedirectURI != code.RedirectUri:
+		return nil, invalidGrant("redirect_uri does not match the authorization request")
+	case !verifyCodeChallenge(code.CodeChallenge, req.CodeVerifier):
+		return nil, invalidGrant("invalid code verifier")
+	}
+
+	user, err := p.store.GetUserByValue(ctx, code.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, invalidGrant("user of the authorization code does not exist")
+		}
+		return nil, err
+	}
+	// The user may have been deactivated since the authorization
+	if user.Status.String != util.StatusActive {
+		return nil, invalidGrant("user is not active")
+	}
+
+	claims, err := p.userClaims(ctx, client.ID, user, code.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, refreshPayload, err := p.createTokens(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// The user signed in on the authorization page right before the code was issued
+	if p.issuesIDTokens() && contains(claims.Scopes, ScopeOpenID) {
+		rsp.IDToken, err = p.tokenMaker.CreateIDToken(token.IDClaims{
+			Issuer:   p.issuer,
+			Subject:  strconv.FormatInt(user.ID, 10),
+			Audience: client.ID,
+			Nonce:    code.Nonce,
+			AuthTime: code.CreatedAt,
+		}, p.accessTokenDuration)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The session starts a new token family, like the one of a login
+	_, err = p.store.CreateSession(ctx, db.CreateSessionParams{
+		ID:           refreshPayload.ID,
+		FamilyID:     refreshPayload.ID,
+		Username:     user.Username,
+		RefreshToken: rsp.RefreshToken,
+		UserAgent:    req.UserAgent,
+		ClientIp:     req.ClientIP,
+		IsBlocked:    false,
+		ExpiresAt:    refreshPayload.ExpiredAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rsp, nil
+}
+
+func (p *Provider) refreshAccessToken(ctx context.Context, client db.UserSvcOAuthClient, req TokenRequest) (*TokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, invalidRequest("refresh_token is required")
+	}
+
+	payload, err := p.tokenMaker.VerifyRefreshToken(req.RefreshToken)
+	if err != nil {
+		return nil, invalidGrant("invalid refresh token")
+	}
+	if payload.ClientID != client.ID {
+		return nil, invalidGrant("refresh token was issued to another client")
+	}
+
+	session, err := p.store.GetSession(ctx, payload.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, invalidGrant("invalid refresh token")
+		}
+		return nil, err
+	}
+
+	// A refresh token can only be exchanged once, presenting a rotated token again revokes the whole token family
+	if session.ReplacedBy.Valid {
+		p.revokeSessionFamily(ctx, client.ID, session)
+		return nil, invalidGrant("refresh token has already been used")
+	}
+
+	switch {
+	case session.IsBlocked:
+		return nil, invalidGrant("session is blocked")
+	case session.Username != payload.Username || session.RefreshToken != req.RefreshToken:
+		return nil, invalidGrant("invalid refresh token")
+	case time.Now().After(session.ExpiresAt):
+		return nil, invalidGrant("expired session")
+	}
+
+	// The scopes can be narrowed, but not extended beyond the ones of the authorization
+	scopes, oauthErr := requestedScopes(req.Scope, payload.Scopes)
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+
+	user, err := p.store.GetUserByValue(ctx, session.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, invalidGrant("user of the session does not exist")
+		}
+		return nil, err
+	}
+	if user.Status.String != util.StatusActive {
+		return nil, invalidGrant("user is not active")
+	}
+
+	claims, err := p.userClaims(ctx, client.ID, user, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, refreshPayload, err := p.createTokens(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.store.RotateSessionTx(ctx, db.RotateSessionTxParams{
+		OldSessionID: session.ID,
+		NewSession: db.CreateSessionParams{
+			ID:           refreshPayload.ID,
+			FamilyID:     session.FamilyID,
+			Username:     session.Username,
+			RefreshToken: rsp.RefreshToken,
+			UserAgent:    req.UserAgent,
+			ClientIp:     req.ClientIP,
+			IsBlocked:    false,
+			ExpiresAt:    refreshPayload.ExpiredAt,
+		},
+	})
+	if err != nil {
+		// Another request rotated the same refresh token in the meantime
+		if errors.Is(err, db.ErrSessionAlreadyRotated) {
+			p.revokeSessionFamily(ctx, client.ID, session)
+			return nil, invalidGrant("refresh token has already been used")
+		}
+		return nil, err
+	}
+	return rsp, nil
+}
+
+// issueClientToken issues an access token of the client credentials grant. The token acts on behalf of the
+// client itself, so it has no user and no refresh token, and only confidential clients may request it.
+func (p *Provider) issueClientToken(client db.UserSvcOAuthClient, req TokenRequest) (*TokenResponse, error) {
+	if client.SecretHash == "" {
+		return nil, unauthorizedClient("public clients may not use the client credentials grant")
+	}
+
+	scopes, oauthErr := requestedScopes(req.Scope, client.Scopes)
+	if oauthErr != nil {
+		return nil, oauthErr
+	}
+
+	// There is no user whose claims the OpenID Connect scopes could grant
+	granted := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !isOpenIDScope(scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	accessToken, _, err := p.createAccessToken(token.Claims{ClientID: client.ID, Scopes: granted})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(p.accessTokenDuration.Seconds()),
+		Scope:       strings.Join(granted, " "),
+	}, nil
+}
+
+// userClaims returns the claims of the tokens a client gets on behalf of a user. The scopes are limited to the
+// permissions the role of the user currently grants, so that a client never gets more than the user has.
+func (p *Provider) userClaims(ctx context.Context, clientID string, user db.UserSvcUser, scopes []string) (token.Claims, error) {
+	subject, err := p.policy.SubjectOf(ctx, user)
+	if err != nil {
+		return token.Claims{}, err
+	}
+
+	granted := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if isOpenIDScope(scope) || subject.HasPermission(scope) {
+			granted = append(granted, scope)
+		}
+	}
+
+	return token.Claims{
+		UserID:   subject.UserID,
+		Username: subject.Username,
+		RoleID:   subject.RoleID,
+		Scopes:   granted,
+		ClientID: clientID,
+	}, nil
+}
+
+// createTokens creates the access and refresh token of a user and returns the payload of the refresh token for its session.
+func (p *Provider) createTokens(claims token.Claims) (*TokenResponse, *token.Payload, error) {
+	accessToken, _, err := p.createAccessToken(claims)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refreshToken, refreshPayload, err := p.tokenMaker.CreateRefreshToken(claims, p.refreshTokenDuration)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(p.accessTokenDuration.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(claims.Scopes, " "),
+	}, refreshPayload, nil
+}
+
+// createAccessToken creates a public access token if signing keys are configured, so that other services can
+// verify it without the symmetric key, and a local access token otherwise.
+func (p *Provider) createAccessToken(claims token.Claims) (string, *token.Payload, error) {
+	if len(p.tokenMaker.PublicKeys()) > 0 {
+		return p.tokenMaker.CreatePublicToken(claims, p.accessTokenDuration)
+	}
+	return p.tokenMaker.CreateLocalToken(claims, p.accessTokenDuration)
+}
+
+// issuesIDTokens reports whether the provider has a signing key for ID tokens, i.e. whether it is an OpenID provider.
+func (p *Provider) issuesIDTokens() bool {
+	return len(p.tokenMaker.PublicKeys()) > 0
+}
+
+// revokeSessionFamily blocks all sessions of the token family of the given session and reports the reuse of its refresh token.
+func (p *Provider) revokeSessionFamily(ctx context.Context, clientID string, session db.UserSvcSession) {
+	revoked, err := p.store.BlockSessionFamily(ctx, session.FamilyID)
+
+	event := log.Warn()
+	if err != nil {
+		event = log.Error().Err(err)
+	}
+	event.Str("event", "refresh_token_reuse").
+		Str("username", session.Username).
+		Str("client_id", clientID).
+		Str("session_id", session.ID.String()).
+		Str("family_id", session.FamilyID.String()).
+		Int64("revoked_sessions", revoked).
+		Msg("security: reuse of a rotated OAuth refresh token detected, revoking token family")
+}
+
+// requestedScopes returns the scopes of a request, which must be among the allowed scopes.
+// Requests without scopes get all allowed scopes.
+func requestedScopes(scope string, allowed []string) ([]string, *Error) {
+	scopes := parseScopes(scope)
+	if len(scopes) == 0 {
+		return append([]string{}, allowed...), nil
+	}
+
+	for _, scope := range scopes {
+		if !contains(allowed, scope) {
+			return nil, invalidScope("scope '" + scope + "' is not allowed")
+		}
+	}
+	return scopes, nil
+}
+
+func isOpenIDScope(scope string) bool {
+	return scope == ScopeOpenID || scope == ScopeProfile || scope == ScopeEmail
+}
+
+// redirectURL adds the parameters of the response, or the error, together with the state to the redirect URI.
+func redirectURL(redirectURI string, params url.Values, state string, oauthErr *Error) string {
+	if oauthErr != nil {
+		params.Set("error", oauthErr.Code)
+		if oauthErr.Description != "" {
+			params.Set("error_description", oauthErr.Description)
+		}
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	target.RawQuery = query.Encode()
+	return target.String()
+}
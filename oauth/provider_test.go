@@ -0,0 +1,577 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/lockout"
+	"github.com/Streamfair/streamfair_user_svc/mfa"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+const testRedirectURI = "https://app.example.com/callback"
+
+func newTestProvider(t *testing.T, store *mock_db.MockStore) (*Provider, token.Maker) {
+	tokenMaker, err := token.NewLocalPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+	return newTestProviderWithMaker(t, store, tokenMaker), tokenMaker
+}
+
+// newTestOpenIDProvider creates a provider whose token maker has a signing key, so that it issues ID tokens.
+func newTestOpenIDProvider(t *testing.T, store *mock_db.MockStore) (*Provider, token.Maker) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	keyring, err := token.NewKeyringFromKeys(util.RandomString(32), nil)
+	require.NoError(t, err)
+	tokenMaker, err := token.NewPublicPasetoMaker(keyring, []string{hex.EncodeToString(privateKey.Seed())}, token.DefaultIssuer, token.DefaultAudience)
+	require.NoError(t, err)
+	return newTestProviderWithMaker(t, store, tokenMaker), tokenMaker
+}
+
+func newTestProviderWithMaker(t *testing.T, store *mock_db.MockStore, tokenMaker token.Maker) *Provider {
+	config := util.Config{
+		OAuthIssuerURL:       "https://auth.example.com/",
+		OAuthCodeDuration:    time.Minute,
+		AccessTokenDuration:  time.Minute,
+		RefreshTokenDuration: time.Hour,
+		LoginMaxAttempts:     3,
+		LoginLockoutDuration: time.Minute,
+		LoginAttemptWindow:   time.Hour,
+	}
+	mfaManager, err := mfa.NewManager(config, store)
+	require.NoError(t, err)
+
+	return NewProvider(config, store, tokenMaker, policy.NewPolicy(store), lockout.NewGuard(config, store), mfaManager)
+}
+
+func newTestClient(secret string) db.UserSvcOAuthClient {
+	client := db.UserSvcOAuthClient{
+		ID:           util.RandomString(8),
+		Name:         "app",
+		RedirectUris: []string{testRedirectURI},
+		GrantTypes:   []string{GrantTypeAuthorizationCode, GrantTypeRefreshToken, GrantTypeClientCredentials},
+		Scopes:       []string{ScopeOpenID, ScopeEmail, policy.PermissionReadUsers, policy.PermissionListUsers},
+	}
+	if secret != "" {
+		client.SecretHash = util.HashSecretCode(secret)
+	}
+	return client
+}
+
+func newTestUser() db.UserSvcUser {
+	return db.UserSvcUser{
+		ID:       util.RandomInt(1, 1000),
+		Username: util.RandomUsername(),
+		FullName: util.RandomString(10),
+		Email:    util.RandomEmail(),
+		RoleID:   util.ConvertToInt8(policy.RoleModerator),
+		Status:   util.ConvertToText(util.StatusActive),
+	}
+}
+
+func requireOAuthError(t *testing.T, err error, code string) {
+	var oauthErr *Error
+	require.True(t, errors.As(err, &oauthErr), "expected an OAuth error, got %v", err)
+	require.Equal(t, code, oauthErr.Code)
+}
+
+func TestDiscovery(t *testing.T) {
+	provider, _ := newTestProvider(t, nil)
+
+	metadata := provider.Discovery()
+	require.Equal(t, "https://auth.example.com", metadata.Issuer)
+	require.Equal(t, "https://auth.example.com/oauth/token", metadata.TokenEndpoint)
+	require.Equal(t, "https://auth.example.com/.well-known/jwks.json", metadata.JwksURI)
+	require.Equal(t, []string{CodeChallengeMethodS256}, metadata.CodeChallengeMethodsSupported)
+
+	// Without a signing key for ID tokens the provider isn't advertised as an OpenID provider
+	require.Empty(t, metadata.IDTokenSigningAlgValuesSupported)
+	require.Empty(t, metadata.ScopesSupported)
+	require.Empty(t, metadata.UserinfoEndpoint)
+
+	provider, _ = newTestOpenIDProvider(t, nil)
+	metadata = provider.Discovery()
+	require.Equal(t, []string{"EdDSA"}, metadata.IDTokenSigningAlgValuesSupported)
+	require.Contains(t, metadata.ScopesSupported, ScopeOpenID)
+	require.Equal(t, "https://auth.example.com/oauth/userinfo", metadata.UserinfoEndpoint)
+}
+
+func TestAuthorize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	provider, _ := newTestProvider(t, store)
+
+	client := newTestClient("")
+	username := util.RandomUsername()
+	challenge := CodeChallenge(util.RandomString(43))
+
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).AnyTimes().Return(client, nil)
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Any()).AnyTimes().Return(db.UserSvcOAuthClient{}, pgx.ErrNoRows)
+
+	var codeHash string
+	store.EXPECT().
+		CreateOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateOAuthAuthorizationCodeParams) (db.UserSvcOAuthAuthorizationCode, error) {
+			require.Equal(t, client.ID, arg.ClientID)
+			require.Equal(t, username, arg.Username)
+			require.Equal(t, testRedirectURI, arg.RedirectUri)
+			require.Equal(t, []string{ScopeOpenID, policy.PermissionReadUsers}, arg.Scopes)
+			require.Equal(t, challenge, arg.CodeChallenge)
+			require.WithinDuration(t, time.Now().Add(time.Minute), arg.ExpiredAt, time.Second)
+			codeHash = arg.CodeHash
+			return db.UserSvcOAuthAuthorizationCode{}, nil
+		})
+
+	req := AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            client.ID,
+		Scope:               ScopeOpenID + " " + policy.PermissionReadUsers,
+		State:               "xyz",
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: CodeChallengeMethodS256,
+	}
+	location, err := provider.Authorize(context.Background(), username, req)
+	require.NoError(t, err)
+
+	redirect, err := url.Parse(location)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(location, testRedirectURI+"?"))
+	require.Equal(t, "xyz", redirect.Query().Get("state"))
+	require.Equal(t, codeHash, util.HashSecretCode(redirect.Query().Get("code")))
+
+	// Requests that can't be redirected to the client fail
+	invalid := req
+	invalid.ClientID = "unknown"
+	_, err = provider.Authorize(context.Background(), username, invalid)
+	requireOAuthError(t, err, "invalid_request")
+
+	invalid = req
+	invalid.RedirectURI = "https://attacker.example.com/callback"
+	_, err = provider.Authorize(context.Background(), username, invalid)
+	requireOAuthError(t, err, "invalid_request")
+
+	// Other errors are reported to the client
+	testCases := []struct {
+		name   string
+		update func(req *AuthorizeRequest)
+		code   string
+	}{
+		{"ResponseType", func(req *AuthorizeRequest) { req.ResponseType = "token" }, "unsupported_response_type"},
+		{"NoChallenge", func(req *AuthorizeRequest) { req.CodeChallenge = "" }, "invalid_request"},
+		{"PlainChallenge", func(req *AuthorizeRequest) { req.CodeChallengeMethod = "plain" }, "invalid_request"},
+		{"Scope", func(req *AuthorizeRequest) { req.Scope = policy.PermissionDeleteUsers }, "invalid_scope"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := req
+			tc.update(&req)
+
+			location, err := provider.Authorize(context.Background(), username, req)
+			require.NoError(t, err)
+			redirect, err := url.Parse(location)
+			require.NoError(t, err)
+			require.Equal(t, tc.code, redirect.Query().Get("error"))
+			require.Equal(t, "xyz", redirect.Query().Get("state"))
+			require.Empty(t, redirect.Query().Get("code"))
+		})
+	}
+}
+
+func TestTokenAuthorizationCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	provider, tokenMaker := newTestProvider(t, store)
+
+	client := newTestClient("")
+	user := newTestUser()
+	verifier := util.RandomString(43)
+	code := util.RandomString(32)
+
+	authorizationCode := db.UserSvcOAuthAuthorizationCode{
+		CodeHash:      util.HashSecretCode(code),
+		ClientID:      client.ID,
+		Username:      user.Username,
+		RedirectUri:   testRedirectURI,
+		Scopes:        []string{ScopeOpenID, policy.PermissionReadUsers, policy.PermissionListUsers},
+		CodeChallenge: CodeChallenge(verifier),
+		ExpiredAt:     time.Now().Add(time.Minute),
+	}
+
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).AnyTimes().Return(client, nil)
+	store.EXPECT().
+		UseOAuthAuthorizationCode(gomock.Any(), gomock.Eq(authorizationCode.CodeHash)).
+		Times(1).
+		Return(authorizationCode, nil)
+	store.EXPECT().
+		GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	// The user lost the permission to list users since the authorization
+	store.EXPECT().
+		ListPermissionsByRoleId(gomock.Any(), gomock.Eq(policy.RoleModerator)).
+		Times(1).
+		Return([]db.UserSvcPermission{{Name: policy.PermissionReadUsers}}, nil)
+
+	var session db.CreateSessionParams
+	store.EXPECT().
+		CreateSession(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateSessionParams) (db.UserSvcSession, error) {
+			session = arg
+			return db.UserSvcSession{}, nil
+		})
+
+	rsp, err := provider.Token(context.Background(), TokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		ClientID:     client.ID,
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: verifier,
+		UserAgent:    "app",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "Bearer", rsp.TokenType)
+	require.Equal(t, int64(60), rsp.ExpiresIn)
+	require.Equal(t, ScopeOpenID+" "+policy.PermissionReadUsers, rsp.Scope)
+	require.Empty(t, rsp.IDToken)
+
+	payload, err := tokenMaker.VerifyLocalToken(rsp.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, client.ID, payload.ClientID)
+	require.Equal(t, user.Username, payload.Username)
+	require.Equal(t, []string{ScopeOpenID, policy.PermissionReadUsers}, payload.Scopes)
+
+	refreshPayload, err := tokenMaker.VerifyRefreshToken(rsp.RefreshToken)
+	require.NoError(t, err)
+	require.Equal(t, refreshPayload.ID, session.ID)
+	require.Equal(t, refreshPayload.ID, session.FamilyID)
+	require.Equal(t, user.Username, session.Username)
+	require.Equal(t, rsp.RefreshToken, session.RefreshToken)
+	require.Equal(t, "app", session.UserAgent)
+}
+
+func TestTokenAuthorizationCodeIDToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	provider, tokenMaker := newTestOpenIDProvider(t, store)
+
+	client := newTestClient("")
+	user := newTestUser()
+	verifier := util.RandomString(43)
+	code := util.RandomString(32)
+
+	authorizationCode := db.UserSvcOAuthAuthorizationCode{
+		CodeHash:      util.HashSecretCode(code),
+		ClientID:      client.ID,
+		Username:      user.Username,
+		RedirectUri:   testRedirectURI,
+		Scopes:        []string{ScopeOpenID},
+		CodeChallenge: CodeChallenge(verifier),
+		CreatedAt:     time.Now().Add(-time.Second),
+		ExpiredAt:     time.Now().Add(time.Minute),
+		Nonce:         "n-0S6_WzA2Mj",
+	}
+
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).AnyTimes().Return(client, nil)
+	store.EXPECT().UseOAuthAuthorizationCode(gomock.Any(), gomock.Any()).Times(1).Return(authorizationCode, nil)
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().ListPermissionsByRoleId(gomock.Any(), gomock.Any()).Times(1).Return([]db.UserSvcPermission{}, nil)
+	store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcSession{}, nil)
+
+	rsp, err := provider.Token(context.Background(), TokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		ClientID:     client.ID,
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: verifier,
+	})
+	require.NoError(t, err)
+
+	// The ID token is signed with the published key and names the user for the client
+	parts := strings.Split(rsp.IDToken, ".")
+	require.Len(t, parts, 3)
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	publicKey := tokenMaker.PublicKeys()[0].Key
+	require.True(t, ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]), signature))
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var claims struct {
+		Issuer   string `json:"iss"`
+		Subject  string `json:"sub"`
+		Audience string `json:"aud"`
+		AuthTime int64  `json:"auth_time"`
+		Nonce    string `json:"nonce"`
+	}
+	require.NoError(t, json.Unmarshal(data, &claims))
+	require.Equal(t, "https://auth.example.com", claims.Issuer)
+	require.Equal(t, strconv.FormatInt(user.ID, 10), claims.Subject)
+	require.Equal(t, client.ID, claims.Audience)
+	require.Equal(t, authorizationCode.CreatedAt.Unix(), claims.AuthTime)
+	require.Equal(t, authorizationCode.Nonce, claims.Nonce)
+}
+
+func TestTokenAuthorizationCodeInvalid(t *testing.T) {
+	client := newTestClient("")
+	verifier := util.RandomString(43)
+
+	testCases := []struct {
+		name   string
+		update func(code *db.UserSvcOAuthAuthorizationCode, req *TokenRequest)
+	}{
+		{"Verifier", func(code *db.UserSvcOAuthAuthorizationCode, req *TokenRequest) {
+			req.CodeVerifier = util.RandomString(43)
+		}},
+		{"RedirectURI", func(code *db.UserSvcOAuthAuthorizationCode, req *TokenRequest) {
+			req.RedirectURI = "https://other.example.com"
+		}},
+		{"MissingRedirectURI", func(code *db.UserSvcOAuthAuthorizationCode, req *TokenRequest) {
+			req.RedirectURI = ""
+		}},
+		{"OtherClient", func(code *db.UserSvcOAuthAuthorizationCode, req *TokenRequest) { code.ClientID = "other" }},
+		{"Expired", func(code *db.UserSvcOAuthAuthorizationCode, req *TokenRequest) {
+			code.ExpiredAt = time.Now().Add(-time.Second)
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			store := mock_db.NewMockStore(ctrl)
+			provider, _ := newTestProvider(t, store)
+
+			code := db.UserSvcOAuthAuthorizationCode{
+				ClientID:      client.ID,
+				RedirectUri:   testRedirectURI,
+				CodeChallenge: CodeChallenge(verifier),
+				ExpiredAt:     time.Now().Add(time.Minute),
+			}
+			req := TokenRequest{
+				GrantType:    GrantTypeAuthorizationCode,
+				ClientID:     client.ID,
+				Code:         util.RandomString(32),
+				RedirectURI:  testRedirectURI,
+				CodeVerifier: verifier,
+			}
+			tc.update(&code, &req)
+
+			store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).Times(1).Return(client, nil)
+			store.EXPECT().UseOAuthAuthorizationCode(gomock.Any(), gomock.Any()).Times(1).Return(code, nil)
+			store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+
+			_, err := provider.Token(context.Background(), req)
+			requireOAuthError(t, err, "invalid_grant")
+		})
+	}
+}
+
+func TestTokenAuthorizationCodeInactiveUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	provider, _ := newTestProvider(t, store)
+
+	client := newTestClient("")
+	user := newTestUser()
+	user.Status = util.ConvertToText(util.StatusInactive)
+	verifier := util.RandomString(43)
+
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).Times(1).Return(client, nil)
+	store.EXPECT().
+		UseOAuthAuthorizationCode(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.UserSvcOAuthAuthorizationCode{
+			ClientID:      client.ID,
+			Username:      user.Username,
+			RedirectUri:   testRedirectURI,
+			CodeChallenge: CodeChallenge(verifier),
+			ExpiredAt:     time.Now().Add(time.Minute),
+		}, nil)
+	// The user was deactivated after the authorization
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).Times(0)
+
+	_, err := provider.Token(context.Background(), TokenRequest{
+		GrantType:    GrantTypeAuthorizationCode,
+		ClientID:     client.ID,
+		Code:         util.RandomString(32),
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: verifier,
+	})
+	requireOAuthError(t, err, "invalid_grant")
+}
+
+func TestTokenRefreshReuse(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	provider, tokenMaker := newTestProvider(t, store)
+
+	secret := util.RandomString(32)
+	client := newTestClient(secret)
+	username := util.RandomUsername()
+
+	refreshToken, payload, err := tokenMaker.CreateRefreshToken(token.Claims{Username: username, ClientID: client.ID}, time.Hour)
+	require.NoError(t, err)
+
+	session := db.UserSvcSession{
+		ID:           payload.ID,
+		FamilyID:     uuid.New(),
+		Username:     username,
+		RefreshToken: refreshToken,
+		ReplacedBy:   pgtype.UUID{Bytes: uuid.New(), Valid: true},
+		ExpiresAt:    payload.ExpiredAt,
+	}
+
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).Times(1).Return(client, nil)
+	store.EXPECT().GetSession(gomock.Any(), gomock.Eq(payload.ID)).Times(1).Return(session, nil)
+	store.EXPECT().BlockSessionFamily(gomock.Any(), gomock.Eq(session.FamilyID)).Times(1).Return(int64(2), nil)
+	store.EXPECT().RotateSessionTx(gomock.Any(), gomock.Any()).Times(0)
+
+	_, err = provider.Token(context.Background(), TokenRequest{
+		GrantType:    GrantTypeRefreshToken,
+		ClientID:     client.ID,
+		ClientSecret: secret,
+		RefreshToken: refreshToken,
+	})
+	requireOAuthError(t, err, "invalid_grant")
+}
+
+func TestTokenClientCredentials(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	provider, tokenMaker := newTestProvider(t, store)
+
+	secret := util.RandomString(32)
+	client := newTestClient(secret)
+	publicClient := newTestClient("")
+
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(client.ID)).AnyTimes().Return(client, nil)
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Eq(publicClient.ID)).AnyTimes().Return(publicClient, nil)
+	store.EXPECT().GetOAuthClient(gomock.Any(), gomock.Any()).AnyTimes().Return(db.UserSvcOAuthClient{}, pgx.ErrNoRows)
+
+	rsp, err := provider.Token(context.Background(), TokenRequest{
+		GrantType:    GrantTypeClientCredentials,
+		ClientID:     client.ID,
+		ClientSecret: secret,
+	})
+	require.NoError(t, err)
+	require.Empty(t, rsp.RefreshToken)
+	require.Equal(t, policy.PermissionReadUsers+" "+policy.PermissionListUsers, rsp.Scope)
+
+	payload, err := tokenMaker.VerifyLocalToken(rsp.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, client.ID, payload.ClientID)
+	require.Empty(t, payload.Username)
+	require.Equal(t, []string{policy.PermissionReadUsers, policy.PermissionListUsers}, payload.Scopes)
+
+	testCases := []struct {
+		name string
+		req  TokenRequest
+		code string
+	}{
+		{"WrongSecret", TokenRequest{ClientID: client.ID, ClientSecret: "wrong"}, "invalid_client"},
+		{"NoSecret", TokenRequest{ClientID: client.ID}, "invalid_client"},
+		{"UnknownClient", TokenRequest{ClientID: "unknown", ClientSecret: secret}, "invalid_client"},
+		{"PublicClient", TokenRequest{ClientID: publicClient.ID}, "unauthorized_client"},
+		{"Scope", TokenRequest{ClientID: client.ID, ClientSecret: secret, Scope: policy.PermissionDeleteUsers}, "invalid_scope"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.req.GrantType = GrantTypeClientCredentials
+			_, err := provider.Token(context.Background(), tc.req)
+			requireOAuthError(t, err, tc.code)
+		})
+	}
+
+	_, err = provider.Token(context.Background(), TokenRequest{GrantType: "password", ClientID: client.ID, ClientSecret: secret})
+	requireOAuthError(t, err, "unsupported_grant_type")
+}
+
+func TestUserInfo(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	provider, _ := newTestProvider(t, store)
+
+	user := newTestUser()
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(2).Return(user, nil)
+
+	info, err := provider.UserInfo(context.Background(), &token.Payload{Username: user.Username, Scopes: []string{ScopeOpenID}})
+	require.NoError(t, err)
+	require.Equal(t, UserInfo{Subject: strconv.FormatInt(user.ID, 10)}, info)
+
+	info, err = provider.UserInfo(context.Background(), &token.Payload{
+		Username: user.Username,
+		Scopes:   []string{ScopeOpenID, ScopeProfile, ScopeEmail},
+	})
+	require.NoError(t, err)
+	require.Equal(t, user.Username, info.PreferredUsername)
+	require.Equal(t, user.FullName, info.Name)
+	require.Equal(t, user.Email, info.Email)
+	require.False(t, *info.EmailVerified)
+
+	// Active users have not necessarily verified their email address
+	user.EmailVerifiedAt = time.Now()
+	store.EXPECT().GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	info, err = provider.UserInfo(context.Background(), &token.Payload{Username: user.Username, Scopes: []string{ScopeOpenID, ScopeEmail}})
+	require.NoError(t, err)
+	require.True(t, *info.EmailVerified)
+
+	_, err = provider.UserInfo(context.Background(), &token.Payload{Username: user.Username})
+	requireOAuthError(t, err, "insufficient_scope")
+}
+
+func TestParseTokenRequest(t *testing.T) {
+	form := url.Values{"grant_type": {GrantTypeClientCredentials}, "scope": {"a b"}}
+	req, err := http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("my%20client", "se%2Bcret")
+
+	tokenRequest, err := ParseTokenRequest(req)
+	require.NoError(t, err)
+	require.Equal(t, GrantTypeClientCredentials, tokenRequest.GrantType)
+	require.Equal(t, "my client", tokenRequest.ClientID)
+	require.Equal(t, "se+cret", tokenRequest.ClientSecret)
+	require.Equal(t, "a b", tokenRequest.Scope)
+
+	// Clients may only use one authentication method
+	form.Set("client_secret", "secret")
+	req, err = http.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("client", "secret")
+
+	_, err = ParseTokenRequest(req)
+	requireOAuthError(t, err, "invalid_request")
+}
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// Example of RFC 7636, appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	require.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", CodeChallenge(verifier))
+	require.True(t, verifyCodeChallenge(CodeChallenge(verifier), verifier))
+	require.False(t, verifyCodeChallenge(CodeChallenge(verifier), "short"))
+	require.False(t, verifyCodeChallenge(CodeChallenge(verifier), util.RandomString(43)))
+}
@@ -0,0 +1,117 @@
+package passwordreset
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/mail"
+	"github.com/Streamfair/streamfair_user_svc/revocation"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/rs/zerolog/log"
+)
+
+// RequestedMessage is returned whether or not an account exists for the email address,
+// so that the endpoint can't be used to find out which email addresses are registered.
+const RequestedMessage = "if an account exists for this email address, a password reset link has been sent"
+
+// Resetter lets users who forgot their password set a new one with a single-use token sent to their email address.
+type Resetter struct {
+	store          db.Store
+	mailer         mail.EmailSender
+	revocations    *revocation.List
+	resetURL       string
+	duration       time.Duration
+	argon2idParams util.Argon2idParams
+
+	// pending tracks the requests whose email is still being sent.
+	pending sync.WaitGroup
+}
+
+// NewResetter creates a new Resetter from the password reset settings of the configuration.
+func NewResetter(config util.Config, store db.Store, mailer mail.EmailSender, revocations *revocation.List) *Resetter {
+	return &Resetter{
+		store:          store,
+		mailer:         mailer,
+		revocations:    revocations,
+		resetURL:       config.PasswordResetURL,
+		duration:       config.PasswordResetDuration,
+		argon2idParams: config.Argon2idParams(),
+	}
+}
+
+// Request sends a password reset link to the email address if an account exists for it. The token is created for
+// every address and the account is looked up after the response, so that neither the response nor its timing
+// reveal whether the address is registered. Failures after the response are only logged.
+func (r *Resetter) Request(ctx context.Context, email string) error {
+	token, err := util.GenerateSecretCode()
+	if err != nil {
+		return err
+	}
+	tokenHash := util.HashSecretCode(token)
+
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		r.send(context.WithoutCancel(ctx), email, token, tokenHash)
+	}()
+	return nil
+}
+
+// send stores the token for the account of the email address and sends it the reset link.
+func (r *Resetter) send(ctx context.Context, email, token, tokenHash string) {
+	user, err := r.store.GetUserByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			log.Error().Err(err).Msg("failed to get user by email")
+		}
+		return
+	}
+
+	_, err = r.store.CreatePasswordReset(ctx, db.CreatePasswordResetParams{
+		Username:  user.Username,
+		TokenHash: tokenHash,
+		ExpiredAt: time.Now().Add(r.duration),
+	})
+	if err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to create password reset")
+		return
+	}
+
+	err = mail.SendPasswordResetEmail(r.mailer, r.resetURL, user.FullName, user.Email, token)
+	if err != nil {
+		log.Error().Err(err).Str("username", user.Username).Msg("failed to send password reset email")
+	}
+}
+
+// Wait blocks until the emails of all requests have been sent, e.g. before the server shuts down.
+func (r *Resetter) Wait() {
+	r.pending.Wait()
+}
+
+// Reset sets a new password using a password reset token and signs the user out of all devices.
+// It returns db.ErrInvalidPasswordReset if the token is unknown, expired or has been used.
+func (r *Resetter) Reset(ctx context.Context, token, newPassword string) (db.ResetPasswordTxResult, error) {
+	hashedPassword, err := util.HashPassword(newPassword, r.argon2idParams)
+	if err != nil {
+		return db.ResetPasswordTxResult{}, err
+	}
+
+	result, err := r.store.ResetPasswordTx(ctx, db.ResetPasswordTxParams{
+		TokenHash:    util.HashSecretCode(token),
+		PasswordHash: hashedPassword,
+	})
+	if err != nil {
+		return db.ResetPasswordTxResult{}, err
+	}
+
+	// Access tokens issued before the reset are revoked along with the sessions. The password was reset already,
+	// so a failure is logged and the access tokens expire on their own.
+	if err := r.revocations.RevokeUserTokens(ctx, result.User.Username); err != nil {
+		log.Error().Err(err).Str("username", result.User.Username).Msg("failed to revoke access tokens after password reset")
+	}
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package passwordreset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/mail"
+	"github.com/Streamfair/streamfair_user_svc/revocation"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestResetter(t *testing.T, store *mock_db.MockStore) (*Resetter, *mail.InMemorySender) {
+	config := util.Config{
+		PasswordResetURL:        "https://localhost:8080/reset_password",
+		PasswordResetDuration:   time.Hour,
+		TokenRevocationInterval: time.Minute,
+	}
+	store.EXPECT().ListRevokedTokens(gomock.Any()).AnyTimes().Return([]db.UserSvcRevokedToken{}, nil)
+	store.EXPECT().ListTokenWatermarks(gomock.Any(), gomock.Any()).AnyTimes().Return([]db.UserSvcTokenWatermark{}, nil)
+
+	revocations := revocation.NewList(config, store)
+	require.NoError(t, revocations.Refresh(context.Background()))
+
+	mailer := mail.NewInMemorySender()
+	return NewResetter(config, store, mailer, revocations), mailer
+}
+
+func TestRequest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	resetter, mailer := newTestResetter(t, store)
+
+	user := db.UserSvcUser{Username: util.RandomUsername(), Email: util.RandomEmail()}
+	unknownEmail := util.RandomEmail()
+
+	// The account is looked up after the request returned
+	lookup := make(chan struct{})
+	store.EXPECT().
+		GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).
+		Times(1).
+		DoAndReturn(func(_ context.Context, _ string) (db.UserSvcUser, error) {
+			<-lookup
+			return user, nil
+		})
+	store.EXPECT().
+		GetUserByEmail(gomock.Any(), gomock.Eq(unknownEmail)).
+		Times(1).
+		Return(db.UserSvcUser{}, pgx.ErrNoRows)
+	store.EXPECT().
+		CreatePasswordReset(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreatePasswordResetParams) (db.UserSvcPasswordReset, error) {
+			require.Equal(t, user.Username, arg.Username)
+			require.Len(t, arg.TokenHash, 64)
+			require.True(t, arg.ExpiredAt.After(time.Now()))
+			return db.UserSvcPasswordReset{Username: arg.Username, TokenHash: arg.TokenHash}, nil
+		})
+
+	// A cancelled request still sends the email
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, resetter.Request(ctx, user.Email))
+	cancel()
+	require.NoError(t, resetter.Request(context.Background(), unknownEmail))
+	close(lookup)
+
+	resetter.Wait()
+	emails := mailer.Emails()
+	require.Len(t, emails, 1)
+	require.Equal(t, []string{user.Email}, emails[0].To)
+}
+
+func TestReset(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	resetter, _ := newTestResetter(t, store)
+
+	resetToken, err := util.GenerateSecretCode()
+	require.NoError(t, err)
+	password := util.RandomString(12)
+	user := db.UserSvcUser{Username: util.RandomUsername()}
+
+	store.EXPECT().
+		ResetPasswordTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.ResetPasswordTxParams) (db.ResetPasswordTxResult, error) {
+			require.Equal(t, util.HashSecretCode(resetToken), arg.TokenHash)
+			require.NoError(t, util.ComparePassword(arg.PasswordHash, "", password))
+			return db.ResetPasswordTxResult{User: user, RevokedSessions: 2}, nil
+		})
+	store.EXPECT().
+		SetTokenWatermark(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.SetTokenWatermarkParams) (db.UserSvcTokenWatermark, error) {
+			return db.UserSvcTokenWatermark{Username: arg.Username, NotBefore: arg.NotBefore}, nil
+		})
+
+	issuedAt := time.Now()
+	result, err := resetter.Reset(context.Background(), resetToken, password)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), result.RevokedSessions)
+
+	// The tokens issued before the reset are revoked
+	payload := &token.Payload{Username: user.Username, IssuedAt: issuedAt}
+	require.ErrorIs(t, resetter.revocations.Check(context.Background(), payload), revocation.ErrTokenRevoked)
+
+	store.EXPECT().
+		ResetPasswordTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.ResetPasswordTxResult{}, db.ErrInvalidPasswordReset)
+	_, err = resetter.Reset(context.Background(), resetToken, password)
+	require.ErrorIs(t, err, db.ErrInvalidPasswordReset)
+}
@@ -0,0 +1,199 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+)
+
+// Roles seeded by the database migrations.
+const (
+	RoleAdmin     int64 = 1
+	RoleModerator int64 = 2
+	RoleUser      int64 = 3
+)
+
+// Permissions seeded by the database migrations.
+const (
+	PermissionReadUsers   = "users:read"
+	PermissionListUsers   = "users:list"
+	PermissionUpdateUsers = "users:update"
+	PermissionDeleteUsers = "users:delete"
+	PermissionAssignRoles = "roles:assign"
+
+	PermissionManageSessions = "sessions:manage"
+	PermissionUnlockUsers    = "users:unlock"
+)
+
+// ErrPermissionDenied is returned when a subject is not allowed to perform an operation.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// Subject is the authenticated user on whose behalf a request is made,
+// together with the permissions granted by its role.
+type Subject struct {
+	UserID      int64
+	Username    string
+	RoleID      int64
+	permissions map[string]bool
+	// delegated is set for OAuth clients and API keys, which act on behalf of the user with limited scopes.
+	delegated bool
+}
+
+// NewSubject creates a subject with the given permissions.
+func NewSubject(userID int64, username string, roleID int64, permissions ...string) *Subject {
+	subject := &Subject{
+		UserID:      userID,
+		Username:    username,
+		RoleID:      roleID,
+		permissions: make(map[string]bool, len(permissions)),
+	}
+	for _, permission := range permissions {
+		subject.permissions[permission] = true
+	}
+	return subject
+}
+
+// Permissions returns the permissions granted by the role of the subject in alphabetical order.
+func (s *Subject) Permissions() []string {
+	permissions := make([]string, 0, len(s.permissions))
+	for permission := range s.permissions {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// WithScopes returns a copy of the subject that only keeps the permissions among the given scopes,
+// so that the token of an OAuth client can't be used beyond the scopes granted to the client.
+// The copy is delegated: changes to the own account need the scope as well.
+func (s *Subject) WithScopes(scopes []string) *Subject {
+	granted := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if s.permissions[scope] {
+			granted = append(granted, scope)
+		}
+	}
+	subject := NewSubject(s.UserID, s.Username, s.RoleID, granted...)
+	subject.delegated = true
+	return subject
+}
+
+// IsDelegated reports whether the subject acts through an OAuth client or an API key instead of a login.
+func (s *Subject) IsDelegated() bool {
+	return s.delegated
+}
+
+// HasPermission reports whether the role of the subject grants the given permission.
+func (s *Subject) HasPermission(permission string) bool {
+	return s.permissions[permission]
+}
+
+// IsSelf reports whether the given user ID belongs to the subject.
+func (s *Subject) IsSelf(userID int64) bool {
+	return s.UserID == userID
+}
+
+// CanReadUser checks if the subject may read the user with the given ID.
+func (s *Subject) CanReadUser(userID int64) error {
+	return s.allowSelfOr(userID, PermissionReadUsers)
+}
+
+// CanListUsers checks if the subject may list all users.
+func (s *Subject) CanListUsers() error {
+	return s.require(PermissionListUsers)
+}
+
+// CanUpdateUser checks if the subject may update the user with the given ID.
+// Delegated subjects need the permission for their own account too.
+func (s *Subject) CanUpdateUser(userID int64) error {
+	if s.delegated {
+		return s.require(PermissionUpdateUsers)
+	}
+	return s.allowSelfOr(userID, PermissionUpdateUsers)
+}
+
+// CanDeleteUsers checks if the subject may delete users, including its own account.
+func (s *Subject) CanDeleteUsers() error {
+	return s.require(PermissionDeleteUsers)
+}
+
+// CanAssignRole checks if the subject may change the role of a user.
+func (s *Subject) CanAssignRole() error {
+	return s.require(PermissionAssignRoles)
+}
+
+// CanChangeStatus checks if the subject may activate or deactivate users. Users can't change the status of their
+// own account, it is activated by verifying the email address.
+func (s *Subject) CanChangeStatus() error {
+	return s.require(PermissionUpdateUsers)
+}
+
+// CanManageSessions checks if the subject may list and revoke the sessions of the user with the given username.
+// Delegated subjects need the permission for their own sessions too.
+func (s *Subject) CanManageSessions(username string) error {
+	if s.Username == username && !s.delegated {
+		return nil
+	}
+	return s.require(PermissionManageSessions)
+}
+
+// CanUnlockUsers checks if the subject may lift the login lockout of users and client addresses.
+func (s *Subject) CanUnlockUsers() error {
+	return s.require(PermissionUnlockUsers)
+}
+
+func (s *Subject) allowSelfOr(userID int64, permission string) error {
+	if s.IsSelf(userID) {
+		return nil
+	}
+	return s.require(permission)
+}
+
+func (s *Subject) require(permission string) error {
+	if !s.HasPermission(permission) {
+		return fmt.Errorf("%w: missing permission '%s'", ErrPermissionDenied, permission)
+	}
+	return nil
+}
+
+// Policy resolves the subjects of authenticated requests from the database.
+type Policy struct {
+	store db.Store
+}
+
+// NewPolicy creates a new Policy.
+func NewPolicy(store db.Store) *Policy {
+	return &Policy{store: store}
+}
+
+// LoadSubject looks up the user with the given username and the permissions of its role.
+func (p *Policy) LoadSubject(ctx context.Context, username string) (*Subject, error) {
+	user, err := p.store.GetUserByValue(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return p.SubjectOf(ctx, user)
+}
+
+// SubjectOf returns the subject of a user that was already loaded, e.g. during a login.
+func (p *Policy) SubjectOf(ctx context.Context, user db.UserSvcUser) (*Subject, error) {
+	// Users without a role have no permissions besides accessing their own account
+	if !user.RoleID.Valid {
+		return NewSubject(user.ID, user.Username, 0), nil
+	}
+
+	permissions, err := p.store.ListPermissionsByRoleId(ctx, user.RoleID.Int64)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(permissions))
+	for i, permission := range permissions {
+		names[i] = permission.Name
+	}
+
+	return NewSubject(user.ID, user.Username, user.RoleID.Int64, names...), nil
+}
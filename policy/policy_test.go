@@ -0,0 +1,106 @@
+package policy
+
+import (
+	"context"
+	"testing"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestSubjectPermissions(t *testing.T) {
+	admin := NewSubject(1, util.RandomUsername(), RoleAdmin,
+		PermissionReadUsers, PermissionListUsers, PermissionUpdateUsers, PermissionDeleteUsers, PermissionAssignRoles, PermissionManageSessions,
+		PermissionUnlockUsers)
+	user := NewSubject(2, util.RandomUsername(), RoleUser)
+
+	// Admins may manage every account
+	require.NoError(t, admin.CanReadUser(user.UserID))
+	require.NoError(t, admin.CanListUsers())
+	require.NoError(t, admin.CanUpdateUser(user.UserID))
+	require.NoError(t, admin.CanDeleteUsers())
+	require.NoError(t, admin.CanAssignRole())
+	require.NoError(t, admin.CanChangeStatus())
+	require.NoError(t, admin.CanManageSessions(user.Username))
+	require.NoError(t, admin.CanUnlockUsers())
+
+	// Regular users may only read and update themselves
+	require.NoError(t, user.CanReadUser(user.UserID))
+	require.NoError(t, user.CanUpdateUser(user.UserID))
+	require.NoError(t, user.CanManageSessions(user.Username))
+	require.ErrorIs(t, user.CanReadUser(admin.UserID), ErrPermissionDenied)
+	require.ErrorIs(t, user.CanUpdateUser(admin.UserID), ErrPermissionDenied)
+	require.ErrorIs(t, user.CanListUsers(), ErrPermissionDenied)
+	require.ErrorIs(t, user.CanDeleteUsers(), ErrPermissionDenied)
+	require.ErrorIs(t, user.CanAssignRole(), ErrPermissionDenied)
+	require.ErrorIs(t, user.CanChangeStatus(), ErrPermissionDenied)
+	require.ErrorIs(t, user.CanManageSessions(admin.Username), ErrPermissionDenied)
+	require.ErrorIs(t, user.CanUnlockUsers(), ErrPermissionDenied)
+}
+
+func TestSubjectWithScopes(t *testing.T) {
+	admin := NewSubject(1, util.RandomUsername(), RoleAdmin, PermissionReadUsers, PermissionDeleteUsers)
+
+	// Scopes can only narrow the permissions of the role
+	scoped := admin.WithScopes([]string{PermissionReadUsers, PermissionManageSessions, "openid"})
+	require.Equal(t, []string{PermissionReadUsers}, scoped.Permissions())
+	require.NoError(t, scoped.CanReadUser(2))
+	require.ErrorIs(t, scoped.CanDeleteUsers(), ErrPermissionDenied)
+	require.ErrorIs(t, scoped.CanManageSessions(util.RandomUsername()), ErrPermissionDenied)
+
+	// The subject itself is unchanged
+	require.NoError(t, admin.CanDeleteUsers())
+	require.False(t, admin.IsDelegated())
+	require.True(t, scoped.IsDelegated())
+}
+
+func TestDelegatedSubjectOwnAccount(t *testing.T) {
+	user := NewSubject(3, util.RandomUsername(), RoleUser)
+	admin := NewSubject(1, util.RandomUsername(), RoleAdmin, PermissionUpdateUsers, PermissionManageSessions)
+
+	// OAuth clients and API keys can't change the account of their user without the scope
+	delegated := user.WithScopes([]string{PermissionUpdateUsers, PermissionManageSessions})
+	require.NoError(t, delegated.CanReadUser(user.UserID))
+	require.ErrorIs(t, delegated.CanUpdateUser(user.UserID), ErrPermissionDenied)
+	require.ErrorIs(t, delegated.CanManageSessions(user.Username), ErrPermissionDenied)
+
+	// Granted scopes still apply
+	delegated = admin.WithScopes([]string{PermissionUpdateUsers, PermissionManageSessions})
+	require.NoError(t, delegated.CanUpdateUser(admin.UserID))
+	require.NoError(t, delegated.CanManageSessions(admin.Username))
+}
+
+func TestLoadSubject(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+
+	user := db.UserSvcUser{
+		ID:       util.RandomInt(1, 1000),
+		Username: util.RandomUsername(),
+		RoleID:   util.ConvertToInt8(RoleModerator),
+	}
+
+	store.EXPECT().
+		GetUserByValue(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().
+		ListPermissionsByRoleId(gomock.Any(), gomock.Eq(RoleModerator)).
+		Times(1).
+		Return([]db.UserSvcPermission{{Name: PermissionReadUsers}, {Name: PermissionListUsers}}, nil)
+
+	subject, err := NewPolicy(store).LoadSubject(context.Background(), user.Username)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, subject.UserID)
+	require.Equal(t, user.Username, subject.Username)
+	require.Equal(t, RoleModerator, subject.RoleID)
+	require.True(t, subject.HasPermission(PermissionReadUsers))
+	require.True(t, subject.HasPermission(PermissionListUsers))
+	require.False(t, subject.HasPermission(PermissionDeleteUsers))
+	require.Equal(t, []string{PermissionListUsers, PermissionReadUsers}, subject.Permissions())
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the in-memory backend drops the buckets that have been refilled completely.
+const sweepInterval = time.Minute
+
+// bucket is a token bucket, its tokens are refilled lazily when a token is taken.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is the time at which the bucket is refilled completely, it can be dropped afterwards.
+	full time.Time
+}
+
+// MemoryBackend keeps the token buckets in the memory of the process.
+type MemoryBackend struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryBackend creates a new in-memory backend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Take removes a token from the bucket of the key, new buckets start full.
+func (b *MemoryBackend) Take(_ context.Context, key string, limit Limit) (time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.sweep(now)
+
+	bkt, ok := b.buckets[key]
+	if !ok {
+		bkt = &bucket{tokens: float64(limit.Burst), updated: now}
+		b.buckets[key] = bkt
+	}
+
+	// Refill the tokens that were added since the last request
+	bkt.tokens += now.Sub(bkt.updated).Seconds() * limit.Rate
+	if bkt.tokens > float64(limit.Burst) {
+		bkt.tokens = float64(limit.Burst)
+	}
+	bkt.updated = now
+
+	var retryAfter time.Duration
+	if bkt.tokens >= 1 {
+		bkt.tokens--
+	} else {
+		retryAfter = seconds((1 - bkt.tokens) / limit.Rate)
+	}
+	bkt.full = now.Add(seconds((float64(limit.Burst) - bkt.tokens) / limit.Rate))
+
+	return retryAfter, nil
+}
+
+// sweep drops the buckets that are full again, they are recreated full on the next request.
+func (b *MemoryBackend) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < sweepInterval {
+		return
+	}
+	b.lastSweep = now
+
+	for key, bkt := range b.buckets {
+		if !bkt.full.After(now) {
+			delete(b.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+)
+
+// DefaultRoute is the route of the limit that applies to every route without a limit of its own.
+const DefaultRoute = "*"
+
+// Limit is the size of a token bucket and the rate at which it is refilled.
+type Limit struct {
+	// Rate is the number of tokens added per second.
+	Rate float64
+	// Burst is the capacity of the bucket, the number of requests that may be made at once.
+	Burst int
+}
+
+// Backend stores the token buckets of the clients. The in-memory backend only limits the requests to a single
+// instance, a shared backend is needed to enforce the limits across several instances of the service.
+type Backend interface {
+	// Take removes a token from the bucket of the key. If the bucket is empty, it returns how long it takes
+	// until the next token is available, zero means the request is allowed.
+	Take(ctx context.Context, key string, limit Limit) (retryAfter time.Duration, err error)
+}
+
+// LimitedError is returned when a client exceeds the limit of a route.
+type LimitedError struct {
+	RetryAfter time.Duration
+}
+
+// Error returns the string representation of the error.
+func (e *LimitedError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, try again in %s", e.RetryAfter)
+}
+
+// RetryAfterSeconds returns the wait time in whole seconds, rounded up, as used by the Retry-After header.
+func (e *LimitedError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Limiter limits the requests per route and client with token buckets.
+type Limiter struct {
+	backend Backend
+	limits  map[string]Limit
+}
+
+// NewLimiter creates a new Limiter that stores its buckets in the given backend.
+func NewLimiter(backend Backend, limits map[string]Limit) *Limiter {
+	return &Limiter{
+		backend: backend,
+		limits:  limits,
+	}
+}
+
+// Allow takes a token from the bucket of the client for the route and returns a LimitedError if the bucket is empty.
+// Routes without a limit and without a default limit are not limited.
+func (l *Limiter) Allow(ctx context.Context, route, client string) error {
+	limit, ok := l.limits[route]
+	if !ok {
+		limit, ok = l.limits[DefaultRoute]
+	}
+	if !ok {
+		return nil
+	}
+
+	retryAfter, err := l.backend.Take(ctx, route+"|"+client, limit)
+	if err != nil {
+		return err
+	}
+	if retryAfter > 0 {
+		return &LimitedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// ClientKey identifies the client of a request by its address. Requests are limited before their credentials
+// are checked, so that invalid tokens and API keys are limited as well.
+func ClientKey(clientIP string) string {
+	return "ip:" + util.NormalizeClientIP(clientIP)
+}
+
+// ParseLimits parses limits of the form <route>=<requests>/<s|m|h>, e.g. "POST /users/login=10/m".
+// The bucket of a limit holds as many tokens as requests are allowed per period.
+func ParseLimits(values []string) (map[string]Limit, error) {
+	limits := make(map[string]Limit, len(values))
+	for _, value := range values {
+		route, rate, ok := strings.Cut(value, "=")
+		route = strings.TrimSpace(route)
+		if !ok || route == "" {
+			return nil, fmt.Errorf("invalid rate limit %q: expected <route>=<requests>/<period>", value)
+		}
+
+		count, unit, ok := strings.Cut(strings.TrimSpace(rate), "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate limit %q: expected <route>=<requests>/<period>", value)
+		}
+		requests, err := strconv.Atoi(count)
+		if err != nil || requests <= 0 {
+			return nil, fmt.Errorf("invalid rate limit %q: the number of requests must be positive", value)
+		}
+
+		var period time.Duration
+		switch unit {
+		case "s":
+			period = time.Second
+		case "m":
+			period = time.Minute
+		case "h":
+			period = time.Hour
+		default:
+			return nil, fmt.Errorf("invalid rate limit %q: the period must be s, m or h", value)
+		}
+
+		limits[route] = Limit{
+			Rate:  float64(requests) / period.Seconds(),
+			Burst: requests,
+		}
+	}
+	return limits, nil
+}
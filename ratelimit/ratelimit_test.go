@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBackend(now *time.Time) *MemoryBackend {
+	backend := NewMemoryBackend()
+	backend.now = func() time.Time { return *now }
+	return backend
+}
+
+func TestMemoryBackendTake(t *testing.T) {
+	now := time.Now()
+	backend := newTestBackend(&now)
+	limit := Limit{Rate: 1, Burst: 3}
+
+	// The burst is allowed at once
+	for i := 0; i < 3; i++ {
+		retryAfter, err := backend.Take(context.Background(), "key", limit)
+		require.NoError(t, err)
+		require.Zero(t, retryAfter)
+	}
+
+	retryAfter, err := backend.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.Equal(t, time.Second, retryAfter)
+
+	// Other keys have their own bucket
+	retryAfter, err = backend.Take(context.Background(), "other", limit)
+	require.NoError(t, err)
+	require.Zero(t, retryAfter)
+
+	// A token is refilled per second
+	now = now.Add(1500 * time.Millisecond)
+	retryAfter, err = backend.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.Zero(t, retryAfter)
+
+	retryAfter, err = backend.Take(context.Background(), "key", limit)
+	require.NoError(t, err)
+	require.Equal(t, 500*time.Millisecond, retryAfter)
+}
+
+func TestMemoryBackendSweep(t *testing.T) {
+	now := time.Now()
+	backend := newTestBackend(&now)
+	limit := Limit{Rate: 1, Burst: 3}
+
+	_, err := backend.Take(context.Background(), "idle", limit)
+	require.NoError(t, err)
+
+	now = now.Add(sweepInterval)
+	_, err = backend.Take(context.Background(), "active", limit)
+	require.NoError(t, err)
+
+	require.Len(t, backend.buckets, 1)
+	require.Contains(t, backend.buckets, "active")
+}
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Now()
+	limiter := NewLimiter(newTestBackend(&now), map[string]Limit{
+		"POST /users/login": {Rate: 1, Burst: 1},
+		DefaultRoute:        {Rate: 1, Burst: 2},
+	})
+	ctx := context.Background()
+	client := ClientKey("10.0.0.1:52814")
+
+	require.NoError(t, limiter.Allow(ctx, "POST /users/login", client))
+
+	var limitedErr *LimitedError
+	require.ErrorAs(t, limiter.Allow(ctx, "POST /users/login", client), &limitedErr)
+	require.Equal(t, 1, limitedErr.RetryAfterSeconds())
+
+	// Other clients and routes have their own buckets
+	require.NoError(t, limiter.Allow(ctx, "POST /users/login", ClientKey("10.0.0.2")))
+	require.NoError(t, limiter.Allow(ctx, "POST /users", client))
+	require.NoError(t, limiter.Allow(ctx, "POST /users", client))
+	require.ErrorAs(t, limiter.Allow(ctx, "POST /users", client), &limitedErr)
+}
+
+func TestLimiterWithoutLimits(t *testing.T) {
+	limiter := NewLimiter(NewMemoryBackend(), nil)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, limiter.Allow(context.Background(), "POST /users", ClientKey("10.0.0.1")))
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	require.Equal(t, "ip:10.0.0.1", ClientKey("10.0.0.1:52814"))
+	require.Equal(t, "ip:::1", ClientKey("[::1]:50051"))
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits([]string{"POST /users/login=10/m", "IdentityProvider/LoginUser=2/s", "*=3600/h"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]Limit{
+		"POST /users/login":          {Rate: 10.0 / 60, Burst: 10},
+		"IdentityProvider/LoginUser": {Rate: 2, Burst: 2},
+		DefaultRoute:                 {Rate: 1, Burst: 3600},
+	}, limits)
+
+	for _, value := range []string{"POST /users", "=10/m", "POST /users=10", "POST /users=0/m", "POST /users=10/d"} {
+		_, err := ParseLimits([]string{value})
+		require.Error(t, err, value)
+	}
+}
@@ -0,0 +1,167 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrTokenRevoked is returned for tokens that were revoked before they expired.
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// List tracks revoked tokens: single tokens by their ID, e.g. after a logout, and all tokens of a user that were
+// issued before a watermark, e.g. after a password reset or the deletion of the account.
+// The revocations are kept in memory and loaded from the database once per refresh interval, so that verifying
+// a token doesn't query the database. Revocations of other instances take effect after the next refresh.
+type List struct {
+	store           db.Store
+	refreshInterval time.Duration
+	// maxTokenLifetime is the duration of the longest living tokens, older watermarks can't match any valid token.
+	maxTokenLifetime time.Duration
+
+	// refreshMu serializes refreshes with revocations, so that a refresh can't drop a revocation of this instance.
+	refreshMu   sync.Mutex
+	mu          sync.RWMutex
+	revoked     map[uuid.UUID]time.Time
+	watermarks  map[string]time.Time
+	refreshedAt time.Time
+}
+
+// NewList creates a new List from the token settings of the configuration.
+func NewList(config util.Config, store db.Store) *List {
+	return &List{
+		store:            store,
+		refreshInterval:  config.TokenRevocationInterval,
+		maxTokenLifetime: max(config.AccessTokenDuration, config.RefreshTokenDuration),
+		revoked:          make(map[uuid.UUID]time.Time),
+		watermarks:       make(map[string]time.Time),
+	}
+}
+
+// Check returns ErrTokenRevoked if the token was revoked. The revocations are refreshed first if they are older
+// than the refresh interval. If the database can't be reached the last known revocations are used, so that
+// a failing database doesn't lock out all users.
+func (l *List) Check(ctx context.Context, payload *token.Payload) error {
+	if l.isStale() {
+		l.refreshStale(ctx)
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if _, ok := l.revoked[payload.ID]; ok {
+		return ErrTokenRevoked
+	}
+	if watermark, ok := l.watermarks[payload.Username]; ok && payload.IssuedAt.Before(watermark) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// RevokeToken revokes a single token until it expires.
+func (l *List) RevokeToken(ctx context.Context, payload *token.Payload) error {
+	l.refreshMu.Lock()
+	defer l.refreshMu.Unlock()
+
+	err := l.store.RevokeToken(ctx, db.RevokeTokenParams{
+		ID:        payload.ID,
+		Username:  payload.Username,
+		ExpiresAt: payload.ExpiredAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.revoked[payload.ID] = payload.ExpiredAt
+	return nil
+}
+
+// RevokeUserTokens revokes all tokens that were issued to the user until now.
+func (l *List) RevokeUserTokens(ctx context.Context, username string) error {
+	l.refreshMu.Lock()
+	defer l.refreshMu.Unlock()
+
+	watermark, err := l.store.SetTokenWatermark(ctx, db.SetTokenWatermarkParams{
+		Username:  username,
+		NotBefore: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if watermark.NotBefore.After(l.watermarks[username]) {
+		l.watermarks[username] = watermark.NotBefore
+	}
+	return nil
+}
+
+// Refresh replaces the cached revocations with the ones in the database.
+func (l *List) Refresh(ctx context.Context) error {
+	l.refreshMu.Lock()
+	defer l.refreshMu.Unlock()
+	return l.refresh(ctx)
+}
+
+func (l *List) refresh(ctx context.Context) error {
+	revokedTokens, err := l.store.ListRevokedTokens(ctx)
+	if err != nil {
+		return err
+	}
+	watermarks, err := l.store.ListTokenWatermarks(ctx, time.Now().Add(-l.maxTokenLifetime))
+	if err != nil {
+		return err
+	}
+
+	revoked := make(map[uuid.UUID]time.Time, len(revokedTokens))
+	for _, revokedToken := range revokedTokens {
+		revoked[revokedToken.ID] = revokedToken.ExpiresAt
+	}
+	notBefore := make(map[string]time.Time, len(watermarks))
+	for _, watermark := range watermarks {
+		notBefore[watermark.Username] = watermark.NotBefore
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.revoked = revoked
+	l.watermarks = notBefore
+	l.refreshedAt = time.Now()
+	return nil
+}
+
+// isStale reports whether the cached revocations are older than the refresh interval.
+func (l *List) isStale() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return time.Since(l.refreshedAt) >= l.refreshInterval
+}
+
+// refreshStale refreshes the revocations unless a concurrent request already did. A failed refresh is only retried
+// after the refresh interval, so that an unavailable database isn't queried by every request.
+func (l *List) refreshStale(ctx context.Context) {
+	l.refreshMu.Lock()
+	defer l.refreshMu.Unlock()
+
+	if !l.isStale() {
+		return
+	}
+
+	if err := l.refresh(ctx); err != nil {
+		log.Error().Err(err).Msg("failed to refresh token revocations")
+
+		l.mu.Lock()
+		l.refreshedAt = time.Now()
+		l.mu.Unlock()
+	}
+}
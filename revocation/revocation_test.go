@@ -0,0 +1,151 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestList(store db.Store) *List {
+	return NewList(util.Config{
+		TokenRevocationInterval: time.Minute,
+		AccessTokenDuration:     15 * time.Minute,
+		RefreshTokenDuration:    24 * time.Hour,
+	}, store)
+}
+
+func randomPayload(t *testing.T, username string) *token.Payload {
+	payload, err := token.NewPayload(token.Claims{Username: username}, token.TokenTypeAccess, token.DefaultIssuer, token.DefaultAudience, time.Minute)
+	require.NoError(t, err)
+	return payload
+}
+
+func TestCheckCachesRevocations(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	list := newTestList(store)
+
+	username := util.RandomUsername()
+	revoked := randomPayload(t, username)
+	valid := randomPayload(t, username)
+
+	// The revocations are loaded once per refresh interval
+	store.EXPECT().
+		ListRevokedTokens(gomock.Any()).
+		Times(1).
+		Return([]db.UserSvcRevokedToken{{ID: revoked.ID, Username: username, ExpiresAt: revoked.ExpiredAt}}, nil)
+	store.EXPECT().
+		ListTokenWatermarks(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, cutoff time.Time) ([]db.UserSvcTokenWatermark, error) {
+			require.WithinDuration(t, time.Now().Add(-24*time.Hour), cutoff, time.Second)
+			return []db.UserSvcTokenWatermark{}, nil
+		})
+
+	require.ErrorIs(t, list.Check(context.Background(), revoked), ErrTokenRevoked)
+	require.NoError(t, list.Check(context.Background(), valid))
+	require.ErrorIs(t, list.Check(context.Background(), revoked), ErrTokenRevoked)
+}
+
+func TestCheckWatermark(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	list := newTestList(store)
+
+	username := util.RandomUsername()
+	before := randomPayload(t, username)
+	other := randomPayload(t, util.RandomUsername())
+	watermark := time.Now()
+
+	store.EXPECT().
+		ListRevokedTokens(gomock.Any()).
+		Times(1).
+		Return([]db.UserSvcRevokedToken{}, nil)
+	store.EXPECT().
+		ListTokenWatermarks(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return([]db.UserSvcTokenWatermark{{Username: username, NotBefore: watermark}}, nil)
+
+	// Tokens issued before the watermark are revoked, later tokens and tokens of other users are not
+	require.ErrorIs(t, list.Check(context.Background(), before), ErrTokenRevoked)
+	require.NoError(t, list.Check(context.Background(), other))
+
+	after := randomPayload(t, username)
+	after.IssuedAt = watermark.Add(time.Millisecond)
+	require.NoError(t, list.Check(context.Background(), after))
+}
+
+func TestRevokeTakesEffectImmediately(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	list := newTestList(store)
+
+	store.EXPECT().
+		ListRevokedTokens(gomock.Any()).
+		Times(1).
+		Return([]db.UserSvcRevokedToken{}, nil)
+	store.EXPECT().
+		ListTokenWatermarks(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return([]db.UserSvcTokenWatermark{}, nil)
+	require.NoError(t, list.Refresh(context.Background()))
+
+	username := util.RandomUsername()
+	payload := randomPayload(t, username)
+
+	store.EXPECT().
+		RevokeToken(gomock.Any(), gomock.Eq(db.RevokeTokenParams{
+			ID:        payload.ID,
+			Username:  username,
+			ExpiresAt: payload.ExpiredAt,
+		})).
+		Times(1).
+		Return(nil)
+	require.NoError(t, list.RevokeToken(context.Background(), payload))
+	require.ErrorIs(t, list.Check(context.Background(), payload), ErrTokenRevoked)
+
+	other := randomPayload(t, username)
+	require.NoError(t, list.Check(context.Background(), other))
+
+	store.EXPECT().
+		SetTokenWatermark(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.SetTokenWatermarkParams) (db.UserSvcTokenWatermark, error) {
+			require.Equal(t, username, arg.Username)
+			return db.UserSvcTokenWatermark{Username: arg.Username, NotBefore: arg.NotBefore}, nil
+		})
+	require.NoError(t, list.RevokeUserTokens(context.Background(), username))
+	require.ErrorIs(t, list.Check(context.Background(), other), ErrTokenRevoked)
+}
+
+func TestCheckFailingRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	list := newTestList(store)
+
+	// A failing database lets tokens through and is only queried again after the refresh interval
+	store.EXPECT().
+		ListRevokedTokens(gomock.Any()).
+		Times(1).
+		Return(nil, errors.New("connection refused"))
+
+	payload := randomPayload(t, util.RandomUsername())
+	require.NoError(t, list.Check(context.Background(), payload))
+	require.NoError(t, list.Check(context.Background(), payload))
+}
@@ -0,0 +1,235 @@
+package social
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// clockSkew is the difference between the clocks of the provider and this service that is tolerated.
+const clockSkew = time.Minute
+
+// keysRefreshInterval is the minimum time between two fetches of the signing keys. Tokens signed with unknown
+// keys therefore can't make the client fetch the keys of the provider on every login.
+const keysRefreshInterval = time.Minute
+
+// minRSAKeySize is the minimum size of the RSA keys ID tokens are signed with.
+const minRSAKeySize = 2048
+
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// Claims are the claims of an ID token the login uses.
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	Locale            string   `json:"locale"`
+}
+
+// audience is the aud claim, which is either a single string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// idTokenHeader holds the fields of the JOSE header of an ID token.
+type idTokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// jsonWebKey holds the fields of an RSA key of a JSON Web Key Set.
+type jsonWebKey struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+}
+
+// keySet holds the RSA signing keys of a provider by their key ID.
+type keySet struct {
+	keys map[string]*rsa.PublicKey
+}
+
+// lookup returns the key of the ID. Tokens without a key ID can only be verified if the provider has a single key.
+func (s *keySet) lookup(keyID string) (*rsa.PublicKey, bool) {
+	if keyID == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[keyID]
+	return key, ok
+}
+
+// VerifyIDToken verifies the signature of the ID token with the keys of the provider and checks that it was issued
+// by the configured issuer to this client for the login of the nonce. Only RS256 signatures are accepted,
+// the algorithm all OpenID Connect providers have to support.
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidIDToken)
+	}
+
+	var header idTokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header", ErrInvalidIDToken)
+	}
+	if header.Algorithm != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidIDToken, header.Algorithm)
+	}
+
+	key, err := p.signingKey(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidIDToken)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, fmt.Errorf("%w: invalid signature", ErrInvalidIDToken)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidIDToken)
+	}
+	if err := p.validateClaims(&claims, nonce, time.Now()); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// validateClaims checks the claims of a verified ID token as defined by OpenID Connect Core 3.1.3.7.
+func (p *Provider) validateClaims(claims *Claims, nonce string, now time.Time) error {
+	if claims.Issuer != p.config.Issuer {
+		return fmt.Errorf("%w: issuer %q doesn't match", ErrInvalidIDToken, claims.Issuer)
+	}
+	if !claims.Audience.contains(p.config.ClientID) {
+		return fmt.Errorf("%w: token wasn't issued to this client", ErrInvalidIDToken)
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedParty != p.config.ClientID {
+		return fmt.Errorf("%w: token wasn't issued to this client", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return fmt.Errorf("%w: token has no subject", ErrInvalidIDToken)
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return fmt.Errorf("%w: token has expired", ErrInvalidIDToken)
+	}
+	if time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)) {
+		return fmt.Errorf("%w: token was issued in the future", ErrInvalidIDToken)
+	}
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return fmt.Errorf("%w: nonce doesn't match", ErrInvalidIDToken)
+	}
+	return nil
+}
+
+// signingKey returns the key of the ID. The keys are fetched again if the provider rotated its keys since the last fetch.
+func (p *Provider) signingKey(ctx context.Context, keyID string) (*rsa.PublicKey, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil {
+		if key, ok := p.keys.lookup(keyID); ok {
+			return key, nil
+		}
+		if time.Since(p.keysFetchedAt) < keysRefreshInterval {
+			return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, keyID)
+		}
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, discovery.JwksURI, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch the keys of %s: %w", p.config.Name, err)
+	}
+	p.keys = newKeySet(jwks.Keys)
+	p.keysFetchedAt = time.Now()
+
+	key, ok := p.keys.lookup(keyID)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidIDToken, keyID)
+	}
+	return key, nil
+}
+
+// newKeySet creates a key set of the RSA signing keys of a JSON Web Key Set, all other keys are skipped.
+func newKeySet(jwks []jsonWebKey) *keySet {
+	set := &keySet{keys: make(map[string]*rsa.PublicKey, len(jwks))}
+	for _, jwk := range jwks {
+		if jwk.KeyType != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		key := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if key.N.BitLen() < minRSAKeySize {
+			continue
+		}
+		set.keys[jwk.KeyID] = key
+	}
+	return set
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,357 @@
+package social
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/oauth"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// providerTimeout limits the requests to the identity providers.
+const providerTimeout = 10 * time.Second
+
+// maxUsernameBase is the length of the username taken from the external account, the rest of the at most
+// 24 characters is a random suffix that keeps the usernames unique.
+const maxUsernameBase = 17
+
+var (
+	ErrUnknownProvider    = errors.New("unknown identity provider")
+	ErrInvalidState       = errors.New("invalid or expired login state")
+	ErrProviderRejected   = errors.New("the identity provider rejected the login")
+	ErrEmailNotVerified   = errors.New("the identity provider hasn't verified the email address")
+	ErrAccountNotVerified = errors.New("the account with this email address is not verified, log in with the password to link the provider")
+	ErrIdentityLinked     = errors.New("the external account is linked to another user")
+	ErrProviderLinked     = errors.New("an account of this identity provider is already linked")
+	ErrNotLinked          = errors.New("no account of this identity provider is linked")
+)
+
+// Result is the outcome of a completed login at an identity provider. Logins return the user the external account
+// belongs to, links only return the identity that was linked to the user who started the link.
+type Result struct {
+	User     db.UserSvcUser
+	Identity db.UserSvcExternalIdentity
+	// Created reports that the user was created by the login.
+	Created bool
+	// Linking reports that the login linked the external account to an existing user.
+	Linking bool
+}
+
+// Manager logs users in with the accounts of external OpenID Connect providers and links the accounts to users.
+type Manager struct {
+	store         db.Store
+	providers     map[string]*Provider
+	stateDuration time.Duration
+	hashParams    util.Argon2idParams
+}
+
+// NewManager creates a new Manager with the providers of the configured providers file.
+// Without a providers file all external logins fail with ErrUnknownProvider.
+func NewManager(config util.Config, store db.Store) (*Manager, error) {
+	manager := &Manager{
+		store:         store,
+		providers:     make(map[string]*Provider),
+		stateDuration: config.ExternalLoginStateDuration,
+		hashParams:    config.Argon2idParams(),
+	}
+
+	if config.ExternalProvidersFile != "" {
+		configs, err := LoadProvidersFile(config.ExternalProvidersFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load identity providers: %w", err)
+		}
+		client := &http.Client{Timeout: providerTimeout}
+		for _, providerConfig := range configs {
+			manager.providers[providerConfig.Name] = NewProvider(providerConfig, client)
+		}
+	}
+	return manager, nil
+}
+
+// Providers returns the names of the configured providers.
+func (m *Manager) Providers() []string {
+	names := make([]string, 0, len(m.providers))
+	for name := range m.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartLogin starts a login at the provider and returns the URL the user is redirected to.
+func (m *Manager) StartLogin(ctx context.Context, provider string) (string, error) {
+	return m.start(ctx, provider, pgtype.Int8{})
+}
+
+// StartLink starts a login at the provider that links the external account to the user once it is completed.
+func (m *Manager) StartLink(ctx context.Context, provider string, userID int64) (string, error) {
+	return m.start(ctx, provider, util.ConvertToInt8(userID))
+}
+
+// start stores the state, nonce and PKCE code verifier of a login and returns the authorization URL of the provider.
+// Only the hash of the state is stored, the state itself comes back with the callback of the provider.
+func (m *Manager) start(ctx context.Context, provider string, userID pgtype.Int8) (string, error) {
+	p, ok := m.providers[provider]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	state, err := util.GenerateSecretCode()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := util.GenerateSecretCode()
+	if err != nil {
+		return "", err
+	}
+	codeVerifier, err := util.GenerateSecretCode()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = m.store.CreateExternalLoginState(ctx, db.CreateExternalLoginStateParams{
+		StateHash:    util.HashSecretCode(state),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		UserID:       userID,
+		ExpiredAt:    time.Now().Add(m.stateDuration),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return p.AuthCodeURL(ctx, state, nonce, oauth.CodeChallenge(codeVerifier))
+}
+
+// Complete completes the login of the callback of the provider. Known external accounts log in their user.
+// Unknown accounts are linked to the user with the same email address or a new user is created for them,
+// in both cases the provider has to have verified the email address.
+func (m *Manager) Complete(ctx context.Context, provider, state, code string) (*Result, error) {
+	p, ok := m.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	if state == "" {
+		return nil, ErrInvalidState
+	}
+
+	// The state is deleted when it is read, so every login can only be completed once
+	loginState, err := m.store.ConsumeExternalLoginState(ctx, util.HashSecretCode(state))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrInvalidState
+		}
+		return nil, err
+	}
+	if loginState.Provider != provider || time.Now().After(loginState.ExpiredAt) {
+		return nil, ErrInvalidState
+	}
+
+	claims, err := p.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := m.store.GetExternalIdentity(ctx, db.GetExternalIdentityParams{
+		Provider: provider,
+		Subject:  claims.Subject,
+	})
+	switch {
+	case err == nil:
+		return m.completeKnownIdentity(ctx, identity, loginState.UserID)
+	case !errors.Is(err, pgx.ErrNoRows):
+		return nil, err
+	}
+
+	if loginState.UserID.Valid {
+		identity, err := m.createIdentity(ctx, loginState.UserID.Int64, provider, claims)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Identity: identity, Linking: true}, nil
+	}
+
+	// Accounts are only matched by email addresses the provider vouches for, otherwise anyone could take over
+	// the account of an address by registering it at the provider
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	user, err := m.store.GetUserByEmail(ctx, claims.Email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return m.createUser(ctx, provider, claims)
+		}
+		return nil, err
+	}
+
+	// Users that didn't verify their current address may not own it, the address could have been registered or
+	// changed to by someone else
+	if user.EmailVerifiedAt.IsZero() {
+		return nil, ErrAccountNotVerified
+	}
+	identity, err = m.createIdentity(ctx, user.ID, provider, claims)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{User: user, Identity: identity, Linking: true}, nil
+}
+
+// completeKnownIdentity logs in the user of a linked external account. Links of accounts that are already linked
+// only succeed for the user they are linked to.
+func (m *Manager) completeKnownIdentity(ctx context.Context, identity db.UserSvcExternalIdentity, linkUserID pgtype.Int8) (*Result, error) {
+	if linkUserID.Valid {
+		if identity.UserID != linkUserID.Int64 {
+			return nil, ErrIdentityLinked
+		}
+		return &Result{Identity: identity, Linking: true}, nil
+	}
+
+	user, err := m.store.GetUserById(ctx, identity.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{User: user, Identity: identity}, nil
+}
+
+// createIdentity links the external account of the claims to the user.
+func (m *Manager) createIdentity(ctx context.Context, userID int64, provider string, claims *Claims) (db.UserSvcExternalIdentity, error) {
+	identity, err := m.store.CreateExternalIdentity(ctx, db.CreateExternalIdentityParams{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+			return db.UserSvcExternalIdentity{}, ErrProviderLinked
+		}
+		return db.UserSvcExternalIdentity{}, err
+	}
+	return identity, nil
+}
+
+// createUser creates an active user for the external account. The user gets a random password nobody knows,
+// a password of their own can be set with a password reset.
+func (m *Manager) createUser(ctx context.Context, provider string, claims *Claims) (*Result, error) {
+	username, err := newUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+	password, err := util.GenerateSecretCode()
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := util.HashPassword(password, m.hashParams)
+	if err != nil {
+		return nil, err
+	}
+
+	fullName := claims.Name
+	if fullName == "" {
+		fullName = username
+	}
+
+	result, err := m.store.CreateExternalUserTx(ctx, db.CreateExternalUserTxParams{
+		CreateUserParams: db.CreateUserParams{
+			Username:     username,
+			FullName:     fullName,
+			Email:        claims.Email,
+			PasswordHash: hashedPassword,
+			CountryCode:  countryCode(claims.Locale),
+			RoleID:       util.ConvertToInt8(policy.RoleUser),
+			Status:       util.ConvertToText(util.StatusActive),
+		},
+		Provider: provider,
+		Subject:  claims.Subject,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Result{User: result.User, Identity: result.Identity, Created: true}, nil
+}
+
+// Unlink removes the link of the external account of the provider from the user.
+func (m *Manager) Unlink(ctx context.Context, userID int64, provider string) error {
+	deleted, err := m.store.DeleteExternalIdentity(ctx, db.DeleteExternalIdentityParams{
+		UserID:   userID,
+		Provider: provider,
+	})
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotLinked
+	}
+	return nil
+}
+
+// ListIdentities returns the external accounts linked to the user.
+func (m *Manager) ListIdentities(ctx context.Context, userID int64) ([]db.UserSvcExternalIdentity, error) {
+	return m.store.ListExternalIdentitiesByUserId(ctx, userID)
+}
+
+// newUsername derives a username from the preferred username or the email address of the external account
+// and appends a random suffix.
+func newUsername(claims *Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	var builder strings.Builder
+	for _, r := range strings.ToLower(base) {
+		if builder.Len() == maxUsernameBase {
+			break
+		}
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			builder.WriteRune(r)
+		}
+	}
+	if builder.Len() < 3 {
+		builder.Reset()
+		builder.WriteString("user")
+	}
+
+	suffix, err := randomSuffix(6)
+	if err != nil {
+		return "", err
+	}
+	return builder.String() + "_" + suffix, nil
+}
+
+// randomSuffix returns n random lowercase letters and digits.
+func randomSuffix(n int) (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+	random := make([]byte, n)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	for i, b := range random {
+		random[i] = alphabet[int(b)%len(alphabet)]
+	}
+	return string(random), nil
+}
+
+// countryCode returns the region of a locale such as en-US, or an empty code if the locale has no region.
+func countryCode(locale string) string {
+	_, region, found := strings.Cut(strings.ReplaceAll(locale, "_", "-"), "-")
+	if !found || len(region) != 2 {
+		return ""
+	}
+	return strings.ToUpper(region)
+}
@@ -0,0 +1,295 @@
+package social
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/policy"
+	"github.com/Streamfair/streamfair_user_svc/social/socialtest"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestManager(t *testing.T, store db.Store) (*Manager, *socialtest.Server) {
+	server := socialtest.NewServer(t, "streamfair", util.RandomString(32))
+	manager, err := NewManager(util.Config{
+		ExternalProvidersFile:      server.ProvidersFile(t, "test", testRedirectURL),
+		ExternalLoginStateDuration: time.Minute,
+	}, store)
+	require.NoError(t, err)
+	return manager, server
+}
+
+// startTestLogin starts a login or link at the provider, logs in the user of the claims at the stand-in provider
+// and expects the callback to consume the stored state. It returns the code and state of the callback.
+func startTestLogin(t *testing.T, manager *Manager, server *socialtest.Server, store *mock_db.MockStore, userID int64, claims map[string]any) (string, string) {
+	var loginState db.UserSvcExternalLoginState
+	store.EXPECT().
+		CreateExternalLoginState(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateExternalLoginStateParams) (db.UserSvcExternalLoginState, error) {
+			loginState = db.UserSvcExternalLoginState{
+				StateHash:    arg.StateHash,
+				Provider:     arg.Provider,
+				Nonce:        arg.Nonce,
+				CodeVerifier: arg.CodeVerifier,
+				UserID:       arg.UserID,
+				ExpiredAt:    arg.ExpiredAt,
+			}
+			return loginState, nil
+		})
+
+	var authURL string
+	var err error
+	if userID != 0 {
+		authURL, err = manager.StartLink(context.Background(), "test", userID)
+	} else {
+		authURL, err = manager.StartLogin(context.Background(), "test")
+	}
+	require.NoError(t, err)
+
+	code, state := server.Authorize(t, authURL, claims)
+	require.Equal(t, util.HashSecretCode(state), loginState.StateHash)
+
+	store.EXPECT().
+		ConsumeExternalLoginState(gomock.Any(), gomock.Eq(loginState.StateHash)).
+		Times(1).
+		Return(loginState, nil)
+	return code, state
+}
+
+func TestNewManager(t *testing.T) {
+	manager, err := NewManager(util.Config{}, nil)
+	require.NoError(t, err)
+	require.Empty(t, manager.Providers())
+
+	_, err = manager.StartLogin(context.Background(), "test")
+	require.ErrorIs(t, err, ErrUnknownProvider)
+
+	_, err = NewManager(util.Config{ExternalProvidersFile: "missing.json"}, nil)
+	require.Error(t, err)
+}
+
+func TestCompleteCreatesUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager, server := newTestManager(t, store)
+	require.Equal(t, []string{"test"}, manager.Providers())
+
+	email := util.RandomEmail()
+	code, state := startTestLogin(t, manager, server, store, 0, map[string]any{
+		"sub":                "12345",
+		"email":              email,
+		"email_verified":     true,
+		"name":               "Jane Doe",
+		"preferred_username": "Jane.Doe",
+		"locale":             "de-AT",
+	})
+
+	store.EXPECT().GetExternalIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcExternalIdentity{}, pgx.ErrNoRows)
+	store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(email)).Times(1).Return(db.UserSvcUser{}, pgx.ErrNoRows)
+	store.EXPECT().
+		CreateExternalUserTx(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ any, arg db.CreateExternalUserTxParams) (db.CreateExternalUserTxResult, error) {
+			require.Regexp(t, `^janedoe_[a-z0-9]{6}$`, arg.Username)
+			require.Equal(t, "Jane Doe", arg.FullName)
+			require.Equal(t, email, arg.Email)
+			require.Equal(t, "AT", arg.CountryCode)
+			require.Equal(t, policy.RoleUser, arg.RoleID.Int64)
+			require.Equal(t, util.StatusActive, arg.Status.String)
+			require.NotEmpty(t, arg.PasswordHash)
+			require.Equal(t, "test", arg.Provider)
+			require.Equal(t, "12345", arg.Subject)
+
+			user := db.UserSvcUser{ID: 1, Username: arg.Username, Email: arg.Email, Status: arg.Status}
+			identity := db.UserSvcExternalIdentity{ID: 1, UserID: user.ID, Provider: arg.Provider, Subject: arg.Subject, Email: arg.Email}
+			return db.CreateExternalUserTxResult{User: user, Identity: identity}, nil
+		})
+
+	result, err := manager.Complete(context.Background(), "test", state, code)
+	require.NoError(t, err)
+	require.True(t, result.Created)
+	require.False(t, result.Linking)
+	require.Equal(t, email, result.User.Email)
+}
+
+func TestCompleteLinksVerifiedEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager, server := newTestManager(t, store)
+
+	user := db.UserSvcUser{
+		ID:              7,
+		Username:        util.RandomUsername(),
+		Email:           util.RandomEmail(),
+		Status:          util.ConvertToText(util.StatusActive),
+		EmailVerifiedAt: time.Now(),
+	}
+	claims := map[string]any{"sub": "12345", "email": user.Email, "email_verified": true}
+
+	code, state := startTestLogin(t, manager, server, store, 0, claims)
+	store.EXPECT().GetExternalIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcExternalIdentity{}, pgx.ErrNoRows)
+	store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).Times(1).Return(user, nil)
+	store.EXPECT().
+		CreateExternalIdentity(gomock.Any(), gomock.Eq(db.CreateExternalIdentityParams{
+			UserID:   user.ID,
+			Provider: "test",
+			Subject:  "12345",
+			Email:    user.Email,
+		})).
+		Times(1).
+		Return(db.UserSvcExternalIdentity{ID: 1, UserID: user.ID, Provider: "test", Subject: "12345"}, nil)
+
+	result, err := manager.Complete(context.Background(), "test", state, code)
+	require.NoError(t, err)
+	require.True(t, result.Linking)
+	require.False(t, result.Created)
+	require.Equal(t, user.ID, result.User.ID)
+
+	// Accounts that never verified their email address, or changed it since, aren't linked even if they are active
+	user.EmailVerifiedAt = time.Time{}
+	code, state = startTestLogin(t, manager, server, store, 0, claims)
+	store.EXPECT().GetExternalIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcExternalIdentity{}, pgx.ErrNoRows)
+	store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).Times(1).Return(user, nil)
+
+	_, err = manager.Complete(context.Background(), "test", state, code)
+	require.ErrorIs(t, err, ErrAccountNotVerified)
+
+	// Email addresses the provider didn't verify aren't matched at all
+	claims["email_verified"] = false
+	code, state = startTestLogin(t, manager, server, store, 0, claims)
+	store.EXPECT().GetExternalIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcExternalIdentity{}, pgx.ErrNoRows)
+	store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(0)
+
+	_, err = manager.Complete(context.Background(), "test", state, code)
+	require.ErrorIs(t, err, ErrEmailNotVerified)
+}
+
+func TestCompleteKnownIdentity(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager, server := newTestManager(t, store)
+
+	user := db.UserSvcUser{ID: 7, Username: util.RandomUsername(), Email: util.RandomEmail()}
+	identity := db.UserSvcExternalIdentity{ID: 1, UserID: user.ID, Provider: "test", Subject: "12345"}
+
+	// The email address of a linked account doesn't matter, it may have changed at the provider
+	code, state := startTestLogin(t, manager, server, store, 0, map[string]any{"sub": "12345"})
+	store.EXPECT().
+		GetExternalIdentity(gomock.Any(), gomock.Eq(db.GetExternalIdentityParams{Provider: "test", Subject: "12345"})).
+		Times(1).
+		Return(identity, nil)
+	store.EXPECT().GetUserById(gomock.Any(), gomock.Eq(user.ID)).Times(1).Return(user, nil)
+
+	result, err := manager.Complete(context.Background(), "test", state, code)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, result.User.Username)
+	require.False(t, result.Created)
+	require.False(t, result.Linking)
+
+	// Other users can't link the account
+	code, state = startTestLogin(t, manager, server, store, 8, map[string]any{"sub": "12345"})
+	store.EXPECT().GetExternalIdentity(gomock.Any(), gomock.Any()).Times(1).Return(identity, nil)
+
+	_, err = manager.Complete(context.Background(), "test", state, code)
+	require.ErrorIs(t, err, ErrIdentityLinked)
+}
+
+func TestCompleteLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager, server := newTestManager(t, store)
+
+	// Links don't require a verified email address, the user proved the ownership of both accounts
+	code, state := startTestLogin(t, manager, server, store, 7, map[string]any{"sub": "12345"})
+	store.EXPECT().GetExternalIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcExternalIdentity{}, pgx.ErrNoRows)
+	store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(0)
+	store.EXPECT().
+		CreateExternalIdentity(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.UserSvcExternalIdentity{ID: 1, UserID: 7, Provider: "test", Subject: "12345"}, nil)
+
+	result, err := manager.Complete(context.Background(), "test", state, code)
+	require.NoError(t, err)
+	require.True(t, result.Linking)
+	require.Equal(t, int64(7), result.Identity.UserID)
+
+	// Users can link a single account per provider
+	code, state = startTestLogin(t, manager, server, store, 7, map[string]any{"sub": "67890"})
+	store.EXPECT().GetExternalIdentity(gomock.Any(), gomock.Any()).Times(1).Return(db.UserSvcExternalIdentity{}, pgx.ErrNoRows)
+	store.EXPECT().
+		CreateExternalIdentity(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.UserSvcExternalIdentity{}, &pgconn.PgError{Code: "23505"})
+
+	_, err = manager.Complete(context.Background(), "test", state, code)
+	require.ErrorIs(t, err, ErrProviderLinked)
+}
+
+func TestCompleteInvalidState(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager, server := newTestManager(t, store)
+
+	_, err := manager.Complete(context.Background(), "other", "state", "code")
+	require.ErrorIs(t, err, ErrUnknownProvider)
+
+	_, err = manager.Complete(context.Background(), "test", "", "code")
+	require.ErrorIs(t, err, ErrInvalidState)
+
+	// Used or unknown states
+	store.EXPECT().
+		ConsumeExternalLoginState(gomock.Any(), gomock.Eq(util.HashSecretCode("state"))).
+		Times(1).
+		Return(db.UserSvcExternalLoginState{}, pgx.ErrNoRows)
+
+	_, err = manager.Complete(context.Background(), "test", "state", "code")
+	require.ErrorIs(t, err, ErrInvalidState)
+
+	// Expired states
+	store.EXPECT().
+		ConsumeExternalLoginState(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.UserSvcExternalLoginState{Provider: "test", ExpiredAt: time.Now().Add(-time.Second)}, nil)
+
+	_, err = manager.Complete(context.Background(), "test", "state", "code")
+	require.ErrorIs(t, err, ErrInvalidState)
+
+	// ID tokens of another login are rejected by the nonce
+	code, state := startTestLogin(t, manager, server, store, 0, map[string]any{"sub": "12345", "nonce": "other"})
+	store.EXPECT().GetExternalIdentity(gomock.Any(), gomock.Any()).Times(0)
+
+	_, err = manager.Complete(context.Background(), "test", state, code)
+	require.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestUnlink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	store := mock_db.NewMockStore(ctrl)
+	manager, _ := newTestManager(t, store)
+
+	arg := db.DeleteExternalIdentityParams{UserID: 7, Provider: "test"}
+	store.EXPECT().DeleteExternalIdentity(gomock.Any(), gomock.Eq(arg)).Times(1).Return(int64(1), nil)
+	require.NoError(t, manager.Unlink(context.Background(), 7, "test"))
+
+	store.EXPECT().DeleteExternalIdentity(gomock.Any(), gomock.Eq(arg)).Times(1).Return(int64(0), nil)
+	require.ErrorIs(t, manager.Unlink(context.Background(), 7, "test"), ErrNotLinked)
+}
+
+func TestNewUsername(t *testing.T) {
+	username, err := newUsername(&Claims{Email: "a.very.long.email.address@example.com"})
+	require.NoError(t, err)
+	require.Regexp(t, `^averylongemailadd_[a-z0-9]{6}$`, username)
+	require.LessOrEqual(t, len(username), 24)
+
+	username, err = newUsername(&Claims{PreferredUsername: "Jö"})
+	require.NoError(t, err)
+	require.Regexp(t, `^user_[a-z0-9]{6}$`, username)
+}
@@ -0,0 +1,214 @@
+package social
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultScopes are requested from providers that don't configure their own scopes.
+var defaultScopes = []string{"openid", "email", "profile"}
+
+// maxResponseSize limits the size of the documents read from a provider.
+const maxResponseSize = 1 << 20
+
+// ProviderConfig is an OpenID Connect provider users can log in with. The redirect URL is the callback
+// of this service that is registered at the provider.
+type ProviderConfig struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+}
+
+// LoadProvidersFile loads the provider configurations from a JSON file of the form
+// [{"name": "google", "issuer": "https://accounts.google.com", "client_id": "...", "client_secret": "...", "redirect_url": "..."}].
+func LoadProvidersFile(path string) ([]ProviderConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configs []ProviderConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid providers file: %w", err)
+	}
+
+	names := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		if config.Name == "" || config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+			return nil, errors.New("invalid providers file: all providers need a name, issuer, client ID and redirect URL")
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("invalid providers file: duplicate provider %q", config.Name)
+		}
+		names[config.Name] = true
+	}
+	return configs, nil
+}
+
+// discoveryDocument holds the fields of the OpenID Connect discovery document the client uses.
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// tokenResponse holds the fields of the token response of a provider the client uses.
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Provider is the OpenID Connect client of an identity provider. The endpoints and signing keys of the provider
+// are discovered from its issuer on first use and cached afterwards.
+type Provider struct {
+	config ProviderConfig
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *discoveryDocument
+	keys          *keySet
+	keysFetchedAt time.Time
+}
+
+// NewProvider creates the client of a provider. Requests to the provider are sent with the given HTTP client.
+func NewProvider(config ProviderConfig, client *http.Client) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = defaultScopes
+	}
+	return &Provider{
+		config: config,
+		client: client,
+	}
+}
+
+// Name returns the name of the provider.
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL returns the URL of the provider the user is redirected to for the login.
+// The code challenge is the S256 challenge of the code verifier that is later sent to Exchange.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+	authURL.RawQuery = query.Encode()
+	return authURL.String(), nil
+}
+
+// Exchange exchanges the authorization code for the tokens of the user and returns the claims of the verified ID token.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// RFC 6749 requires the credentials to be form encoded before they are put into the basic authentication
+	req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	defer rsp.Body.Close()
+
+	var token tokenResponse
+	if err := json.NewDecoder(io.LimitReader(rsp.Body, maxResponseSize)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("invalid token response of %s: %w", p.config.Name, err)
+	}
+	if rsp.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("%w: %s %s", ErrProviderRejected, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("%w: no ID token in the token response", ErrProviderRejected)
+	}
+
+	return p.VerifyIDToken(ctx, token.IDToken, nonce)
+}
+
+// discover returns the discovery document of the provider and fetches it on first use.
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery discoveryDocument
+	wellKnown := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := p.getJSON(ctx, wellKnown, &discovery); err != nil {
+		return nil, fmt.Errorf("failed to discover %s: %w", p.config.Name, err)
+	}
+	// The issuer of the document has to be the one it was fetched from, otherwise tokens of another issuer would be accepted
+	if discovery.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("failed to discover %s: issuer %q doesn't match %q", p.config.Name, discovery.Issuer, p.config.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JwksURI == "" {
+		return nil, fmt.Errorf("failed to discover %s: incomplete discovery document", p.config.Name)
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// getJSON fetches a JSON document of the provider.
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s of %s", rsp.Status, url)
+	}
+	return json.NewDecoder(io.LimitReader(rsp.Body, maxResponseSize)).Decode(v)
+}
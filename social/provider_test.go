@@ -0,0 +1,204 @@
+package social
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/oauth"
+	"github.com/Streamfair/streamfair_user_svc/social/socialtest"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+)
+
+const testRedirectURL = "https://localhost:8080/users/login/external/test/callback"
+
+func newTestProvider(t *testing.T) (*Provider, *socialtest.Server) {
+	server := socialtest.NewServer(t, "streamfair", util.RandomString(32))
+	provider := NewProvider(ProviderConfig{
+		Name:         "test",
+		Issuer:       server.Issuer(),
+		ClientID:     server.ClientID,
+		ClientSecret: server.ClientSecret,
+		RedirectURL:  testRedirectURL,
+	}, server.Client())
+	return provider, server
+}
+
+func TestProviderExchange(t *testing.T) {
+	provider, server := newTestProvider(t)
+	ctx := context.Background()
+
+	codeVerifier := util.RandomString(43)
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", oauth.CodeChallenge(codeVerifier))
+	require.NoError(t, err)
+	require.Contains(t, authURL, server.URL+"/authorize?")
+	require.Contains(t, authURL, "scope=openid+email+profile")
+
+	code, state := server.Authorize(t, authURL, map[string]any{
+		"sub":            "12345",
+		"email":          "user@example.com",
+		"email_verified": true,
+	})
+	require.Equal(t, "state", state)
+
+	claims, err := provider.Exchange(ctx, code, codeVerifier, "nonce")
+	require.NoError(t, err)
+	require.Equal(t, "12345", claims.Subject)
+	require.Equal(t, "user@example.com", claims.Email)
+	require.True(t, claims.EmailVerified)
+
+	// Codes can only be exchanged once
+	_, err = provider.Exchange(ctx, code, codeVerifier, "nonce")
+	require.ErrorIs(t, err, ErrProviderRejected)
+
+	// The provider checks the code verifier of the challenge
+	code, _ = server.Authorize(t, authURL, map[string]any{"sub": "12345"})
+	_, err = provider.Exchange(ctx, code, util.RandomString(43), "nonce")
+	require.ErrorIs(t, err, ErrProviderRejected)
+}
+
+func TestProviderDiscoveryIssuerMismatch(t *testing.T) {
+	server := socialtest.NewServer(t, "streamfair", util.RandomString(32))
+	provider := NewProvider(ProviderConfig{
+		Name:     "test",
+		Issuer:   server.Issuer() + "/",
+		ClientID: server.ClientID,
+	}, server.Client())
+
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+	require.ErrorContains(t, err, "doesn't match")
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	provider, server := newTestProvider(t)
+	now := time.Now()
+
+	validClaims := func() map[string]any {
+		return map[string]any{
+			"iss":   server.Issuer(),
+			"sub":   "12345",
+			"aud":   server.ClientID,
+			"exp":   now.Add(time.Hour).Unix(),
+			"iat":   now.Unix(),
+			"nonce": "nonce",
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		modify func(claims map[string]any)
+		nonce  string
+		ok     bool
+	}{
+		{
+			name:   "OK",
+			modify: func(claims map[string]any) {},
+			nonce:  "nonce",
+			ok:     true,
+		},
+		{
+			name: "AudienceList",
+			modify: func(claims map[string]any) {
+				claims["aud"] = []string{"other", server.ClientID}
+				claims["azp"] = server.ClientID
+			},
+			nonce: "nonce",
+			ok:    true,
+		},
+		{
+			name:   "AudienceListWithoutAuthorizedParty",
+			modify: func(claims map[string]any) { claims["aud"] = []string{"other", server.ClientID} },
+			nonce:  "nonce",
+		},
+		{
+			name:   "OtherIssuer",
+			modify: func(claims map[string]any) { claims["iss"] = "https://attacker.example.com" },
+			nonce:  "nonce",
+		},
+		{
+			name:   "OtherAudience",
+			modify: func(claims map[string]any) { claims["aud"] = "other" },
+			nonce:  "nonce",
+		},
+		{
+			name:   "Expired",
+			modify: func(claims map[string]any) { claims["exp"] = now.Add(-2 * clockSkew).Unix() },
+			nonce:  "nonce",
+		},
+		{
+			name:   "IssuedInFuture",
+			modify: func(claims map[string]any) { claims["iat"] = now.Add(2 * clockSkew).Unix() },
+			nonce:  "nonce",
+		},
+		{
+			name:   "NoSubject",
+			modify: func(claims map[string]any) { delete(claims, "sub") },
+			nonce:  "nonce",
+		},
+		{
+			name:   "OtherNonce",
+			modify: func(claims map[string]any) {},
+			nonce:  "other",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := validClaims()
+			tc.modify(claims)
+
+			_, err := provider.VerifyIDToken(context.Background(), server.SignIDToken(t, claims), tc.nonce)
+			if tc.ok {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrInvalidIDToken)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenSignature(t *testing.T) {
+	provider, server := newTestProvider(t)
+	other := socialtest.NewServer(t, server.ClientID, server.ClientSecret)
+
+	claims := map[string]any{
+		"iss":   server.Issuer(),
+		"sub":   "12345",
+		"aud":   server.ClientID,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": "nonce",
+	}
+
+	// Tokens signed by another key with the same key ID are rejected
+	_, err := provider.VerifyIDToken(context.Background(), other.SignIDToken(t, claims), "nonce")
+	require.ErrorIs(t, err, ErrInvalidIDToken)
+
+	// Unsigned tokens are rejected
+	_, err = provider.VerifyIDToken(context.Background(), "eyJhbGciOiJub25lIn0.e30.", "nonce")
+	require.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestLoadProvidersFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "providers.json")
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name": "google", "issuer": "https://accounts.google.com", "client_id": "id", "client_secret": "secret", "redirect_url": "https://localhost/callback"}]`), 0o600))
+	configs, err := LoadProvidersFile(path)
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	require.Equal(t, "https://accounts.google.com", configs[0].Issuer)
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name": "google", "client_id": "id"}]`), 0o600))
+	_, err = LoadProvidersFile(path)
+	require.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"name": "a", "issuer": "i", "client_id": "c", "redirect_url": "r"}, {"name": "a", "issuer": "i", "client_id": "c", "redirect_url": "r"}]`), 0o600))
+	_, err = LoadProvidersFile(path)
+	require.ErrorContains(t, err, "duplicate")
+
+	_, err = LoadProvidersFile(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+}
@@ -0,0 +1,236 @@
+// Package socialtest provides a stand-in OpenID Connect provider for tests of the external login.
+package socialtest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// KeyID is the key ID of the signing key of the server.
+const KeyID = "socialtest"
+
+// authorization is an authorization code issued by the server.
+type authorization struct {
+	codeChallenge string
+	redirectURI   string
+	claims        map[string]any
+}
+
+// Server is a local OpenID Connect provider that serves the discovery document, its signing keys and a token
+// endpoint. Logins at the provider are simulated with Authorize.
+type Server struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+// NewServer starts a new provider with a client of the given ID and secret. It is closed when the test finishes.
+func NewServer(t testing.TB, clientID, clientSecret string) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+
+	server := &Server{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		key:          key,
+		codes:        make(map[string]authorization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", server.discovery)
+	mux.HandleFunc("/jwks", server.jwks)
+	mux.HandleFunc("/token", server.token)
+	server.Server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Issuer returns the issuer of the provider.
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// ProvidersFile writes a providers file with the server as the provider of the name and returns its path.
+func (s *Server) ProvidersFile(t testing.TB, name, redirectURL string) string {
+	data, err := json.Marshal([]map[string]string{{
+		"name":          name,
+		"issuer":        s.Issuer(),
+		"client_id":     s.ClientID,
+		"client_secret": s.ClientSecret,
+		"redirect_url":  redirectURL,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "providers.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write providers file: %v", err)
+	}
+	return path
+}
+
+// Authorize simulates the login of a user at the authorization URL of a client and returns the code and state
+// the provider redirects back with. The claims are put into the ID token and override the default claims,
+// a nil value removes a claim.
+func (s *Server) Authorize(t testing.TB, authCodeURL string, claims map[string]any) (code, state string) {
+	authURL, err := url.Parse(authCodeURL)
+	if err != nil {
+		t.Fatalf("invalid authorization URL: %v", err)
+	}
+	query := authURL.Query()
+	if query.Get("client_id") != s.ClientID || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization request: %s", authCodeURL)
+	}
+
+	now := time.Now()
+	idClaims := map[string]any{
+		"iss":   s.Issuer(),
+		"aud":   s.ClientID,
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		if value == nil {
+			delete(idClaims, name)
+			continue
+		}
+		idClaims[name] = value
+	}
+
+	code = base64.RawURLEncoding.EncodeToString(randomBytes())
+	s.mu.Lock()
+	s.codes[code] = authorization{
+		codeChallenge: query.Get("code_challenge"),
+		redirectURI:   query.Get("redirect_uri"),
+		claims:        idClaims,
+	}
+	s.mu.Unlock()
+	return code, query.Get("state")
+}
+
+// SignIDToken returns an ID token of the claims signed with the key of the server.
+func (s *Server) SignIDToken(t testing.TB, claims map[string]any) string {
+	idToken, err := s.signIDToken(claims)
+	if err != nil {
+		t.Fatalf("failed to sign ID token: %v", err)
+	}
+	return idToken
+}
+
+func (s *Server) signIDToken(claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": KeyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.Issuer(),
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": KeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token exchanges the authorization codes of Authorize for ID tokens after checking the client credentials,
+// the redirect URI and the PKCE code verifier.
+func (s *Server) token(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost || req.ParseForm() != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, clientSecret, _ := req.BasicAuth()
+	if clientID != url.QueryEscape(s.ClientID) || clientSecret != url.QueryEscape(s.ClientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.mu.Lock()
+	code, ok := s.codes[req.PostForm.Get("code")]
+	delete(s.codes, req.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(req.PostForm.Get("code_verifier")))
+	if !ok ||
+		req.PostForm.Get("grant_type") != "authorization_code" ||
+		req.PostForm.Get("redirect_uri") != code.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != code.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken, err := s.signIDToken(code.claims)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": base64.RawURLEncoding.EncodeToString(randomBytes()),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomBytes() []byte {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		panic(err)
+	}
+	return random
+}
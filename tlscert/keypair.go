@@ -0,0 +1,153 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotReloadable = errors.New("certificate was not loaded from files and can't be reloaded")
+	ErrExpired       = errors.New("certificate has expired")
+	ErrNotYetValid   = errors.New("certificate is not valid yet")
+)
+
+// Keypair holds the certificate and private key of the server. TLS configurations take the certificate from the
+// keypair on every handshake, so that a reloaded certificate is used for new connections while established
+// connections keep theirs.
+type Keypair struct {
+	certPath string
+	keyPath  string
+	// roots are the CAs new certificates must be issued by, nil skips the check.
+	roots *x509.CertPool
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// LoadKeypair loads the certificate and private key from PEM files and validates them.
+func LoadKeypair(certPath, keyPath string, roots *x509.CertPool) (*Keypair, error) {
+	keypair := &Keypair{certPath: certPath, keyPath: keyPath, roots: roots}
+	cert, err := keypair.load()
+	if err != nil {
+		return nil, err
+	}
+	keypair.cert = cert
+	return keypair, nil
+}
+
+// NewKeypair creates a keypair from a certificate that was loaded from raw PEM data. It can't be reloaded.
+func NewKeypair(cert tls.Certificate) (*Keypair, error) {
+	if err := validate(&cert, nil, time.Now()); err != nil {
+		return nil, err
+	}
+	return &Keypair{cert: &cert}, nil
+}
+
+// Paths returns the PEM files of the certificate and private key, or empty paths if the keypair can't be reloaded.
+func (k *Keypair) Paths() (certPath, keyPath string) {
+	return k.certPath, k.keyPath
+}
+
+// Leaf returns the parsed certificate of the server.
+func (k *Keypair) Leaf() *x509.Certificate {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.cert.Leaf
+}
+
+// Reload reads the PEM files again and replaces the certificate if the new pair is valid. An invalid pair
+// is returned as error and the current certificate is kept.
+func (k *Keypair) Reload() (*x509.Certificate, error) {
+	if k.certPath == "" {
+		return nil, ErrNotReloadable
+	}
+
+	cert, err := k.load()
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.cert = cert
+	return cert.Leaf, nil
+}
+
+// GetCertificate returns the current certificate for the handshakes of servers.
+func (k *Keypair) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.cert, nil
+}
+
+// GetClientCertificate returns the current certificate for the handshakes of clients, e.g. the gateway
+// that calls the gRPC server.
+func (k *Keypair) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.cert, nil
+}
+
+// Apply makes the TLS configuration take the certificate from the keypair instead of a fixed certificate.
+func (k *Keypair) Apply(tlsConfig *tls.Config) {
+	tlsConfig.Certificates = nil
+	tlsConfig.GetCertificate = k.GetCertificate
+	tlsConfig.GetClientCertificate = k.GetClientCertificate
+}
+
+// load reads and validates the certificate and private key from the PEM files.
+func (k *Keypair) load() (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(k.certPath, k.keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificates: %w", err)
+	}
+	if err := validate(&cert, k.roots, time.Now()); err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// validate checks that the certificate is currently valid and issued by one of the roots, and stores the parsed
+// certificate as leaf. Whether the private key matches the certificate is already checked when loading the pair.
+func validate(cert *tls.Certificate, roots *x509.CertPool, now time.Time) error {
+	if len(cert.Certificate) == 0 {
+		return errors.New("no certificate found")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	if now.Before(leaf.NotBefore) {
+		return fmt.Errorf("%w: valid from %s", ErrNotYetValid, leaf.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(leaf.NotAfter) {
+		return fmt.Errorf("%w: valid until %s", ErrExpired, leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	if roots != nil {
+		intermediates := x509.NewCertPool()
+		for _, der := range cert.Certificate[1:] {
+			intermediate, err := x509.ParseCertificate(der)
+			if err != nil {
+				return fmt.Errorf("failed to parse intermediate certificate: %w", err)
+			}
+			intermediates.AddCert(intermediate)
+		}
+		_, err := leaf.Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			CurrentTime:   now,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			return fmt.Errorf("certificate is not issued by the CA: %w", err)
+		}
+	}
+
+	cert.Leaf = leaf
+	return nil
+}
@@ -0,0 +1,181 @@
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testCA is a CA that issues the certificates of the tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// writePair writes a certificate for the DNS name issued by the CA, valid in the given window, and its key.
+func (ca *testCA) writePair(t *testing.T, dir, name string, notBefore, notAfter time.Time) (certPath, keyPath string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath = filepath.Join(dir, "cert.pem")
+	keyPath = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certPath, keyPath
+}
+
+func TestKeypairReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	now := time.Now()
+	certPath, keyPath := ca.writePair(t, dir, "first.streamfair", now.Add(-time.Minute), now.Add(time.Hour))
+
+	keypair, err := LoadKeypair(certPath, keyPath, ca.pool)
+	require.NoError(t, err)
+	require.Equal(t, "first.streamfair", keypair.Leaf().Subject.CommonName)
+
+	tlsConfig := &tls.Config{}
+	keypair.Apply(tlsConfig)
+	require.Empty(t, tlsConfig.Certificates)
+
+	// New handshakes get the reloaded certificate
+	ca.writePair(t, dir, "second.streamfair", now.Add(-time.Minute), now.Add(time.Hour))
+	leaf, err := keypair.Reload()
+	require.NoError(t, err)
+	require.Equal(t, "second.streamfair", leaf.Subject.CommonName)
+
+	cert, err := tlsConfig.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	require.Equal(t, "second.streamfair", cert.Leaf.Subject.CommonName)
+	cert, err = tlsConfig.GetClientCertificate(&tls.CertificateRequestInfo{})
+	require.NoError(t, err)
+	require.Equal(t, "second.streamfair", cert.Leaf.Subject.CommonName)
+}
+
+func TestKeypairReloadKeepsCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	now := time.Now()
+	certPath, keyPath := ca.writePair(t, dir, "current.streamfair", now.Add(-time.Minute), now.Add(time.Hour))
+
+	keypair, err := LoadKeypair(certPath, keyPath, ca.pool)
+	require.NoError(t, err)
+
+	mismatchedKey, err := os.ReadFile(keyPath)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name  string
+		write func()
+		err   error
+	}{
+		{
+			name: "Expired",
+			write: func() {
+				ca.writePair(t, dir, "expired.streamfair", now.Add(-2*time.Hour), now.Add(-time.Hour))
+			},
+			err: ErrExpired,
+		},
+		{
+			name: "NotYetValid",
+			write: func() {
+				ca.writePair(t, dir, "future.streamfair", now.Add(time.Hour), now.Add(2*time.Hour))
+			},
+			err: ErrNotYetValid,
+		},
+		{
+			name: "OtherCA",
+			write: func() {
+				newTestCA(t).writePair(t, dir, "other.streamfair", now.Add(-time.Minute), now.Add(time.Hour))
+			},
+		},
+		{
+			name: "MismatchedKey",
+			write: func() {
+				ca.writePair(t, dir, "mismatched.streamfair", now.Add(-time.Minute), now.Add(time.Hour))
+				require.NoError(t, os.WriteFile(keyPath, mismatchedKey, 0o600))
+			},
+		},
+		{
+			name: "MissingFile",
+			write: func() {
+				require.NoError(t, os.Remove(certPath))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.write()
+			_, err := keypair.Reload()
+			require.Error(t, err)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+			}
+			require.Equal(t, "current.streamfair", keypair.Leaf().Subject.CommonName)
+		})
+	}
+}
+
+func TestNewKeypairCantBeReloaded(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certPath, keyPath := ca.writePair(t, dir, "ci.streamfair", time.Now().Add(-time.Minute), time.Now().Add(time.Hour))
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	require.NoError(t, err)
+	keypair, err := NewKeypair(cert)
+	require.NoError(t, err)
+	require.Equal(t, "ci.streamfair", keypair.Leaf().Subject.CommonName)
+
+	_, err = keypair.Reload()
+	require.ErrorIs(t, err, ErrNotReloadable)
+}
@@ -0,0 +1,64 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// IDClaims are the claims of an OpenID Connect ID token, which tells a client who signed in.
+type IDClaims struct {
+	Issuer   string
+	Subject  string
+	Audience string
+	Nonce    string
+	AuthTime time.Time
+}
+
+// idTokenHeader is the JOSE header of ID tokens. The key ID matches the key IDs of the JSON web key set.
+type idTokenHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+// idTokenPayload is the payload of ID tokens as defined by OpenID Connect Core 1.0, section 2.
+type idTokenPayload struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud"`
+	ExpiresAt int64  `json:"exp"`
+	IssuedAt  int64  `json:"iat"`
+	AuthTime  int64  `json:"auth_time,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+}
+
+// CreateIDToken creates an ID token, a JWT signed with EdDSA, so that clients can verify it with the published keys.
+func (maker *PublicPasetoMaker) CreateIDToken(claims IDClaims, duration time.Duration) (string, error) {
+	now := time.Now()
+	payload := idTokenPayload{
+		Issuer:    claims.Issuer,
+		Subject:   claims.Subject,
+		Audience:  claims.Audience,
+		ExpiresAt: now.Add(duration).Unix(),
+		IssuedAt:  now.Unix(),
+		Nonce:     claims.Nonce,
+	}
+	if !claims.AuthTime.IsZero() {
+		payload.AuthTime = claims.AuthTime.Unix()
+	}
+
+	header, err := json.Marshal(idTokenHeader{Algorithm: "EdDSA", Type: "JWT", KeyID: maker.signingKID})
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	signature := ed25519.Sign(maker.signingKey, []byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
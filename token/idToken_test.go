@@ -0,0 +1,70 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateIDToken(t *testing.T) {
+	maker, err := NewPublicPasetoMaker(randomKeyring(t), []string{randomPrivateKey(t)}, DefaultIssuer, DefaultAudience)
+	require.NoError(t, err)
+
+	claims := IDClaims{
+		Issuer:   "https://auth.example.com",
+		Subject:  "42",
+		Audience: "web-app",
+		Nonce:    "n-0S6_WzA2Mj",
+		AuthTime: time.Now().Add(-time.Second),
+	}
+	issuedAt := time.Now()
+
+	idToken, err := maker.CreateIDToken(claims, time.Minute)
+	require.NoError(t, err)
+
+	parts := strings.Split(idToken, ".")
+	require.Len(t, parts, 3)
+
+	// The token verifies with the published key of the key ID in its header
+	var header idTokenHeader
+	requireDecodeSegment(t, parts[0], &header)
+	require.Equal(t, "EdDSA", header.Algorithm)
+	require.Equal(t, "JWT", header.Type)
+
+	key := NewJSONWebKeySet(maker.PublicKeys()).Keys[0]
+	require.Equal(t, key.KeyID, header.KeyID)
+	publicKey, err := base64.RawURLEncoding.DecodeString(key.X)
+	require.NoError(t, err)
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	require.True(t, ed25519.Verify(publicKey, []byte(parts[0]+"."+parts[1]), signature))
+
+	var payload idTokenPayload
+	requireDecodeSegment(t, parts[1], &payload)
+	require.Equal(t, claims.Issuer, payload.Issuer)
+	require.Equal(t, claims.Subject, payload.Subject)
+	require.Equal(t, claims.Audience, payload.Audience)
+	require.Equal(t, claims.Nonce, payload.Nonce)
+	require.Equal(t, claims.AuthTime.Unix(), payload.AuthTime)
+	require.WithinDuration(t, issuedAt, time.Unix(payload.IssuedAt, 0), time.Second)
+	require.WithinDuration(t, issuedAt.Add(time.Minute), time.Unix(payload.ExpiresAt, 0), time.Second)
+}
+
+func TestCreateIDTokenWithoutSigningKey(t *testing.T) {
+	maker := NewKeyringPasetoMaker(randomKeyring(t), DefaultIssuer, DefaultAudience)
+
+	idToken, err := maker.CreateIDToken(IDClaims{Subject: "42"}, time.Minute)
+	require.ErrorIs(t, err, ErrPublicTokensDisabled)
+	require.Empty(t, idToken)
+}
+
+func requireDecodeSegment(t *testing.T, segment string, v any) {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, v))
+}
@@ -0,0 +1,36 @@
+package token
+
+import "encoding/base64"
+
+// JSONWebKey is an Ed25519 public key in the JWK format (RFC 8037), so that other services can load
+// the keys that verify public tokens with their JOSE libraries.
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+// JSONWebKeySet is the set of public keys published by the service.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewJSONWebKeySet converts the public keys of a maker into a JSON web key set.
+// The key IDs match the key IDs in the footers of the public tokens.
+func NewJSONWebKeySet(keys []PublicKey) JSONWebKeySet {
+	set := JSONWebKeySet{Keys: make([]JSONWebKey, len(keys))}
+	for i, key := range keys {
+		set.Keys[i] = JSONWebKey{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(key.Key),
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: "EdDSA",
+		}
+	}
+	return set
+}
@@ -0,0 +1,150 @@
+package token
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/aead/chacha20poly1305"
+	"golang.org/x/crypto/blake2b"
+)
+
+// localKeyIDHeader is the header of PASERK key IDs of v2.local keys.
+const localKeyIDHeader = "k2.lid."
+
+// SymmetricKey is a key of the keyring that creates and verifies local tokens.
+type SymmetricKey struct {
+	ID  string `json:"id"`
+	Key string `json:"key"`
+	// Retired keys don't verify tokens anymore. They can be kept in the keyring file for reference.
+	Retired bool `json:"retired"`
+}
+
+// keyringFile is the format of a keyring file.
+type keyringFile struct {
+	// Active is the ID of the key that creates new tokens.
+	Active string         `json:"active"`
+	Keys   []SymmetricKey `json:"keys"`
+}
+
+// Keyring holds the symmetric keys of local tokens: the active key creates new tokens, all keys that are not retired
+// verify them. Tokens name their key in the footer, so that keys can be rotated without invalidating issued tokens.
+// The keys can be replaced at runtime.
+type Keyring struct {
+	mu     sync.RWMutex
+	active SymmetricKey
+	keys   []SymmetricKey
+}
+
+// NewKeyring creates a new keyring with the given active key and keys for verification. Keys without ID are
+// identified by their PASERK key ID.
+func NewKeyring(activeID string, keys []SymmetricKey) (*Keyring, error) {
+	keyring := &Keyring{}
+	seen := make(map[string]bool, len(keys))
+
+	for _, key := range keys {
+		if len(key.Key) != chacha20poly1305.KeySize {
+			return nil, fmt.Errorf("invalid size of key %q: must be exactly %d characters", key.ID, chacha20poly1305.KeySize)
+		}
+		if key.ID == "" {
+			key.ID = LocalKeyID([]byte(key.Key))
+		}
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate key ID %q", key.ID)
+		}
+		seen[key.ID] = true
+
+		if key.ID == activeID {
+			if key.Retired {
+				return nil, fmt.Errorf("active key %q is retired", key.ID)
+			}
+			keyring.active = key
+		}
+		keyring.keys = append(keyring.keys, key)
+	}
+
+	if keyring.active.ID == "" {
+		return nil, fmt.Errorf("active key %q is not in the keyring", activeID)
+	}
+	return keyring, nil
+}
+
+// NewKeyringFromKeys creates a keyring from the active key and previous keys that still verify tokens.
+func NewKeyringFromKeys(activeKey string, previousKeys []string) (*Keyring, error) {
+	keys := []SymmetricKey{{Key: activeKey}}
+	for _, key := range previousKeys {
+		keys = append(keys, SymmetricKey{Key: key})
+	}
+	return NewKeyring(LocalKeyID([]byte(activeKey)), keys)
+}
+
+// LoadKeyringFile loads a keyring from a JSON file of the form
+// {"active": "2024-06", "keys": [{"id": "2024-06", "key": "..."}, {"id": "2024-01", "key": "...", "retired": true}]}.
+func LoadKeyringFile(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid keyring file: %w", err)
+	}
+	for _, key := range file.Keys {
+		if key.ID == "" {
+			return nil, errors.New("invalid keyring file: all keys need an ID")
+		}
+	}
+	return NewKeyring(file.Active, file.Keys)
+}
+
+// LoadKeyring loads the keyring from the file if a path is given and creates it from the keys otherwise.
+func LoadKeyring(path, activeKey string, previousKeys []string) (*Keyring, error) {
+	if path != "" {
+		return LoadKeyringFile(path)
+	}
+	return NewKeyringFromKeys(activeKey, previousKeys)
+}
+
+// Active returns the key that creates new tokens.
+func (k *Keyring) Active() SymmetricKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.active
+}
+
+// Lookup returns the key with the given ID if it may verify tokens.
+func (k *Keyring) Lookup(id string) (SymmetricKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	for _, key := range k.keys {
+		if key.ID == id && !key.Retired {
+			return key, true
+		}
+	}
+	return SymmetricKey{}, false
+}
+
+// Replace replaces the keys with the ones of another keyring, e.g. after the keyring file changed.
+func (k *Keyring) Replace(other *Keyring) {
+	other.mu.RLock()
+	active, keys := other.active, other.keys
+	other.mu.RUnlock()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.active, k.keys = active, keys
+}
+
+// LocalKeyID returns the PASERK key ID (k2.lid) of a symmetric key.
+func LocalKeyID(key []byte) string {
+	paserk := "k2.local." + base64.RawURLEncoding.EncodeToString(key)
+
+	hash, _ := blake2b.New(33, nil)
+	hash.Write([]byte(localKeyIDHeader))
+	hash.Write([]byte(paserk))
+	return localKeyIDHeader + base64.RawURLEncoding.EncodeToString(hash.Sum(nil))
+}
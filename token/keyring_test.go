@@ -0,0 +1,166 @@
+package token
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/o1egl/paseto"
+	"github.com/stretchr/testify/require"
+)
+
+func randomKeyring(t *testing.T) *Keyring {
+	keyring, err := NewKeyringFromKeys(util.RandomString(32), nil)
+	require.NoError(t, err)
+	return keyring
+}
+
+func writeKeyringFile(t *testing.T, path, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey := util.RandomString(32)
+	newKey := util.RandomString(32)
+
+	keyring, err := NewKeyringFromKeys(oldKey, nil)
+	require.NoError(t, err)
+	maker := NewKeyringPasetoMaker(keyring, DefaultIssuer, DefaultAudience)
+
+	token, _, err := maker.CreateLocalToken(randomClaims(), time.Minute)
+	require.NoError(t, err)
+
+	// The footer names the key the token was created with
+	var footer tokenFooter
+	require.NoError(t, paseto.ParseFooter(token, &footer))
+	require.Equal(t, LocalKeyID([]byte(oldKey)), footer.KeyID)
+
+	// After the rotation new tokens are created with the new key and old tokens are still valid
+	rotated, err := NewKeyringFromKeys(newKey, []string{oldKey})
+	require.NoError(t, err)
+	keyring.Replace(rotated)
+
+	_, err = maker.VerifyLocalToken(token)
+	require.NoError(t, err)
+
+	newToken, _, err := maker.CreateLocalToken(randomClaims(), time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, paseto.ParseFooter(newToken, &footer))
+	require.Equal(t, LocalKeyID([]byte(newKey)), footer.KeyID)
+
+	// Once the old key is removed its tokens are rejected
+	withoutOldKey, err := NewKeyringFromKeys(newKey, nil)
+	require.NoError(t, err)
+	keyring.Replace(withoutOldKey)
+
+	_, err = maker.VerifyLocalToken(token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	_, err = maker.VerifyLocalToken(newToken)
+	require.NoError(t, err)
+}
+
+func TestKeyringRetiredKey(t *testing.T) {
+	oldKey := util.RandomString(32)
+	newKey := util.RandomString(32)
+
+	keyring, err := NewKeyring("old", []SymmetricKey{{ID: "old", Key: oldKey}})
+	require.NoError(t, err)
+	maker := NewKeyringPasetoMaker(keyring, DefaultIssuer, DefaultAudience)
+
+	token, _, err := maker.CreateLocalToken(randomClaims(), time.Minute)
+	require.NoError(t, err)
+
+	retired, err := NewKeyring("new", []SymmetricKey{
+		{ID: "new", Key: newKey},
+		{ID: "old", Key: oldKey, Retired: true},
+	})
+	require.NoError(t, err)
+	keyring.Replace(retired)
+
+	_, ok := keyring.Lookup("old")
+	require.False(t, ok)
+
+	_, err = maker.VerifyLocalToken(token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestKeyringTokenWithoutKeyID(t *testing.T) {
+	oldKey := util.RandomString(32)
+
+	// Tokens without a footer don't name their key and are rejected
+	token, err := paseto.NewV2().Encrypt([]byte(oldKey), &Payload{
+		Username:  util.RandomUsername(),
+		TokenType: TokenTypeAccess,
+		Issuer:    DefaultIssuer,
+		Audience:  DefaultAudience,
+		IssuedAt:  time.Now(),
+		ExpiredAt: time.Now().Add(time.Minute),
+	}, nil)
+	require.NoError(t, err)
+
+	keyring, err := NewKeyringFromKeys(util.RandomString(32), []string{oldKey})
+	require.NoError(t, err)
+
+	_, err = NewKeyringPasetoMaker(keyring, DefaultIssuer, DefaultAudience).VerifyLocalToken(token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestNewKeyringInvalidKeys(t *testing.T) {
+	key := util.RandomString(32)
+
+	testCases := []struct {
+		name     string
+		activeID string
+		keys     []SymmetricKey
+	}{
+		{"InvalidKeySize", "a", []SymmetricKey{{ID: "a", Key: "short"}}},
+		{"DuplicateID", "a", []SymmetricKey{{ID: "a", Key: key}, {ID: "a", Key: util.RandomString(32)}}},
+		{"MissingActiveKey", "b", []SymmetricKey{{ID: "a", Key: key}}},
+		{"RetiredActiveKey", "a", []SymmetricKey{{ID: "a", Key: key, Retired: true}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewKeyring(tc.activeID, tc.keys)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestLoadKeyringFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	newKey := util.RandomString(32)
+	oldKey := util.RandomString(32)
+
+	writeKeyringFile(t, path, `{"active": "2024-06", "keys": [
+		{"id": "2024-06", "key": "`+newKey+`"},
+		{"id": "2024-01", "key": "`+oldKey+`", "retired": true}
+	]}`)
+
+	keyring, err := LoadKeyring(path, "", nil)
+	require.NoError(t, err)
+	require.Equal(t, SymmetricKey{ID: "2024-06", Key: newKey}, keyring.Active())
+	_, ok := keyring.Lookup("2024-01")
+	require.False(t, ok)
+
+	// Keys of a keyring file need explicit IDs
+	writeKeyringFile(t, path, `{"active": "a", "keys": [{"key": "`+newKey+`"}]}`)
+	_, err = LoadKeyringFile(path)
+	require.Error(t, err)
+
+	writeKeyringFile(t, path, `not json`)
+	_, err = LoadKeyringFile(path)
+	require.Error(t, err)
+
+	_, err = LoadKeyringFile(filepath.Join(t.TempDir(), "missing.json"))
+	require.Error(t, err)
+
+	// Without a file the keyring is created from the configured keys
+	keyring, err = LoadKeyring("", newKey, []string{oldKey})
+	require.NoError(t, err)
+	require.Equal(t, LocalKeyID([]byte(newKey)), keyring.Active().ID)
+	_, ok = keyring.Lookup(LocalKeyID([]byte(oldKey)))
+	require.True(t, ok)
+}
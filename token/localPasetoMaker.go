@@ -10,8 +10,10 @@ import (
 
 // PasetoMaker is a PASETO implementation of a token maker
 type PasetoMaker struct {
-	paseto       *paseto.V2
-	symmetricKey []byte
+	paseto   *paseto.V2
+	keyring  *Keyring
+	issuer   string
+	audience string
 }
 
 // NewPasetoMaker creates a new PasetoMaker
@@ -20,38 +22,106 @@ func NewLocalPasetoMaker(symmetricKey string) (Maker, error) {
 		return nil, fmt.Errorf("invalid key size: must be exactly %d characters", chacha20poly1305.KeySize)
 	}
 
-	maker := &PasetoMaker{
-		paseto:       paseto.NewV2(),
-		symmetricKey: []byte(symmetricKey),
+	keyring, err := NewKeyringFromKeys(symmetricKey, nil)
+	if err != nil {
+		return nil, err
 	}
+	return NewKeyringPasetoMaker(keyring, DefaultIssuer, DefaultAudience), nil
+}
 
-	return maker, nil
+// NewKeyringPasetoMaker creates a new PasetoMaker that creates local tokens with the active key of the keyring
+// and verifies them with all keys that are not retired. Only tokens of the given issuer and audience are valid.
+func NewKeyringPasetoMaker(keyring *Keyring, issuer, audience string) *PasetoMaker {
+	return &PasetoMaker{
+		paseto:   paseto.NewV2(),
+		keyring:  keyring,
+		issuer:   issuer,
+		audience: audience,
+	}
+}
+
+// CreateToken creates a new access token for a specific user and duration
+func (maker *PasetoMaker) CreateLocalToken(claims Claims, duration time.Duration) (string, *Payload, error) {
+	return maker.createLocalToken(claims, TokenTypeAccess, duration)
+}
+
+// VerifyToken checks if the access token is valid or not
+func (maker *PasetoMaker) VerifyLocalToken(token string) (*Payload, error) {
+	return maker.verifyLocalToken(token, TokenTypeAccess)
 }
 
-// CreateToken creates a new token for a specific username and duration
-func (maker *PasetoMaker) CreateLocalToken(username string, duration time.Duration) (string, *Payload, error) {
-	payload, err := NewPayload(username, duration)
+// CreateRefreshToken creates a new local refresh token for a specific user and duration
+func (maker *PasetoMaker) CreateRefreshToken(claims Claims, duration time.Duration) (string, *Payload, error) {
+	return maker.createLocalToken(claims, TokenTypeRefresh, duration)
+}
+
+// VerifyRefreshToken checks if the refresh token is valid or not
+func (maker *PasetoMaker) VerifyRefreshToken(token string) (*Payload, error) {
+	return maker.verifyLocalToken(token, TokenTypeRefresh)
+}
+
+// createLocalToken creates a new local token of the given type with the active key of the keyring
+func (maker *PasetoMaker) createLocalToken(claims Claims, tokenType string, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(claims, tokenType, maker.issuer, maker.audience, duration)
 	if err != nil {
 		return "", payload, err
 	}
 
-	token, err := maker.paseto.Encrypt(maker.symmetricKey, payload, nil)
+	key := maker.keyring.Active()
+	token, err := maker.paseto.Encrypt([]byte(key.Key), payload, tokenFooter{KeyID: key.ID})
 	return token, payload, err
 }
 
-// VerifyToken checks if the token is valid or not
-func (maker *PasetoMaker) VerifyLocalToken(token string) (*Payload, error) {
-	payload := &Payload{}
+// verifyLocalToken checks if the local token is valid and of the given type
+func (maker *PasetoMaker) verifyLocalToken(token, tokenType string) (*Payload, error) {
+	var footer tokenFooter
+	if err := paseto.ParseFooter(token, &footer); err != nil {
+		return nil, ErrInvalidToken
+	}
 
-	err := maker.paseto.Decrypt(token, maker.symmetricKey, payload, nil)
-	if err != nil {
+	// Tokens name their key in the footer
+	key, ok := maker.keyring.Lookup(footer.KeyID)
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{}
+	if err := maker.paseto.Decrypt(token, []byte(key.Key), payload, nil); err != nil {
 		return nil, ErrInvalidToken
 	}
 
-	err = payload.Valid()
+	err := payload.Valid(maker.issuer, maker.audience)
 	if err != nil {
 		return nil, err
 	}
+	if err := payload.checkType(tokenType); err != nil {
+		return nil, err
+	}
 
 	return payload, nil
 }
+
+// CreatePublicToken fails, because the maker has no signing key for public tokens
+func (maker *PasetoMaker) CreatePublicToken(claims Claims, duration time.Duration) (string, *Payload, error) {
+	return "", nil, ErrPublicTokensDisabled
+}
+
+// VerifyPublicToken rejects all public tokens, because the maker has no public keys
+func (maker *PasetoMaker) VerifyPublicToken(token string) (*Payload, error) {
+	return nil, ErrInvalidToken
+}
+
+// CreateIDToken fails, because the maker has no signing key for ID tokens
+func (maker *PasetoMaker) CreateIDToken(claims IDClaims, duration time.Duration) (string, error) {
+	return "", ErrPublicTokensDisabled
+}
+
+// PublicKeys returns no keys, because the maker only creates local tokens
+func (maker *PasetoMaker) PublicKeys() []PublicKey {
+	return nil
+}
+
+// Keyring returns the keyring of local tokens, whose keys can be replaced at runtime.
+func (maker *PasetoMaker) Keyring() *Keyring {
+	return maker.keyring
+}
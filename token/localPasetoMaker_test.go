@@ -8,17 +8,26 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func randomClaims() Claims {
+	return Claims{
+		UserID:   util.RandomInt(1, 1000),
+		Username: util.RandomUsername(),
+		RoleID:   util.RandomInt(1, 3),
+		Scopes:   []string{"users:read", "users:list"},
+	}
+}
+
 func TestLocalPasetoMaker(t *testing.T) {
 	maker, err := NewLocalPasetoMaker(util.RandomString(32))
 	require.NoError(t, err)
 
-	username := util.RandomUsername()
+	claims := randomClaims()
 	duration := time.Minute
 
 	issuedAt := time.Now()
 	expiredAt := issuedAt.Add(duration)
 
-	token, payload, err := maker.CreateLocalToken(username, duration)
+	token, payload, err := maker.CreateLocalToken(claims, duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -28,8 +37,16 @@ func TestLocalPasetoMaker(t *testing.T) {
 	require.NotEmpty(t, payload)
 
 	require.NotZero(t, payload.ID)
-	require.Equal(t, username, payload.Username)
+	require.Equal(t, claims.UserID, payload.UserID)
+	require.Equal(t, claims.Username, payload.Username)
+	require.Equal(t, claims.RoleID, payload.RoleID)
+	require.Equal(t, claims.Scopes, payload.Scopes)
+	require.True(t, payload.HasScope("users:read"))
+	require.False(t, payload.HasScope("users:delete"))
+	require.Equal(t, DefaultIssuer, payload.Issuer)
+	require.Equal(t, DefaultAudience, payload.Audience)
 	require.WithinDuration(t, issuedAt, payload.IssuedAt, time.Second)
+	require.WithinDuration(t, issuedAt, payload.NotBefore, time.Second)
 	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second)
 }
 
@@ -37,10 +54,9 @@ func TestExpiredToken(t *testing.T) {
 	maker, err := NewLocalPasetoMaker(util.RandomString(32))
 	require.NoError(t, err)
 
-	username := util.RandomUsername()
 	duration := -time.Minute
 
-	token, payload, err := maker.CreateLocalToken(username, duration)
+	token, payload, err := maker.CreateLocalToken(randomClaims(), duration)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
@@ -60,3 +76,53 @@ func TestInvalidToken(t *testing.T) {
 	require.EqualError(t, err, ErrInvalidToken.Error())
 	require.Nil(t, payload)
 }
+
+func TestTokenOfOtherIssuerOrAudience(t *testing.T) {
+	keyring := randomKeyring(t)
+	maker := NewKeyringPasetoMaker(keyring, DefaultIssuer, DefaultAudience)
+
+	otherIssuer := NewKeyringPasetoMaker(keyring, "other_svc", DefaultAudience)
+	token, _, err := otherIssuer.CreateLocalToken(randomClaims(), time.Minute)
+	require.NoError(t, err)
+	_, err = maker.VerifyLocalToken(token)
+	require.EqualError(t, err, ErrInvalidIssuer.Error())
+
+	otherAudience := NewKeyringPasetoMaker(keyring, DefaultIssuer, "other")
+	token, _, err = otherAudience.CreateLocalToken(randomClaims(), time.Minute)
+	require.NoError(t, err)
+	_, err = maker.VerifyLocalToken(token)
+	require.EqualError(t, err, ErrInvalidAudience.Error())
+}
+
+func TestPayloadNotValidYet(t *testing.T) {
+	payload, err := NewPayload(randomClaims(), TokenTypeAccess, DefaultIssuer, DefaultAudience, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, payload.Valid(DefaultIssuer, DefaultAudience))
+
+	payload.NotBefore = time.Now().Add(time.Minute)
+	require.EqualError(t, payload.Valid(DefaultIssuer, DefaultAudience), ErrTokenNotValidYet.Error())
+}
+
+func TestRefreshToken(t *testing.T) {
+	maker, err := NewLocalPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	refreshToken, payload, err := maker.CreateRefreshToken(randomClaims(), time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, TokenTypeRefresh, payload.TokenType)
+
+	verified, err := maker.VerifyRefreshToken(refreshToken)
+	require.NoError(t, err)
+	require.Equal(t, payload.ID, verified.ID)
+
+	// Refresh tokens don't authenticate requests and access tokens don't renew access tokens
+	_, err = maker.VerifyLocalToken(refreshToken)
+	require.EqualError(t, err, ErrInvalidTokenType.Error())
+	_, err = VerifyToken(maker, refreshToken)
+	require.EqualError(t, err, ErrInvalidTokenType.Error())
+
+	accessToken, _, err := maker.CreateLocalToken(randomClaims(), time.Hour)
+	require.NoError(t, err)
+	_, err = maker.VerifyRefreshToken(accessToken)
+	require.EqualError(t, err, ErrInvalidTokenType.Error())
+}
@@ -4,9 +4,45 @@ import "time"
 
 // Maker is an interface for managing tokens
 type Maker interface {
-	// CreateLocalToken creates a new local token for a specific username and duration
-	CreateLocalToken(username string, duration time.Duration) (string, *Payload, error)
+	// CreateLocalToken creates a new local token for a specific user and duration
+	CreateLocalToken(claims Claims, duration time.Duration) (string, *Payload, error)
 
 	// VerifyLocalToken checks if the local token is valid or not
 	VerifyLocalToken(token string) (*Payload, error)
+
+	// CreatePublicToken creates a new public token for a specific user and duration
+	CreatePublicToken(claims Claims, duration time.Duration) (string, *Payload, error)
+
+	// VerifyPublicToken checks if the public token is valid or not
+	VerifyPublicToken(token string) (*Payload, error)
+
+	// CreateRefreshToken creates a new local refresh token for a specific user and duration
+	CreateRefreshToken(claims Claims, duration time.Duration) (string, *Payload, error)
+
+	// VerifyRefreshToken checks if the refresh token is valid or not
+	VerifyRefreshToken(token string) (*Payload, error)
+
+	// CreateIDToken creates a new OpenID Connect ID token signed with the key of public tokens
+	CreateIDToken(claims IDClaims, duration time.Duration) (string, error)
+
+	// PublicKeys returns the public keys that verify public tokens
+	PublicKeys() []PublicKey
+}
+
+// NewPasetoMaker creates a PublicPasetoMaker if Ed25519 private keys are given and a local-only PasetoMaker otherwise.
+// Local tokens are created and verified with the keys of the keyring. All tokens carry the issuer and audience
+// and tokens of other issuers or for other audiences are rejected.
+func NewPasetoMaker(keyring *Keyring, privateKeys []string, issuer, audience string) (Maker, error) {
+	if len(privateKeys) == 0 {
+		return NewKeyringPasetoMaker(keyring, issuer, audience), nil
+	}
+	return NewPublicPasetoMaker(keyring, privateKeys, issuer, audience)
+}
+
+// VerifyToken checks a local or public access token, depending on the header of the token.
+func VerifyToken(maker Maker, token string) (*Payload, error) {
+	if IsPublicToken(token) {
+		return maker.VerifyPublicToken(token)
+	}
+	return maker.VerifyLocalToken(token)
 }
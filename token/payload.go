@@ -7,39 +7,116 @@ import (
 	"github.com/google/uuid"
 )
 
+// Default issuer and audience of the tokens of the service.
+const (
+	DefaultIssuer   = "streamfair_user_svc"
+	DefaultAudience = "streamfair"
+)
+
 // Different types of errors returned by the VerifyToken function
 var (
-	ErrInvalidToken = errors.New("token is invalid")
-	ErrExpiredToken = errors.New("token has expired")
+	ErrInvalidToken     = errors.New("token is invalid")
+	ErrExpiredToken     = errors.New("token has expired")
+	ErrTokenNotValidYet = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("token has an invalid issuer")
+	ErrInvalidAudience  = errors.New("token has an invalid audience")
+	ErrInvalidTokenType = errors.New("token has an invalid type")
+)
+
+// Types of tokens, stored in the token_use claim. Access tokens authenticate requests, refresh tokens only renew
+// access tokens, so that a leaked refresh token can't be used as a long lived access token and vice versa.
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
 )
 
+// Claims describe the user a token is issued to.
+type Claims struct {
+	UserID   int64
+	Username string
+	// RoleID is 0 for users without a role.
+	RoleID int64
+	// Scopes are the permissions granted by the role of the user.
+	Scopes []string
+	// ClientID is the OAuth client the token was issued to, with the scopes granted to the client.
+	// Tokens of the client credentials grant have a client but no user.
+	ClientID string
+}
+
+// Payload is the content of a token. The token ID, username and the times are stored in the registered
+// PASETO claims jti, sub, iat, nbf and exp, so that other services can verify tokens with any PASETO library.
 type Payload struct {
-	ID        uuid.UUID `json:"id"`
-	Username  string    `json:"username"`
-	IssuedAt time.Time `json:"issued_at"`
-	ExpiredAt time.Time `json:"expired_at"`
+	ID        uuid.UUID `json:"jti"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"sub"`
+	RoleID    int64     `json:"role_id"`
+	Scopes    []string  `json:"scopes"`
+	ClientID  string    `json:"client_id,omitempty"`
+	TokenType string    `json:"token_use"`
+	Issuer    string    `json:"iss"`
+	Audience  string    `json:"aud"`
+	IssuedAt  time.Time `json:"iat"`
+	NotBefore time.Time `json:"nbf"`
+	ExpiredAt time.Time `json:"exp"`
 }
 
-func NewPayload(username string, duration time.Duration) (*Payload, error) {
+func NewPayload(claims Claims, tokenType, issuer, audience string, duration time.Duration) (*Payload, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now()
 	payload := &Payload{
 		ID:        tokenID,
-		Username:  username,
-		IssuedAt: time.Now(),
-		ExpiredAt: time.Now().Add(duration),
+		UserID:    claims.UserID,
+		Username:  claims.Username,
+		RoleID:    claims.RoleID,
+		Scopes:    claims.Scopes,
+		ClientID:  claims.ClientID,
+		TokenType: tokenType,
+		Issuer:    issuer,
+		Audience:  audience,
+		IssuedAt:  now,
+		NotBefore: now,
+		ExpiredAt: now.Add(duration),
 	}
 
 	return payload, nil
 }
 
-// Valid checks if the token payload is valid or not
-func (p *Payload) Valid() error {
-	if time.Now().After(p.ExpiredAt) {
+// Valid checks if the token payload is valid or not, and if it was issued by the given issuer for the given audience
+func (p *Payload) Valid(issuer, audience string) error {
+	now := time.Now()
+	if now.After(p.ExpiredAt) {
 		return ErrExpiredToken
 	}
+	if now.Before(p.NotBefore) {
+		return ErrTokenNotValidYet
+	}
+	if p.Issuer != issuer {
+		return ErrInvalidIssuer
+	}
+	if p.Audience != audience {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// checkType checks if the payload is of the given token type.
+func (p *Payload) checkType(tokenType string) error {
+	if p.TokenType != tokenType {
+		return ErrInvalidTokenType
+	}
 	return nil
 }
+
+// HasScope reports whether the token grants the given scope.
+func (p *Payload) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package token
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloadRegisteredClaims(t *testing.T) {
+	payload, err := NewPayload(randomClaims(), TokenTypeAccess, DefaultIssuer, DefaultAudience, time.Minute)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	var claims map[string]any
+	require.NoError(t, json.Unmarshal(data, &claims))
+	for _, claim := range []string{"jti", "sub", "token_use", "iss", "aud", "iat", "nbf", "exp"} {
+		require.Contains(t, claims, claim)
+	}
+	require.Equal(t, payload.ID.String(), claims["jti"])
+	require.Equal(t, payload.Username, claims["sub"])
+	require.NotContains(t, claims, "expired_at")
+	require.NotContains(t, claims, "issued_at")
+
+	var decoded Payload
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, payload.ID, decoded.ID)
+	require.Equal(t, payload.Username, decoded.Username)
+	require.WithinDuration(t, payload.ExpiredAt, decoded.ExpiredAt, time.Second)
+}
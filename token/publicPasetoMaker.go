@@ -0,0 +1,238 @@
+package token
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	// publicTokenHeader is the header of PASETO v4.public tokens, which are signed with Ed25519.
+	publicTokenHeader = "v4.public."
+	// publicKeyIDHeader is the header of PASERK key IDs of v4.public keys.
+	publicKeyIDHeader = "k4.pid."
+)
+
+// ErrPublicTokensDisabled is returned by makers that have no signing key for public tokens.
+var ErrPublicTokensDisabled = errors.New("public tokens are not configured")
+
+// PublicKey is a public key that verifies public tokens, identified by its PASERK key ID.
+type PublicKey struct {
+	ID  string
+	Key ed25519.PublicKey
+}
+
+// tokenFooter is the unencrypted but authenticated footer of a token, which names the key it was created with.
+type tokenFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// PublicPasetoMaker is a PASETO implementation of a token maker that signs public tokens with Ed25519,
+// so that other services can verify them with the published public keys. Local tokens are still
+// created with the symmetric key.
+type PublicPasetoMaker struct {
+	*PasetoMaker
+	signingKey ed25519.PrivateKey
+	signingKID string
+	publicKeys []PublicKey
+}
+
+// NewPublicPasetoMaker creates a new PublicPasetoMaker from the keyring of local tokens and hex encoded Ed25519
+// private keys, either as 32 byte seeds or as 64 byte private keys. The first private key signs new tokens, the others
+// only verify tokens created before a key rotation.
+func NewPublicPasetoMaker(keyring *Keyring, privateKeys []string, issuer, audience string) (Maker, error) {
+	if len(privateKeys) == 0 {
+		return nil, errors.New("at least one private key is required")
+	}
+
+	maker := &PublicPasetoMaker{PasetoMaker: NewKeyringPasetoMaker(keyring, issuer, audience)}
+	for i, privateKey := range privateKeys {
+		key, err := parsePrivateKey(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key %d: %w", i, err)
+		}
+
+		publicKey := key.Public().(ed25519.PublicKey)
+		maker.publicKeys = append(maker.publicKeys, PublicKey{ID: PublicKeyID(publicKey), Key: publicKey})
+		if i == 0 {
+			maker.signingKey = key
+			maker.signingKID = maker.publicKeys[0].ID
+		}
+	}
+
+	return maker, nil
+}
+
+// CreatePublicToken creates a new public access token for a specific user and duration.
+func (maker *PublicPasetoMaker) CreatePublicToken(claims Claims, duration time.Duration) (string, *Payload, error) {
+	payload, err := NewPayload(claims, TokenTypeAccess, maker.issuer, maker.audience, duration)
+	if err != nil {
+		return "", payload, err
+	}
+
+	message, err := json.Marshal(payload)
+	if err != nil {
+		return "", payload, err
+	}
+	footer, err := json.Marshal(tokenFooter{KeyID: maker.signingKID})
+	if err != nil {
+		return "", payload, err
+	}
+
+	return signPublicToken(maker.signingKey, message, footer), payload, nil
+}
+
+// VerifyPublicToken checks if the public access token is valid or not.
+func (maker *PublicPasetoMaker) VerifyPublicToken(token string) (*Payload, error) {
+	message, signature, footer, err := splitPublicToken(token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	// Tokens name their key in the footer
+	var f tokenFooter
+	if err := json.Unmarshal(footer, &f); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	verified := false
+	for _, publicKey := range maker.publicKeys {
+		if publicKey.ID != f.KeyID {
+			continue
+		}
+		if verifyPublicToken(publicKey.Key, message, signature, footer) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidToken
+	}
+
+	payload := &Payload{}
+	if err := json.Unmarshal(message, payload); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	err = payload.Valid(maker.issuer, maker.audience)
+	if err != nil {
+		return nil, err
+	}
+	if err := payload.checkType(TokenTypeAccess); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
+
+// PublicKeys returns the public keys that verify public tokens, the key of new tokens first.
+func (maker *PublicPasetoMaker) PublicKeys() []PublicKey {
+	return maker.publicKeys
+}
+
+// PublicKeyID returns the PASERK key ID (k4.pid) of a public key.
+func PublicKeyID(publicKey ed25519.PublicKey) string {
+	paserk := "k4.public." + base64.RawURLEncoding.EncodeToString(publicKey)
+
+	// BLAKE2b-264 is used, so that the ID encodes to base64 without padding
+	hash, _ := blake2b.New(33, nil)
+	hash.Write([]byte(publicKeyIDHeader))
+	hash.Write([]byte(paserk))
+	return publicKeyIDHeader + base64.RawURLEncoding.EncodeToString(hash.Sum(nil))
+}
+
+// IsPublicToken reports whether the token is a public token rather than a local token.
+func IsPublicToken(token string) bool {
+	return strings.HasPrefix(token, publicTokenHeader)
+}
+
+// signPublicToken signs the message and footer and returns the v4.public token.
+func signPublicToken(privateKey ed25519.PrivateKey, message, footer []byte) string {
+	signature := ed25519.Sign(privateKey, preAuthEncode([]byte(publicTokenHeader), message, footer, nil))
+
+	body := make([]byte, 0, len(message)+len(signature))
+	body = append(append(body, message...), signature...)
+
+	token := publicTokenHeader + base64.RawURLEncoding.EncodeToString(body)
+	if len(footer) > 0 {
+		token += "." + base64.RawURLEncoding.EncodeToString(footer)
+	}
+	return token
+}
+
+// splitPublicToken decodes the message, signature and footer of a v4.public token without verifying it.
+func splitPublicToken(token string) (message, signature, footer []byte, err error) {
+	if !IsPublicToken(token) {
+		return nil, nil, nil, ErrInvalidToken
+	}
+
+	parts := strings.Split(strings.TrimPrefix(token, publicTokenHeader), ".")
+	if len(parts) > 2 {
+		return nil, nil, nil, ErrInvalidToken
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(body) < ed25519.SignatureSize {
+		return nil, nil, nil, ErrInvalidToken
+	}
+	if len(parts) == 2 {
+		footer, err = base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, nil, nil, ErrInvalidToken
+		}
+	}
+
+	split := len(body) - ed25519.SignatureSize
+	return body[:split], body[split:], footer, nil
+}
+
+// verifyPublicToken checks the signature of a v4.public token over its message and footer.
+func verifyPublicToken(publicKey ed25519.PublicKey, message, signature, footer []byte) bool {
+	return ed25519.Verify(publicKey, preAuthEncode([]byte(publicTokenHeader), message, footer, nil), signature)
+}
+
+// preAuthEncode is the pre-authentication encoding (PAE) of PASETO, which encodes the pieces of a token
+// unambiguously before they are signed.
+func preAuthEncode(pieces ...[]byte) []byte {
+	var buf bytes.Buffer
+	writeLE64 := func(n uint64) {
+		var b [8]byte
+		// The most significant bit is cleared for interoperability with languages without unsigned integers
+		binary.LittleEndian.PutUint64(b[:], n&^(1<<63))
+		buf.Write(b[:])
+	}
+
+	writeLE64(uint64(len(pieces)))
+	for _, piece := range pieces {
+		writeLE64(uint64(len(piece)))
+		buf.Write(piece)
+	}
+	return buf.Bytes()
+}
+
+// parsePrivateKey parses a hex encoded Ed25519 seed or private key.
+func parsePrivateKey(value string) (ed25519.PrivateKey, error) {
+	key, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		// The public half is derived again, so that a corrupted key can't sign unverifiable tokens
+		return ed25519.NewKeyFromSeed(key[:ed25519.SeedSize]), nil
+	default:
+		return nil, fmt.Errorf("must be %d or %d bytes, hex encoded", ed25519.SeedSize, ed25519.PrivateKeySize)
+	}
+}
@@ -0,0 +1,160 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomPrivateKey(t *testing.T) string {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	return hex.EncodeToString(privateKey.Seed())
+}
+
+func TestPublicTokenTestVector(t *testing.T) {
+	// Test vector 4-S-1 of the PASETO specification
+	privateKey, err := parsePrivateKey("b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a3774" +
+		"1eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2")
+	require.NoError(t, err)
+	message := `{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`
+	expected := "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9" +
+		"bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA"
+
+	require.Equal(t, expected, signPublicToken(privateKey, []byte(message), nil))
+
+	gotMessage, signature, footer, err := splitPublicToken(expected)
+	require.NoError(t, err)
+	require.Equal(t, message, string(gotMessage))
+	require.Empty(t, footer)
+	require.True(t, verifyPublicToken(privateKey.Public().(ed25519.PublicKey), gotMessage, signature, footer))
+}
+
+func TestPublicPasetoMaker(t *testing.T) {
+	maker, err := NewPublicPasetoMaker(randomKeyring(t), []string{randomPrivateKey(t)}, DefaultIssuer, DefaultAudience)
+	require.NoError(t, err)
+
+	claims := randomClaims()
+	duration := time.Minute
+
+	issuedAt := time.Now()
+	expiredAt := issuedAt.Add(duration)
+
+	token, payload, err := maker.CreatePublicToken(claims, duration)
+	require.NoError(t, err)
+	require.True(t, IsPublicToken(token))
+	require.NotEmpty(t, payload)
+
+	payload, err = maker.VerifyPublicToken(token)
+	require.NoError(t, err)
+	require.Equal(t, claims.UserID, payload.UserID)
+	require.Equal(t, claims.Username, payload.Username)
+	require.Equal(t, claims.RoleID, payload.RoleID)
+	require.Equal(t, claims.Scopes, payload.Scopes)
+	require.Equal(t, DefaultIssuer, payload.Issuer)
+	require.Equal(t, DefaultAudience, payload.Audience)
+	require.WithinDuration(t, issuedAt, payload.IssuedAt, time.Second)
+	require.WithinDuration(t, expiredAt, payload.ExpiredAt, time.Second)
+
+	// The footer names the signing key
+	require.Len(t, maker.PublicKeys(), 1)
+	_, _, footer, err := splitPublicToken(token)
+	require.NoError(t, err)
+	require.Contains(t, string(footer), maker.PublicKeys()[0].ID)
+
+	// Local tokens are still created with the symmetric key
+	localToken, _, err := maker.CreateLocalToken(claims, duration)
+	require.NoError(t, err)
+	_, err = VerifyToken(maker, localToken)
+	require.NoError(t, err)
+	_, err = VerifyToken(maker, token)
+	require.NoError(t, err)
+}
+
+func TestPublicPasetoMakerKeyRotation(t *testing.T) {
+	keyring := randomKeyring(t)
+	oldKey := randomPrivateKey(t)
+	newKey := randomPrivateKey(t)
+
+	oldMaker, err := NewPublicPasetoMaker(keyring, []string{oldKey}, DefaultIssuer, DefaultAudience)
+	require.NoError(t, err)
+	token, _, err := oldMaker.CreatePublicToken(randomClaims(), time.Minute)
+	require.NoError(t, err)
+
+	// Tokens of the previous key are accepted as long as the key is configured
+	maker, err := NewPublicPasetoMaker(keyring, []string{newKey, oldKey}, DefaultIssuer, DefaultAudience)
+	require.NoError(t, err)
+	_, err = maker.VerifyPublicToken(token)
+	require.NoError(t, err)
+	require.Len(t, maker.PublicKeys(), 2)
+	require.Equal(t, PublicKeyID(oldMaker.PublicKeys()[0].Key), maker.PublicKeys()[1].ID)
+
+	maker, err = NewPublicPasetoMaker(keyring, []string{newKey}, DefaultIssuer, DefaultAudience)
+	require.NoError(t, err)
+	_, err = maker.VerifyPublicToken(token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestInvalidPublicToken(t *testing.T) {
+	maker, err := NewPublicPasetoMaker(randomKeyring(t), []string{randomPrivateKey(t)}, DefaultIssuer, DefaultAudience)
+	require.NoError(t, err)
+
+	token, _, err := maker.CreatePublicToken(randomClaims(), time.Minute)
+	require.NoError(t, err)
+
+	// Changing the payload breaks the signature
+	tampered := publicTokenHeader + "X" + strings.TrimPrefix(token, publicTokenHeader)[1:]
+	// Tokens without a footer don't name their key
+	withoutFooter := token[:strings.LastIndex(token, ".")]
+	for _, invalid := range []string{tampered, withoutFooter, "v4.public.invalid", "v4.public.", "invalid_token"} {
+		payload, err := maker.VerifyPublicToken(invalid)
+		require.EqualError(t, err, ErrInvalidToken.Error(), invalid)
+		require.Nil(t, payload)
+	}
+
+	token, _, err = maker.CreatePublicToken(randomClaims(), -time.Minute)
+	require.NoError(t, err)
+	_, err = maker.VerifyPublicToken(token)
+	require.EqualError(t, err, ErrExpiredToken.Error())
+}
+
+func TestLocalPasetoMakerWithoutPublicKeys(t *testing.T) {
+	maker, err := NewLocalPasetoMaker(util.RandomString(32))
+	require.NoError(t, err)
+
+	_, _, err = maker.CreatePublicToken(randomClaims(), time.Minute)
+	require.ErrorIs(t, err, ErrPublicTokensDisabled)
+	require.Empty(t, maker.PublicKeys())
+
+	publicMaker, err := NewPublicPasetoMaker(randomKeyring(t), []string{randomPrivateKey(t)}, DefaultIssuer, DefaultAudience)
+	require.NoError(t, err)
+	token, _, err := publicMaker.CreatePublicToken(randomClaims(), time.Minute)
+	require.NoError(t, err)
+
+	_, err = VerifyToken(maker, token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestNewPublicPasetoMakerInvalidKeys(t *testing.T) {
+	for _, privateKeys := range [][]string{nil, {"not hex"}, {"abcd"}} {
+		_, err := NewPublicPasetoMaker(randomKeyring(t), privateKeys, DefaultIssuer, DefaultAudience)
+		require.Error(t, err)
+	}
+}
+
+func TestNewJSONWebKeySet(t *testing.T) {
+	maker, err := NewPublicPasetoMaker(randomKeyring(t), []string{randomPrivateKey(t)}, DefaultIssuer, DefaultAudience)
+	require.NoError(t, err)
+
+	set := NewJSONWebKeySet(maker.PublicKeys())
+	require.Len(t, set.Keys, 1)
+	require.Equal(t, "OKP", set.Keys[0].KeyType)
+	require.Equal(t, "Ed25519", set.Keys[0].Curve)
+	require.Equal(t, maker.PublicKeys()[0].ID, set.Keys[0].KeyID)
+	require.True(t, strings.HasPrefix(set.Keys[0].KeyID, publicKeyIDHeader))
+}
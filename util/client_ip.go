@@ -0,0 +1,83 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NormalizeClientIP returns the host of a client address as it is recorded for a request.
+// The address may contain a port, which is removed.
+func NormalizeClientIP(clientIP string) string {
+	clientIP = strings.TrimSpace(clientIP)
+	if host, _, err := net.SplitHostPort(clientIP); err == nil {
+		clientIP = host
+	}
+	return clientIP
+}
+
+// TrustedProxies are the networks of the proxies whose X-Forwarded-For headers are trusted,
+// e.g. the gateway that forwards HTTP requests to the gRPC server.
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses a list of IP addresses and CIDR ranges.
+func ParseTrustedProxies(entries []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, 0, len(entries))
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q, expected an IP address or CIDR range", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q, expected an IP address or CIDR range", entry)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// Contains reports whether the address belongs to a trusted proxy.
+func (p TrustedProxies) Contains(address string) bool {
+	ip := net.ParseIP(NormalizeClientIP(address))
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client of a request that was received from the peer address. Every proxy
+// appends the address it received the request from to X-Forwarded-For, so the list is walked from the right
+// as long as the addresses belong to trusted proxies. The entries left of the first untrusted address are set
+// by the client and are ignored, as are all entries of requests that don't come from a trusted proxy.
+func (p TrustedProxies) ClientIP(peerAddress string, forwardedFor []string) string {
+	clientIP := NormalizeClientIP(peerAddress)
+
+	var hops []string
+	for _, header := range forwardedFor {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0 && p.Contains(clientIP); i-- {
+		hop := NormalizeClientIP(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		clientIP = hop
+	}
+	return clientIP
+}
@@ -0,0 +1,78 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedProxiesClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"127.0.0.1", "::1", "10.0.0.0/8"})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		peerAddress  string
+		forwardedFor []string
+		clientIP     string
+	}{
+		{
+			name:        "DirectClient",
+			peerAddress: "203.0.113.7:4711",
+			clientIP:    "203.0.113.7",
+		},
+		{
+			name:         "UntrustedPeerCantForward",
+			peerAddress:  "203.0.113.7:4711",
+			forwardedFor: []string{"198.51.100.1"},
+			clientIP:     "203.0.113.7",
+		},
+		{
+			name:         "Gateway",
+			peerAddress:  "127.0.0.1:50000",
+			forwardedFor: []string{"203.0.113.7"},
+			clientIP:     "203.0.113.7",
+		},
+		{
+			name:         "ClientSetsForwardedFor",
+			peerAddress:  "127.0.0.1:50000",
+			forwardedFor: []string{"198.51.100.1, 203.0.113.7"},
+			clientIP:     "203.0.113.7",
+		},
+		{
+			name:         "LoadBalancerBehindGateway",
+			peerAddress:  "[::1]:50000",
+			forwardedFor: []string{"198.51.100.1, 203.0.113.7", "10.1.2.3"},
+			clientIP:     "203.0.113.7",
+		},
+		{
+			name:         "OnlyTrustedHops",
+			peerAddress:  "127.0.0.1:50000",
+			forwardedFor: []string{"10.1.2.3"},
+			clientIP:     "10.1.2.3",
+		},
+		{
+			name:         "InvalidHop",
+			peerAddress:  "127.0.0.1:50000",
+			forwardedFor: []string{"203.0.113.7, not-an-ip"},
+			clientIP:     "127.0.0.1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.clientIP, proxies.ClientIP(tc.peerAddress, tc.forwardedFor))
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	_, err := ParseTrustedProxies([]string{"localhost"})
+	require.Error(t, err)
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	require.Error(t, err)
+
+	proxies, err := ParseTrustedProxies(nil)
+	require.NoError(t, err)
+	require.False(t, proxies.Contains("127.0.0.1"))
+}
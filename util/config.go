@@ -25,6 +25,138 @@ type Config struct {
 	CertPem              string        `mapstructure:"CERT_PEM"`
 	KeyPem               string        `mapstructure:"KEY_PEM"`
 	CaCertPem            string        `mapstructure:"CA_CERT_PEM"`
+	GrpcPublicMethods    []string      `mapstructure:"GRPC_PUBLIC_METHODS"`
+	// Maintenance worker: how often it runs, how many sessions it deletes per statement
+	// and how long expired or blocked sessions are kept before they are purged.
+	SessionCleanupInterval  time.Duration `mapstructure:"SESSION_CLEANUP_INTERVAL"`
+	SessionCleanupBatchSize int32         `mapstructure:"SESSION_CLEANUP_BATCH_SIZE"`
+	SessionRetention        time.Duration `mapstructure:"SESSION_RETENTION"`
+	// Email verification: the link sent to new users and how long it is valid.
+	VerifyEmailURL      string        `mapstructure:"VERIFY_EMAIL_URL"`
+	VerifyEmailDuration time.Duration `mapstructure:"VERIFY_EMAIL_DURATION"`
+	// Password reset: the page the reset link points to and how long the link is valid.
+	PasswordResetURL      string        `mapstructure:"PASSWORD_RESET_URL"`
+	PasswordResetDuration time.Duration `mapstructure:"PASSWORD_RESET_DURATION"`
+	// Password hashing: the Argon2id cost parameters of new hashes, memory is given in KiB.
+	// Stored hashes with other parameters are rehashed on the next successful login.
+	Argon2idTime    uint32 `mapstructure:"ARGON2ID_TIME"`
+	Argon2idMemory  uint32 `mapstructure:"ARGON2ID_MEMORY"`
+	Argon2idThreads uint8  `mapstructure:"ARGON2ID_THREADS"`
+	// Login throttling: failed attempts per username and per client address before the login is locked.
+	// The lockout doubles with every further failure up to the maximum, failures older than the window are forgotten.
+	// A limit of 0 disables the throttling of usernames or client addresses.
+	LoginMaxAttempts        int32         `mapstructure:"LOGIN_MAX_ATTEMPTS"`
+	LoginIPMaxAttempts      int32         `mapstructure:"LOGIN_IP_MAX_ATTEMPTS"`
+	LoginLockoutDuration    time.Duration `mapstructure:"LOGIN_LOCKOUT_DURATION"`
+	LoginLockoutMaxDuration time.Duration `mapstructure:"LOGIN_LOCKOUT_MAX_DURATION"`
+	LoginAttemptWindow      time.Duration `mapstructure:"LOGIN_ATTEMPT_WINDOW"`
+	// Rate limiting: token bucket limits per route and client of the form <route>=<requests>/<s|m|h>.
+	// Routes are "<METHOD> <path>" for the HTTP API and the plain gateway endpoints and "<Service>/<Method>" for gRPC,
+	// "*" applies to all other routes.
+	RateLimits []string `mapstructure:"RATE_LIMITS"`
+	// Two-factor authentication: the 32 character key the TOTP secrets are encrypted with, the issuer shown
+	// in authenticator apps and how long the MFA token of a login waits for its code.
+	// Without an encryption key users can't enable two-factor authentication.
+	MfaEncryptionKey     string        `mapstructure:"MFA_ENCRYPTION_KEY"`
+	MfaIssuer            string        `mapstructure:"MFA_ISSUER"`
+	MfaChallengeDuration time.Duration `mapstructure:"MFA_CHALLENGE_DURATION"`
+	// Public tokens: hex encoded Ed25519 private keys. If set, access tokens are issued as public tokens signed
+	// with the first key, which other services verify with the keys published at /.well-known/jwks.json.
+	// The other keys only verify the tokens of previous keys until they expire.
+	TokenPrivateKeys []string `mapstructure:"TOKEN_PRIVATE_KEYS"`
+	// Symmetric key rotation: previous keys that still verify local tokens after TOKEN_SYMMETRIC_KEY was replaced.
+	// Alternatively the keys are read from a JSON keyring file, which is checked for changes once per interval.
+	TokenPreviousSymmetricKeys []string      `mapstructure:"TOKEN_PREVIOUS_SYMMETRIC_KEYS"`
+	TokenKeyringFile           string        `mapstructure:"TOKEN_KEYRING_FILE"`
+	TokenKeyringReloadInterval time.Duration `mapstructure:"TOKEN_KEYRING_RELOAD_INTERVAL"`
+	// Token claims: the issuer put into all tokens and the audience they are meant for.
+	// Tokens of other issuers or for other audiences are rejected.
+	TokenIssuer   string `mapstructure:"TOKEN_ISSUER"`
+	TokenAudience string `mapstructure:"TOKEN_AUDIENCE"`
+	// Token revocation: how often the revoked tokens are loaded from the database. Revocations of other
+	// instances take effect after at most this interval, 0 loads them on every request.
+	TokenRevocationInterval time.Duration `mapstructure:"TOKEN_REVOCATION_INTERVAL"`
+	// Token introspection: the services that may introspect tokens, identified by the DNS name or common name
+	// of the client certificate they present. Without any services the introspection endpoints reject all callers.
+	IntrospectionClients []string `mapstructure:"INTROSPECTION_CLIENTS"`
+	// OAuth2 and OpenID Connect: the URL the service is reached at, which identifies it as issuer in the discovery
+	// document and prefixes its endpoints, and how long an authorization code can be exchanged for tokens.
+	OAuthIssuerURL    string        `mapstructure:"OAUTH_ISSUER_URL"`
+	OAuthCodeDuration time.Duration `mapstructure:"OAUTH_CODE_DURATION"`
+	// External login: a JSON file with the OpenID Connect providers users can log in with and how long
+	// a login at a provider may take. Without a file the external login is disabled.
+	ExternalProvidersFile      string        `mapstructure:"EXTERNAL_PROVIDERS_FILE"`
+	ExternalLoginStateDuration time.Duration `mapstructure:"EXTERNAL_LOGIN_STATE_DURATION"`
+	// Mutual TLS of the gRPC server: whether callers are asked for a client certificate issued by the CA
+	// ("none", "request" or "require"), and the methods only certain services may call, as entries of the form
+	// /package.Service/Method=identity. An identity is a DNS name, URI or common name of the client certificate.
+	GrpcClientAuth       string   `mapstructure:"GRPC_CLIENT_AUTH"`
+	GrpcServiceAllowlist []string `mapstructure:"GRPC_SERVICE_ALLOWLIST"`
+	// TLS certificate rotation: how often the certificate and key files are checked for changes, 0 only reloads
+	// them on SIGHUP. Certificates given as raw PEM data in CI aren't reloaded.
+	TLSCertReloadInterval time.Duration `mapstructure:"TLS_CERT_RELOAD_INTERVAL"`
+	// Client addresses: the IP addresses and CIDR ranges of the proxies whose X-Forwarded-For headers are trusted.
+	// The gateway forwards requests to the gRPC server with the address of its client, so its address has to be
+	// among them, by default it connects over loopback. Requests of other peers are recorded with the peer address.
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+	// Email delivery: "smtp" sends the emails through the SMTP server at host:port, authenticated with the
+	// username and password if given, from the sender address. "log" only writes the emails to the log and is
+	// refused outside of the development environment, as users would never receive their links.
+	Environment      string `mapstructure:"ENVIRONMENT"`
+	EmailSender      string `mapstructure:"EMAIL_SENDER"`
+	SMTPAddress      string `mapstructure:"SMTP_ADDRESS"`
+	SMTPUsername     string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword     string `mapstructure:"SMTP_PASSWORD"`
+	EmailFromName    string `mapstructure:"EMAIL_FROM_NAME"`
+	EmailFromAddress string `mapstructure:"EMAIL_FROM_ADDRESS"`
+}
+
+// defaultConfigValues holds the optional keys of the configuration together with their default values.
+// These keys don't have to be present in the environment or in the configuration file.
+var defaultConfigValues = map[string]string{
+	"GRPC_PUBLIC_METHODS":           "/pb.IdentityProvider/LoginUser,/pb.IdentityProvider/RegisterUser,/grpc.health.v1.Health/Check,/grpc.health.v1.Health/Watch,/grpc.reflection.v1.ServerReflection/ServerReflectionInfo,/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo,/pb.TokenIntrospection/IntrospectToken,/pb.TokenRenewal/RenewAccessToken",
+	"SESSION_CLEANUP_INTERVAL":      "1h",
+	"SESSION_CLEANUP_BATCH_SIZE":    "1000",
+	"SESSION_RETENTION":             "168h",
+	"VERIFY_EMAIL_URL":              "https://localhost:8080/streamfair/v1/verify_email",
+	"VERIFY_EMAIL_DURATION":         "24h",
+	"PASSWORD_RESET_URL":            "https://localhost:8080/reset_password",
+	"PASSWORD_RESET_DURATION":       "1h",
+	"ARGON2ID_TIME":                 "2",
+	"ARGON2ID_MEMORY":               "19456",
+	"ARGON2ID_THREADS":              "1",
+	"LOGIN_MAX_ATTEMPTS":            "5",
+	"LOGIN_IP_MAX_ATTEMPTS":         "20",
+	"LOGIN_LOCKOUT_DURATION":        "1m",
+	"LOGIN_LOCKOUT_MAX_DURATION":    "1h",
+	"LOGIN_ATTEMPT_WINDOW":          "1h",
+	"RATE_LIMITS":                   "POST /users=5/m,POST /users/login=10/m,PUT /users/change_password=5/m,POST /users/password_reset/request=5/m,GET /streamfair/v1/verify_email=10/m,POST /streamfair/v1/request_password_reset=5/m,POST /streamfair/v1/reset_password=5/m,POST /streamfair/v1/change_password=5/m,POST /streamfair/v1/login_mfa=10/m,POST /oauth/authorize=10/m,POST /oauth/token=10/m,IdentityProvider/LoginUser=10/m,IdentityProvider/RegisterUser=5/m,*=600/m",
+	"MFA_ENCRYPTION_KEY":            "",
+	"TOKEN_PRIVATE_KEYS":            "",
+	"MFA_ISSUER":                    "Streamfair",
+	"MFA_CHALLENGE_DURATION":        "5m",
+	"TOKEN_PREVIOUS_SYMMETRIC_KEYS": "",
+	"TOKEN_KEYRING_FILE":            "",
+	"TOKEN_KEYRING_RELOAD_INTERVAL": "1m",
+	"TOKEN_ISSUER":                  "streamfair_user_svc",
+	"TOKEN_AUDIENCE":                "streamfair",
+	"TOKEN_REVOCATION_INTERVAL":     "30s",
+	"INTROSPECTION_CLIENTS":         "",
+	"OAUTH_ISSUER_URL":              "https://localhost:8080",
+	"OAUTH_CODE_DURATION":           "1m",
+	"EXTERNAL_PROVIDERS_FILE":       "",
+	"EXTERNAL_LOGIN_STATE_DURATION": "10m",
+	"GRPC_CLIENT_AUTH":              "none",
+	"GRPC_SERVICE_ALLOWLIST":        "",
+	"TLS_CERT_RELOAD_INTERVAL":      "1m",
+	"TRUSTED_PROXIES":               "127.0.0.1,::1",
+	"ENVIRONMENT":                   "production",
+	"EMAIL_SENDER":                  "log",
+	"SMTP_ADDRESS":                  "",
+	"SMTP_USERNAME":                 "",
+	"SMTP_PASSWORD":                 "",
+	"EMAIL_FROM_NAME":               "Streamfair",
+	"EMAIL_FROM_ADDRESS":            "",
 }
 
 // LoadConfig loads the configuration from the environment variables using viper package.
@@ -34,6 +166,10 @@ type Config struct {
 func LoadConfig() (config Config, err error) {
 	viper.AutomaticEnv()
 
+	for key, value := range defaultConfigValues {
+		viper.SetDefault(key, value)
+	}
+
 	// Define a list of keys to check
 	keys := []string{
 		"SERVER_NAME",
@@ -77,6 +213,49 @@ func LoadConfig() (config Config, err error) {
 	config.TokenSymmetricKey = viper.GetString("TOKEN_SYMMETRIC_KEY")
 	config.AccessTokenDuration = viper.GetDuration("ACCESS_TOKEN_DURATION")
 	config.RefreshTokenDuration = viper.GetDuration("REFRESH_TOKEN_DURATION")
+	config.GrpcPublicMethods = splitList(viper.GetString("GRPC_PUBLIC_METHODS"))
+	config.SessionCleanupInterval = viper.GetDuration("SESSION_CLEANUP_INTERVAL")
+	config.SessionCleanupBatchSize = viper.GetInt32("SESSION_CLEANUP_BATCH_SIZE")
+	config.SessionRetention = viper.GetDuration("SESSION_RETENTION")
+	config.VerifyEmailURL = viper.GetString("VERIFY_EMAIL_URL")
+	config.VerifyEmailDuration = viper.GetDuration("VERIFY_EMAIL_DURATION")
+	config.PasswordResetURL = viper.GetString("PASSWORD_RESET_URL")
+	config.PasswordResetDuration = viper.GetDuration("PASSWORD_RESET_DURATION")
+	config.Argon2idTime = viper.GetUint32("ARGON2ID_TIME")
+	config.Argon2idMemory = viper.GetUint32("ARGON2ID_MEMORY")
+	config.Argon2idThreads = uint8(viper.GetUint("ARGON2ID_THREADS"))
+	config.LoginMaxAttempts = viper.GetInt32("LOGIN_MAX_ATTEMPTS")
+	config.LoginIPMaxAttempts = viper.GetInt32("LOGIN_IP_MAX_ATTEMPTS")
+	config.LoginLockoutDuration = viper.GetDuration("LOGIN_LOCKOUT_DURATION")
+	config.LoginLockoutMaxDuration = viper.GetDuration("LOGIN_LOCKOUT_MAX_DURATION")
+	config.LoginAttemptWindow = viper.GetDuration("LOGIN_ATTEMPT_WINDOW")
+	config.RateLimits = splitList(viper.GetString("RATE_LIMITS"))
+	config.MfaEncryptionKey = viper.GetString("MFA_ENCRYPTION_KEY")
+	config.MfaIssuer = viper.GetString("MFA_ISSUER")
+	config.MfaChallengeDuration = viper.GetDuration("MFA_CHALLENGE_DURATION")
+	config.TokenPrivateKeys = splitList(viper.GetString("TOKEN_PRIVATE_KEYS"))
+	config.TokenPreviousSymmetricKeys = splitList(viper.GetString("TOKEN_PREVIOUS_SYMMETRIC_KEYS"))
+	config.TokenKeyringFile = viper.GetString("TOKEN_KEYRING_FILE")
+	config.TokenKeyringReloadInterval = viper.GetDuration("TOKEN_KEYRING_RELOAD_INTERVAL")
+	config.TokenIssuer = viper.GetString("TOKEN_ISSUER")
+	config.TokenAudience = viper.GetString("TOKEN_AUDIENCE")
+	config.TokenRevocationInterval = viper.GetDuration("TOKEN_REVOCATION_INTERVAL")
+	config.IntrospectionClients = splitList(viper.GetString("INTROSPECTION_CLIENTS"))
+	config.OAuthIssuerURL = viper.GetString("OAUTH_ISSUER_URL")
+	config.OAuthCodeDuration = viper.GetDuration("OAUTH_CODE_DURATION")
+	config.ExternalProvidersFile = viper.GetString("EXTERNAL_PROVIDERS_FILE")
+	config.ExternalLoginStateDuration = viper.GetDuration("EXTERNAL_LOGIN_STATE_DURATION")
+	config.GrpcClientAuth = viper.GetString("GRPC_CLIENT_AUTH")
+	config.GrpcServiceAllowlist = splitList(viper.GetString("GRPC_SERVICE_ALLOWLIST"))
+	config.TLSCertReloadInterval = viper.GetDuration("TLS_CERT_RELOAD_INTERVAL")
+	config.TrustedProxies = splitList(viper.GetString("TRUSTED_PROXIES"))
+	config.Environment = viper.GetString("ENVIRONMENT")
+	config.EmailSender = viper.GetString("EMAIL_SENDER")
+	config.SMTPAddress = viper.GetString("SMTP_ADDRESS")
+	config.SMTPUsername = viper.GetString("SMTP_USERNAME")
+	config.SMTPPassword = viper.GetString("SMTP_PASSWORD")
+	config.EmailFromName = viper.GetString("EMAIL_FROM_NAME")
+	config.EmailFromAddress = viper.GetString("EMAIL_FROM_ADDRESS")
 	certPemPath := viper.GetString("CERT_PEM")
 	keyPemPath := viper.GetString("KEY_PEM")
 	caCertPemPath := viper.GetString("CA_CERT_PEM")
@@ -104,6 +283,15 @@ func LoadConfig() (config Config, err error) {
 	return config, err
 }
 
+// Argon2idParams returns the configured Argon2id parameters for new password hashes.
+func (config Config) Argon2idParams() Argon2idParams {
+	return Argon2idParams{
+		Time:    config.Argon2idTime,
+		Memory:  config.Argon2idMemory,
+		Threads: config.Argon2idThreads,
+	}.withDefaults()
+}
+
 // readEnvFromFile reads the missing key from the configuration file.
 func readEnvFromFile(key string) (string, error) {
 	// Determine the project root dynamically.
@@ -161,3 +349,14 @@ func setConfigValue(config *Config, key string, value string) error {
 	}
 	return err
 }
+
+// splitList splits a comma separated configuration value into its trimmed, non-empty elements.
+func splitList(value string) []string {
+	var list []string
+	for _, element := range strings.Split(value, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			list = append(list, element)
+		}
+	}
+	return list
+}
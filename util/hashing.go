@@ -1,13 +1,22 @@
 package util
 
 import (
-	"bytes"
 	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"strings"
 
 	"golang.org/x/crypto/argon2"
 )
 
+// ErrInvalidPassword is returned when a password doesn't match the stored hash.
+var ErrInvalidPassword = errors.New("invalid password")
+
+// argon2idPrefix starts every Argon2id hash in the PHC string format.
+const argon2idPrefix = "$argon2id$"
+
 type Argon2idHash struct {
 	// time represents the number of passed over the specified memory.
 	time uint32
@@ -73,68 +82,170 @@ func (a *Argon2idHash) compare(hash, salt, password []byte) error {
 	if err != nil {
 		return err
 	}
-	// Compare the generated hash with the stored hash.
+	// Compare the generated hash with the stored hash in constant time.
 	// If they don't match return error.
-	if !bytes.Equal(hash, hashSalt.Hash) {
-		return errors.New("invalid password")
+	if subtle.ConstantTimeCompare(hash, hashSalt.Hash) != 1 {
+		return ErrInvalidPassword
 	}
 	return nil
 }
 
+// encode formats the hash in the PHC string format: $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>
+// with salt and hash encoded as unpadded standard base64.
+func (a *Argon2idHash) encode(hashSalt *HashSalt) string {
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix,
+		argon2.Version,
+		a.memory,
+		a.time,
+		a.threads,
+		base64.RawStdEncoding.EncodeToString(hashSalt.Salt),
+		base64.RawStdEncoding.EncodeToString(hashSalt.Hash),
+	)
+}
+
+// decodeArgon2idHash parses a hash in the PHC string format.
+// The returned Argon2idHash holds the parameters the hash was generated with.
+func decodeArgon2idHash(encodedHash string) (*Argon2idHash, *HashSalt, error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, errors.New("invalid argon2id hash format")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return nil, nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+
+	a := &Argon2idHash{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &a.memory, &a.time, &a.threads); err != nil {
+		return nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid argon2id hash: %w", err)
+	}
+	a.saltLen = uint32(len(salt))
+	a.keyLen = uint32(len(hash))
+
+	return a, &HashSalt{Hash: hash, Salt: salt}, nil
+}
+
+// Argon2idParams are the cost parameters used to hash new passwords.
 type Argon2idParams struct {
 	Time    uint32
-	Memory  uint32
+	Memory  uint32 // in KiB
 	Threads uint8
-	KeyLen  uint32
-	SaltLen uint32
+	KeyLen  uint32 // in bytes
+	SaltLen uint32 // in bytes
 }
 
-// HashPassword generates a hash of the given password using the Argon2id algorithm.
-// Returns a HashSalt struct containing the generated hash and salt.
-func HashPassword(password string) (*HashSalt, error) {
-	if password == "" {
-		return nil, errors.New("password cannot be empty")
-	}
-
-	defaultParams := Argon2idParams{
+// DefaultArgon2idParams returns the parameters recommended by OWASP for Argon2id.
+func DefaultArgon2idParams() Argon2idParams {
+	return Argon2idParams{
 		Time:    2,
-		Memory:  19, // in MiB
+		Memory:  19 * 1024,
 		Threads: 1,
-		KeyLen:  32, // in bytes
-		SaltLen: 16, // in bytes
+		KeyLen:  32,
+		SaltLen: 16,
 	}
-	argon2idHash := newArgon2idHash(defaultParams.Time, defaultParams.SaltLen, defaultParams.Memory, defaultParams.Threads, defaultParams.KeyLen)
-	hash, err := argon2idHash.generateHash([]byte(password), nil)
-	if err != nil {
-		return nil, err
+}
+
+// legacyArgon2idParams are the parameters of the hashes that were stored base64 encoded
+// with the salt in a separate column, before hashes were stored in the PHC string format.
+var legacyArgon2idParams = Argon2idParams{
+	Time:    2,
+	Memory:  19,
+	Threads: 1,
+	KeyLen:  32,
+	SaltLen: 16,
+}
+
+// withDefaults replaces the unset parameters with the default ones.
+func (params Argon2idParams) withDefaults() Argon2idParams {
+	defaults := DefaultArgon2idParams()
+	if params.Time == 0 {
+		params.Time = defaults.Time
 	}
-	return hash, nil
+	if params.Memory == 0 {
+		params.Memory = defaults.Memory
+	}
+	if params.Threads == 0 {
+		params.Threads = defaults.Threads
+	}
+	if params.KeyLen == 0 {
+		params.KeyLen = defaults.KeyLen
+	}
+	if params.SaltLen == 0 {
+		params.SaltLen = defaults.SaltLen
+	}
+	return params
 }
 
-// ComparePassword compares a given hash, salt, and password.
-// If the hash of the password matches the given hash, it returns nil.
-// Otherwise, it returns an error indicating the password is invalid.
-func ComparePassword(hash, salt []byte, password string) error {
+func (params Argon2idParams) hasher() *Argon2idHash {
+	return newArgon2idHash(params.Time, params.SaltLen, params.Memory, params.Threads, params.KeyLen)
+}
+
+// NewArgon2idHasher returns the PasswordHasher for new passwords, unset parameters fall back to the defaults.
+func NewArgon2idHasher(params Argon2idParams) PasswordHasher {
+	return params.withDefaults().hasher()
+}
+
+// Hash generates a hash of the password with the parameters of the hasher. The hash is returned
+// in the PHC string format, which contains the parameters and the salt, so it can be verified
+// after the parameters have been changed.
+func (a *Argon2idHash) Hash(password string) (string, error) {
 	if password == "" {
-		return errors.New("password cannot be empty")
+		return "", errors.New("password cannot be empty")
 	}
 
-	defaultParams := Argon2idParams{
-		Time:    2,
-		Memory:  19, // in MiB
-		Threads: 1,
-		KeyLen:  32, // in bytes
-		SaltLen: 16, // in bytes
+	hashSalt, err := a.generateHash([]byte(password), nil)
+	if err != nil {
+		return "", err
 	}
-	argon2idHash := newArgon2idHash(defaultParams.Time, defaultParams.SaltLen, defaultParams.Memory, defaultParams.Threads, defaultParams.KeyLen)
-
-	hashBytes := []byte(hash)
-	saltBytes := []byte(salt)
-	passwordBytes := []byte(password)
+	return a.encode(hashSalt), nil
+}
 
-	err := argon2idHash.compare(hashBytes, saltBytes, passwordBytes)
+// Compare compares the password with an Argon2id hash in the PHC string format.
+// The parameters stored in the hash are used, not the ones of the hasher.
+func (a *Argon2idHash) Compare(encodedHash, password string) error {
+	stored, hashSalt, err := decodeArgon2idHash(encodedHash)
 	if err != nil {
 		return err
 	}
-	return nil
+	return stored.compare(hashSalt.Hash, hashSalt.Salt, []byte(password))
+}
+
+// NeedsRehash reports whether the encoded hash is not an Argon2id hash with the parameters of the hasher.
+func (a *Argon2idHash) NeedsRehash(encodedHash string) bool {
+	stored, _, err := decodeArgon2idHash(encodedHash)
+	if err != nil {
+		return true
+	}
+	return *stored != *a
+}
+
+// compareLegacyArgon2id compares a password with a legacy hash, which was stored base64 encoded next to a separate salt.
+func compareLegacyArgon2id(passwordHash, passwordSalt, password string) error {
+	hash, err := base64.StdEncoding.DecodeString(passwordHash)
+	if err != nil {
+		return fmt.Errorf("failed to decode password hash: %w", err)
+	}
+	salt, err := base64.StdEncoding.DecodeString(passwordSalt)
+	if err != nil {
+		return fmt.Errorf("failed to decode password salt: %w", err)
+	}
+	if len(hash) == 0 || len(salt) == 0 {
+		return errors.New("password hash and salt cannot be empty")
+	}
+
+	return legacyArgon2idParams.hasher().compare(hash, salt, []byte(password))
 }
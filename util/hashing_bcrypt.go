@@ -0,0 +1,57 @@
+package util
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptHash verifies bcrypt hashes, e.g. imported from a legacy system.
+type BcryptHash struct {
+	// cost is the bcrypt cost of new hashes.
+	cost int
+}
+
+// NewBcryptHasher returns a PasswordHasher for bcrypt hashes, a cost of 0 uses bcrypt.DefaultCost.
+func NewBcryptHasher(cost int) PasswordHasher {
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	return &BcryptHash{cost: cost}
+}
+
+// isBcryptHash reports whether the encoded hash uses one of the bcrypt prefixes $2a$, $2b$ or $2y$.
+func isBcryptHash(encodedHash string) bool {
+	return strings.HasPrefix(encodedHash, "$2a$") ||
+		strings.HasPrefix(encodedHash, "$2b$") ||
+		strings.HasPrefix(encodedHash, "$2y$")
+}
+
+// Hash generates a bcrypt hash of the password with the cost of the hasher.
+func (b *BcryptHash) Hash(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("password cannot be empty")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// Compare compares the password with a bcrypt hash, the cost stored in the hash is used.
+func (b *BcryptHash) Compare(encodedHash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(encodedHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrInvalidPassword
+	}
+	return err
+}
+
+// NeedsRehash reports whether the encoded hash is not a bcrypt hash with the cost of the hasher.
+func (b *BcryptHash) NeedsRehash(encodedHash string) bool {
+	cost, err := bcrypt.Cost([]byte(encodedHash))
+	return err != nil || cost != b.cost
+}
@@ -0,0 +1,142 @@
+package util
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scryptPrefix starts every scrypt hash in the PHC string format.
+const scryptPrefix = "$scrypt$"
+
+// ScryptParams are the cost parameters of the scrypt algorithm, N is given as its base 2 logarithm.
+type ScryptParams struct {
+	LogN    uint8
+	R       int
+	P       int
+	KeyLen  int // in bytes
+	SaltLen int // in bytes
+}
+
+// DefaultScryptParams returns the parameters recommended by OWASP for scrypt with N=2^15.
+func DefaultScryptParams() ScryptParams {
+	return ScryptParams{
+		LogN:    15,
+		R:       8,
+		P:       1,
+		KeyLen:  32,
+		SaltLen: 16,
+	}
+}
+
+// withDefaults replaces the unset parameters with the default ones.
+func (params ScryptParams) withDefaults() ScryptParams {
+	defaults := DefaultScryptParams()
+	if params.LogN == 0 {
+		params.LogN = defaults.LogN
+	}
+	if params.R == 0 {
+		params.R = defaults.R
+	}
+	if params.P == 0 {
+		params.P = defaults.P
+	}
+	if params.KeyLen == 0 {
+		params.KeyLen = defaults.KeyLen
+	}
+	if params.SaltLen == 0 {
+		params.SaltLen = defaults.SaltLen
+	}
+	return params
+}
+
+// ScryptHash verifies scrypt hashes in the PHC string format $scrypt$ln=<log2 N>,r=<r>,p=<p>$<salt>$<hash>,
+// e.g. imported from a legacy system.
+type ScryptHash struct {
+	params ScryptParams
+}
+
+// NewScryptHasher returns a PasswordHasher for scrypt hashes, unset parameters fall back to the defaults.
+func NewScryptHasher(params ScryptParams) PasswordHasher {
+	return &ScryptHash{params: params.withDefaults()}
+}
+
+// Hash generates a scrypt hash of the password with the parameters of the hasher.
+func (s *ScryptHash) Hash(password string) (string, error) {
+	if password == "" {
+		return "", errors.New("password cannot be empty")
+	}
+
+	salt, err := randomSecret(uint32(s.params.SaltLen))
+	if err != nil {
+		return "", err
+	}
+	hash, err := scrypt.Key([]byte(password), salt, 1<<s.params.LogN, s.params.R, s.params.P, s.params.KeyLen)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%sln=%d,r=%d,p=%d$%s$%s",
+		scryptPrefix,
+		s.params.LogN,
+		s.params.R,
+		s.params.P,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
+}
+
+// Compare compares the password with a scrypt hash, the parameters stored in the hash are used.
+func (s *ScryptHash) Compare(encodedHash, password string) error {
+	params, salt, hash, err := decodeScryptHash(encodedHash)
+	if err != nil {
+		return err
+	}
+
+	computed, err := scrypt.Key([]byte(password), salt, 1<<params.LogN, params.R, params.P, params.KeyLen)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(hash, computed) != 1 {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+// NeedsRehash reports whether the encoded hash is not a scrypt hash with the parameters of the hasher.
+func (s *ScryptHash) NeedsRehash(encodedHash string) bool {
+	params, _, _, err := decodeScryptHash(encodedHash)
+	return err != nil || params != s.params
+}
+
+// decodeScryptHash parses a scrypt hash in the PHC string format.
+func decodeScryptHash(encodedHash string) (params ScryptParams, salt, hash []byte, err error) {
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 5 || parts[1] != "scrypt" {
+		return params, nil, nil, errors.New("invalid scrypt hash format")
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &params.LogN, &params.R, &params.P); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid scrypt parameters: %w", err)
+	}
+	if params.LogN < 1 || params.LogN > 31 {
+		return params, nil, nil, fmt.Errorf("invalid scrypt parameter ln=%d", params.LogN)
+	}
+
+	salt, err = base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid scrypt salt: %w", err)
+	}
+	hash, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid scrypt hash: %w", err)
+	}
+	params.KeyLen = len(hash)
+	params.SaltLen = len(salt)
+
+	return params, salt, hash, nil
+}
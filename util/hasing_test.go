@@ -1,39 +1,74 @@
 package util
 
 import (
+	"encoding/base64"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
+// testArgon2idParams keeps the tests fast, the default parameters use 19 MiB of memory.
+var testArgon2idParams = Argon2idParams{Time: 1, Memory: 64, Threads: 1}
+
 func TestGenerateHash(t *testing.T) {
 	password := RandomString(8)
-	hashedPassword, err := HashPassword(password)
+	hashedPassword, err := HashPassword(password, testArgon2idParams)
 	require.NoError(t, err)
 	require.NotEmpty(t, hashedPassword)
+	require.True(t, strings.HasPrefix(hashedPassword, "$argon2id$v=19$m=64,t=1,p=1$"))
 
 	// Test with matching password
-	err = ComparePassword(hashedPassword.Hash, hashedPassword.Salt, password)
+	err = ComparePassword(hashedPassword, "", password)
 	require.NoError(t, err)
 
 	// Test with wrong password
 	wrongPassword := RandomString(8)
-	err = ComparePassword(hashedPassword.Hash, hashedPassword.Salt, wrongPassword)
+	err = ComparePassword(hashedPassword, "", wrongPassword)
 	require.Error(t, err)
 	require.Equal(t, "invalid password", err.Error())
 
 	// Test with empty password
 	emptyPassword := ""
-	err = ComparePassword(hashedPassword.Hash, hashedPassword.Salt, emptyPassword)
+	err = ComparePassword(hashedPassword, "", emptyPassword)
 	require.Error(t, err)
 
-	// Test with nil hash and salt
-	err = ComparePassword(nil, nil, password)
+	// Test with empty hash and salt
+	err = ComparePassword("", "", password)
 	require.Error(t, err)
 
 	// Test with same password but different hash
-	hashedPassword2, err := HashPassword(password)
+	hashedPassword2, err := HashPassword(password, testArgon2idParams)
 	require.NoError(t, err)
 	require.NotEmpty(t, hashedPassword)
-	require.NotEqual(t, hashedPassword.Hash, hashedPassword2.Hash)
+	require.NotEqual(t, hashedPassword, hashedPassword2)
+}
+
+func TestCompareLegacyPassword(t *testing.T) {
+	password := RandomString(8)
+
+	argon2idHash := legacyArgon2idParams.hasher()
+	hashSalt, err := argon2idHash.generateHash([]byte(password), nil)
+	require.NoError(t, err)
+	hash := base64.StdEncoding.EncodeToString(hashSalt.Hash)
+	salt := base64.StdEncoding.EncodeToString(hashSalt.Salt)
+
+	require.NoError(t, ComparePassword(hash, salt, password))
+	require.ErrorIs(t, ComparePassword(hash, salt, RandomString(8)), ErrInvalidPassword)
+	require.True(t, NeedsRehash(hash, testArgon2idParams))
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hashedPassword, err := HashPassword(RandomString(8), testArgon2idParams)
+	require.NoError(t, err)
+
+	require.False(t, NeedsRehash(hashedPassword, testArgon2idParams))
+
+	stronger := testArgon2idParams
+	stronger.Time = 2
+	require.True(t, NeedsRehash(hashedPassword, stronger))
+
+	stronger = testArgon2idParams
+	stronger.Memory = 128
+	require.True(t, NeedsRehash(hashedPassword, stronger))
 }
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"errors"
+	"strings"
+)
+
+// PasswordHasher hashes passwords and verifies them against stored hashes of its algorithm.
+// New passwords are always hashed with Argon2id, the other algorithms are supported to verify
+// hashes imported from legacy systems until they are upgraded on the next successful login.
+type PasswordHasher interface {
+	// Hash returns the self-describing encoded hash of the password.
+	Hash(password string) (string, error)
+	// Compare returns nil if the password matches the encoded hash, ErrInvalidPassword otherwise.
+	Compare(encodedHash, password string) error
+	// NeedsRehash reports whether the encoded hash was not produced by this hasher with its current parameters.
+	NeedsRehash(encodedHash string) bool
+}
+
+// ErrUnknownHashAlgorithm is returned when the algorithm of a stored password hash can't be detected.
+var ErrUnknownHashAlgorithm = errors.New("unknown password hash algorithm")
+
+// DetectPasswordHasher returns a hasher that can verify the given encoded hash, based on its prefix.
+func DetectPasswordHasher(encodedHash string) (PasswordHasher, error) {
+	switch {
+	case strings.HasPrefix(encodedHash, argon2idPrefix):
+		return NewArgon2idHasher(Argon2idParams{}), nil
+	case isBcryptHash(encodedHash):
+		return NewBcryptHasher(0), nil
+	case strings.HasPrefix(encodedHash, scryptPrefix):
+		return NewScryptHasher(ScryptParams{}), nil
+	default:
+		return nil, ErrUnknownHashAlgorithm
+	}
+}
+
+// HashPassword hashes the password with Argon2id and the given parameters, unset parameters fall back to the defaults.
+func HashPassword(password string, params Argon2idParams) (string, error) {
+	return NewArgon2idHasher(params).Hash(password)
+}
+
+// ComparePassword compares a password with the stored password hash and salt of a user.
+// The algorithm is detected from the hash, which carries its own salt and parameters. The salt is only used
+// for legacy Argon2id hashes that were stored base64 encoded next to a separate salt.
+// If the hash of the password matches, it returns nil. Otherwise, it returns ErrInvalidPassword.
+func ComparePassword(passwordHash, passwordSalt, password string) error {
+	if password == "" {
+		return errors.New("password cannot be empty")
+	}
+
+	if passwordSalt != "" && !strings.HasPrefix(passwordHash, "$") {
+		return compareLegacyArgon2id(passwordHash, passwordSalt, password)
+	}
+
+	hasher, err := DetectPasswordHasher(passwordHash)
+	if err != nil {
+		return err
+	}
+	return hasher.Compare(passwordHash, password)
+}
+
+// NeedsRehash reports whether a stored password hash is not an Argon2id hash with the given parameters,
+// because it was imported from another algorithm, is a legacy hash or the parameters have been changed since.
+// The password should then be hashed again after the next successful login.
+func NeedsRehash(passwordHash string, params Argon2idParams) bool {
+	return NewArgon2idHasher(params).NeedsRehash(passwordHash)
+}
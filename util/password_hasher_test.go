@@ -0,0 +1,78 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	testCases := []struct {
+		name   string
+		hasher PasswordHasher
+		prefix string
+	}{
+		{
+			name:   "Argon2id",
+			hasher: NewArgon2idHasher(testArgon2idParams),
+			prefix: "$argon2id$",
+		},
+		{
+			name:   "Bcrypt",
+			hasher: NewBcryptHasher(bcrypt.MinCost),
+			prefix: "$2a$",
+		},
+		{
+			name:   "Scrypt",
+			hasher: NewScryptHasher(ScryptParams{LogN: 4}),
+			prefix: "$scrypt$ln=4,r=8,p=1$",
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+
+		t.Run(tc.name, func(t *testing.T) {
+			password := RandomString(12)
+
+			hash, err := tc.hasher.Hash(password)
+			require.NoError(t, err)
+			require.Contains(t, hash, tc.prefix)
+			require.False(t, tc.hasher.NeedsRehash(hash))
+
+			require.NoError(t, tc.hasher.Compare(hash, password))
+			require.ErrorIs(t, tc.hasher.Compare(hash, RandomString(12)), ErrInvalidPassword)
+
+			// The algorithm is detected from the stored hash
+			detected, err := DetectPasswordHasher(hash)
+			require.NoError(t, err)
+			require.IsType(t, tc.hasher, detected)
+			require.NoError(t, ComparePassword(hash, "", password))
+		})
+	}
+}
+
+func TestImportedHashesNeedRehash(t *testing.T) {
+	password := RandomString(12)
+
+	bcryptHash, err := NewBcryptHasher(bcrypt.MinCost).Hash(password)
+	require.NoError(t, err)
+	require.True(t, NeedsRehash(bcryptHash, testArgon2idParams))
+
+	scryptHash, err := NewScryptHasher(ScryptParams{LogN: 4}).Hash(password)
+	require.NoError(t, err)
+	require.True(t, NeedsRehash(scryptHash, testArgon2idParams))
+
+	// Hashes of other systems can also be verified without the hasher that created them
+	require.NoError(t, ComparePassword(bcryptHash, "", password))
+	require.NoError(t, ComparePassword("$2y$04$"+bcryptHash[7:], "", password))
+}
+
+func TestDetectPasswordHasherUnknownAlgorithm(t *testing.T) {
+	_, err := DetectPasswordHasher("$md5$abc")
+	require.ErrorIs(t, err, ErrUnknownHashAlgorithm)
+
+	err = ComparePassword("plaintext", "", RandomString(12))
+	require.ErrorIs(t, err, ErrUnknownHashAlgorithm)
+}
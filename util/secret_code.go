@@ -0,0 +1,26 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// secretCodeLength is the number of random bytes of a secret code.
+const secretCodeLength = 32
+
+// GenerateSecretCode generates a random, URL safe secret code for single-use links such as email verification.
+func GenerateSecretCode() (string, error) {
+	secret, err := randomSecret(secretCodeLength)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// HashSecretCode returns the SHA-256 hash of a secret code. Only the hash is stored in the database,
+// a fast hash is sufficient because the code itself is long and random.
+func HashSecretCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,21 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSecretCode(t *testing.T) {
+	code1, err := GenerateSecretCode()
+	require.NoError(t, err)
+	require.Len(t, code1, 43)
+
+	code2, err := GenerateSecretCode()
+	require.NoError(t, err)
+	require.NotEqual(t, code1, code2)
+
+	require.Equal(t, HashSecretCode(code1), HashSecretCode(code1))
+	require.NotEqual(t, HashSecretCode(code1), HashSecretCode(code2))
+	require.NotContains(t, HashSecretCode(code1), code1)
+}
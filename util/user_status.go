@@ -0,0 +1,7 @@
+package util
+
+// Statuses of a user account. Users are inactive until they verified their email address.
+const (
+	StatusActive   = "active"
+	StatusInactive = "inactive"
+)
@@ -0,0 +1,155 @@
+package worker
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/tlscert"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// certificateExpiryWarning is how long before its expiry a certificate is logged as a warning,
+// certificateExpiryReminder how often the warning is repeated while the certificate isn't replaced.
+const (
+	certificateExpiryWarning  = 14 * 24 * time.Hour
+	certificateExpiryReminder = 24 * time.Hour
+)
+
+// CertificateReloader periodically checks the certificate and key files of the server, and reads them again on
+// SIGHUP. A new pair replaces the certificate of the running servers if it is valid, so that certificates can be
+// rotated without a restart and without dropping established connections.
+type CertificateReloader struct {
+	keypair     *tlscert.Keypair
+	certPath    string
+	keyPath     string
+	interval    time.Duration
+	certModTime time.Time
+	keyModTime  time.Time
+	// lastWarning is when the upcoming expiry of the certificate was last logged.
+	lastWarning time.Time
+}
+
+// NewCertificateReloader creates a new CertificateReloader for the keypair of the server.
+func NewCertificateReloader(config util.Config, keypair *tlscert.Keypair) *CertificateReloader {
+	certPath, keyPath := keypair.Paths()
+	return &CertificateReloader{
+		keypair:  keypair,
+		certPath: certPath,
+		keyPath:  keyPath,
+		interval: config.TLSCertReloadInterval,
+	}
+}
+
+// Run checks the certificate files once per interval and on every SIGHUP until the context is cancelled.
+func (r *CertificateReloader) Run(ctx context.Context) {
+	if r.certPath == "" {
+		return
+	}
+
+	// The certificate was loaded from the files when the server was created
+	r.certModTime, r.keyModTime, _ = r.modTimes()
+	r.logExpiry(r.keypair.Leaf(), "certificate reloader: started")
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	// Without an interval the files are only read again on SIGHUP
+	var tick <-chan time.Time
+	if r.interval > 0 {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("certificate reloader: stopped")
+			return
+		case <-hangup:
+			log.Info().Msg("certificate reloader: received SIGHUP")
+			r.reload()
+		case <-tick:
+			if !r.Reload() {
+				r.warnExpiry()
+			}
+		}
+	}
+}
+
+// Reload replaces the certificate if one of the files changed since the last reload. It reports whether
+// the certificate was replaced.
+func (r *CertificateReloader) Reload() bool {
+	certModTime, keyModTime, err := r.modTimes()
+	if err != nil {
+		log.Error().Err(err).Str("path", r.certPath).Msg("certificate reloader: failed to check certificate files")
+		return false
+	}
+	if certModTime.Equal(r.certModTime) && keyModTime.Equal(r.keyModTime) {
+		return false
+	}
+	return r.reload()
+}
+
+// reload reads the certificate files and replaces the certificate if the new pair is valid. An invalid pair is
+// logged and the current certificate is kept, e.g. while only one of the files has been replaced yet.
+func (r *CertificateReloader) reload() bool {
+	certModTime, keyModTime, _ := r.modTimes()
+
+	leaf, err := r.keypair.Reload()
+	if err != nil {
+		log.Error().Err(err).Str("path", r.certPath).Msg("certificate reloader: failed to load certificate, keeping the current certificate")
+		return false
+	}
+
+	r.certModTime, r.keyModTime = certModTime, keyModTime
+	r.lastWarning = time.Time{}
+	r.logExpiry(leaf, "certificate reloader: reloaded certificate")
+	return true
+}
+
+// warnExpiry logs a warning once per reminder interval while the certificate expires soon.
+func (r *CertificateReloader) warnExpiry() {
+	if time.Until(r.keypair.Leaf().NotAfter) > certificateExpiryWarning || time.Since(r.lastWarning) < certificateExpiryReminder {
+		return
+	}
+	r.logExpiry(r.keypair.Leaf(), "certificate reloader: certificate expires soon")
+}
+
+// logExpiry logs the validity of the certificate, as a warning if it expires soon.
+func (r *CertificateReloader) logExpiry(leaf *x509.Certificate, msg string) {
+	level := zerolog.InfoLevel
+	expiresIn := time.Until(leaf.NotAfter)
+	if expiresIn <= certificateExpiryWarning {
+		level = zerolog.WarnLevel
+		r.lastWarning = time.Now()
+	}
+
+	log.WithLevel(level).
+		Str("path", r.certPath).
+		Str("subject", leaf.Subject.String()).
+		Time("not_before", leaf.NotBefore).
+		Time("not_after", leaf.NotAfter).
+		Dur("expires_in", expiresIn).
+		Msg(msg)
+}
+
+// modTimes returns the modification times of the certificate and key files.
+func (r *CertificateReloader) modTimes() (certModTime, keyModTime time.Time, err error) {
+	certInfo, err := os.Stat(r.certPath)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	keyInfo, err := os.Stat(r.keyPath)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
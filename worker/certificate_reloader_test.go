@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/tlscert"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes a self-signed certificate with the common name and its key, both with the modification time.
+func writeCertificate(t *testing.T, certPath, keyPath, name string, notAfter, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.Chtimes(certPath, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyPath, modTime, modTime))
+}
+
+func TestCertificateReloader(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	modTime := time.Now().Add(-time.Hour)
+	writeCertificate(t, certPath, keyPath, "first", time.Now().Add(time.Hour), modTime)
+
+	keypair, err := tlscert.LoadKeypair(certPath, keyPath, nil)
+	require.NoError(t, err)
+
+	reloader := NewCertificateReloader(util.Config{TLSCertReloadInterval: time.Minute}, keypair)
+	reloader.certModTime, reloader.keyModTime = modTime, modTime
+
+	// Nothing changed
+	require.False(t, reloader.Reload())
+	require.Equal(t, "first", keypair.Leaf().Subject.CommonName)
+
+	// A changed pair replaces the certificate
+	modTime = modTime.Add(time.Minute)
+	writeCertificate(t, certPath, keyPath, "second", time.Now().Add(time.Hour), modTime)
+	require.True(t, reloader.Reload())
+	require.Equal(t, "second", keypair.Leaf().Subject.CommonName)
+
+	// An expired certificate keeps the current one
+	modTime = modTime.Add(time.Minute)
+	writeCertificate(t, certPath, keyPath, "expired", time.Now().Add(-time.Minute), modTime)
+	require.False(t, reloader.Reload())
+	require.Equal(t, "second", keypair.Leaf().Subject.CommonName)
+
+	// Only the certificate was replaced yet, so it doesn't match the key
+	key, err := os.ReadFile(keyPath)
+	require.NoError(t, err)
+	modTime = modTime.Add(time.Minute)
+	writeCertificate(t, certPath, keyPath, "third", time.Now().Add(time.Hour), modTime)
+	require.NoError(t, os.WriteFile(keyPath, key, 0o600))
+	require.False(t, reloader.Reload())
+	require.Equal(t, "second", keypair.Leaf().Subject.CommonName)
+
+	require.NoError(t, os.Remove(certPath))
+	require.False(t, reloader.Reload())
+	require.Equal(t, "second", keypair.Leaf().Subject.CommonName)
+}
@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/rs/zerolog/log"
+)
+
+// KeyringReloader periodically checks the keyring file of local tokens and replaces the keys of the running
+// keyring when the file changed, so that keys can be rotated without a restart.
+type KeyringReloader struct {
+	keyring  *token.Keyring
+	path     string
+	interval time.Duration
+	modTime  time.Time
+}
+
+// NewKeyringReloader creates a new KeyringReloader from the keyring settings of the configuration.
+func NewKeyringReloader(config util.Config, keyring *token.Keyring) *KeyringReloader {
+	return &KeyringReloader{
+		keyring:  keyring,
+		path:     config.TokenKeyringFile,
+		interval: config.TokenKeyringReloadInterval,
+	}
+}
+
+// Run checks the keyring file once per interval until the context is cancelled.
+func (r *KeyringReloader) Run(ctx context.Context) {
+	if r.path == "" {
+		return
+	}
+	if r.interval <= 0 {
+		log.Warn().Msg("keyring reloader: disabled, interval must be positive")
+		return
+	}
+
+	// The keyring was loaded from the file when the server was created
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+
+	log.Info().
+		Str("path", r.path).
+		Dur("interval", r.interval).
+		Msg("keyring reloader: started")
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("keyring reloader: stopped")
+			return
+		case <-ticker.C:
+			r.Reload()
+		}
+	}
+}
+
+// Reload replaces the keys of the keyring if the file changed since the last reload. A file that can't be read
+// or contains an invalid keyring is logged and the current keys are kept. It reports whether the keys were replaced.
+func (r *KeyringReloader) Reload() bool {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		log.Error().Err(err).Str("path", r.path).Msg("keyring reloader: failed to check keyring file")
+		return false
+	}
+	if info.ModTime().Equal(r.modTime) {
+		return false
+	}
+
+	keyring, err := token.LoadKeyringFile(r.path)
+	if err != nil {
+		log.Error().Err(err).Str("path", r.path).Msg("keyring reloader: failed to load keyring file, keeping the current keys")
+		return false
+	}
+
+	r.keyring.Replace(keyring)
+	r.modTime = info.ModTime()
+	log.Info().
+		Str("path", r.path).
+		Str("active_key", keyring.Active().ID).
+		Msg("keyring reloader: reloaded keyring")
+	return true
+}
@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Streamfair/streamfair_user_svc/token"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKeyringFile(t *testing.T, path, activeID, key string, modTime time.Time) {
+	content := `{"active": "` + activeID + `", "keys": [{"id": "` + activeID + `", "key": "` + key + `"}]}`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestKeyringReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	modTime := time.Now().Add(-time.Hour)
+	writeKeyringFile(t, path, "first", util.RandomString(32), modTime)
+
+	keyring, err := token.LoadKeyringFile(path)
+	require.NoError(t, err)
+
+	reloader := NewKeyringReloader(util.Config{
+		TokenKeyringFile:           path,
+		TokenKeyringReloadInterval: time.Minute,
+	}, keyring)
+	reloader.modTime = modTime
+
+	// Nothing changed
+	require.False(t, reloader.Reload())
+	require.Equal(t, "first", keyring.Active().ID)
+
+	// A changed file replaces the keys
+	modTime = modTime.Add(time.Minute)
+	writeKeyringFile(t, path, "second", util.RandomString(32), modTime)
+	require.True(t, reloader.Reload())
+	require.Equal(t, "second", keyring.Active().ID)
+
+	// An invalid file keeps the current keys
+	modTime = modTime.Add(time.Minute)
+	writeKeyringFile(t, path, "third", "short", modTime)
+	require.False(t, reloader.Reload())
+	require.Equal(t, "second", keyring.Active().ID)
+
+	require.NoError(t, os.Remove(path))
+	require.False(t, reloader.Reload())
+	require.Equal(t, "second", keyring.Active().ID)
+}
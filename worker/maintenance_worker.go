@@ -0,0 +1,156 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/rs/zerolog/log"
+)
+
+// MaintenanceWorker periodically deletes the rows that are no longer needed from the database: expired and
+// blocked sessions, failed logins that are no longer counted, token revocations of expired tokens, expired
+// OAuth authorization codes and external login states that were never completed.
+type MaintenanceWorker struct {
+	store              db.Store
+	interval           time.Duration
+	batchSize          int32
+	retention          time.Duration
+	loginAttemptWindow time.Duration
+	maxTokenLifetime   time.Duration
+}
+
+// NewMaintenanceWorker creates a new MaintenanceWorker from the cleanup settings of the configuration.
+func NewMaintenanceWorker(config util.Config, store db.Store) *MaintenanceWorker {
+	return &MaintenanceWorker{
+		store:              store,
+		interval:           config.SessionCleanupInterval,
+		batchSize:          config.SessionCleanupBatchSize,
+		retention:          config.SessionRetention,
+		loginAttemptWindow: config.LoginAttemptWindow,
+		maxTokenLifetime:   max(config.AccessTokenDuration, config.RefreshTokenDuration),
+	}
+}
+
+// maintenanceJob is a single cleanup step of a maintenance pass.
+type maintenanceJob struct {
+	name string
+	run  func(ctx context.Context) (int64, error)
+}
+
+func (w *MaintenanceWorker) jobs() []maintenanceJob {
+	return []maintenanceJob{
+		{name: "stale sessions", run: w.PurgeStaleSessions},
+		{name: "stale login attempts", run: w.PurgeStaleLoginAttempts},
+		{name: "stale token revocations", run: w.PurgeStaleTokenRevocations},
+		{name: "expired authorization codes", run: w.PurgeExpiredAuthorizationCodes},
+		{name: "expired external login states", run: w.PurgeExpiredExternalLoginStates},
+	}
+}
+
+// Run runs a maintenance pass right away and then once per interval until the context is cancelled.
+func (w *MaintenanceWorker) Run(ctx context.Context) {
+	if w.interval <= 0 || w.batchSize <= 0 {
+		log.Warn().Msg("maintenance worker: disabled, interval and batch size must be positive")
+		return
+	}
+
+	log.Info().
+		Dur("interval", w.interval).
+		Int32("batch_size", w.batchSize).
+		Dur("retention", w.retention).
+		Msg("maintenance worker: started")
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.runJobs(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Info().Msg("maintenance worker: stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runJobs runs every cleanup job once. A failing job is logged and doesn't keep the other jobs from running.
+func (w *MaintenanceWorker) runJobs(ctx context.Context) {
+	for _, job := range w.jobs() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		start := time.Now()
+		deleted, err := job.run(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Str("job", job.name).Int64("deleted", deleted).Msg("maintenance worker: failed to purge " + job.name)
+			continue
+		}
+		if deleted > 0 {
+			log.Info().
+				Str("job", job.name).
+				Int64("deleted", deleted).
+				Dur("duration", time.Since(start)).
+				Msg("maintenance worker: purged " + job.name)
+		}
+	}
+}
+
+// PurgeStaleSessions deletes the sessions that expired or were blocked longer than the retention ago.
+// The rows are deleted in batches to keep the transactions short, the total number of deleted rows is returned.
+func (w *MaintenanceWorker) PurgeStaleSessions(ctx context.Context) (int64, error) {
+	arg := db.DeleteStaleSessionsParams{
+		Cutoff:    time.Now().Add(-w.retention),
+		BatchSize: w.batchSize,
+	}
+
+	var total int64
+	for {
+		deleted, err := w.store.DeleteStaleSessions(ctx, arg)
+		if err != nil {
+			return total, err
+		}
+		total += deleted
+
+		if deleted < int64(w.batchSize) || ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+	}
+}
+
+// PurgeStaleLoginAttempts deletes the failed logins that are older than the attempt window and no longer locked.
+func (w *MaintenanceWorker) PurgeStaleLoginAttempts(ctx context.Context) (int64, error) {
+	return w.store.DeleteStaleLoginAttempts(ctx, time.Now().Add(-w.loginAttemptWindow))
+}
+
+// PurgeStaleTokenRevocations deletes the revocations of expired tokens and the watermarks that are older than
+// the longest living tokens, as they can't match any valid token anymore.
+func (w *MaintenanceWorker) PurgeStaleTokenRevocations(ctx context.Context) (int64, error) {
+	now := time.Now()
+
+	revokedTokens, err := w.store.DeleteExpiredRevokedTokens(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	watermarks, err := w.store.DeleteStaleTokenWatermarks(ctx, now.Add(-w.maxTokenLifetime))
+	if err != nil {
+		return revokedTokens, err
+	}
+
+	return revokedTokens + watermarks, nil
+}
+
+// PurgeExpiredAuthorizationCodes deletes the OAuth authorization codes that expired, used or not.
+func (w *MaintenanceWorker) PurgeExpiredAuthorizationCodes(ctx context.Context) (int64, error) {
+	return w.store.DeleteExpiredOAuthAuthorizationCodes(ctx, time.Now())
+}
+
+// PurgeExpiredExternalLoginStates deletes the states of logins at identity providers that were never completed.
+func (w *MaintenanceWorker) PurgeExpiredExternalLoginStates(ctx context.Context) (int64, error) {
+	return w.store.DeleteExpiredExternalLoginStates(ctx, time.Now())
+}
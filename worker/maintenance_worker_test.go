@@ -0,0 +1,234 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mock_db "github.com/Streamfair/streamfair_user_svc/db/mock"
+	db "github.com/Streamfair/streamfair_user_svc/db/sqlc"
+	"github.com/Streamfair/streamfair_user_svc/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func newTestMaintenanceWorker(store db.Store) *MaintenanceWorker {
+	return NewMaintenanceWorker(util.Config{
+		SessionCleanupInterval:  time.Minute,
+		SessionCleanupBatchSize: 10,
+		SessionRetention:        time.Hour,
+		LoginAttemptWindow:      time.Hour,
+		AccessTokenDuration:     15 * time.Minute,
+		RefreshTokenDuration:    24 * time.Hour,
+	}, store)
+}
+
+func TestPurgeStaleSessions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+
+	// Keep deleting until a batch is not full anymore
+	gomock.InOrder(
+		store.EXPECT().
+			DeleteStaleSessions(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, arg db.DeleteStaleSessionsParams) (int64, error) {
+				require.Equal(t, int32(10), arg.BatchSize)
+				require.WithinDuration(t, time.Now().Add(-time.Hour), arg.Cutoff, time.Second)
+				return 10, nil
+			}),
+		store.EXPECT().
+			DeleteStaleSessions(gomock.Any(), gomock.Any()).
+			Return(int64(10), nil),
+		store.EXPECT().
+			DeleteStaleSessions(gomock.Any(), gomock.Any()).
+			Return(int64(3), nil),
+	)
+
+	deleted, err := newTestMaintenanceWorker(store).PurgeStaleSessions(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(23), deleted)
+}
+
+func TestPurgeStaleSessionsError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+
+	dbErr := errors.New("connection lost")
+	gomock.InOrder(
+		store.EXPECT().
+			DeleteStaleSessions(gomock.Any(), gomock.Any()).
+			Return(int64(10), nil),
+		store.EXPECT().
+			DeleteStaleSessions(gomock.Any(), gomock.Any()).
+			Return(int64(0), dbErr),
+	)
+
+	deleted, err := newTestMaintenanceWorker(store).PurgeStaleSessions(context.Background())
+	require.ErrorIs(t, err, dbErr)
+	require.Equal(t, int64(10), deleted)
+}
+
+func TestPurgeStaleLoginAttempts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	store.EXPECT().
+		DeleteStaleLoginAttempts(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, cutoff time.Time) (int64, error) {
+			require.WithinDuration(t, time.Now().Add(-time.Hour), cutoff, time.Second)
+			return 4, nil
+		})
+
+	deleted, err := newTestMaintenanceWorker(store).PurgeStaleLoginAttempts(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(4), deleted)
+}
+
+func TestPurgeStaleTokenRevocations(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	store.EXPECT().
+		DeleteExpiredRevokedTokens(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, cutoff time.Time) (int64, error) {
+			require.WithinDuration(t, time.Now(), cutoff, time.Second)
+			return 5, nil
+		})
+	// Watermarks are kept as long as the longest living tokens
+	store.EXPECT().
+		DeleteStaleTokenWatermarks(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, cutoff time.Time) (int64, error) {
+			require.WithinDuration(t, time.Now().Add(-24*time.Hour), cutoff, time.Second)
+			return 2, nil
+		})
+
+	deleted, err := newTestMaintenanceWorker(store).PurgeStaleTokenRevocations(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(7), deleted)
+}
+
+func TestPurgeExpiredAuthorizationCodes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	store.EXPECT().
+		DeleteExpiredOAuthAuthorizationCodes(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, cutoff time.Time) (int64, error) {
+			require.WithinDuration(t, time.Now(), cutoff, time.Second)
+			return 3, nil
+		})
+
+	deleted, err := newTestMaintenanceWorker(store).PurgeExpiredAuthorizationCodes(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(3), deleted)
+}
+
+func TestPurgeExpiredExternalLoginStates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	store.EXPECT().
+		DeleteExpiredExternalLoginStates(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, cutoff time.Time) (int64, error) {
+			require.WithinDuration(t, time.Now(), cutoff, time.Second)
+			return 2, nil
+		})
+
+	deleted, err := newTestMaintenanceWorker(store).PurgeExpiredExternalLoginStates(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted)
+}
+
+func TestRunStopsOnCancel(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+	store.EXPECT().
+		DeleteStaleSessions(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(int64(0), nil)
+	store.EXPECT().
+		DeleteStaleLoginAttempts(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(int64(0), nil)
+	store.EXPECT().
+		DeleteExpiredRevokedTokens(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(int64(0), nil)
+	store.EXPECT().
+		DeleteStaleTokenWatermarks(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(int64(0), nil)
+	store.EXPECT().
+		DeleteExpiredOAuthAuthorizationCodes(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(int64(0), nil)
+	store.EXPECT().
+		DeleteExpiredExternalLoginStates(gomock.Any(), gomock.Any()).
+		AnyTimes().
+		Return(int64(0), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		newTestMaintenanceWorker(store).Run(ctx)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("maintenance worker did not stop after the context was cancelled")
+	}
+}
+
+func TestRunJobsContinuesAfterError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock_db.NewMockStore(ctrl)
+
+	// Every job runs once even though the ones before it failed
+	store.EXPECT().
+		DeleteStaleSessions(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(int64(0), errors.New("connection reset"))
+	store.EXPECT().
+		DeleteStaleLoginAttempts(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(int64(0), errors.New("connection reset"))
+	store.EXPECT().
+		DeleteExpiredRevokedTokens(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(int64(0), errors.New("connection reset"))
+	store.EXPECT().
+		DeleteStaleTokenWatermarks(gomock.Any(), gomock.Any()).
+		Times(0)
+	store.EXPECT().
+		DeleteExpiredOAuthAuthorizationCodes(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(int64(0), errors.New("connection reset"))
+	store.EXPECT().
+		DeleteExpiredExternalLoginStates(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(int64(3), nil)
+
+	newTestMaintenanceWorker(store).runJobs(context.Background())
+}